@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+
+	"github.com/HasanNugroho/starter-golang/internal"
+	"github.com/labstack/echo/v4"
+)
+
+// Applies the collections, indexes and validators declared by the modules.
+// With -check it only reports what is missing and the drift, and exits with 1
+// when the database does not match the declarations.
+func main() {
+	check := flag.Bool("check", false, "report missing collections, indexes and drift without changing anything")
+	flag.Parse()
+
+	apps := internal.Bootstrap(echo.New())
+	if apps.DB == nil {
+		apps.Log.Fatal().Msg("❌ Database is not enabled, set ACTIVATE_RDBMS=true")
+	}
+
+	ctx := context.Background()
+	if *check {
+		report, err := apps.Schema.Check(ctx, apps.DB, apps.Log)
+		if err != nil {
+			apps.Log.Fatal().Msg(err.Error())
+		}
+		for _, name := range report.Collections {
+			apps.Log.Info().Msgf("Missing collection %s", name)
+		}
+		for _, name := range report.Validators {
+			apps.Log.Info().Msgf("Outdated validator on %s", name)
+		}
+		for _, name := range report.Indexes {
+			apps.Log.Info().Msgf("Missing index %s", name)
+		}
+		if report.Pending() || len(report.Drift) > 0 {
+			os.Exit(1)
+		}
+		apps.Log.Info().Msg("✅ Collections match their declarations")
+		return
+	}
+
+	report, err := apps.Schema.Apply(ctx, apps.DB, apps.Log)
+	if err != nil {
+		apps.Log.Fatal().Msg(err.Error())
+	}
+	apps.Log.Info().Msgf("✅ Schema applied: %d collections, %d validators, %d indexes, %d drift",
+		len(report.Collections), len(report.Validators), len(report.Indexes), len(report.Drift))
+}
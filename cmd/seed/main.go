@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"os"
+	"strings"
+
+	"github.com/HasanNugroho/starter-golang/internal"
+	"github.com/labstack/echo/v4"
+)
+
+// Seeds the system roles and, with -admin-email, creates that user holding the
+// super-admin role. An existing account is never promoted. The password is taken from
+// BOOTSTRAP_ADMIN_PASSWORD or read from stdin, so it never shows up in the
+// process list.
+func main() {
+	email := flag.String("admin-email", "", "email of the super-admin to bootstrap, defaults to BOOTSTRAP_ADMIN_EMAIL")
+	name := flag.String("admin-name", "", "name given to the super-admin when it is created")
+	flag.Parse()
+
+	apps := internal.Bootstrap(echo.New())
+	if apps.DB == nil {
+		apps.Log.Fatal().Msg("❌ Database is not enabled, set ACTIVATE_RDBMS=true")
+	}
+
+	security := &apps.Config.Security
+	if *email != "" {
+		security.BootstrapAdminEmail = *email
+	}
+	if *name != "" {
+		security.BootstrapAdminName = *name
+	}
+	if security.BootstrapAdminEmail != "" && security.BootstrapAdminPassword == "" {
+		os.Stderr.WriteString("Password: ")
+		password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && password == "" {
+			apps.Log.Fatal().Msg("❌ Failed to read the password: " + err.Error())
+		}
+		security.BootstrapAdminPassword = strings.TrimRight(password, "\r\n")
+	}
+
+	if err := apps.Seeds.Run(context.Background(), apps.Log); err != nil {
+		apps.Log.Fatal().Msg(err.Error())
+	}
+	apps.Log.Info().Msg("✅ Seeds applied")
+}
@@ -1,11 +1,15 @@
 package config
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"log"
 
 	"strings"
 
 	"github.com/HasanNugroho/starter-golang/internal/shared/modules"
+	"github.com/HasanNugroho/starter-golang/internal/shared/storage"
 	"github.com/spf13/viper"
 	"github.com/ulule/limiter/v3"
 )
@@ -14,16 +18,18 @@ var GlobalConfig Config
 
 // Config menyimpan semua konfigurasi aplikasi
 type Config struct {
-	AppName           string                      `mapstructure:"APP_NAME"`
-	Version           string                      `mapstructure:"VERSION"`
-	AppEnv            string                      `mapstructure:"APP_ENV"`
-	Server            ServerConfig                `mapstructure:",squash"`
-	DB                DatabaseConfig              `mapstructure:",squash"`
-	Redis             RedisConfig                 `mapstructure:",squash"`
-	Security          SecurityConfig              `mapstructure:",squash"`
-	Logger            LoggerConfig                `mapstructure:",squash"`
-	Search            modules.ElasticSearchConfig `mapstructure:",squash"`
-	ModulePermissions []string
+	AppName  string                      `mapstructure:"APP_NAME"`
+	Version  string                      `mapstructure:"VERSION"`
+	AppEnv   string                      `mapstructure:"APP_ENV"`
+	Server   ServerConfig                `mapstructure:",squash"`
+	DB       DatabaseConfig              `mapstructure:",squash"`
+	Redis    RedisConfig                 `mapstructure:",squash"`
+	Security SecurityConfig              `mapstructure:",squash"`
+	Logger   LoggerConfig                `mapstructure:",squash"`
+	Search   modules.ElasticSearchConfig `mapstructure:",squash"`
+	Mail     modules.MailConfig          `mapstructure:",squash"`
+	Storage  storage.StorageConfig       `mapstructure:",squash"`
+	Avatar   AvatarConfig                `mapstructure:",squash"`
 }
 
 // ServerConfig menyimpan konfigurasi server
@@ -36,10 +42,16 @@ type ServerConfig struct {
 
 // RDBMSConfig menyimpan konfigurasi database
 type DatabaseConfig struct {
-	Enabled  bool   `mapstructure:"ACTIVATE_RDBMS"`
-	URI      string `mapstructure:"MONGO_URI"`
-	Database string `mapstructure:"MONGO_DB"`
-	Timeout  int    `mapstructure:"MONGO_TIMEOUT"`
+	Enabled             bool   `mapstructure:"ACTIVATE_RDBMS"`
+	URI                 string `mapstructure:"MONGO_URI"`
+	Database            string `mapstructure:"MONGO_DB"`
+	Timeout             int    `mapstructure:"MONGO_TIMEOUT"`
+	SoftDeleteRetention int    `mapstructure:"SOFT_DELETE_RETENTION_DAYS" envDefault:"30"`
+	PurgeInterval       int    `mapstructure:"PURGE_INTERVAL_MINUTES" envDefault:"60"`
+	DataExportRetention int    `mapstructure:"DATA_EXPORT_RETENTION_DAYS" envDefault:"7"`
+	ImportJobRetention  int    `mapstructure:"IMPORT_JOB_RETENTION_DAYS" envDefault:"30"`
+	ImportMaxSize       int    `mapstructure:"IMPORT_MAX_SIZE" envDefault:"10240"`
+	SkipSchemaApply     bool   `mapstructure:"MONGO_SKIP_SCHEMA_APPLY"`
 }
 
 // DBSsl menyimpan konfigurasi SSL untuk database
@@ -65,23 +77,46 @@ type RedisConfig struct {
 
 // SecurityConfig menyimpan konfigurasi keamanan aplikasi
 type SecurityConfig struct {
-	CheckOrigin            bool   `mapstructure:"ACTIVATE_ORIGIN_VALIDATION"`
-	RateLimit              int    `mapstructure:"RATE_LIMIT" envDefault:"60"`
-	TrustedPlatform        string `mapstructure:"TRUSTED_PLATFORM"`
-	ExpectedHost           string `mapstructure:"EXPECTED_HOST"`
-	XFrameOptions          string `mapstructure:"X_FRAME_OPTIONS"`
-	ContentSecurity        string `mapstructure:"CONTENT_SECURITY_POLICY"`
-	XXSSProtection         string `mapstructure:"X_XSS_PROTECTION"`
-	StrictTransport        string `mapstructure:"STRICT_TRANSPORT_SECURITY"`
-	ReferrerPolicy         string `mapstructure:"REFERRER_POLICY"`
-	XContentTypeOpts       string `mapstructure:"X_CONTENT_TYPE_OPTIONS"`
-	PermissionsPolicy      string `mapstructure:"PERMISSIONS_POLICY"`
-	JWTSecretKey           string `mapstructure:"JWT_SECRET_KEY"`
-	JWTExpired             int    `mapstructure:"JWT_EXPIRED" envDefault:"15"`
-	JWTRefreshTokenExpired int    `mapstructure:"JWT_REFRESH_TOKEN_EXPIRED" envDefault:"24"`
+	CheckOrigin            bool     `mapstructure:"ACTIVATE_ORIGIN_VALIDATION"`
+	RateLimit              int      `mapstructure:"RATE_LIMIT" envDefault:"60"`
+	TrustedPlatform        string   `mapstructure:"TRUSTED_PLATFORM"`
+	ExpectedHost           string   `mapstructure:"EXPECTED_HOST"`
+	XFrameOptions          string   `mapstructure:"X_FRAME_OPTIONS"`
+	ContentSecurity        string   `mapstructure:"CONTENT_SECURITY_POLICY"`
+	XXSSProtection         string   `mapstructure:"X_XSS_PROTECTION"`
+	StrictTransport        string   `mapstructure:"STRICT_TRANSPORT_SECURITY"`
+	ReferrerPolicy         string   `mapstructure:"REFERRER_POLICY"`
+	XContentTypeOpts       string   `mapstructure:"X_CONTENT_TYPE_OPTIONS"`
+	PermissionsPolicy      string   `mapstructure:"PERMISSIONS_POLICY"`
+	JWTSecretKey           string   `mapstructure:"JWT_SECRET_KEY"`
+	FileURLSecret          string   `mapstructure:"FILE_URL_SECRET"`
+	CursorSecret           string   `mapstructure:"CURSOR_SECRET"`
+	JWTExpired             int      `mapstructure:"JWT_EXPIRED" envDefault:"15"`
+	JWTRefreshTokenExpired int      `mapstructure:"JWT_REFRESH_TOKEN_EXPIRED" envDefault:"24"`
+	JWTAudience            string   `mapstructure:"JWT_AUDIENCE"`
+	DPoPProofMaxAge        int      `mapstructure:"DPOP_PROOF_MAX_AGE" envDefault:"60"`
+	TokenExchangeExpired   int      `mapstructure:"TOKEN_EXCHANGE_EXPIRED" envDefault:"5"`
+	TokenExchangeClients   []string `mapstructure:"TOKEN_EXCHANGE_CLIENTS"`
+	EmailChangeExpired     int      `mapstructure:"EMAIL_CHANGE_EXPIRED" envDefault:"24"`
+	InvitationExpired      int      `mapstructure:"INVITATION_EXPIRED" envDefault:"72"`
+	AuthzMode              string   `mapstructure:"AUTHZ_MODE" envDefault:"token"`
+	AuthzCacheTTL          int      `mapstructure:"AUTHZ_CACHE_TTL" envDefault:"10"`
+	PolicyFile             string   `mapstructure:"AUTHZ_POLICY_FILE"`
+	PolicyReloadInterval   int      `mapstructure:"AUTHZ_POLICY_RELOAD_INTERVAL" envDefault:"1"`
+	RoleSweepInterval      int      `mapstructure:"ROLE_ASSIGNMENT_SWEEP_INTERVAL" envDefault:"1"`
+	BootstrapAdminEmail    string   `mapstructure:"BOOTSTRAP_ADMIN_EMAIL"`
+	BootstrapAdminPassword string   `mapstructure:"BOOTSTRAP_ADMIN_PASSWORD"`
+	BootstrapAdminName     string   `mapstructure:"BOOTSTRAP_ADMIN_NAME" envDefault:"Administrator"`
 	LimiterInstance        *limiter.Limiter
 }
 
+// AvatarConfig menyimpan batasan upload avatar
+type AvatarConfig struct {
+	MaxSize      int `mapstructure:"AVATAR_MAX_SIZE" envDefault:"2048"`
+	MinDimension int `mapstructure:"AVATAR_MIN_DIMENSION" envDefault:"64"`
+	MaxDimension int `mapstructure:"AVATAR_MAX_DIMENSION" envDefault:"4096"`
+}
+
 // LoggerConfig menyimpan konfigurasi logger
 type LoggerConfig struct {
 	LogLevel string `mapstructure:"LOG_LEVEL"`
@@ -102,6 +137,7 @@ func LoadConfig() (*Config, error) {
 
 	GlobalConfig.Server.AllowedOrigins = strings.Split(viper.GetString("ALLOWED_ORIGINS"), ",")
 	GlobalConfig.Search.Host = strings.Split(viper.GetString("ELASTICSEARCH_HOST"), ",")
+	GlobalConfig.Security.TokenExchangeClients = strings.Split(viper.GetString("TOKEN_EXCHANGE_CLIENTS"), ",")
 
 	return &GlobalConfig, nil
 }
@@ -109,3 +145,25 @@ func LoadConfig() (*Config, error) {
 func GetConfig() *Config {
 	return &GlobalConfig
 }
+
+// FileURLKey returns the key signing file download URLs
+func (s SecurityConfig) FileURLKey() string {
+	return purposeKey(s.FileURLSecret, s.JWTSecretKey, "file-url")
+}
+
+// CursorKey returns the key signing pagination cursors
+func (s SecurityConfig) CursorKey() string {
+	return purposeKey(s.CursorSecret, s.JWTSecretKey, "cursor")
+}
+
+// purposeKey returns the configured secret, or derives one from the JWT
+// secret so a value signed for one purpose is never accepted for another
+func purposeKey(secret string, jwtSecret string, purpose string) string {
+	if secret != "" {
+		return secret
+	}
+
+	mac := hmac.New(sha256.New, []byte(jwtSecret))
+	mac.Write([]byte("starter-golang/" + purpose))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"time"
 
+	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/event"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
@@ -46,3 +47,14 @@ func (cfg *DatabaseConfig) InitMongo() (*mongo.Database, error) {
 	Logger.Info().Msg("✅ Database MongoDB connected successfully!")
 	return client.Database(cfg.Database), nil
 }
+
+// SupportsTransactions reports whether the server is a replica set member or a
+// mongos, standalone servers do not support multi-document transactions
+func SupportsTransactions(ctx context.Context, db *mongo.Database) bool {
+	var hello bson.M
+	if err := db.RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello); err != nil {
+		return false
+	}
+	_, replicaSet := hello["setName"]
+	return replicaSet || hello["msg"] == "isdbgrid"
+}
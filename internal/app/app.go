@@ -4,7 +4,11 @@ import (
 	"fmt"
 
 	"github.com/HasanNugroho/starter-golang/config"
+	"github.com/HasanNugroho/starter-golang/internal/shared/authz"
 	"github.com/HasanNugroho/starter-golang/internal/shared/modules"
+	"github.com/HasanNugroho/starter-golang/internal/shared/permission"
+	"github.com/HasanNugroho/starter-golang/internal/shared/storage"
+	"github.com/HasanNugroho/starter-golang/internal/shared/tenant"
 	"github.com/labstack/echo/v4"
 	"github.com/redis/go-redis/v9"
 	"github.com/rs/zerolog"
@@ -14,13 +18,34 @@ import (
 var GlobalApps *Apps
 
 type Apps struct {
-	Config   *config.Config
-	Log      *zerolog.Logger
-	Redis    *redis.Client
-	DB       *mongo.Database
-	Bus      *modules.EventBus
-	Router   *echo.Echo
-	Features []Feature
+	Config    *config.Config
+	Log       *zerolog.Logger
+	Redis     *redis.Client
+	DB        *mongo.Database
+	Bus       *modules.EventBus
+	Scheduler *modules.Scheduler
+	Mailer    modules.Mailer
+	Storage   storage.Storage
+	Files     *storage.URLSigner
+	// PersonalData lists the personal data features hold, for data-subject requests
+	PersonalData *modules.PersonalDataRegistry
+	// Schema lists the collections features declare with their indexes and validators
+	Schema *modules.SchemaRegistry
+	// Seeds insert the documents a fresh deployment needs, system roles included
+	Seeds *modules.SeedRegistry
+	// Authz decides access to resources from permissions and policies
+	Authz *authz.Engine
+	// Permissions lists the permissions features declare
+	Permissions *permission.Registry
+	// Tenants resolves the organization a request acts in, set by the organizations feature
+	Tenants tenant.Resolver
+	// Grants resolves the permissions of each request when AUTHZ_MODE is cache, set by the users feature
+	Grants permission.Resolver
+	// Transactions tells whether the database supports multi-document
+	// transactions, detected once at startup
+	Transactions bool
+	Router       *echo.Echo
+	Features     []Feature
 }
 
 type Feature interface {
@@ -0,0 +1,137 @@
+package attributes
+
+import (
+	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+type AttributeHandler struct {
+	attributeService IAttributeService
+	validate         *validator.Validate
+}
+
+func NewAttributeHandler(as IAttributeService) *AttributeHandler {
+	return &AttributeHandler{
+		attributeService: as,
+		validate:         validator.New(),
+	}
+}
+
+// CreateAttribute godoc
+// @Summary      Create a user attribute
+// @Description  Define a custom user attribute, values are stored on users under attributes.<key>
+// @Tags         attributes
+// @Accept       json
+// @Produce      json
+// @Param        attribute  body  AttributeCreateModel  true  "Attribute definition"
+// @Success      201  {object}  shared.Response
+// @Failure      400  {object}  shared.Response
+// @Failure      409  {object}  shared.Response
+// @Router       /attributes [post]
+// @Security ApiKeyAuth
+func (c *AttributeHandler) Create(ctx echo.Context) error {
+	var payload AttributeCreateModel
+	ctx.Bind(&payload)
+
+	if err := c.validate.Struct(payload); err != nil {
+		return utils.NewBadRequest(err.Error())
+	}
+
+	if err := c.attributeService.Create(ctx, &payload); err != nil {
+		return err
+	}
+
+	utils.SendSuccess(ctx, 201, "attribute created successfully", nil)
+	return nil
+}
+
+// FindAllAttributes godoc
+// @Summary      Get user attributes
+// @Description  Retrieve every custom user attribute definition
+// @Tags         attributes
+// @Accept       json
+// @Produce      json
+// @Success      200  {object}  shared.Response{data=[]entities.AttributeDefinition}
+// @Failure      500  {object}  shared.Response
+// @Router       /attributes [get]
+// @Security ApiKeyAuth
+func (c *AttributeHandler) FindAll(ctx echo.Context) error {
+	definitions, err := c.attributeService.FindAll(ctx)
+	if err != nil {
+		return err
+	}
+
+	utils.SendSuccess(ctx, 200, "attributes retrieved successfully", definitions)
+	return nil
+}
+
+// FindAttribute godoc
+// @Summary      Get user attribute
+// @Description  Retrieve a custom user attribute definition by key
+// @Tags         attributes
+// @Accept       json
+// @Produce      json
+// @Param key path string true "attribute key"
+// @Success      200  {object}  shared.Response{data=entities.AttributeDefinition}
+// @Failure      404  {object}  shared.Response
+// @Router       /attributes/{key} [get]
+// @Security ApiKeyAuth
+func (c *AttributeHandler) FindByKey(ctx echo.Context) error {
+	definition, err := c.attributeService.FindByKey(ctx, ctx.Param("key"))
+	if err != nil {
+		return err
+	}
+
+	utils.SendSuccess(ctx, 200, "attribute retrieved successfully", definition)
+	return nil
+}
+
+// UpdateAttribute godoc
+// @Summary      Update user attribute
+// @Description  Update a custom user attribute definition, the key and type can not change
+// @Tags         attributes
+// @Accept       json
+// @Produce      json
+// @Param key path string true "attribute key"
+// @Param        attribute  body  AttributeUpdateModel  true  "Attribute definition"
+// @Success      200  {object}  shared.Response
+// @Failure      400  {object}  shared.Response
+// @Failure      404  {object}  shared.Response
+// @Router       /attributes/{key} [put]
+// @Security ApiKeyAuth
+func (c *AttributeHandler) Update(ctx echo.Context) error {
+	var payload AttributeUpdateModel
+	ctx.Bind(&payload)
+
+	if err := c.validate.Struct(payload); err != nil {
+		return utils.NewBadRequest(err.Error())
+	}
+
+	if err := c.attributeService.Update(ctx, ctx.Param("key"), &payload); err != nil {
+		return err
+	}
+
+	utils.SendSuccess(ctx, 200, "attribute updated successfully", nil)
+	return nil
+}
+
+// DeleteAttribute godoc
+// @Summary      Delete user attribute
+// @Description  Delete a custom user attribute definition and the values users hold for it
+// @Tags         attributes
+// @Accept       json
+// @Produce      json
+// @Param key path string true "attribute key"
+// @Success      200  {object}  shared.Response
+// @Failure      404  {object}  shared.Response
+// @Router       /attributes/{key} [delete]
+// @Security ApiKeyAuth
+func (c *AttributeHandler) Delete(ctx echo.Context) error {
+	if err := c.attributeService.Delete(ctx, ctx.Param("key")); err != nil {
+		return err
+	}
+
+	utils.SendSuccess(ctx, 200, "attribute deleted successfully", nil)
+	return nil
+}
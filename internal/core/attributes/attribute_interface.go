@@ -0,0 +1,28 @@
+package attributes
+
+import (
+	"github.com/HasanNugroho/starter-golang/internal/core/entities"
+	"github.com/HasanNugroho/starter-golang/internal/shared/query"
+	"github.com/labstack/echo/v4"
+)
+
+type IAttributeRepository interface {
+	Create(ctx echo.Context, definition *entities.AttributeDefinition) error
+	FindAll(ctx echo.Context) ([]entities.AttributeDefinition, error)
+	FindByKey(ctx echo.Context, key string) (entities.AttributeDefinition, error)
+	Update(ctx echo.Context, key string, definition *entities.AttributeDefinition) error
+	Delete(ctx echo.Context, key string) error
+}
+
+// IAttributeService manages the definitions and checks the attribute values of users,
+// the users, me and auth modules use it for the values
+type IAttributeService interface {
+	Create(ctx echo.Context, payload *AttributeCreateModel) error
+	FindAll(ctx echo.Context) ([]entities.AttributeDefinition, error)
+	FindByKey(ctx echo.Context, key string) (entities.AttributeDefinition, error)
+	Update(ctx echo.Context, key string, payload *AttributeUpdateModel) error
+	Delete(ctx echo.Context, key string) error
+	Validate(ctx echo.Context, current map[string]interface{}, values map[string]interface{}, self bool) (map[string]interface{}, error)
+	TokenClaims(ctx echo.Context, values map[string]interface{}) (map[string]interface{}, error)
+	QuerySchema(ctx echo.Context, base query.Schema) (query.Schema, error)
+}
@@ -0,0 +1,30 @@
+package attributes
+
+const (
+	TypeString  = "string"
+	TypeNumber  = "number"
+	TypeBoolean = "boolean"
+	TypeDate    = "date"
+)
+
+type AttributeCreateModel struct {
+	Key          string   `json:"key" validate:"required,max=64"`
+	Label        string   `json:"label" validate:"required"`
+	Type         string   `json:"type" validate:"required,oneof=string number boolean date"`
+	Required     bool     `json:"required"`
+	Pattern      string   `json:"pattern"`
+	Enum         []string `json:"enum"`
+	SelfEditable bool     `json:"self_editable"`
+	InToken      bool     `json:"in_token"`
+}
+
+// AttributeUpdateModel changes a definition, the key and type are fixed once created
+// since stored values depend on them
+type AttributeUpdateModel struct {
+	Label        string   `json:"label" validate:"required"`
+	Required     bool     `json:"required"`
+	Pattern      string   `json:"pattern"`
+	Enum         []string `json:"enum"`
+	SelfEditable bool     `json:"self_editable"`
+	InToken      bool     `json:"in_token"`
+}
@@ -0,0 +1,119 @@
+package attributes
+
+import (
+	"time"
+
+	"github.com/HasanNugroho/starter-golang/internal/app"
+	"github.com/HasanNugroho/starter-golang/internal/core/entities"
+	"github.com/HasanNugroho/starter-golang/internal/shared/query"
+	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+type AttributeRepository struct {
+	app        *app.Apps
+	collection *mongo.Collection
+	users      *mongo.Collection
+}
+
+func NewAttributeRepository(app *app.Apps) *AttributeRepository {
+	return &AttributeRepository{
+		app:        app,
+		collection: app.DB.Collection("user_attributes"),
+		users:      app.DB.Collection("users"),
+	}
+}
+
+func (r *AttributeRepository) Create(ctx echo.Context, definition *entities.AttributeDefinition) error {
+	c := ctx.Request().Context()
+
+	if _, err := r.collection.InsertOne(c, definition); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return utils.NewConflict("attribute already exists")
+		}
+		return utils.NewInternal("failed to create attribute")
+	}
+
+	return nil
+}
+
+func (r *AttributeRepository) FindAll(ctx echo.Context) ([]entities.AttributeDefinition, error) {
+	c := ctx.Request().Context()
+
+	cursor, err := r.collection.Find(c, bson.M{}, options.Find().SetSort(bson.D{{Key: "key", Value: 1}}))
+	if err != nil {
+		return nil, utils.NewInternal("failed to query data")
+	}
+
+	definitions := []entities.AttributeDefinition{}
+	if err := cursor.All(c, &definitions); err != nil {
+		return nil, utils.NewInternal("failed to decode data")
+	}
+
+	return definitions, nil
+}
+
+func (r *AttributeRepository) FindByKey(ctx echo.Context, key string) (entities.AttributeDefinition, error) {
+	c := ctx.Request().Context()
+
+	var definition entities.AttributeDefinition
+	if err := r.collection.FindOne(c, bson.M{"key": key}).Decode(&definition); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return entities.AttributeDefinition{}, utils.NewNotFound("attribute not found")
+		}
+		return entities.AttributeDefinition{}, utils.NewInternal("failed to query data")
+	}
+
+	return definition, nil
+}
+
+func (r *AttributeRepository) Update(ctx echo.Context, key string, definition *entities.AttributeDefinition) error {
+	c := ctx.Request().Context()
+
+	result, err := r.collection.UpdateOne(c, bson.M{"key": key}, bson.M{
+		"$set": bson.M{
+			"label":         definition.Label,
+			"required":      definition.Required,
+			"pattern":       definition.Pattern,
+			"enum":          definition.Enum,
+			"self_editable": definition.SelfEditable,
+			"in_token":      definition.InToken,
+			"updated_at":    time.Now(),
+		}})
+	if err != nil {
+		return utils.NewInternal("failed to update attribute")
+	}
+
+	if result.MatchedCount == 0 {
+		return utils.NewNotFound("attribute not found")
+	}
+
+	return nil
+}
+
+// Delete removes the definition together with the values users hold for it
+func (r *AttributeRepository) Delete(ctx echo.Context, key string) error {
+	c := ctx.Request().Context()
+
+	result, err := r.collection.DeleteOne(c, bson.M{"key": key})
+	if err != nil {
+		return utils.NewInternal("failed to delete attribute")
+	}
+
+	if result.DeletedCount == 0 {
+		return utils.NewNotFound("attribute not found")
+	}
+
+	field := "attributes." + key
+	_, err = r.users.UpdateMany(c, bson.M{field: bson.M{"$exists": true}}, query.BumpVersion(bson.M{
+		"$unset": bson.M{field: ""},
+	}))
+	if err != nil {
+		return utils.NewInternal("failed to remove attribute values")
+	}
+
+	return nil
+}
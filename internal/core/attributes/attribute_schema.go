@@ -0,0 +1,24 @@
+package attributes
+
+import (
+	"github.com/HasanNugroho/starter-golang/internal/shared/modules"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// attributeSchema declares the collection of attribute definitions
+var attributeSchema = modules.CollectionSchema{
+	Name: "user_attributes",
+	Indexes: []modules.IndexSpec{
+		{Name: "key_unique", Keys: bson.D{{Key: "key", Value: 1}}, Unique: true},
+	},
+	Validator: bson.M{
+		"bsonType": "object",
+		"required": bson.A{"key", "label", "type"},
+		"properties": bson.M{
+			"key":   bson.M{"bsonType": "string", "pattern": "^[a-z][a-z0-9_]*$"},
+			"label": bson.M{"bsonType": "string"},
+			"type":  bson.M{"enum": bson.A{TypeString, TypeNumber, TypeBoolean, TypeDate}},
+			"enum":  bson.M{"bsonType": "array", "items": bson.M{"bsonType": "string"}},
+		},
+	},
+}
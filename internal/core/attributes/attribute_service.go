@@ -0,0 +1,135 @@
+package attributes
+
+import (
+	"maps"
+	"regexp"
+	"time"
+
+	"github.com/HasanNugroho/starter-golang/internal/app"
+	"github.com/HasanNugroho/starter-golang/internal/core/entities"
+	"github.com/HasanNugroho/starter-golang/internal/shared/query"
+	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
+	"github.com/labstack/echo/v4"
+)
+
+// keyPattern keeps keys usable as document fields and in filter[attributes.<key>]
+var keyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+type AttributeService struct {
+	repo IAttributeRepository
+	app  *app.Apps
+}
+
+func NewAttributeService(app *app.Apps, repo IAttributeRepository) *AttributeService {
+	return &AttributeService{
+		repo: repo,
+		app:  app,
+	}
+}
+
+func (s *AttributeService) Create(ctx echo.Context, payload *AttributeCreateModel) error {
+	if !keyPattern.MatchString(payload.Key) {
+		return utils.NewBadRequest("key must start with a letter and contain only lowercase letters, digits and underscores")
+	}
+
+	definition := entities.AttributeDefinition{
+		Key:          payload.Key,
+		Label:        payload.Label,
+		Type:         payload.Type,
+		Required:     payload.Required,
+		Pattern:      payload.Pattern,
+		Enum:         payload.Enum,
+		SelfEditable: payload.SelfEditable,
+		InToken:      payload.InToken,
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}
+	if err := checkConstraints(definition); err != nil {
+		return err
+	}
+
+	return s.repo.Create(ctx, &definition)
+}
+
+func (s *AttributeService) FindAll(ctx echo.Context) ([]entities.AttributeDefinition, error) {
+	return s.repo.FindAll(ctx)
+}
+
+func (s *AttributeService) FindByKey(ctx echo.Context, key string) (entities.AttributeDefinition, error) {
+	return s.repo.FindByKey(ctx, key)
+}
+
+func (s *AttributeService) Update(ctx echo.Context, key string, payload *AttributeUpdateModel) error {
+	definition, err := s.repo.FindByKey(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	definition.Label = payload.Label
+	definition.Required = payload.Required
+	definition.Pattern = payload.Pattern
+	definition.Enum = payload.Enum
+	definition.SelfEditable = payload.SelfEditable
+	definition.InToken = payload.InToken
+	if err := checkConstraints(definition); err != nil {
+		return err
+	}
+
+	return s.repo.Update(ctx, key, &definition)
+}
+
+func (s *AttributeService) Delete(ctx echo.Context, key string) error {
+	return s.repo.Delete(ctx, key)
+}
+
+// Validate checks attribute values against the current definitions, see Validate
+func (s *AttributeService) Validate(ctx echo.Context, current map[string]interface{}, values map[string]interface{}, self bool) (map[string]interface{}, error) {
+	definitions, err := s.repo.FindAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return Validate(definitions, current, values, self)
+}
+
+// TokenClaims returns the values flagged for the token payload
+func (s *AttributeService) TokenClaims(ctx echo.Context, values map[string]interface{}) (map[string]interface{}, error) {
+	if len(values) == 0 {
+		return map[string]interface{}{}, nil
+	}
+
+	definitions, err := s.repo.FindAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return TokenClaims(definitions, values), nil
+}
+
+// QuerySchema extends a listing schema with the attributes as filterable fields
+func (s *AttributeService) QuerySchema(ctx echo.Context, base query.Schema) (query.Schema, error) {
+	definitions, err := s.repo.FindAll(ctx)
+	if err != nil {
+		return query.Schema{}, err
+	}
+
+	schema := base
+	schema.Fields = maps.Clone(base.Fields)
+	maps.Copy(schema.Fields, QueryFields(definitions))
+	return schema, nil
+}
+
+// checkConstraints rejects constraints that do not fit the attribute type
+func checkConstraints(definition entities.AttributeDefinition) error {
+	if definition.Type != TypeString && (definition.Pattern != "" || len(definition.Enum) > 0) {
+		return utils.NewBadRequest("pattern and enum only apply to string attributes")
+	}
+
+	if definition.Pattern != "" {
+		if _, err := compilePattern(definition.Pattern); err != nil {
+			return utils.NewBadRequest("invalid pattern: " + err.Error())
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,266 @@
+package attributes
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/HasanNugroho/starter-golang/internal/core/entities"
+	"github.com/HasanNugroho/starter-golang/internal/shared/query"
+	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// patterns caches the compiled patterns of the definitions, definitions are
+// loaded on every request but rarely change
+var patterns sync.Map
+
+// compilePattern compiles a definition pattern once and reuses it afterwards
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if cached, ok := patterns.Load(pattern); ok {
+		return cached.(*regexp.Regexp), nil
+	}
+
+	compiled, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	patterns.Store(pattern, compiled)
+	return compiled, nil
+}
+
+// Validate checks the attribute values of a user against the definitions and
+// returns them ready to store. A null value removes the attribute. current holds
+// the stored values: with self, attributes users may not edit must be sent back
+// unchanged and are kept as stored.
+func Validate(definitions []entities.AttributeDefinition, current map[string]interface{}, values map[string]interface{}, self bool) (map[string]interface{}, error) {
+	byKey := make(map[string]entities.AttributeDefinition, len(definitions))
+	for _, definition := range definitions {
+		byKey[definition.Key] = definition
+	}
+
+	result := map[string]interface{}{}
+	for key, value := range values {
+		definition, ok := byKey[key]
+		if !ok {
+			return nil, utils.NewBadRequest("unknown attribute " + key)
+		}
+		if self && !definition.SelfEditable {
+			if !sameValue(definition, current[key], value) {
+				return nil, utils.NewForbidden("attribute " + key + " can not be changed")
+			}
+			continue
+		}
+		if value == nil {
+			continue
+		}
+
+		normalized, err := normalize(definition, value)
+		if err != nil {
+			return nil, utils.NewBadRequest(fmt.Sprintf("attribute %s %s", key, err.Error()))
+		}
+		result[key] = normalized
+	}
+
+	for _, definition := range definitions {
+		if self && !definition.SelfEditable {
+			stored := current[definition.Key]
+			if _, sent := values[definition.Key]; !sent && stored != nil {
+				return nil, utils.NewForbidden("attribute " + definition.Key + " can not be changed")
+			}
+			if stored != nil {
+				result[definition.Key] = stored
+			}
+			continue
+		}
+
+		if definition.Required && result[definition.Key] == nil {
+			return nil, utils.NewBadRequest("attribute " + definition.Key + " is required")
+		}
+	}
+
+	return result, nil
+}
+
+// FromText converts text values, e.g. the cells of a CSV file, to the type of
+// their attribute. Values that do not convert stay text for Validate to report,
+// empty ones are left out.
+func FromText(definitions []entities.AttributeDefinition, values map[string]string) map[string]interface{} {
+	types := make(map[string]string, len(definitions))
+	for _, definition := range definitions {
+		types[definition.Key] = definition.Type
+	}
+
+	result := make(map[string]interface{}, len(values))
+	for key, text := range values {
+		if text == "" {
+			continue
+		}
+		result[key] = text
+		switch types[key] {
+		case TypeNumber:
+			if number, err := strconv.ParseFloat(text, 64); err == nil {
+				result[key] = number
+			}
+		case TypeBoolean:
+			if flag, err := strconv.ParseBool(text); err == nil {
+				result[key] = flag
+			}
+		}
+	}
+	return result
+}
+
+// TokenClaims picks the values of the attributes flagged for the token payload
+func TokenClaims(definitions []entities.AttributeDefinition, values map[string]interface{}) map[string]interface{} {
+	claims := map[string]interface{}{}
+	for _, definition := range definitions {
+		value, ok := values[definition.Key]
+		if !definition.InToken || !ok || value == nil {
+			continue
+		}
+
+		if definition.Type == TypeDate {
+			if date, ok := toDate(value); ok {
+				value = date.Format(time.RFC3339)
+			}
+		}
+		claims[definition.Key] = value
+	}
+	return claims
+}
+
+// Plain converts stored values into their JSON form, so a patch leaving the
+// attributes untouched compares equal to the stored ones
+func Plain(values map[string]interface{}) map[string]interface{} {
+	if values == nil {
+		return nil
+	}
+
+	plain := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		switch date := value.(type) {
+		case bson.DateTime:
+			plain[key] = date.Time().UTC().Format(time.RFC3339Nano)
+		case time.Time:
+			plain[key] = date.UTC().Format(time.RFC3339Nano)
+		default:
+			plain[key] = value
+		}
+	}
+	return plain
+}
+
+// QueryFields makes every attribute filterable and sortable as attributes.<key>
+func QueryFields(definitions []entities.AttributeDefinition) map[string]query.Field {
+	types := map[string]query.FieldType{
+		TypeString:  query.String,
+		TypeNumber:  query.Number,
+		TypeBoolean: query.Bool,
+		TypeDate:    query.Time,
+	}
+
+	fields := make(map[string]query.Field, len(definitions))
+	for _, definition := range definitions {
+		fields["attributes."+definition.Key] = query.Field{Type: types[definition.Type], Sortable: true}
+	}
+	return fields
+}
+
+func normalize(definition entities.AttributeDefinition, value interface{}) (interface{}, error) {
+	switch definition.Type {
+	case TypeString:
+		text, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("must be a string")
+		}
+		if len(definition.Enum) > 0 && !slices.Contains(definition.Enum, text) {
+			return nil, fmt.Errorf("must be one of %v", definition.Enum)
+		}
+		if definition.Pattern != "" {
+			pattern, err := compilePattern(definition.Pattern)
+			if err != nil || !pattern.MatchString(text) {
+				return nil, fmt.Errorf("does not match %s", definition.Pattern)
+			}
+		}
+		return text, nil
+
+	case TypeNumber:
+		if number, ok := toNumber(value); ok {
+			return number, nil
+		}
+		return nil, fmt.Errorf("must be a number")
+
+	case TypeBoolean:
+		if flag, ok := value.(bool); ok {
+			return flag, nil
+		}
+		return nil, fmt.Errorf("must be a boolean")
+
+	case TypeDate:
+		if date, ok := toDate(value); ok {
+			return date, nil
+		}
+		return nil, fmt.Errorf("must be an RFC 3339 date")
+	}
+
+	return nil, fmt.Errorf("has an unknown type %s", definition.Type)
+}
+
+func toNumber(value interface{}) (float64, bool) {
+	switch number := value.(type) {
+	case float64:
+		return number, true
+	case int:
+		return float64(number), true
+	case int32:
+		return float64(number), true
+	case int64:
+		return float64(number), true
+	case json.Number:
+		parsed, err := number.Float64()
+		return parsed, err == nil
+	}
+	return 0, false
+}
+
+// toDate truncates to milliseconds, the precision MongoDB keeps
+func toDate(value interface{}) (time.Time, bool) {
+	switch date := value.(type) {
+	case time.Time:
+		return date.UTC().Truncate(time.Millisecond), true
+	case bson.DateTime:
+		return date.Time().UTC(), true
+	case string:
+		if parsed, err := query.ParseDate(date); err == nil {
+			return parsed.UTC().Truncate(time.Millisecond), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// sameValue compares a stored value with a sent one without validating either,
+// a value stored before its definition changed stays comparable
+func sameValue(definition entities.AttributeDefinition, stored interface{}, sent interface{}) bool {
+	if stored == nil || sent == nil {
+		return stored == nil && sent == nil
+	}
+
+	if definition.Type == TypeDate {
+		before, okBefore := toDate(stored)
+		after, okAfter := toDate(sent)
+		return okBefore && okAfter && before.Equal(after)
+	}
+
+	if before, ok := toNumber(stored); ok {
+		after, ok := toNumber(sent)
+		return ok && before == after
+	}
+
+	return reflect.DeepEqual(stored, sent)
+}
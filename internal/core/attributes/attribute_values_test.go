@@ -0,0 +1,85 @@
+package attributes_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/HasanNugroho/starter-golang/internal/core/attributes"
+	"github.com/HasanNugroho/starter-golang/internal/core/entities"
+	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+var definitions = []entities.AttributeDefinition{
+	{Key: "employee_id", Type: attributes.TypeString, Required: true, Pattern: `^E[0-9]+$`, InToken: true},
+	{Key: "department", Type: attributes.TypeString, Enum: []string{"sales", "finance"}},
+	{Key: "locale", Type: attributes.TypeString, SelfEditable: true, InToken: true},
+	{Key: "hired_at", Type: attributes.TypeDate, InToken: true},
+}
+
+func TestValidate_NormalizesValues(t *testing.T) {
+	values, err := attributes.Validate(definitions, nil, map[string]interface{}{
+		"employee_id": "E42",
+		"department":  "sales",
+		"hired_at":    "2024-03-01T08:00:00.123456Z",
+		"locale":      nil,
+	}, false)
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"employee_id": "E42",
+		"department":  "sales",
+		"hired_at":    time.Date(2024, 3, 1, 8, 0, 0, 123000000, time.UTC),
+	}, values)
+}
+
+func TestValidate_Rejections(t *testing.T) {
+	tests := []struct {
+		name   string
+		values map[string]interface{}
+		err    error
+	}{
+		{"unknown", map[string]interface{}{"employee_id": "E1", "team": "x"}, utils.NewBadRequest("unknown attribute team")},
+		{"required", map[string]interface{}{"department": "sales"}, utils.NewBadRequest("attribute employee_id is required")},
+		{"pattern", map[string]interface{}{"employee_id": "42"}, utils.NewBadRequest("attribute employee_id does not match ^E[0-9]+$")},
+		{"enum", map[string]interface{}{"employee_id": "E1", "department": "hr"}, utils.NewBadRequest("attribute department must be one of [sales finance]")},
+		{"type", map[string]interface{}{"employee_id": "E1", "hired_at": 12}, utils.NewBadRequest("attribute hired_at must be an RFC 3339 date")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := attributes.Validate(definitions, nil, tt.values, false)
+			assert.Equal(t, tt.err, err)
+		})
+	}
+}
+
+func TestValidate_SelfKeepsLockedAttributes(t *testing.T) {
+	hired := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	current := map[string]interface{}{"employee_id": "legacy", "hired_at": hired, "locale": "en"}
+
+	values, err := attributes.Validate(definitions, current, map[string]interface{}{
+		"employee_id": "legacy",
+		"hired_at":    "2024-03-01T00:00:00Z",
+		"locale":      "id",
+	}, true)
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"employee_id": "legacy", "hired_at": hired, "locale": "id"}, values)
+
+	_, err = attributes.Validate(definitions, current, map[string]interface{}{"employee_id": "E7", "hired_at": hired}, true)
+	assert.Equal(t, utils.NewForbidden("attribute employee_id can not be changed"), err)
+
+	_, err = attributes.Validate(definitions, current, map[string]interface{}{"employee_id": "legacy"}, true)
+	assert.Equal(t, utils.NewForbidden("attribute hired_at can not be changed"), err)
+}
+
+func TestTokenClaims_OnlyFlagged(t *testing.T) {
+	claims := attributes.TokenClaims(definitions, map[string]interface{}{
+		"employee_id": "E42",
+		"department":  "sales",
+		"hired_at":    time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC),
+	})
+
+	assert.Equal(t, map[string]interface{}{"employee_id": "E42", "hired_at": "2024-03-01T08:00:00Z"}, claims)
+}
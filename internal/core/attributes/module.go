@@ -0,0 +1,51 @@
+package attributes
+
+import (
+	"github.com/HasanNugroho/starter-golang/internal/app"
+	"github.com/HasanNugroho/starter-golang/internal/shared/middleware"
+	"github.com/HasanNugroho/starter-golang/internal/shared/permission"
+	"github.com/labstack/echo/v4"
+)
+
+// permissions are the permissions the module declares
+var permissions = []permission.Definition{
+	{Name: "attributes:read", Description: "List custom user attributes", Category: "attributes", Risk: permission.RiskLow},
+	{Name: "attributes:manage", Description: "Define, change and remove custom user attributes", Category: "attributes", Risk: permission.RiskHigh, DependsOn: []string{"attributes:read"}},
+}
+
+type AttributeModule struct {
+	Handler *AttributeHandler
+}
+
+func NewAttributeModule(app *app.Apps) *AttributeModule {
+	attributeService := NewAttributeService(app, NewAttributeRepository(app))
+	return &AttributeModule{
+		Handler: NewAttributeHandler(attributeService),
+	}
+}
+
+func (m *AttributeModule) Register(app *app.Apps) error {
+	app.Log.Info().Msg("Attribute Module Initialized")
+
+	if err := app.Permissions.Declare(permissions...); err != nil {
+		return err
+	}
+
+	app.Schema.Declare(attributeSchema)
+
+	return nil
+}
+
+func (m *AttributeModule) Route(router *echo.Group, app *app.Apps) {
+	route := router.Group("/v1/attributes")
+	{
+		route.Use(middleware.AuthMiddleware(app))
+		// Whoever creates or edits users needs the definitions to fill in the values
+		readers := []string{"attributes:read", "attributes:manage", "users:read", "users:create", "users:update"}
+		route.GET("", m.Handler.FindAll, middleware.CheckAccess(readers))
+		route.GET("/:key", m.Handler.FindByKey, middleware.CheckAccess(readers))
+		route.POST("", m.Handler.Create, middleware.CheckAccess([]string{"attributes:manage"}))
+		route.PUT("/:key", m.Handler.Update, middleware.CheckAccess([]string{"attributes:manage"}))
+		route.DELETE("/:key", m.Handler.Delete, middleware.CheckAccess([]string{"attributes:manage"}))
+	}
+}
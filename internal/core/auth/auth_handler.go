@@ -57,11 +57,12 @@ func (c *AuthHandler) Register(ctx echo.Context) error {
 
 // Login godoc
 // @Summary      Login
-// @Description  Login an user
+// @Description  Login an user, tenant optionally names the organization (id or slug) the tokens are issued for
 // @Tags         auth
 // @Accept       json
 // @Produce      json
 // @Param        user  body  AuthModel  true  "User Data"
+// @Param        DPoP  header  string  false  "DPoP proof binding the issued tokens to the client key"
 // @Success      200 {object}  shared.Response{data=AuthResponse}
 // @Failure      400  {object}  shared.Response
 // @Failure      404  {object}  shared.Response
@@ -77,7 +78,7 @@ func (c *AuthHandler) Login(ctx echo.Context) error {
 		return utils.NewBadRequest(err.Error())
 	}
 
-	token, err := c.authService.Login(ctx, c.app, user.Email, user.Password)
+	token, err := c.authService.Login(ctx, c.app, user.Email, user.Password, user.Tenant)
 	if err != nil {
 		return err
 	}
@@ -116,6 +117,7 @@ func (c *AuthHandler) Logout(ctx echo.Context) error {
 // @Accept       json
 // @Produce      json
 // @Param        request body LogoutRequest true "Logout payload"
+// @Param        DPoP  header  string  false  "DPoP proof, required for DPoP-bound refresh tokens"
 // @Success      200 {object}  shared.Response
 // @Failure      400  {object}  shared.Response
 // @Failure      404  {object}  shared.Response
@@ -131,3 +133,40 @@ func (c *AuthHandler) GenerateAccessToken(ctx echo.Context) error {
 	utils.SendSuccess(ctx, http.StatusOK, "Renew token successfully", token)
 	return nil
 }
+
+// Token exchange godoc
+// @Summary      Token exchange
+// @Description  Exchange a user access token for a narrower, audience-restricted token (RFC 8693)
+// @Tags         auth
+// @Accept       json
+// @Accept       x-www-form-urlencoded
+// @Produce      json
+// @Param        request body TokenExchangeRequest true "Token exchange payload"
+// @Success      200 {object}  shared.Response{data=TokenExchangeResponse}
+// @Failure      400  {object}  shared.Response
+// @Failure      401  {object}  shared.Response
+// @Failure      500  {object}  shared.Response
+// @Router       /auth/token-exchange [post]
+func (c *AuthHandler) ExchangeToken(ctx echo.Context) error {
+	var req TokenExchangeRequest
+	if err := ctx.Bind(&req); err != nil {
+		return utils.NewBadRequest("Invalid data format")
+	}
+
+	// Client credentials may also be sent with HTTP Basic authentication
+	if id, secret, ok := ctx.Request().BasicAuth(); ok {
+		req.ClientID, req.ClientSecret = id, secret
+	}
+
+	if err := c.validate.Struct(req); err != nil {
+		return utils.NewBadRequest(err.Error())
+	}
+
+	token, err := c.authService.ExchangeToken(ctx, c.app, &req)
+	if err != nil {
+		return err
+	}
+
+	utils.SendSuccess(ctx, http.StatusOK, "Token exchanged successfully", token)
+	return nil
+}
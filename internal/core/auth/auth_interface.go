@@ -7,8 +7,9 @@ import (
 )
 
 type IAuthService interface {
-	Login(ctx echo.Context, app *app.Apps, email string, password string) (AuthResponse, error)
+	Login(ctx echo.Context, app *app.Apps, email string, password string, tenantRef string) (AuthResponse, error)
 	Register(ctx echo.Context, app *app.Apps, user *users.UserCreateModel) error
 	Logout(ctx echo.Context, app *app.Apps) error
 	GenerateAccessToken(ctx echo.Context, app *app.Apps) (AuthResponse, error)
+	ExchangeToken(ctx echo.Context, app *app.Apps, req *TokenExchangeRequest) (TokenExchangeResponse, error)
 }
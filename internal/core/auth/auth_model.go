@@ -3,6 +3,7 @@ package auth
 type AuthModel struct {
 	Email    string `json:"email" validate:"required,email"`
 	Password string `json:"password" validate:"required"`
+	Tenant   string `json:"tenant,omitempty" example:"acme"`
 }
 
 type AuthResponse struct {
@@ -14,3 +15,21 @@ type AuthResponse struct {
 type LogoutRequest struct {
 	RefreshToken string `json:"refresh_token" example:"your-refresh-token"`
 }
+
+type TokenExchangeRequest struct {
+	GrantType        string `json:"grant_type" form:"grant_type" validate:"required" example:"urn:ietf:params:oauth:grant-type:token-exchange"`
+	SubjectToken     string `json:"subject_token" form:"subject_token" validate:"required"`
+	SubjectTokenType string `json:"subject_token_type" form:"subject_token_type" validate:"required" example:"urn:ietf:params:oauth:token-type:access_token"`
+	Audience         string `json:"audience" form:"audience" validate:"required"`
+	Scope            string `json:"scope" form:"scope" validate:"required" example:"users:read"`
+	ClientID         string `json:"client_id" form:"client_id"`
+	ClientSecret     string `json:"client_secret" form:"client_secret"`
+}
+
+type TokenExchangeResponse struct {
+	AccessToken     string `json:"access_token"`
+	IssuedTokenType string `json:"issued_token_type"`
+	TokenType       string `json:"token_type"`
+	ExpiresIn       int64  `json:"expires_in"`
+	Scope           string `json:"scope"`
+}
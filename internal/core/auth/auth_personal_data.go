@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/HasanNugroho/starter-golang/internal/app"
+	"github.com/HasanNugroho/starter-golang/internal/shared/modules"
+	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
+)
+
+// SessionPersonalDataModel is an active session in a personal data archive,
+// the refresh token itself is a credential and never exported
+type SessionPersonalDataModel struct {
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// sessionsDataSource declares the refresh tokens kept in Redis as personal data
+func sessionsDataSource(app *app.Apps) modules.PersonalDataSource {
+	return modules.PersonalDataSource{
+		Name: "sessions",
+		Export: func(ctx context.Context, subject modules.DataSubject, archive modules.PersonalDataArchive) error {
+			keys, err := sessionKeys(ctx, app, subject.ID.Hex())
+			if err != nil {
+				return err
+			}
+
+			sessions := []SessionPersonalDataModel{}
+			for _, key := range keys {
+				ttl, err := app.Redis.TTL(ctx, key).Result()
+				if err != nil || ttl <= 0 {
+					continue
+				}
+				sessions = append(sessions, SessionPersonalDataModel{ExpiresAt: time.Now().Add(ttl).Truncate(time.Second)})
+			}
+
+			return archive.AddJSON("sessions.json", sessions)
+		},
+		Erase: func(ctx context.Context, subject modules.DataSubject) error {
+			keys, err := sessionKeys(ctx, app, subject.ID.Hex())
+			if err != nil {
+				return err
+			}
+
+			if len(keys) > 0 {
+				if err := app.Redis.Del(ctx, keys...).Err(); err != nil {
+					return err
+				}
+			}
+			return utils.RevokeUserTokens(app, subject.ID.Hex())
+		},
+	}
+}
+
+// sessionKeys finds the refresh tokens of the user. Tokens are keyed by the token,
+// so this scans every refresh token, which is fine for rare data-subject requests.
+func sessionKeys(ctx context.Context, app *app.Apps, userID string) ([]string, error) {
+	if !app.Config.Redis.Enabled {
+		return nil, nil
+	}
+
+	keys := []string{}
+	iter := app.Redis.Scan(ctx, 0, "refresh_token:*", 500).Iterator()
+	for iter.Next(ctx) {
+		owner, err := app.Redis.Get(ctx, iter.Val()).Result()
+		if err == nil && owner == userID {
+			keys = append(keys, iter.Val())
+		}
+	}
+	return keys, iter.Err()
+}
@@ -1,25 +1,34 @@
 package auth
 
 import (
+	"crypto/subtle"
+	"slices"
+	"strings"
+
 	"github.com/HasanNugroho/starter-golang/internal/app"
+	"github.com/HasanNugroho/starter-golang/internal/core/attributes"
 	"github.com/HasanNugroho/starter-golang/internal/core/entities"
 	"github.com/HasanNugroho/starter-golang/internal/core/users"
+	"github.com/HasanNugroho/starter-golang/internal/shared/permission"
+	"github.com/HasanNugroho/starter-golang/internal/shared/tenant"
 	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/labstack/echo/v4"
 )
 
 type AuthService struct {
-	repo users.IUserRepository
+	repo       users.IUserRepository
+	attributes attributes.IAttributeService
 }
 
-func NewAuthService(repo users.IUserRepository) *AuthService {
+func NewAuthService(repo users.IUserRepository, attributes attributes.IAttributeService) *AuthService {
 	return &AuthService{
-		repo: repo,
+		repo:       repo,
+		attributes: attributes,
 	}
 }
 
-func (a *AuthService) Login(ctx echo.Context, app *app.Apps, email string, password string) (AuthResponse, error) {
+func (a *AuthService) Login(ctx echo.Context, app *app.Apps, email string, password string, tenantRef string) (AuthResponse, error) {
 	existingUser, err := a.repo.FindByEmail(ctx, email)
 	if err != nil || existingUser.Email == "" {
 		return AuthResponse{}, utils.NewBadRequest("Incorrect email or password")
@@ -29,21 +38,45 @@ func (a *AuthService) Login(ctx echo.Context, app *app.Apps, email string, passw
 		return AuthResponse{}, utils.NewBadRequest("Incorrect email or password")
 	}
 
-	var allPermissions []string
-	for _, role := range existingUser.RolesData {
-		allPermissions = append(allPermissions, role.Permissions...)
+	if err := utils.CheckAccountStatus(existingUser.Status); err != nil {
+		return AuthResponse{}, err
+	}
+
+	allPermissions := existingUser.Permissions()
+
+	claims, err := a.attributes.TokenClaims(ctx, existingUser.Attributes)
+	if err != nil {
+		return AuthResponse{}, err
+	}
+
+	payload := existingUser.TokenPayload(claims)
+
+	// The tenant is only carried in the token, its permissions are resolved per request
+	if tenantRef != "" {
+		if app.Tenants == nil {
+			return AuthResponse{}, utils.NewBadRequest("organizations are not enabled")
+		}
+		current, err := app.Tenants.Resolve(ctx.Request().Context(), existingUser.ID.Hex(), tenantRef)
+		if err != nil {
+			return AuthResponse{}, err
+		}
+		if !current.Member && !permission.Compile(allPermissions).Allows(permission.System) {
+			return AuthResponse{}, utils.NewForbidden("not a member of the organization")
+		}
+		payload[tenant.Claim] = current.ID.Hex()
 	}
 
-	payload := map[string]interface{}{
-		"id":         existingUser.ID,
-		"email":      existingUser.Email,
-		"name":       existingUser.Name,
-		"created_at": existingUser.CreatedAt,
-		"permission": allPermissions,
-		"roles":      existingUser.RolesData,
+	// Bind the issued tokens when the client presents a DPoP proof
+	var jkt string
+	if proofHeader := ctx.Request().Header.Get(utils.DPoPHeader); proofHeader != "" {
+		proof, err := utils.VerifyDPoPProof(app, proofHeader, ctx.Request().Method, utils.DPoPRequestURL(ctx), "")
+		if err != nil {
+			return AuthResponse{}, err
+		}
+		jkt = proof.Thumbprint
 	}
 
-	accessToken, refreshToken, err := utils.GenerateAuthToken(app, payload)
+	accessToken, refreshToken, err := utils.GenerateAuthToken(app, utils.IdentityClaims(app, payload), jkt, existingUser.GrantsExpireAt())
 	if err != nil {
 		return AuthResponse{}, utils.NewInternal(err.Error())
 	}
@@ -56,7 +89,8 @@ func (a *AuthService) Login(ctx echo.Context, app *app.Apps, email string, passw
 }
 
 func (a *AuthService) Register(ctx echo.Context, app *app.Apps, user *users.UserCreateModel) error {
-	_, err := a.repo.FindByEmail(ctx, user.Email)
+	// Users signing up can only fill in the attributes they may edit themselves
+	values, err := a.attributes.Validate(ctx, nil, user.Attributes, true)
 	if err != nil {
 		return err
 	}
@@ -67,9 +101,11 @@ func (a *AuthService) Register(ctx echo.Context, app *app.Apps, user *users.User
 	}
 
 	payload := entities.User{
-		Email:    user.Email,
-		Name:     user.Name,
-		Password: password,
+		Email:      user.Email,
+		Name:       user.Name,
+		Password:   password,
+		Status:     utils.StatusActive,
+		Attributes: values,
 	}
 
 	if err = a.repo.Create(ctx, &payload); err != nil {
@@ -79,7 +115,7 @@ func (a *AuthService) Register(ctx echo.Context, app *app.Apps, user *users.User
 }
 
 func (a *AuthService) Logout(ctx echo.Context, app *app.Apps) error {
-	tokenString := ctx.Request().Header.Get("Authorization")
+	tokenString, _ := utils.ExtractToken(ctx.Request().Header.Get("Authorization"))
 	if tokenString == "" {
 		return utils.NewBadRequest("Token is required")
 	}
@@ -114,6 +150,22 @@ func (a *AuthService) GenerateAccessToken(ctx echo.Context, app *app.Apps) (Auth
 		return AuthResponse{}, utils.NewBadRequest("invalid claims in refresh token")
 	}
 
+	if !utils.IsTokenType(claims, utils.TokenTypeRefresh) {
+		return AuthResponse{}, utils.NewForbidden("refresh token is invalid")
+	}
+
+	// A DPoP-bound refresh token requires a proof from the same key
+	jkt := utils.BoundThumbprint(claims)
+	if jkt != "" {
+		proof, err := utils.VerifyDPoPProof(app, ctx.Request().Header.Get(utils.DPoPHeader), ctx.Request().Method, utils.DPoPRequestURL(ctx), "")
+		if err != nil {
+			return AuthResponse{}, err
+		}
+		if proof.Thumbprint != jkt {
+			return AuthResponse{}, utils.NewUnauthorized("DPoP proof key does not match refresh token")
+		}
+	}
+
 	data, ok := claims["data"].(map[string]interface{})
 	if !ok {
 		return AuthResponse{}, utils.NewBadRequest("Invalid data in claims")
@@ -129,21 +181,22 @@ func (a *AuthService) GenerateAccessToken(ctx echo.Context, app *app.Apps) (Auth
 		return AuthResponse{}, utils.NewBadRequest("user not found")
 	}
 
-	// Aggregate permissions
-	var allPermissions []string
-	for _, role := range existingUser.RolesData {
-		allPermissions = append(allPermissions, role.Permissions...)
+	if err := utils.CheckAccountStatus(existingUser.Status); err != nil {
+		return AuthResponse{}, err
+	}
+
+	attributeClaims, err := a.attributes.TokenClaims(ctx, existingUser.Attributes)
+	if err != nil {
+		return AuthResponse{}, err
 	}
 
-	newPayload := map[string]interface{}{
-		"id":          userID,
-		"email":       existingUser.Email,
-		"name":        existingUser.Name,
-		"permissions": allPermissions,
+	newPayload := existingUser.TokenPayload(attributeClaims)
+	if current, ok := data[tenant.Claim].(string); ok {
+		newPayload[tenant.Claim] = current
 	}
 
 	// Generate new access token
-	newAccessToken, err := utils.RefreshAccessToken(app, req.RefreshToken, newPayload)
+	newAccessToken, err := utils.RefreshAccessToken(app, req.RefreshToken, utils.IdentityClaims(app, newPayload), jkt, existingUser.GrantsExpireAt())
 	if err != nil {
 		return AuthResponse{}, utils.NewInternal(err.Error())
 	}
@@ -154,3 +207,146 @@ func (a *AuthService) GenerateAccessToken(ctx echo.Context, app *app.Apps) (Auth
 		Data:         newPayload,
 	}, nil
 }
+
+func (a *AuthService) ExchangeToken(ctx echo.Context, app *app.Apps, req *TokenExchangeRequest) (TokenExchangeResponse, error) {
+	if req.GrantType != utils.TokenExchangeGrantType {
+		return TokenExchangeResponse{}, utils.NewBadRequest("unsupported grant_type")
+	}
+
+	if req.SubjectTokenType != utils.AccessTokenType {
+		return TokenExchangeResponse{}, utils.NewBadRequest("unsupported subject_token_type")
+	}
+
+	if !authenticateClient(app, req.ClientID, req.ClientSecret) {
+		return TokenExchangeResponse{}, utils.NewUnauthorized("invalid client credentials")
+	}
+
+	token, err := utils.ValidateToken(app, req.SubjectToken)
+	if err != nil || !token.Valid {
+		return TokenExchangeResponse{}, utils.NewBadRequest("subject token is invalid")
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return TokenExchangeResponse{}, utils.NewBadRequest("invalid claims in subject token")
+	}
+
+	// A refresh token is not the access token subject_token_type names
+	if !utils.IsTokenType(claims, utils.TokenTypeAccess) {
+		return TokenExchangeResponse{}, utils.NewBadRequest("subject token is not an access token")
+	}
+
+	// Only tokens meant for this API, or exchanged for the calling service, can be exchanged
+	if !subjectAudienceAllowed(app, claims, req.ClientID) {
+		return TokenExchangeResponse{}, utils.NewBadRequest("subject token audience is not accepted")
+	}
+
+	data, ok := claims["data"].(map[string]interface{})
+	if !ok {
+		return TokenExchangeResponse{}, utils.NewBadRequest("Invalid data in claims")
+	}
+
+	userID, ok := data["id"].(string)
+	if !ok {
+		return TokenExchangeResponse{}, utils.NewBadRequest("invalid or missing user ID in token")
+	}
+
+	existingUser, err := a.repo.FindById(ctx, userID)
+	if err != nil {
+		return TokenExchangeResponse{}, utils.NewBadRequest("user not found")
+	}
+
+	if err := utils.CheckAccountStatus(existingUser.Status); err != nil {
+		return TokenExchangeResponse{}, err
+	}
+
+	// Permissions the user actually holds are the ceiling of the new token
+	held := existingUser.Permissions()
+
+	// A token that was already exchanged can only be narrowed further
+	var previousScope []string
+	if scope, ok := claims["scope"].(string); ok {
+		previousScope = strings.Fields(scope)
+	}
+
+	requested := strings.Fields(req.Scope)
+	for _, permission := range requested {
+		if !canDelegate(app, held, previousScope, permission) {
+			return TokenExchangeResponse{}, utils.NewBadRequest("invalid_scope: " + permission)
+		}
+	}
+
+	// Record the calling service on top of any existing delegation chain
+	act := map[string]interface{}{"sub": req.ClientID}
+	if previous, ok := claims["act"].(map[string]interface{}); ok {
+		act["act"] = previous
+	}
+
+	attributeClaims, err := a.attributes.TokenClaims(ctx, existingUser.Attributes)
+	if err != nil {
+		return TokenExchangeResponse{}, err
+	}
+
+	// The exchanged token only carries the delegated scope
+	payload := existingUser.TokenPayload(attributeClaims)
+	payload["permission"] = requested
+	delete(payload, "roles")
+
+	// A DPoP-bound subject token stays bound to the same key once exchanged
+	accessToken, expiration, err := utils.GenerateExchangedToken(app, payload, req.Audience, requested, act, utils.BoundThumbprint(claims), existingUser.GrantsExpireAt())
+	if err != nil {
+		return TokenExchangeResponse{}, err
+	}
+
+	return TokenExchangeResponse{
+		AccessToken:     accessToken,
+		IssuedTokenType: utils.AccessTokenType,
+		TokenType:       "Bearer",
+		ExpiresIn:       int64(expiration.Seconds()),
+		Scope:           strings.Join(requested, " "),
+	}, nil
+}
+
+// authenticateClient checks the credentials of a service allowed to exchange tokens
+func authenticateClient(app *app.Apps, clientID string, clientSecret string) bool {
+	if clientID == "" || clientSecret == "" {
+		return false
+	}
+
+	for _, client := range app.Config.Security.TokenExchangeClients {
+		id, secret, ok := strings.Cut(strings.TrimSpace(client), ":")
+		if !ok || id != clientID {
+			continue
+		}
+		return subtle.ConstantTimeCompare([]byte(secret), []byte(clientSecret)) == 1
+	}
+
+	return false
+}
+
+// subjectAudienceAllowed reports whether the subject token may be exchanged by
+// the client: first-party tokens, tokens for this API and tokens issued to the
+// client by an earlier exchange
+func subjectAudienceAllowed(app *app.Apps, claims jwt.MapClaims, clientID string) bool {
+	if utils.AcceptsAudience(app, claims) {
+		return true
+	}
+
+	audiences, err := claims.GetAudience()
+	return err == nil && slices.Contains(audiences, clientID)
+}
+
+// canDelegate reports whether a permission may be down-scoped into an exchanged token
+func canDelegate(app *app.Apps, held []string, previousScope []string, name string) bool {
+	if previousScope != nil && !slices.Contains(previousScope, name) {
+		return false
+	}
+
+	if slices.Contains(held, name) {
+		return true
+	}
+
+	// Permissions covered by a held pattern, manage:system included, may be
+	// delegated as long as they are registered
+	return permission.Compile(held).Allows(name) && permission.Registered(name, app.Permissions.Names())
+}
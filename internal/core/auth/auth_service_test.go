@@ -0,0 +1,202 @@
+package auth
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/HasanNugroho/starter-golang/config"
+	"github.com/HasanNugroho/starter-golang/internal/app"
+	"github.com/HasanNugroho/starter-golang/internal/core/attributes"
+	"github.com/HasanNugroho/starter-golang/internal/core/roles"
+	"github.com/HasanNugroho/starter-golang/internal/core/users"
+	"github.com/HasanNugroho/starter-golang/internal/shared/permission"
+	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+const testSecret = "test-secret"
+
+type stubUserRepository struct {
+	users.IUserRepository
+	user users.UserModel
+}
+
+func (s *stubUserRepository) FindById(ctx echo.Context, id string) (users.UserModel, error) {
+	if id != s.user.ID.Hex() {
+		return users.UserModel{}, utils.NewNotFound("data not found")
+	}
+	return s.user, nil
+}
+
+type stubAttributeService struct {
+	attributes.IAttributeService
+}
+
+func (s *stubAttributeService) TokenClaims(ctx echo.Context, values map[string]interface{}) (map[string]interface{}, error) {
+	return map[string]interface{}{}, nil
+}
+
+func newTestApp(t *testing.T) *app.Apps {
+	apps := &app.Apps{Config: &config.Config{}, Permissions: permission.NewRegistry()}
+	apps.Config.AppName = "api"
+	apps.Config.Security.JWTSecretKey = testSecret
+	apps.Config.Security.TokenExchangeClients = []string{"reports:s3cret", "billing:other"}
+	require.NoError(t, apps.Permissions.Declare(
+		permission.Definition{Name: "users:read", Description: "Read users", Category: "users", Risk: permission.RiskLow},
+		permission.Definition{Name: "users:update", Description: "Update users", Category: "users", Risk: permission.RiskMedium},
+	))
+	return apps
+}
+
+// newSubjectToken signs an access token for the user with the extra claims
+func newSubjectToken(t *testing.T, userID string, extra jwt.MapClaims) string {
+	claims := jwt.MapClaims{
+		"data":               map[string]interface{}{"id": userID},
+		"exp":                time.Now().Add(time.Minute).Unix(),
+		utils.TokenTypeClaim: utils.TokenTypeAccess,
+	}
+	for key, value := range extra {
+		claims[key] = value
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(testSecret))
+	require.NoError(t, err)
+	return token
+}
+
+func TestAuthenticateClient(t *testing.T) {
+	apps := newTestApp(t)
+
+	tests := []struct {
+		name     string
+		clientID string
+		secret   string
+		want     bool
+	}{
+		{"valid", "reports", "s3cret", true},
+		{"wrong secret", "reports", "nope", false},
+		{"secret of another client", "reports", "other", false},
+		{"unknown client", "unknown", "s3cret", false},
+		{"missing id", "", "s3cret", false},
+		{"missing secret", "reports", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, authenticateClient(apps, tt.clientID, tt.secret))
+		})
+	}
+}
+
+func TestCanDelegate(t *testing.T) {
+	apps := newTestApp(t)
+
+	tests := []struct {
+		name     string
+		held     []string
+		previous []string
+		scope    string
+		want     bool
+	}{
+		{"held permission", []string{"users:read"}, nil, "users:read", true},
+		{"not held", []string{"users:read"}, nil, "users:update", false},
+		{"covered by a held pattern", []string{"users:*"}, nil, "users:update", true},
+		{"covered but not registered", []string{"users:*"}, nil, "users:purge", false},
+		{"system admin", []string{permission.System}, nil, "users:update", true},
+		{"within the previous scope", []string{"users:*"}, []string{"users:read"}, "users:read", true},
+		{"outside the previous scope", []string{"users:*"}, []string{"users:read"}, "users:update", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, canDelegate(apps, tt.held, tt.previous, tt.scope))
+		})
+	}
+}
+
+func TestAuthService_ExchangeToken(t *testing.T) {
+	apps := newTestApp(t)
+	user := users.UserModel{
+		ID:        bson.NewObjectID(),
+		Email:     "jane@example.com",
+		Status:    utils.StatusActive,
+		RolesData: []roles.RoleModel{{Permissions: []string{"users:read"}}},
+	}
+	service := NewAuthService(&stubUserRepository{user: user}, &stubAttributeService{})
+	ctx := echo.New().NewContext(httptest.NewRequest("POST", "/", nil), httptest.NewRecorder())
+
+	request := func(subject string, clientID string, secret string, scope string) *TokenExchangeRequest {
+		return &TokenExchangeRequest{
+			GrantType:        utils.TokenExchangeGrantType,
+			SubjectToken:     subject,
+			SubjectTokenType: utils.AccessTokenType,
+			Audience:         "reports",
+			Scope:            scope,
+			ClientID:         clientID,
+			ClientSecret:     secret,
+		}
+	}
+	firstParty := newSubjectToken(t, user.ID.Hex(), nil)
+
+	failures := []struct {
+		name string
+		req  *TokenExchangeRequest
+		err  error
+	}{
+		{"unsupported grant type", &TokenExchangeRequest{GrantType: "password"}, utils.NewBadRequest("unsupported grant_type")},
+		{"unsupported token type", &TokenExchangeRequest{GrantType: utils.TokenExchangeGrantType, SubjectTokenType: "id_token"}, utils.NewBadRequest("unsupported subject_token_type")},
+		{"wrong client secret", request(firstParty, "reports", "nope", "users:read"), utils.NewUnauthorized("invalid client credentials")},
+		{"invalid subject token", request("not-a-token", "reports", "s3cret", "users:read"), utils.NewBadRequest("subject token is invalid")},
+		{"refresh token as subject", request(newSubjectToken(t, user.ID.Hex(), jwt.MapClaims{utils.TokenTypeClaim: utils.TokenTypeRefresh}), "reports", "s3cret", "users:read"),
+			utils.NewBadRequest("subject token is not an access token")},
+		{"subject token for another service", request(newSubjectToken(t, user.ID.Hex(), jwt.MapClaims{"aud": "billing"}), "reports", "s3cret", "users:read"),
+			utils.NewBadRequest("subject token audience is not accepted")},
+		{"permission not held", request(firstParty, "reports", "s3cret", "users:update"), utils.NewBadRequest("invalid_scope: users:update")},
+		{"widening an exchanged token", request(newSubjectToken(t, user.ID.Hex(), jwt.MapClaims{"aud": "reports", "scope": "users:list"}), "reports", "s3cret", "users:read"),
+			utils.NewBadRequest("invalid_scope: users:read")},
+	}
+	for _, tt := range failures {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.ExchangeToken(ctx, apps, tt.req)
+			assert.Equal(t, tt.err, err)
+		})
+	}
+
+	t.Run("keeps the DPoP binding", func(t *testing.T) {
+		subject := newSubjectToken(t, user.ID.Hex(), jwt.MapClaims{"cnf": map[string]interface{}{"jkt": "thumbprint"}})
+
+		response, err := service.ExchangeToken(ctx, apps, request(subject, "reports", "s3cret", "users:read"))
+		require.NoError(t, err)
+		assert.Equal(t, "users:read", response.Scope)
+
+		token, err := utils.ValidateToken(apps, response.AccessToken)
+		require.NoError(t, err)
+		claims := token.Claims.(jwt.MapClaims)
+		assert.Equal(t, "thumbprint", utils.BoundThumbprint(claims))
+		assert.Equal(t, "reports", claims["aud"])
+	})
+
+	t.Run("re-exchanges a token issued to the client", func(t *testing.T) {
+		subject := newSubjectToken(t, user.ID.Hex(), jwt.MapClaims{"aud": "reports", "scope": "users:read"})
+
+		_, err := service.ExchangeToken(ctx, apps, request(subject, "reports", "s3cret", "users:read"))
+		assert.NoError(t, err)
+	})
+}
+
+func TestAuthService_GenerateAccessToken_NotRefreshToken(t *testing.T) {
+	apps := newTestApp(t)
+	service := NewAuthService(&stubUserRepository{}, &stubAttributeService{})
+
+	// An access token can't renew itself
+	access := newSubjectToken(t, bson.NewObjectID().Hex(), nil)
+	req := httptest.NewRequest("POST", "/", strings.NewReader(`{"refresh_token":"`+access+`"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	ctx := echo.New().NewContext(req, httptest.NewRecorder())
+
+	_, err := service.GenerateAccessToken(ctx, apps)
+	assert.Equal(t, utils.NewForbidden("refresh token is invalid"), err)
+}
@@ -2,6 +2,7 @@ package auth
 
 import (
 	"github.com/HasanNugroho/starter-golang/internal/app"
+	"github.com/HasanNugroho/starter-golang/internal/core/attributes"
 	"github.com/HasanNugroho/starter-golang/internal/core/users"
 	"github.com/HasanNugroho/starter-golang/internal/shared/middleware"
 	"github.com/labstack/echo/v4"
@@ -13,7 +14,7 @@ type AuthModule struct {
 
 func NewAuthModule(app *app.Apps) *AuthModule {
 	userRepository := users.NewUserRepository(app)
-	authService := NewAuthService(userRepository)
+	authService := NewAuthService(userRepository, attributes.NewAttributeService(app, attributes.NewAttributeRepository(app)))
 	AuthHandler := NewAuthHandler(authService, app)
 	return &AuthModule{
 		Handler: AuthHandler,
@@ -22,6 +23,9 @@ func NewAuthModule(app *app.Apps) *AuthModule {
 
 func (u *AuthModule) Register(app *app.Apps) error {
 	app.Log.Info().Msg("Auth Module Initialized")
+
+	// Refresh tokens tell when and how long a user has been signed in
+	app.PersonalData.Register(sessionsDataSource(app))
 	return nil
 }
 
@@ -31,6 +35,7 @@ func (a *AuthModule) Route(router *echo.Group, app *app.Apps) {
 		authRoutes.POST("/login", a.Handler.Login)
 		authRoutes.POST("/register", a.Handler.Register)
 		authRoutes.POST("/refresh-token", a.Handler.GenerateAccessToken)
+		authRoutes.POST("/token-exchange", a.Handler.ExchangeToken)
 
 		authRoutes.Use(middleware.AuthMiddleware(app))
 		authRoutes.POST("/logout", a.Handler.Logout)
@@ -0,0 +1,23 @@
+package entities
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// AttributeDefinition is a custom user attribute defined by admins.
+// Values are stored in the attributes of the user document under the key.
+type AttributeDefinition struct {
+	ID           bson.ObjectID `bson:"_id,omitempty" json:"id"`
+	Key          string        `bson:"key" json:"key"`
+	Label        string        `bson:"label" json:"label"`
+	Type         string        `bson:"type" json:"type"`
+	Required     bool          `bson:"required" json:"required"`
+	Pattern      string        `bson:"pattern,omitempty" json:"pattern,omitempty"`
+	Enum         []string      `bson:"enum,omitempty" json:"enum,omitempty"`
+	SelfEditable bool          `bson:"self_editable" json:"self_editable"`
+	InToken      bool          `bson:"in_token" json:"in_token"`
+	CreatedAt    time.Time     `bson:"created_at,omitempty" json:"created_at,omitempty"`
+	UpdatedAt    time.Time     `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+}
@@ -0,0 +1,21 @@
+package entities
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Group bundles users under roles they all inherit.
+// Membership is stored on the users, like their directly assigned roles.
+// Groups of an organization can only hold roles of the same organization.
+type Group struct {
+	ID           bson.ObjectID   `bson:"_id,omitempty" json:"id"`
+	Name         string          `bson:"name" json:"name"`
+	Description  string          `bson:"description,omitempty" json:"description,omitempty"`
+	Roles        []bson.ObjectID `bson:"roles" json:"roles"`
+	Organization *bson.ObjectID  `bson:"organization,omitempty" json:"organization,omitempty"`
+	Version      int64           `bson:"version" json:"version"`
+	CreatedAt    time.Time       `bson:"created_at,omitempty" json:"created_at,omitempty"`
+	UpdatedAt    time.Time       `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+}
@@ -0,0 +1,35 @@
+package entities
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type ImportJob struct {
+	ID         bson.ObjectID   `bson:"_id,omitempty" json:"id"`
+	Format     string          `bson:"format" json:"format"`
+	Status     string          `bson:"status" json:"status"`
+	Roles      []bson.ObjectID `bson:"roles" json:"roles"`
+	Total      int             `bson:"total" json:"total"`
+	Created    int             `bson:"created" json:"created"`
+	Duplicates int             `bson:"duplicates" json:"duplicates"`
+	Invalid    int             `bson:"invalid" json:"invalid"`
+	Failed     int             `bson:"failed" json:"failed"`
+	Error      string          `bson:"error,omitempty" json:"error,omitempty"`
+	CreatedBy  *bson.ObjectID  `bson:"created_by,omitempty" json:"created_by,omitempty"`
+	CreatedAt  time.Time       `bson:"created_at" json:"created_at"`
+	FinishedAt *time.Time      `bson:"finished_at,omitempty" json:"finished_at,omitempty"`
+	LeaseUntil *time.Time      `bson:"lease_until,omitempty" json:"-"`
+}
+
+// ImportRowResult is the outcome of a single row of an import, stored in its
+// own collection so large imports don't grow the job document
+type ImportRowResult struct {
+	Job       bson.ObjectID `bson:"job" json:"-"`
+	Row       int           `bson:"row" json:"row"`
+	Email     string        `bson:"email" json:"email"`
+	Status    string        `bson:"status" json:"status"`
+	Message   string        `bson:"message,omitempty" json:"message,omitempty"`
+	CreatedAt time.Time     `bson:"created_at" json:"-"`
+}
@@ -0,0 +1,41 @@
+package entities
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Organization is a tenant. The roles and groups it owns and its members are
+// only visible while it is the active tenant of a request.
+type Organization struct {
+	ID        bson.ObjectID  `bson:"_id,omitempty" json:"id"`
+	Name      string         `bson:"name" json:"name"`
+	Slug      string         `bson:"slug" json:"slug"`
+	CreatedBy *bson.ObjectID `bson:"created_by,omitempty" json:"created_by,omitempty"`
+	Version   int64          `bson:"version" json:"version"`
+	CreatedAt time.Time      `bson:"created_at,omitempty" json:"created_at,omitempty"`
+	UpdatedAt time.Time      `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+}
+
+// Membership puts a user in an organization with roles the organization owns.
+// Memberships are stored on the users, like their groups.
+type Membership struct {
+	Organization bson.ObjectID   `bson:"organization" json:"organization"`
+	Roles        []bson.ObjectID `bson:"roles" json:"roles"`
+	JoinedAt     time.Time       `bson:"joined_at" json:"joined_at"`
+}
+
+// Invitation asks the owner of an email address to join an organization.
+// Only the hash of the token sent by email is stored.
+type Invitation struct {
+	ID           bson.ObjectID   `bson:"_id,omitempty" json:"id"`
+	Organization bson.ObjectID   `bson:"organization" json:"organization"`
+	Email        string          `bson:"email" json:"email"`
+	Roles        []bson.ObjectID `bson:"roles" json:"roles"`
+	Key          string          `bson:"key" json:"-"`
+	InvitedBy    *bson.ObjectID  `bson:"invited_by,omitempty" json:"invited_by,omitempty"`
+	ExpiresAt    time.Time       `bson:"expires_at" json:"expires_at"`
+	AcceptedAt   *time.Time      `bson:"accepted_at,omitempty" json:"accepted_at,omitempty"`
+	CreatedAt    time.Time       `bson:"created_at" json:"created_at"`
+}
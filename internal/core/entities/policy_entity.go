@@ -0,0 +1,20 @@
+package entities
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Policy is an access policy managed through the API, it grants or denies
+// actions on the resources its condition holds for
+type Policy struct {
+	ID         bson.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name       string        `bson:"name" json:"name"`
+	Effect     string        `bson:"effect" json:"effect"`
+	Actions    []string      `bson:"actions" json:"actions"`
+	Permission string        `bson:"permission,omitempty" json:"permission,omitempty"`
+	Condition  string        `bson:"condition" json:"condition"`
+	CreatedAt  time.Time     `bson:"created_at,omitempty" json:"created_at,omitempty"`
+	UpdatedAt  time.Time     `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+}
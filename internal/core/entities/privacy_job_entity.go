@@ -0,0 +1,25 @@
+package entities
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// PrivacyJob is a data export or erasure run for a data-subject request
+type PrivacyJob struct {
+	ID          bson.ObjectID  `bson:"_id,omitempty" json:"id"`
+	Type        string         `bson:"type" json:"type"`
+	UserID      bson.ObjectID  `bson:"user_id" json:"user_id"`
+	Status      string         `bson:"status" json:"status"`
+	Sections    []string       `bson:"sections" json:"sections"`
+	ArchiveKey  string         `bson:"archive_key,omitempty" json:"-"`
+	ArchiveURL  string         `bson:"-" json:"archive_url,omitempty"`
+	Error       string         `bson:"error,omitempty" json:"error,omitempty"`
+	RequestedBy *bson.ObjectID `bson:"requested_by,omitempty" json:"requested_by,omitempty"`
+	CreatedAt   time.Time      `bson:"created_at" json:"created_at"`
+	FinishedAt  *time.Time     `bson:"finished_at,omitempty" json:"finished_at,omitempty"`
+	ExpiresAt   *time.Time     `bson:"expires_at,omitempty" json:"expires_at,omitempty"`
+	LeaseUntil  *time.Time     `bson:"lease_until,omitempty" json:"-"`
+	Attempts    int            `bson:"attempts,omitempty" json:"-"`
+}
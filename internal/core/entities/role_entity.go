@@ -7,9 +7,26 @@ import (
 )
 
 type Role struct {
-	ID          bson.ObjectID `bson:"_id,omitempty" json:"id"`
-	Name        string        `bson:"name" json:"name"`
-	Permissions []string      `bson:"permissions" json:"permissions"`
-	CreatedAt   time.Time     `bson:"created_at,omitempty" json:"created_at,omitempty"`
-	UpdatedAt   time.Time     `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+	ID           bson.ObjectID   `bson:"_id,omitempty" json:"id"`
+	Name         string          `bson:"name" json:"name"`
+	Permissions  []string        `bson:"permissions" json:"permissions"`
+	Parents      []bson.ObjectID `bson:"parents,omitempty" json:"parents,omitempty"`
+	IsSystem     bool            `bson:"is_system,omitempty" json:"is_system,omitempty"`
+	Organization *bson.ObjectID  `bson:"organization,omitempty" json:"organization,omitempty"`
+	Version      int64           `bson:"version" json:"version"`
+	CreatedAt    time.Time       `bson:"created_at,omitempty" json:"created_at,omitempty"`
+	UpdatedAt    time.Time       `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+	DeletedAt    *time.Time      `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"`
+	DeletedBy    *bson.ObjectID  `bson:"deleted_by,omitempty" json:"deleted_by,omitempty"`
+}
+
+// RoleAssignment grants a role to a user for a time window, an open end
+// never expires
+type RoleAssignment struct {
+	Role       bson.ObjectID  `bson:"role" json:"role"`
+	StartsAt   *time.Time     `bson:"starts_at,omitempty" json:"starts_at,omitempty"`
+	ExpiresAt  *time.Time     `bson:"expires_at,omitempty" json:"expires_at,omitempty"`
+	Note       string         `bson:"note" json:"note"`
+	AssignedBy *bson.ObjectID `bson:"assigned_by,omitempty" json:"assigned_by,omitempty"`
+	AssignedAt time.Time      `bson:"assigned_at" json:"assigned_at"`
 }
@@ -7,11 +7,40 @@ import (
 )
 
 type User struct {
-	ID        bson.ObjectID   `bson:"_id,omitempty" json:"id"`
-	Email     string          `bson:"email" json:"email"`
-	Name      string          `bson:"name" json:"name"`
-	Password  string          `bson:"password" json:"password"`
-	Roles     []bson.ObjectID `bson:"roles" json:"roles"`
-	CreatedAt time.Time       `bson:"created_at,omitempty" json:"created_at,omitempty"`
-	UpdatedAt time.Time       `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+	ID              bson.ObjectID          `bson:"_id,omitempty" json:"id"`
+	Email           string                 `bson:"email" json:"email"`
+	Name            string                 `bson:"name" json:"name"`
+	Password        string                 `bson:"password" json:"password"`
+	Roles           []bson.ObjectID        `bson:"roles" json:"roles"`
+	RoleAssignments []RoleAssignment       `bson:"role_assignments,omitempty" json:"role_assignments,omitempty"`
+	Groups          []bson.ObjectID        `bson:"groups,omitempty" json:"groups,omitempty"`
+	Memberships     []Membership           `bson:"memberships,omitempty" json:"memberships,omitempty"`
+	Status          string                 `bson:"status,omitempty" json:"status,omitempty"`
+	StatusReason    string                 `bson:"status_reason,omitempty" json:"status_reason,omitempty"`
+	Preferences     map[string]interface{} `bson:"preferences,omitempty" json:"preferences,omitempty"`
+	Attributes      map[string]interface{} `bson:"attributes,omitempty" json:"attributes,omitempty"`
+	PendingEmail    *PendingEmail          `bson:"pending_email,omitempty" json:"-"`
+	Avatar          *Avatar                `bson:"avatar,omitempty" json:"avatar,omitempty"`
+	Version         int64                  `bson:"version" json:"version"`
+	CreatedAt       time.Time              `bson:"created_at,omitempty" json:"created_at,omitempty"`
+	UpdatedAt       time.Time              `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+	DeletedAt       *time.Time             `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"`
+	DeletedBy       *bson.ObjectID         `bson:"deleted_by,omitempty" json:"deleted_by,omitempty"`
+}
+
+// PendingEmail is an email change waiting to be confirmed from the new address.
+// Only hashes of the confirm and cancel tokens are stored.
+type PendingEmail struct {
+	Email      string    `bson:"email"`
+	ConfirmKey string    `bson:"confirm_key"`
+	CancelKey  string    `bson:"cancel_key"`
+	ExpiresAt  time.Time `bson:"expires_at"`
+}
+
+// Avatar points at the resized profile pictures of a user in file storage.
+// Every upload gets a new version so old links never show the new picture.
+type Avatar struct {
+	Version   string    `bson:"version" json:"version"`
+	Sizes     []int     `bson:"sizes" json:"sizes"`
+	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
 }
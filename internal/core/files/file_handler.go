@@ -0,0 +1,69 @@
+package files
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/HasanNugroho/starter-golang/internal/app"
+	"github.com/HasanNugroho/starter-golang/internal/shared/storage"
+	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
+	"github.com/labstack/echo/v4"
+)
+
+type FileHandler struct {
+	app *app.Apps
+}
+
+func NewFileHandler(app *app.Apps) *FileHandler {
+	return &FileHandler{
+		app: app,
+	}
+}
+
+// Download godoc
+// @Summary      Download file
+// @Description  Download a stored file with a signed link, links are handed out by other endpoints and expire
+// @Tags         files
+// @Produce      octet-stream
+// @Param        key  path  string  true  "file key"
+// @Param        expires  query  int  true  "unix time the link expires at"
+// @Param        signature  query  string  true  "link signature"
+// @Success      200  {file}  file
+// @Failure      403  {object}  shared.Response
+// @Failure      404  {object}  shared.Response
+// @Router       /files/{key} [get]
+func (h *FileHandler) Download(ctx echo.Context) error {
+	key, err := url.PathUnescape(ctx.Param("*"))
+	if err != nil {
+		return utils.NewNotFound("file not found")
+	}
+
+	expires := ctx.QueryParam("expires")
+	if !h.app.Files.Verify(key, expires, ctx.QueryParam("signature")) {
+		return utils.NewForbidden("link is invalid or expired")
+	}
+
+	reader, object, err := h.app.Storage.Get(ctx.Request().Context(), key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return utils.NewNotFound("file not found")
+		}
+		return err
+	}
+	defer reader.Close()
+
+	// Let clients cache the file for as long as the link is valid
+	if expiresAt, err := strconv.ParseInt(expires, 10, 64); err == nil {
+		maxAge := max(int64(time.Until(time.Unix(expiresAt, 0)).Seconds()), 0)
+		ctx.Response().Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", maxAge))
+	}
+
+	if object.Size > 0 {
+		ctx.Response().Header().Set(echo.HeaderContentLength, strconv.FormatInt(object.Size, 10))
+	}
+	return ctx.Stream(http.StatusOK, object.ContentType, reader)
+}
@@ -0,0 +1,30 @@
+package files
+
+import (
+	"github.com/HasanNugroho/starter-golang/internal/app"
+	"github.com/labstack/echo/v4"
+)
+
+type FileModule struct {
+	Handler *FileHandler
+}
+
+func NewFileModule(app *app.Apps) *FileModule {
+	fileHandler := NewFileHandler(app)
+	return &FileModule{
+		Handler: fileHandler,
+	}
+}
+
+func (m *FileModule) Register(app *app.Apps) error {
+	app.Log.Info().Msg("File Module Initialized")
+	return nil
+}
+
+func (m *FileModule) Route(router *echo.Group, app *app.Apps) {
+	fileRoutes := router.Group("/v1/files")
+	{
+		// Links are signed and expiring, the signature is the credential
+		fileRoutes.GET("/*", m.Handler.Download)
+	}
+}
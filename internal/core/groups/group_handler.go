@@ -0,0 +1,272 @@
+package groups
+
+import (
+	"github.com/HasanNugroho/starter-golang/internal/shared/query"
+	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+type GroupHandler struct {
+	groupService IGroupService
+	validate     *validator.Validate
+}
+
+func NewGroupHandler(gs IGroupService) *GroupHandler {
+	return &GroupHandler{
+		groupService: gs,
+		validate:     validator.New(),
+	}
+}
+
+// CreateGroup godoc
+// @Summary      Create a group
+// @Description  Create a group, its members inherit the roles of the group. Giving it roles requires roles:assign.
+// @Tags         groups
+// @Accept       json
+// @Produce      json
+// @Param        group  body  GroupUpdateModel  true  "Group Data"
+// @Success      201  {object}  shared.Response
+// @Failure      400  {object}  shared.Response
+// @Failure      403  {object}  shared.Response
+// @Failure      409  {object}  shared.Response
+// @Failure      500  {object}  shared.Response
+// @Router       /groups [post]
+// @Security ApiKeyAuth
+func (c *GroupHandler) Create(ctx echo.Context) error {
+	var group GroupUpdateModel
+	ctx.Bind(&group)
+
+	if err := c.validate.Struct(group); err != nil {
+		return utils.NewBadRequest(err.Error())
+	}
+
+	if err := c.groupService.Create(ctx, &group); err != nil {
+		return err
+	}
+
+	utils.SendSuccess(ctx, 201, "group created successfully", nil)
+	return nil
+}
+
+// FindAllGroups godoc
+// @Summary      Get all groups
+// @Description  Retrieve a list of all groups
+// @Tags         groups
+// @Accept       json
+// @Produce      json
+// @Param limit query int false "total data per-page" minimum(1) maximum(100) default(10)
+// @Param page query int false "page" minimum(1) default(1)
+// @Param cursor query string false "opaque cursor from next_cursor or prev_cursor, send empty to start cursor pagination"
+// @Param include_total query bool false "count total items in cursor mode"
+// @Param search query string false "keyword matched against name and description"
+// @Param sort query string false "comma separated fields, prefix with - for descending" example(name)
+// @Param role_id query string false "groups granting the role"
+// @Param filter[field][operator] query string false "filter on name, role_id, created_at or updated_at"
+// @Success      200     {object}  shared.Response{data=shared.DataWithPagination{items=[]GroupModel}}
+// @Failure      500     {object}  shared.Response
+// @Router       /groups [get]
+// @Security ApiKeyAuth
+func (c *GroupHandler) FindAll(ctx echo.Context) error {
+	q, err := query.Parse(ctx.QueryParams(), GroupQuerySchema)
+	if err != nil {
+		return err
+	}
+
+	groups, err := c.groupService.FindAll(ctx, q)
+	if err != nil {
+		return err
+	}
+
+	utils.SendSuccess(ctx, 200, "groups retrieved successfully", groups)
+	return nil
+}
+
+// FindGroup godoc
+// @Summary      Get group
+// @Description  Retrieve a group by ID with its roles
+// @Tags         groups
+// @Accept       json
+// @Produce      json
+// @Param id path string true "id"
+// @Success      200     {object}  shared.Response{data=GroupModel}
+// @Header       200  {string}  ETag  "version of the returned document"
+// @Failure      404     {object}  shared.Response
+// @Failure      500     {object}  shared.Response
+// @Router       /groups/{id} [get]
+// @Security ApiKeyAuth
+func (c *GroupHandler) FindById(ctx echo.Context) error {
+	id := ctx.Param("id")
+
+	group, err := c.groupService.FindById(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	utils.SetETag(ctx, group.Version)
+	utils.SendSuccess(ctx, 200, "group retrieved successfully", group)
+	return nil
+}
+
+// UpdateGroup godoc
+// @Summary      Update group
+// @Description  Replace the name, description and roles of a group, omit roles to keep them. Adding roles requires roles:assign.
+// @Tags         groups
+// @Accept       json
+// @Produce      json
+// @Param id path string true "id"
+// @Param If-Match header string true "ETag from the last read, or * to skip the check"
+// @Param        group  body  GroupUpdateModel  true  "Group Data"
+// @Success      200  {object}  shared.Response
+// @Failure      400  {object}  shared.Response
+// @Failure      403  {object}  shared.Response
+// @Failure      404  {object}  shared.Response
+// @Failure      409  {object}  shared.Response
+// @Failure      412  {object}  shared.Response
+// @Failure      428  {object}  shared.Response
+// @Router       /groups/{id} [put]
+// @Security ApiKeyAuth
+func (c *GroupHandler) Update(ctx echo.Context) error {
+	id := ctx.Param("id")
+	var group GroupUpdateModel
+	ctx.Bind(&group)
+
+	if err := c.validate.Struct(group); err != nil {
+		return utils.NewBadRequest(err.Error())
+	}
+
+	if err := c.groupService.Update(ctx, id, &group); err != nil {
+		return err
+	}
+
+	utils.SendSuccess(ctx, 200, "group updated successfully", nil)
+	return nil
+}
+
+// DeleteGroup godoc
+// @Summary      Delete group
+// @Description  Delete a group, its members lose the roles they inherited from it
+// @Tags         groups
+// @Accept       json
+// @Produce      json
+// @Param id path string true "id"
+// @Param If-Match header string true "ETag from the last read, or * to skip the check"
+// @Success      200     {object}  shared.Response
+// @Failure      404     {object}  shared.Response
+// @Failure      412  {object}  shared.Response
+// @Failure      428  {object}  shared.Response
+// @Router       /groups/{id} [delete]
+// @Security ApiKeyAuth
+func (c *GroupHandler) Delete(ctx echo.Context) error {
+	if err := c.groupService.Delete(ctx, ctx.Param("id")); err != nil {
+		return err
+	}
+
+	utils.SendSuccess(ctx, 200, "group deleted successfully", nil)
+	return nil
+}
+
+// FindGroupMembers godoc
+// @Summary      Get group members
+// @Description  Retrieve the users of a group
+// @Tags         groups
+// @Accept       json
+// @Produce      json
+// @Param id path string true "id"
+// @Param limit query int false "total data per-page" minimum(1) maximum(100) default(10)
+// @Param page query int false "page" minimum(1) default(1)
+// @Param cursor query string false "opaque cursor from next_cursor or prev_cursor, send empty to start cursor pagination"
+// @Param search query string false "keyword matched against email and name"
+// @Param sort query string false "comma separated fields, prefix with - for descending" example(name)
+// @Param filter[field][operator] query string false "filter on email, name or status"
+// @Success      200     {object}  shared.Response{data=shared.DataWithPagination{items=[]GroupMemberModel}}
+// @Failure      404     {object}  shared.Response
+// @Router       /groups/{id}/members [get]
+// @Security ApiKeyAuth
+func (c *GroupHandler) FindMembers(ctx echo.Context) error {
+	q, err := query.Parse(ctx.QueryParams(), GroupMemberQuerySchema)
+	if err != nil {
+		return err
+	}
+
+	members, err := c.groupService.FindMembers(ctx, ctx.Param("id"), q)
+	if err != nil {
+		return err
+	}
+
+	utils.SendSuccess(ctx, 200, "group members retrieved successfully", members)
+	return nil
+}
+
+// AddGroupMembers godoc
+// @Summary      Add group members
+// @Description  Add users to a group, fails without changes when a user does not exist. Requires roles:assign when the group has roles.
+// @Tags         groups
+// @Accept       json
+// @Produce      json
+// @Param id path string true "id"
+// @Param        members  body  GroupMembersModel  true  "Users to add"
+// @Success      201  {object}  shared.Response
+// @Failure      400  {object}  shared.Response
+// @Failure      403  {object}  shared.Response
+// @Failure      404  {object}  shared.Response
+// @Router       /groups/{id}/members [post]
+// @Security ApiKeyAuth
+func (c *GroupHandler) AddMembers(ctx echo.Context) error {
+	var payload GroupMembersModel
+	ctx.Bind(&payload)
+
+	if err := c.validate.Struct(payload); err != nil {
+		return utils.NewBadRequest(err.Error())
+	}
+
+	if err := c.groupService.AddMembers(ctx, ctx.Param("id"), &payload); err != nil {
+		return err
+	}
+
+	utils.SendSuccess(ctx, 201, "group members added successfully", nil)
+	return nil
+}
+
+// RemoveGroupMember godoc
+// @Summary      Remove group member
+// @Description  Remove a user from a group, requires roles:unassign when the group has roles
+// @Tags         groups
+// @Accept       json
+// @Produce      json
+// @Param id path string true "id"
+// @Param userId path string true "user id"
+// @Success      200  {object}  shared.Response
+// @Failure      403  {object}  shared.Response
+// @Failure      404  {object}  shared.Response
+// @Router       /groups/{id}/members/{userId} [delete]
+// @Security ApiKeyAuth
+func (c *GroupHandler) RemoveMember(ctx echo.Context) error {
+	if err := c.groupService.RemoveMember(ctx, ctx.Param("id"), ctx.Param("userId")); err != nil {
+		return err
+	}
+
+	utils.SendSuccess(ctx, 200, "group member removed successfully", nil)
+	return nil
+}
+
+// FindUserGroups godoc
+// @Summary      Get user groups
+// @Description  Retrieve the groups a user is a member of
+// @Tags         groups
+// @Accept       json
+// @Produce      json
+// @Param id path string true "user id"
+// @Success      200  {object}  shared.Response{data=[]GroupModel}
+// @Failure      404  {object}  shared.Response
+// @Router       /users/{id}/groups [get]
+// @Security ApiKeyAuth
+func (c *GroupHandler) FindByUser(ctx echo.Context) error {
+	groups, err := c.groupService.FindByUser(ctx, ctx.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	utils.SendSuccess(ctx, 200, "user groups retrieved successfully", groups)
+	return nil
+}
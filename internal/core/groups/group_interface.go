@@ -0,0 +1,35 @@
+package groups
+
+import (
+	"github.com/HasanNugroho/starter-golang/internal/core/entities"
+	shared "github.com/HasanNugroho/starter-golang/internal/shared/model"
+	"github.com/HasanNugroho/starter-golang/internal/shared/query"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type IGroupRepository interface {
+	Create(ctx echo.Context, group *entities.Group) error
+	FindById(ctx echo.Context, id string) (GroupModel, error)
+	FindAll(ctx echo.Context, q *query.Query) (query.Page[GroupModel], error)
+	FindByUser(ctx echo.Context, userID string) ([]GroupModel, error)
+	Update(ctx echo.Context, id string, group *entities.Group) error
+	Delete(ctx echo.Context, id string) error
+	CountRoles(ctx echo.Context, ids []bson.ObjectID) (int64, error)
+	CountUsers(ctx echo.Context, ids []bson.ObjectID) (int64, error)
+	FindMembers(ctx echo.Context, id bson.ObjectID, q *query.Query) (query.Page[GroupMemberModel], error)
+	AddMembers(ctx echo.Context, id bson.ObjectID, userIDs []bson.ObjectID) error
+	RemoveMember(ctx echo.Context, id bson.ObjectID, userID string) error
+}
+
+type IGroupService interface {
+	Create(ctx echo.Context, group *GroupUpdateModel) error
+	FindById(ctx echo.Context, id string) (GroupModel, error)
+	FindAll(ctx echo.Context, q *query.Query) (shared.DataWithPagination, error)
+	FindByUser(ctx echo.Context, userID string) ([]GroupModel, error)
+	Update(ctx echo.Context, id string, group *GroupUpdateModel) error
+	Delete(ctx echo.Context, id string) error
+	FindMembers(ctx echo.Context, id string, q *query.Query) (shared.DataWithPagination, error)
+	AddMembers(ctx echo.Context, id string, payload *GroupMembersModel) error
+	RemoveMember(ctx echo.Context, id string, userID string) error
+}
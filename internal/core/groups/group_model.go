@@ -0,0 +1,65 @@
+package groups
+
+import (
+	"time"
+
+	"github.com/HasanNugroho/starter-golang/internal/core/roles"
+	"github.com/HasanNugroho/starter-golang/internal/shared/query"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type GroupModel struct {
+	ID           bson.ObjectID     `bson:"_id" json:"id"`
+	Name         string            `bson:"name" json:"name"`
+	Description  string            `bson:"description,omitempty" json:"description,omitempty"`
+	Roles        []bson.ObjectID   `bson:"roles" json:"roles"`
+	RolesData    []roles.RoleModel `bson:"roles_data,omitempty" json:"roles_data,omitempty"`
+	Organization *bson.ObjectID    `bson:"organization,omitempty" json:"organization,omitempty"`
+	Version      int64             `bson:"version" json:"version"`
+	CreatedAt    time.Time         `bson:"created_at" json:"created_at"`
+	UpdatedAt    time.Time         `bson:"updated_at" json:"updated_at"`
+}
+
+type GroupUpdateModel struct {
+	Name        string   `json:"name" validate:"required"`
+	Description string   `json:"description"`
+	Roles       []string `json:"roles"`
+}
+
+type GroupMembersModel struct {
+	UserIDs []string `json:"user_ids" validate:"required,min=1"`
+}
+
+// GroupMemberModel is a user listed as a member of a group
+type GroupMemberModel struct {
+	ID     bson.ObjectID `bson:"_id" json:"id"`
+	Email  string        `bson:"email" json:"email"`
+	Name   string        `bson:"name" json:"name"`
+	Status string        `bson:"status" json:"status"`
+}
+
+// GroupQuerySchema whitelists the fields usable to filter and sort groups
+var GroupQuerySchema = query.Schema{
+	Fields: map[string]query.Field{
+		"name":       {Type: query.String, Sortable: true},
+		"role_id":    {Name: "roles", Type: query.ObjectID},
+		"created_at": {Type: query.Time, Sortable: true},
+		"updated_at": {Type: query.Time, Sortable: true},
+	},
+	Search:      []string{"name", "description"},
+	DefaultSort: "name",
+	Aliases: map[string]query.Alias{
+		"role_id": {Field: "role_id", Operator: query.Eq},
+	},
+}
+
+// GroupMemberQuerySchema whitelists the fields usable to filter and sort the members of a group
+var GroupMemberQuerySchema = query.Schema{
+	Fields: map[string]query.Field{
+		"email":  {Type: query.String, Sortable: true},
+		"name":   {Type: query.String, Sortable: true},
+		"status": {Type: query.String, Operators: []query.Operator{query.Eq, query.Ne, query.In, query.Nin}},
+	},
+	Search:      []string{"email", "name"},
+	DefaultSort: "name",
+}
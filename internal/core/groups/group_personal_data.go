@@ -0,0 +1,73 @@
+package groups
+
+import (
+	"context"
+	"errors"
+
+	"github.com/HasanNugroho/starter-golang/internal/app"
+	"github.com/HasanNugroho/starter-golang/internal/core/entities"
+	"github.com/HasanNugroho/starter-golang/internal/shared/modules"
+	"github.com/HasanNugroho/starter-golang/internal/shared/permission"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// GroupPersonalDataModel is a group the user belongs to in a personal data archive
+type GroupPersonalDataModel struct {
+	ID           bson.ObjectID  `json:"id"`
+	Name         string         `json:"name"`
+	Description  string         `json:"description,omitempty"`
+	Organization *bson.ObjectID `json:"organization,omitempty"`
+}
+
+// membershipDataSource declares the group memberships stored on the users as personal data
+func membershipDataSource(app *app.Apps) modules.PersonalDataSource {
+	users := app.DB.Collection("users")
+	groups := app.DB.Collection("groups")
+
+	return modules.PersonalDataSource{
+		Name: "groups",
+		Export: func(ctx context.Context, subject modules.DataSubject, archive modules.PersonalDataArchive) error {
+			var user struct {
+				Groups []bson.ObjectID `bson:"groups"`
+			}
+			opts := options.FindOne().SetProjection(bson.M{"groups": 1})
+			err := users.FindOne(ctx, bson.M{"_id": subject.ID}, opts).Decode(&user)
+			if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+				return err
+			}
+
+			exported := []GroupPersonalDataModel{}
+			if len(user.Groups) > 0 {
+				cursor, err := groups.Find(ctx, bson.M{"_id": bson.M{"$in": user.Groups}})
+				if err != nil {
+					return err
+				}
+
+				var found []entities.Group
+				if err := cursor.All(ctx, &found); err != nil {
+					return err
+				}
+				for _, group := range found {
+					exported = append(exported, GroupPersonalDataModel{
+						ID:           group.ID,
+						Name:         group.Name,
+						Description:  group.Description,
+						Organization: group.Organization,
+					})
+				}
+			}
+
+			return archive.AddJSON("groups.json", exported)
+		},
+		Erase: func(ctx context.Context, subject modules.DataSubject) error {
+			if _, err := users.UpdateOne(ctx, bson.M{"_id": subject.ID}, bson.M{"$unset": bson.M{"groups": ""}}); err != nil {
+				return err
+			}
+
+			app.Bus.Emit(permission.ChangedEvent, permission.Changed{UserIDs: []string{subject.ID.Hex()}})
+			return nil
+		},
+	}
+}
@@ -0,0 +1,248 @@
+package groups
+
+import (
+	"time"
+
+	"github.com/HasanNugroho/starter-golang/internal/app"
+	"github.com/HasanNugroho/starter-golang/internal/core/entities"
+	"github.com/HasanNugroho/starter-golang/internal/shared/query"
+	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+type GroupRepository struct {
+	app        *app.Apps
+	collection query.Collection
+	users      query.Collection
+	roles      query.Collection
+}
+
+func NewGroupRepository(app *app.Apps) *GroupRepository {
+	return &GroupRepository{
+		app:        app,
+		collection: query.Owned(app.DB.Collection("groups")),
+		users:      query.Members(app.DB.Collection("users")),
+		roles:      query.Owned(app.DB.Collection("roles")),
+	}
+}
+
+func (r *GroupRepository) Create(ctx echo.Context, group *entities.Group) error {
+	c := ctx.Request().Context()
+
+	if _, err := r.collection.Unscoped().InsertOne(c, group); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return utils.NewConflict("group already exists")
+		}
+		return utils.NewInternal("failed to create group")
+	}
+
+	return nil
+}
+
+func (r *GroupRepository) FindById(ctx echo.Context, id string) (GroupModel, error) {
+	c := ctx.Request().Context()
+
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return GroupModel{}, utils.NewBadRequest("invalid id format")
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"_id": objectID}}},
+		{{
+			Key: "$lookup",
+			Value: bson.D{
+				{Key: "from", Value: "roles"},
+				{Key: "localField", Value: "roles"},
+				{Key: "foreignField", Value: "_id"},
+				{Key: "pipeline", Value: mongo.Pipeline{{{Key: "$match", Value: query.NotDeleted()}}}},
+				{Key: "as", Value: "roles_data"},
+			},
+		}},
+	}
+
+	cursor, err := r.collection.For(ctx).Aggregate(c, pipeline)
+	if err != nil {
+		return GroupModel{}, utils.NewInternal("failed to query data")
+	}
+	defer cursor.Close(c)
+
+	var groups []GroupModel
+	if err := cursor.All(c, &groups); err != nil {
+		return GroupModel{}, utils.NewInternal("failed to decode data")
+	}
+
+	if len(groups) == 0 {
+		return GroupModel{}, utils.NewNotFound("data not found")
+	}
+
+	return groups[0], nil
+}
+
+func (r *GroupRepository) FindAll(ctx echo.Context, q *query.Query) (query.Page[GroupModel], error) {
+	c := ctx.Request().Context()
+
+	return query.FindIn[GroupModel](c, r.collection.For(ctx), q, []byte(r.app.Config.Security.CursorKey()))
+}
+
+// FindByUser lists the groups of the active tenant the user is a member of
+func (r *GroupRepository) FindByUser(ctx echo.Context, userID string) ([]GroupModel, error) {
+	c := ctx.Request().Context()
+
+	objectID, err := bson.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, utils.NewBadRequest("invalid user id")
+	}
+
+	var user entities.User
+	if err := r.users.Unscoped().FindOne(c, bson.M{"_id": objectID, "deleted_at": nil}).Decode(&user); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, utils.NewNotFound("user not found")
+		}
+		return nil, utils.NewInternal("failed to query data")
+	}
+
+	groups := []GroupModel{}
+	if len(user.Groups) == 0 {
+		return groups, nil
+	}
+
+	cursor, err := r.collection.For(ctx).Find(c, bson.M{"_id": bson.M{"$in": user.Groups}})
+	if err != nil {
+		return nil, utils.NewInternal("failed to query data")
+	}
+	if err := cursor.All(c, &groups); err != nil {
+		return nil, utils.NewInternal("failed to decode data")
+	}
+
+	return groups, nil
+}
+
+func (r *GroupRepository) Update(ctx echo.Context, id string, group *entities.Group) error {
+	c := ctx.Request().Context()
+
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return utils.NewBadRequest("invalid id format")
+	}
+
+	groups := r.collection.For(ctx)
+	filter := bson.M{"_id": objectID}
+	result, err := groups.UpdateOne(c, utils.VersionedFilter(ctx, filter), query.BumpVersion(bson.M{
+		"$set": bson.M{
+			"name":        group.Name,
+			"description": group.Description,
+			"roles":       group.Roles,
+			"updated_at":  time.Now(),
+		}}))
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return utils.NewConflict("group already exists")
+		}
+		return utils.NewInternal("failed to update data")
+	}
+
+	if result.MatchedCount == 0 {
+		return groups.NotMatched(c, filter)
+	}
+
+	return nil
+}
+
+// Delete removes the group and takes its members out of it
+func (r *GroupRepository) Delete(ctx echo.Context, id string) error {
+	c := ctx.Request().Context()
+
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return utils.NewBadRequest("invalid id format")
+	}
+
+	groups := r.collection.For(ctx)
+	filter := bson.M{"_id": objectID}
+	result, err := groups.DeleteOne(c, utils.VersionedFilter(ctx, filter))
+	if err != nil {
+		return utils.NewInternal("failed to delete data")
+	}
+
+	if result.DeletedCount == 0 {
+		return groups.NotMatched(c, filter)
+	}
+
+	_, err = r.users.Unscoped().UpdateMany(c, bson.M{"groups": objectID}, query.BumpVersion(bson.M{
+		"$pull": bson.M{"groups": objectID},
+	}))
+	if err != nil {
+		return utils.NewInternal("failed to remove group members")
+	}
+
+	return nil
+}
+
+// CountRoles counts the roles among ids that exist, are not deleted and belong
+// to the active tenant
+func (r *GroupRepository) CountRoles(ctx echo.Context, ids []bson.ObjectID) (int64, error) {
+	c := ctx.Request().Context()
+
+	count, err := r.roles.For(ctx).CountDocuments(c, bson.M{"_id": bson.M{"$in": ids}, "deleted_at": nil})
+	if err != nil {
+		return 0, utils.NewInternal("failed to query data")
+	}
+	return count, nil
+}
+
+// CountUsers counts the users among ids that exist, are not deleted and are
+// members of the active tenant
+func (r *GroupRepository) CountUsers(ctx echo.Context, ids []bson.ObjectID) (int64, error) {
+	c := ctx.Request().Context()
+
+	count, err := r.users.For(ctx).CountDocuments(c, bson.M{"_id": bson.M{"$in": ids}, "deleted_at": nil})
+	if err != nil {
+		return 0, utils.NewInternal("failed to query data")
+	}
+	return count, nil
+}
+
+func (r *GroupRepository) FindMembers(ctx echo.Context, id bson.ObjectID, q *query.Query) (query.Page[GroupMemberModel], error) {
+	c := ctx.Request().Context()
+
+	return query.Find[GroupMemberModel](c, r.users.Unscoped(), q.WithoutDeleted().Where(bson.M{"groups": id}), []byte(r.app.Config.Security.CursorKey()))
+}
+
+func (r *GroupRepository) AddMembers(ctx echo.Context, id bson.ObjectID, userIDs []bson.ObjectID) error {
+	c := ctx.Request().Context()
+
+	filter := bson.M{"_id": bson.M{"$in": userIDs}, "deleted_at": nil, "groups": bson.M{"$ne": id}}
+	_, err := r.users.Unscoped().UpdateMany(c, filter, query.BumpVersion(bson.M{
+		"$addToSet": bson.M{"groups": id},
+	}))
+	if err != nil {
+		return utils.NewInternal("failed to add group members")
+	}
+
+	return nil
+}
+
+func (r *GroupRepository) RemoveMember(ctx echo.Context, id bson.ObjectID, userID string) error {
+	c := ctx.Request().Context()
+
+	objectUserID, err := bson.ObjectIDFromHex(userID)
+	if err != nil {
+		return utils.NewBadRequest("invalid user id")
+	}
+
+	result, err := r.users.Unscoped().UpdateOne(c, bson.M{"_id": objectUserID, "groups": id}, query.BumpVersion(bson.M{
+		"$pull": bson.M{"groups": id},
+	}))
+	if err != nil {
+		return utils.NewInternal("failed to remove group member")
+	}
+
+	if result.MatchedCount == 0 {
+		return utils.NewNotFound("user is not a member of the group")
+	}
+
+	return nil
+}
@@ -0,0 +1,26 @@
+package groups
+
+import (
+	"github.com/HasanNugroho/starter-golang/internal/shared/modules"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// groupSchema declares the groups collection, names are unique within an
+// organization and among global groups
+var groupSchema = modules.CollectionSchema{
+	Name: "groups",
+	Indexes: []modules.IndexSpec{
+		{Name: "organization_name_unique", Keys: bson.D{{Key: "organization", Value: 1}, {Key: "name", Value: 1}}, Unique: true},
+		{Name: "roles", Keys: bson.D{{Key: "roles", Value: 1}}},
+	},
+	Validator: bson.M{
+		"bsonType": "object",
+		"required": bson.A{"name", "roles"},
+		"properties": bson.M{
+			"name":         bson.M{"bsonType": "string", "minLength": 1},
+			"roles":        bson.M{"bsonType": "array", "items": bson.M{"bsonType": "objectId"}},
+			"organization": bson.M{"bsonType": "objectId"},
+			"version":      bson.M{"bsonType": bson.A{"int", "long"}},
+		},
+	},
+}
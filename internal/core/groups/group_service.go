@@ -0,0 +1,238 @@
+package groups
+
+import (
+	"slices"
+	"time"
+
+	"github.com/HasanNugroho/starter-golang/internal/app"
+	"github.com/HasanNugroho/starter-golang/internal/core/entities"
+	shared "github.com/HasanNugroho/starter-golang/internal/shared/model"
+	"github.com/HasanNugroho/starter-golang/internal/shared/permission"
+	"github.com/HasanNugroho/starter-golang/internal/shared/query"
+	"github.com/HasanNugroho/starter-golang/internal/shared/tenant"
+	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type GroupService struct {
+	repo IGroupRepository
+	app  *app.Apps
+}
+
+func NewGroupService(app *app.Apps, repo IGroupRepository) *GroupService {
+	return &GroupService{
+		repo: repo,
+		app:  app,
+	}
+}
+
+func (s *GroupService) Create(ctx echo.Context, group *GroupUpdateModel) error {
+	roleIDs, err := s.roleIDs(ctx, group.Roles)
+	if err != nil {
+		return err
+	}
+
+	if len(roleIDs) > 0 {
+		if err := s.holds(ctx, "roles:assign"); err != nil {
+			return err
+		}
+	}
+
+	// Groups created inside a tenant belong to it
+	payload := entities.Group{
+		Name:         group.Name,
+		Description:  group.Description,
+		Roles:        roleIDs,
+		Organization: tenant.ID(ctx),
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}
+
+	return s.repo.Create(ctx, &payload)
+}
+
+func (s *GroupService) FindById(ctx echo.Context, id string) (GroupModel, error) {
+	return s.repo.FindById(ctx, id)
+}
+
+func (s *GroupService) FindAll(ctx echo.Context, q *query.Query) (shared.DataWithPagination, error) {
+	page, err := s.repo.FindAll(ctx, q)
+	if err != nil {
+		return shared.DataWithPagination{}, err
+	}
+
+	return page.Response(q), nil
+}
+
+func (s *GroupService) FindByUser(ctx echo.Context, userID string) ([]GroupModel, error) {
+	return s.repo.FindByUser(ctx, userID)
+}
+
+func (s *GroupService) Update(ctx echo.Context, id string, group *GroupUpdateModel) error {
+	current, err := s.repo.FindById(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := utils.CheckVersion(ctx, current.Version); err != nil {
+		return err
+	}
+
+	updated := entities.Group{
+		Name:        group.Name,
+		Description: group.Description,
+		Roles:       current.Roles,
+	}
+
+	// A missing role list keeps the roles of the group
+	if group.Roles != nil {
+		if updated.Roles, err = s.roleIDs(ctx, group.Roles); err != nil {
+			return err
+		}
+	}
+
+	for _, roleID := range updated.Roles {
+		if slices.Contains(current.Roles, roleID) {
+			continue
+		}
+		if err := s.holds(ctx, "roles:assign"); err != nil {
+			return err
+		}
+		break
+	}
+
+	if err := s.repo.Update(ctx, id, &updated); err != nil {
+		return err
+	}
+
+	if !slices.Equal(current.Roles, updated.Roles) {
+		s.app.Bus.Emit(permission.ChangedEvent, permission.Changed{})
+	}
+	return nil
+}
+
+func (s *GroupService) Delete(ctx echo.Context, id string) error {
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return err
+	}
+
+	// The members lose the roles of the group
+	s.app.Bus.Emit(permission.ChangedEvent, permission.Changed{})
+	return nil
+}
+
+func (s *GroupService) FindMembers(ctx echo.Context, id string, q *query.Query) (shared.DataWithPagination, error) {
+	group, err := s.repo.FindById(ctx, id)
+	if err != nil {
+		return shared.DataWithPagination{}, err
+	}
+
+	page, err := s.repo.FindMembers(ctx, group.ID, q)
+	if err != nil {
+		return shared.DataWithPagination{}, err
+	}
+
+	return page.Response(q), nil
+}
+
+func (s *GroupService) AddMembers(ctx echo.Context, id string, payload *GroupMembersModel) error {
+	group, err := s.repo.FindById(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	// New members get the roles of the group
+	if len(group.Roles) > 0 {
+		if err := s.holds(ctx, "roles:assign"); err != nil {
+			return err
+		}
+	}
+
+	userIDs, err := objectIDs(payload.UserIDs)
+	if err != nil {
+		return utils.NewBadRequest("invalid user id")
+	}
+
+	// Add everyone or no one, a partial membership change is hard to spot
+	count, err := s.repo.CountUsers(ctx, userIDs)
+	if err != nil {
+		return err
+	}
+	if count != int64(len(userIDs)) {
+		return utils.NewBadRequest("users not found")
+	}
+
+	if err := s.repo.AddMembers(ctx, group.ID, userIDs); err != nil {
+		return err
+	}
+
+	s.app.Bus.Emit(permission.ChangedEvent, permission.Changed{UserIDs: payload.UserIDs})
+	return nil
+}
+
+func (s *GroupService) RemoveMember(ctx echo.Context, id string, userID string) error {
+	group, err := s.repo.FindById(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if len(group.Roles) > 0 {
+		if err := s.holds(ctx, "roles:unassign"); err != nil {
+			return err
+		}
+	}
+
+	if err := s.repo.RemoveMember(ctx, group.ID, userID); err != nil {
+		return err
+	}
+
+	s.app.Bus.Emit(permission.ChangedEvent, permission.Changed{UserIDs: []string{userID}})
+	return nil
+}
+
+// holds refuses handing out or taking away roles through a group without the
+// permission doing so directly needs, groups:* alone must not grant any role
+func (s *GroupService) holds(ctx echo.Context, name string) error {
+	if !s.app.Authz.Holds(ctx, name) {
+		return utils.NewForbidden("changing the roles of group members requires " + name)
+	}
+	return nil
+}
+
+// roleIDs converts the role ids of a request, every role must exist
+func (s *GroupService) roleIDs(ctx echo.Context, ids []string) ([]bson.ObjectID, error) {
+	roleIDs, err := objectIDs(ids)
+	if err != nil {
+		return nil, utils.NewBadRequest("invalid role id")
+	}
+
+	if len(roleIDs) == 0 {
+		return roleIDs, nil
+	}
+
+	count, err := s.repo.CountRoles(ctx, roleIDs)
+	if err != nil {
+		return nil, err
+	}
+	if count != int64(len(roleIDs)) {
+		return nil, utils.NewBadRequest("roles not found")
+	}
+
+	return roleIDs, nil
+}
+
+// objectIDs parses hex ids, dropping duplicates
+func objectIDs(ids []string) ([]bson.ObjectID, error) {
+	result := make([]bson.ObjectID, 0, len(ids))
+	for _, id := range ids {
+		objectID, err := bson.ObjectIDFromHex(id)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(result, objectID) {
+			result = append(result, objectID)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,167 @@
+package groups_test
+
+import (
+	"testing"
+
+	"github.com/HasanNugroho/starter-golang/config"
+	"github.com/HasanNugroho/starter-golang/internal/app"
+	"github.com/HasanNugroho/starter-golang/internal/core/entities"
+	"github.com/HasanNugroho/starter-golang/internal/core/groups"
+	"github.com/HasanNugroho/starter-golang/internal/shared/authz"
+	"github.com/HasanNugroho/starter-golang/internal/shared/modules"
+	"github.com/HasanNugroho/starter-golang/internal/shared/permission"
+	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type MockGroupRepo struct {
+	groups.IGroupRepository
+	mock.Mock
+}
+
+func (m *MockGroupRepo) Create(ctx echo.Context, group *entities.Group) error {
+	args := m.Called(ctx, group)
+	return args.Error(0)
+}
+
+func (m *MockGroupRepo) FindById(ctx echo.Context, id string) (groups.GroupModel, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(groups.GroupModel), args.Error(1)
+}
+
+func (m *MockGroupRepo) Update(ctx echo.Context, id string, group *entities.Group) error {
+	args := m.Called(ctx, id, group)
+	return args.Error(0)
+}
+
+func (m *MockGroupRepo) CountRoles(ctx echo.Context, ids []bson.ObjectID) (int64, error) {
+	args := m.Called(ctx, ids)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockGroupRepo) CountUsers(ctx echo.Context, ids []bson.ObjectID) (int64, error) {
+	args := m.Called(ctx, ids)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockGroupRepo) AddMembers(ctx echo.Context, id bson.ObjectID, userIDs []bson.ObjectID) error {
+	args := m.Called(ctx, id, userIDs)
+	return args.Error(0)
+}
+
+func newTestService(repo groups.IGroupRepository) *groups.GroupService {
+	return groups.NewGroupService(&app.Apps{Config: &config.Config{}, Bus: modules.EventNew(), Authz: authz.NewEngine()}, repo)
+}
+
+// newTestContext is a request of a principal holding the permissions
+func newTestContext(permissions ...string) echo.Context {
+	ctx := echo.New().NewContext(nil, nil)
+	ctx.Set("granted_permissions", permissions)
+	return ctx
+}
+
+func TestGroupService_Create_DeduplicatesRoles(t *testing.T) {
+	mockRepo := new(MockGroupRepo)
+	service := newTestService(mockRepo)
+	ctx := newTestContext("groups:create", "roles:assign")
+	roleID := bson.NewObjectID()
+
+	mockRepo.On("CountRoles", ctx, []bson.ObjectID{roleID}).Return(int64(1), nil)
+	mockRepo.On("Create", ctx, mock.MatchedBy(func(group *entities.Group) bool {
+		return group.Name == "Finance" && len(group.Roles) == 1 && group.Roles[0] == roleID
+	})).Return(nil)
+
+	err := service.Create(ctx, &groups.GroupUpdateModel{Name: "Finance", Roles: []string{roleID.Hex(), roleID.Hex()}})
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGroupService_Create_UnknownRole(t *testing.T) {
+	mockRepo := new(MockGroupRepo)
+	service := newTestService(mockRepo)
+	ctx := echo.New().NewContext(nil, nil)
+
+	mockRepo.On("CountRoles", ctx, mock.Anything).Return(int64(1), nil)
+
+	err := service.Create(ctx, &groups.GroupUpdateModel{Name: "Finance", Roles: []string{bson.NewObjectID().Hex(), bson.NewObjectID().Hex()}})
+
+	assert.Equal(t, utils.NewBadRequest("roles not found"), err)
+	mockRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestGroupService_Update_KeepsRolesWhenOmitted(t *testing.T) {
+	mockRepo := new(MockGroupRepo)
+	service := newTestService(mockRepo)
+	ctx := echo.New().NewContext(nil, nil)
+	roles := []bson.ObjectID{bson.NewObjectID()}
+
+	mockRepo.On("FindById", ctx, "g1").Return(groups.GroupModel{Name: "Finance", Roles: roles, Version: 2}, nil)
+	mockRepo.On("Update", ctx, "g1", &entities.Group{Name: "Accounting", Roles: roles}).Return(nil)
+
+	err := service.Update(ctx, "g1", &groups.GroupUpdateModel{Name: "Accounting"})
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGroupService_AddMembers_MissingUser(t *testing.T) {
+	mockRepo := new(MockGroupRepo)
+	service := newTestService(mockRepo)
+	ctx := echo.New().NewContext(nil, nil)
+	groupID := bson.NewObjectID()
+
+	mockRepo.On("FindById", ctx, groupID.Hex()).Return(groups.GroupModel{ID: groupID}, nil)
+	mockRepo.On("CountUsers", ctx, mock.Anything).Return(int64(1), nil)
+
+	err := service.AddMembers(ctx, groupID.Hex(), &groups.GroupMembersModel{UserIDs: []string{bson.NewObjectID().Hex(), bson.NewObjectID().Hex()}})
+
+	assert.Equal(t, utils.NewBadRequest("users not found"), err)
+	mockRepo.AssertNotCalled(t, "AddMembers", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestGroupService_AddMembers_ForgetsPermissions(t *testing.T) {
+	mockRepo := new(MockGroupRepo)
+	apps := &app.Apps{Config: &config.Config{}, Bus: modules.EventNew(), Authz: authz.NewEngine()}
+	service := groups.NewGroupService(apps, mockRepo)
+	ctx := echo.New().NewContext(nil, nil)
+	groupID, userID := bson.NewObjectID(), bson.NewObjectID()
+
+	events := make(chan permission.Changed, 1)
+	apps.Bus.On(permission.ChangedEvent, func(payload any) {
+		events <- payload.(permission.Changed)
+	})
+
+	mockRepo.On("FindById", ctx, groupID.Hex()).Return(groups.GroupModel{ID: groupID}, nil)
+	mockRepo.On("CountUsers", ctx, []bson.ObjectID{userID}).Return(int64(1), nil)
+	mockRepo.On("AddMembers", ctx, groupID, []bson.ObjectID{userID}).Return(nil)
+
+	err := service.AddMembers(ctx, groupID.Hex(), &groups.GroupMembersModel{UserIDs: []string{userID.Hex()}})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{userID.Hex()}, (<-events).UserIDs)
+}
+
+func TestGroupService_RolesRequireAssign(t *testing.T) {
+	mockRepo := new(MockGroupRepo)
+	service := newTestService(mockRepo)
+	ctx := newTestContext("groups:create", "groups:update", "groups:members")
+	roleID, groupID := bson.NewObjectID(), bson.NewObjectID()
+	forbidden := utils.NewForbidden("changing the roles of group members requires roles:assign")
+
+	mockRepo.On("CountRoles", ctx, []bson.ObjectID{roleID}).Return(int64(1), nil)
+	mockRepo.On("FindById", ctx, groupID.Hex()).Return(groups.GroupModel{ID: groupID, Roles: []bson.ObjectID{roleID}}, nil)
+
+	err := service.Create(ctx, &groups.GroupUpdateModel{Name: "Admins", Roles: []string{roleID.Hex()}})
+	assert.Equal(t, forbidden, err)
+
+	// Joining a group with roles hands them out as well
+	err = service.AddMembers(ctx, groupID.Hex(), &groups.GroupMembersModel{UserIDs: []string{bson.NewObjectID().Hex()}})
+	assert.Equal(t, forbidden, err)
+
+	mockRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "AddMembers", mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,60 @@
+package groups
+
+import (
+	"github.com/HasanNugroho/starter-golang/internal/app"
+	"github.com/HasanNugroho/starter-golang/internal/shared/middleware"
+	"github.com/HasanNugroho/starter-golang/internal/shared/permission"
+	"github.com/labstack/echo/v4"
+)
+
+// permissions are the permissions the module declares
+var permissions = []permission.Definition{
+	{Name: "groups:create", Description: "Create groups", Category: "groups", Risk: permission.RiskMedium, DependsOn: []string{"groups:read"}},
+	{Name: "groups:read", Description: "List and view groups", Category: "groups", Risk: permission.RiskLow},
+	{Name: "groups:update", Description: "Change the name and roles of groups", Category: "groups", Risk: permission.RiskHigh, DependsOn: []string{"groups:read", "roles:read"}},
+	{Name: "groups:delete", Description: "Delete groups", Category: "groups", Risk: permission.RiskMedium, DependsOn: []string{"groups:read"}},
+	{Name: "groups:members", Description: "Add and remove group members", Category: "groups", Risk: permission.RiskHigh, DependsOn: []string{"groups:read", "users:read"}},
+}
+
+type GroupModule struct {
+	Handler *GroupHandler
+}
+
+func NewGroupModule(app *app.Apps) *GroupModule {
+	groupRepository := NewGroupRepository(app)
+	groupService := NewGroupService(app, groupRepository)
+	return &GroupModule{
+		Handler: NewGroupHandler(groupService),
+	}
+}
+
+func (m *GroupModule) Register(app *app.Apps) error {
+	app.Log.Info().Msg("Group Module Initialized")
+
+	if err := app.Permissions.Declare(permissions...); err != nil {
+		return err
+	}
+
+	app.Schema.Declare(groupSchema)
+	app.PersonalData.Register(membershipDataSource(app))
+
+	return nil
+}
+
+func (m *GroupModule) Route(router *echo.Group, app *app.Apps) {
+	route := router.Group("/v1/groups")
+	{
+		route.Use(middleware.AuthMiddleware(app))
+		route.POST("", m.Handler.Create, middleware.CheckAccess([]string{"groups:create"}))
+		route.GET("", m.Handler.FindAll, middleware.CheckAccess([]string{"groups:read"}))
+		route.GET("/:id", m.Handler.FindById, middleware.CheckAccess([]string{"groups:read"}))
+		route.PUT("/:id", m.Handler.Update, middleware.CheckAccess([]string{"groups:update"}), middleware.RequireIfMatch())
+		route.DELETE("/:id", m.Handler.Delete, middleware.CheckAccess([]string{"groups:delete"}), middleware.RequireIfMatch())
+		route.GET("/:id/members", m.Handler.FindMembers, middleware.CheckAccess([]string{"groups:read", "groups:members"}))
+		route.POST("/:id/members", m.Handler.AddMembers, middleware.CheckAccess([]string{"groups:members"}))
+		route.DELETE("/:id/members/:userId", m.Handler.RemoveMember, middleware.CheckAccess([]string{"groups:members"}))
+	}
+
+	// Listed under the users resource, so it carries its own middleware
+	router.GET("/v1/users/:id/groups", m.Handler.FindByUser, middleware.AuthMiddleware(app), middleware.CheckAccess([]string{"groups:read"}))
+}
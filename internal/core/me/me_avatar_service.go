@@ -0,0 +1,168 @@
+package me
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/HasanNugroho/starter-golang/internal/app"
+	"github.com/HasanNugroho/starter-golang/internal/core/entities"
+	"github.com/HasanNugroho/starter-golang/internal/shared/imaging"
+	"github.com/HasanNugroho/starter-golang/internal/shared/patch"
+	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// AvatarSizes are the square sizes in pixels every avatar is resized to
+var AvatarSizes = []int{64, 128, 256}
+
+var avatarContentTypes = []string{"image/jpeg", "image/png", "image/gif"}
+
+// AvatarKey is the storage key of one size of an avatar version
+func AvatarKey(userID string, version string, size int) string {
+	return fmt.Sprintf("avatars/%s/%s/%d.png", userID, version, size)
+}
+
+// avatarMaxSize is the largest accepted upload in bytes
+func avatarMaxSize(app *app.Apps) int64 {
+	if app.Config.Avatar.MaxSize <= 0 {
+		return 2048 * 1024
+	}
+	return int64(app.Config.Avatar.MaxSize) * 1024
+}
+
+// UploadAvatar validates the image, stores it in every avatar size and replaces the previous avatar
+func (m *MeService) UploadAvatar(ctx echo.Context, file io.Reader) (AvatarURLs, error) {
+	user, err := m.currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	maxSize := avatarMaxSize(m.app)
+	data, err := io.ReadAll(io.LimitReader(file, maxSize+1))
+	if err != nil {
+		return nil, utils.NewBadRequest("failed to read upload")
+	}
+	if int64(len(data)) > maxSize {
+		return nil, utils.NewBadRequest(fmt.Sprintf("avatar must be at most %d KB", maxSize/1024))
+	}
+
+	// Trust the content, not the file name or the declared content type
+	if !slices.Contains(avatarContentTypes, http.DetectContentType(data)) {
+		return nil, utils.NewBadRequest("avatar must be a JPEG, PNG or GIF image")
+	}
+
+	// Check the dimensions from the header before decoding the whole image
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, utils.NewBadRequest("avatar is not a valid image")
+	}
+	minDimension, maxDimension := m.avatarDimensions()
+	if config.Width < minDimension || config.Height < minDimension {
+		return nil, utils.NewBadRequest(fmt.Sprintf("avatar must be at least %dx%d pixels", minDimension, minDimension))
+	}
+	if config.Width > maxDimension || config.Height > maxDimension {
+		return nil, utils.NewBadRequest(fmt.Sprintf("avatar must be at most %dx%d pixels", maxDimension, maxDimension))
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, utils.NewBadRequest("avatar is not a valid image")
+	}
+
+	userID := user.ID.Hex()
+	avatar := entities.Avatar{
+		Version:   bson.NewObjectID().Hex(),
+		Sizes:     AvatarSizes,
+		UpdatedAt: time.Now(),
+	}
+
+	c := ctx.Request().Context()
+	for _, size := range avatar.Sizes {
+		var buffer bytes.Buffer
+		if err := png.Encode(&buffer, imaging.SquareThumbnail(img, size)); err != nil {
+			m.removeAvatar(ctx, userID, &avatar)
+			return nil, err
+		}
+
+		key := AvatarKey(userID, avatar.Version, size)
+		if err := m.app.Storage.Put(c, key, &buffer, int64(buffer.Len()), "image/png"); err != nil {
+			m.removeAvatar(ctx, userID, &avatar)
+			return nil, utils.NewInternal("failed to store avatar")
+		}
+	}
+
+	if err := m.repo.Patch(ctx, userID, patch.Changes{Set: bson.M{"avatar": avatar}}); err != nil {
+		m.removeAvatar(ctx, userID, &avatar)
+		return nil, err
+	}
+
+	m.removeAvatar(ctx, userID, user.Avatar)
+	return m.avatarURLs(userID, &avatar)
+}
+
+// DeleteAvatar removes the avatar of the current user
+func (m *MeService) DeleteAvatar(ctx echo.Context) error {
+	user, err := m.currentUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	if user.Avatar == nil {
+		return utils.NewNotFound("avatar not found")
+	}
+
+	changes := patch.Changes{Set: bson.M{}, Unset: bson.M{"avatar": ""}}
+	if err := m.repo.Patch(ctx, user.ID.Hex(), changes); err != nil {
+		return err
+	}
+
+	m.removeAvatar(ctx, user.ID.Hex(), user.Avatar)
+	return nil
+}
+
+func (m *MeService) avatarDimensions() (int, int) {
+	minDimension, maxDimension := m.app.Config.Avatar.MinDimension, m.app.Config.Avatar.MaxDimension
+	if minDimension <= 0 {
+		minDimension = 64
+	}
+	if maxDimension <= 0 {
+		maxDimension = 4096
+	}
+	return minDimension, maxDimension
+}
+
+func (m *MeService) avatarURLs(userID string, avatar *entities.Avatar) (AvatarURLs, error) {
+	urls := AvatarURLs{}
+	for _, size := range avatar.Sizes {
+		url, err := m.app.Files.URL(AvatarKey(userID, avatar.Version, size))
+		if err != nil {
+			return nil, err
+		}
+		urls[strconv.Itoa(size)] = url
+	}
+	return urls, nil
+}
+
+// removeAvatar deletes the stored files of an avatar version, failures only leave orphaned files behind
+func (m *MeService) removeAvatar(ctx echo.Context, userID string, avatar *entities.Avatar) {
+	if avatar == nil {
+		return
+	}
+
+	for _, size := range avatar.Sizes {
+		key := AvatarKey(userID, avatar.Version, size)
+		if err := m.app.Storage.Delete(ctx.Request().Context(), key); err != nil {
+			m.app.Log.Warn().Err(err).Msgf("Failed to delete avatar file %s", key)
+		}
+	}
+}
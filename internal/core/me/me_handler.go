@@ -0,0 +1,248 @@
+package me
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+type MeHandler struct {
+	meService IMeService
+	validate  *validator.Validate
+}
+
+func NewMeHandler(ms IMeService) *MeHandler {
+	return &MeHandler{
+		meService: ms,
+		validate:  validator.New(),
+	}
+}
+
+// Profile godoc
+// @Summary      Get my profile
+// @Description  Retrieve the profile of the authenticated user with effective roles and permissions
+// @Tags         me
+// @Accept       json
+// @Produce      json
+// @Success      200  {object}  shared.Response{data=ProfileModel}
+// @Failure      401  {object}  shared.Response
+// @Failure      500  {object}  shared.Response
+// @Router       /me [get]
+// @Security ApiKeyAuth
+func (c *MeHandler) Profile(ctx echo.Context) error {
+	profile, err := c.meService.Profile(ctx)
+	if err != nil {
+		return err
+	}
+
+	utils.SendSuccess(ctx, http.StatusOK, "profile retrieved successfully", profile)
+	return nil
+}
+
+// UpdateProfile godoc
+// @Summary      Update my profile
+// @Description  Update name and preferences with a JSON Merge Patch (RFC 7396) or a JSON Patch (RFC 6902)
+// @Tags         me
+// @Accept       application/merge-patch+json,application/json-patch+json,json
+// @Produce      json
+// @Param        profile  body  ProfileUpdateModel  true  "Profile patch"
+// @Success      200  {object}  shared.Response
+// @Failure      400  {object}  shared.Response
+// @Failure      401  {object}  shared.Response
+// @Failure      500  {object}  shared.Response
+// @Router       /me [patch]
+// @Security ApiKeyAuth
+func (c *MeHandler) Update(ctx echo.Context) error {
+	body, err := io.ReadAll(ctx.Request().Body)
+	if err != nil || len(body) == 0 {
+		return utils.NewBadRequest("patch body is required")
+	}
+
+	if err := c.meService.Update(ctx, ctx.Request().Header.Get(echo.HeaderContentType), body); err != nil {
+		return err
+	}
+
+	utils.SendSuccess(ctx, http.StatusOK, "profile updated successfully", nil)
+	return nil
+}
+
+// ChangePassword godoc
+// @Summary      Change my password
+// @Description  Change the password of the authenticated user. Every other session is signed out and a new token pair is returned.
+// @Tags         me
+// @Accept       json
+// @Produce      json
+// @Param        password  body  ChangePasswordModel  true  "Current and new password"
+// @Success      200  {object}  shared.Response{data=TokenResponse}
+// @Failure      400  {object}  shared.Response
+// @Failure      401  {object}  shared.Response
+// @Failure      500  {object}  shared.Response
+// @Router       /me/password [post]
+// @Security ApiKeyAuth
+func (c *MeHandler) ChangePassword(ctx echo.Context) error {
+	var payload ChangePasswordModel
+	if err := ctx.Bind(&payload); err != nil {
+		return utils.NewBadRequest("Invalid data format")
+	}
+
+	if err := c.validate.Struct(payload); err != nil {
+		return utils.NewBadRequest(err.Error())
+	}
+
+	tokens, err := c.meService.ChangePassword(ctx, &payload)
+	if err != nil {
+		return err
+	}
+
+	utils.SendSuccess(ctx, http.StatusOK, "password changed successfully", tokens)
+	return nil
+}
+
+// ChangeEmail godoc
+// @Summary      Change my email
+// @Description  Request an email change. A confirmation token is sent to the new address and a cancel token to the current one.
+// @Tags         me
+// @Accept       json
+// @Produce      json
+// @Param        email  body  ChangeEmailModel  true  "New email and current password"
+// @Success      202  {object}  shared.Response
+// @Failure      400  {object}  shared.Response
+// @Failure      401  {object}  shared.Response
+// @Failure      409  {object}  shared.Response
+// @Failure      500  {object}  shared.Response
+// @Router       /me/email [post]
+// @Security ApiKeyAuth
+func (c *MeHandler) ChangeEmail(ctx echo.Context) error {
+	var payload ChangeEmailModel
+	if err := ctx.Bind(&payload); err != nil {
+		return utils.NewBadRequest("Invalid data format")
+	}
+
+	if err := c.validate.Struct(payload); err != nil {
+		return utils.NewBadRequest(err.Error())
+	}
+
+	if err := c.meService.RequestEmailChange(ctx, &payload); err != nil {
+		return err
+	}
+
+	utils.SendSuccess(ctx, http.StatusAccepted, "confirmation sent to the new email address", nil)
+	return nil
+}
+
+// ConfirmEmail godoc
+// @Summary      Confirm email change
+// @Description  Confirm a pending email change with the token sent to the new address
+// @Tags         me
+// @Accept       json
+// @Produce      json
+// @Param        token  body  EmailChangeTokenModel  true  "Confirmation token"
+// @Success      200  {object}  shared.Response
+// @Failure      400  {object}  shared.Response
+// @Failure      409  {object}  shared.Response
+// @Failure      500  {object}  shared.Response
+// @Router       /me/email/confirm [post]
+func (c *MeHandler) ConfirmEmail(ctx echo.Context) error {
+	var payload EmailChangeTokenModel
+	if err := ctx.Bind(&payload); err != nil {
+		return utils.NewBadRequest("Invalid data format")
+	}
+
+	if err := c.validate.Struct(payload); err != nil {
+		return utils.NewBadRequest(err.Error())
+	}
+
+	if err := c.meService.ConfirmEmailChange(ctx, payload.Token); err != nil {
+		return err
+	}
+
+	utils.SendSuccess(ctx, http.StatusOK, "email changed successfully", nil)
+	return nil
+}
+
+// CancelEmail godoc
+// @Summary      Cancel email change
+// @Description  Cancel a pending email change with the token sent to the current address
+// @Tags         me
+// @Accept       json
+// @Produce      json
+// @Param        token  body  EmailChangeTokenModel  true  "Cancel token"
+// @Success      200  {object}  shared.Response
+// @Failure      400  {object}  shared.Response
+// @Failure      500  {object}  shared.Response
+// @Router       /me/email/cancel [post]
+func (c *MeHandler) CancelEmail(ctx echo.Context) error {
+	var payload EmailChangeTokenModel
+	if err := ctx.Bind(&payload); err != nil {
+		return utils.NewBadRequest("Invalid data format")
+	}
+
+	if err := c.validate.Struct(payload); err != nil {
+		return utils.NewBadRequest(err.Error())
+	}
+
+	if err := c.meService.CancelEmailChange(ctx, payload.Token); err != nil {
+		return err
+	}
+
+	utils.SendSuccess(ctx, http.StatusOK, "email change cancelled", nil)
+	return nil
+}
+
+// UploadAvatar godoc
+// @Summary      Upload my avatar
+// @Description  Upload a JPEG, PNG or GIF profile picture. It is cropped to a square and resized to 64, 128 and 256 pixels, the previous avatar is replaced. Returns signed, expiring download URLs per size.
+// @Tags         me
+// @Accept       multipart/form-data
+// @Produce      json
+// @Param        avatar  formData  file  true  "Image file"
+// @Success      200  {object}  shared.Response{data=AvatarURLs}
+// @Failure      400  {object}  shared.Response
+// @Failure      401  {object}  shared.Response
+// @Failure      413  {object}  shared.Response
+// @Failure      500  {object}  shared.Response
+// @Router       /me/avatar [put]
+// @Security ApiKeyAuth
+func (c *MeHandler) UploadAvatar(ctx echo.Context) error {
+	fileHeader, err := ctx.FormFile("avatar")
+	if err != nil {
+		return utils.NewBadRequest("avatar is required")
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return utils.NewBadRequest("failed to read upload")
+	}
+	defer file.Close()
+
+	urls, err := c.meService.UploadAvatar(ctx, file)
+	if err != nil {
+		return err
+	}
+
+	utils.SendSuccess(ctx, http.StatusOK, "avatar uploaded successfully", urls)
+	return nil
+}
+
+// DeleteAvatar godoc
+// @Summary      Delete my avatar
+// @Description  Remove the profile picture of the authenticated user
+// @Tags         me
+// @Produce      json
+// @Success      200  {object}  shared.Response
+// @Failure      401  {object}  shared.Response
+// @Failure      404  {object}  shared.Response
+// @Failure      500  {object}  shared.Response
+// @Router       /me/avatar [delete]
+// @Security ApiKeyAuth
+func (c *MeHandler) DeleteAvatar(ctx echo.Context) error {
+	if err := c.meService.DeleteAvatar(ctx); err != nil {
+		return err
+	}
+
+	utils.SendSuccess(ctx, http.StatusOK, "avatar deleted successfully", nil)
+	return nil
+}
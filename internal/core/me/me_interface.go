@@ -0,0 +1,18 @@
+package me
+
+import (
+	"io"
+
+	"github.com/labstack/echo/v4"
+)
+
+type IMeService interface {
+	Profile(ctx echo.Context) (ProfileModel, error)
+	Update(ctx echo.Context, contentType string, body []byte) error
+	ChangePassword(ctx echo.Context, payload *ChangePasswordModel) (TokenResponse, error)
+	RequestEmailChange(ctx echo.Context, payload *ChangeEmailModel) error
+	ConfirmEmailChange(ctx echo.Context, token string) error
+	CancelEmailChange(ctx echo.Context, token string) error
+	UploadAvatar(ctx echo.Context, file io.Reader) (AvatarURLs, error)
+	DeleteAvatar(ctx echo.Context) error
+}
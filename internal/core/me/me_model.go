@@ -0,0 +1,51 @@
+package me
+
+import (
+	"time"
+
+	"github.com/HasanNugroho/starter-golang/internal/core/roles"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type ProfileModel struct {
+	ID           bson.ObjectID          `json:"id"`
+	Email        string                 `json:"email"`
+	Name         string                 `json:"name"`
+	PendingEmail string                 `json:"pending_email,omitempty"`
+	Avatar       AvatarURLs             `json:"avatar,omitempty"`
+	Preferences  map[string]interface{} `json:"preferences,omitempty"`
+	Attributes   map[string]interface{} `json:"attributes,omitempty"`
+	Roles        []roles.RoleModel      `json:"roles"`
+	Permissions  []string               `json:"permissions"`
+	CreatedAt    time.Time              `json:"created_at"`
+	UpdatedAt    time.Time              `json:"updated_at"`
+}
+
+// ProfileUpdateModel is the part of the profile users may change themselves
+type ProfileUpdateModel struct {
+	Name        string                 `json:"name" bson:"name" validate:"required"`
+	Preferences map[string]interface{} `json:"preferences,omitempty" bson:"preferences,omitempty"`
+	Attributes  map[string]interface{} `json:"attributes,omitempty" bson:"attributes,omitempty"`
+}
+
+type ChangePasswordModel struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required,min=6,nefield=CurrentPassword"`
+}
+
+type ChangeEmailModel struct {
+	Email           string `json:"email" validate:"required,email"`
+	CurrentPassword string `json:"current_password" validate:"required"`
+}
+
+type EmailChangeTokenModel struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type TokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// AvatarURLs maps each avatar size in pixels to a signed, expiring download URL
+type AvatarURLs map[string]string
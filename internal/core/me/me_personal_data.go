@@ -0,0 +1,70 @@
+package me
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/HasanNugroho/starter-golang/internal/app"
+	"github.com/HasanNugroho/starter-golang/internal/core/entities"
+	"github.com/HasanNugroho/starter-golang/internal/shared/modules"
+	"github.com/HasanNugroho/starter-golang/internal/shared/storage"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// uploadsDataSource declares the files users upload themselves as personal data
+func uploadsDataSource(app *app.Apps) modules.PersonalDataSource {
+	findAvatar := func(ctx context.Context, subject modules.DataSubject) (*entities.Avatar, error) {
+		var user struct {
+			Avatar *entities.Avatar `bson:"avatar"`
+		}
+		opts := options.FindOne().SetProjection(bson.M{"avatar": 1})
+		err := app.DB.Collection("users").FindOne(ctx, bson.M{"_id": subject.ID}, opts).Decode(&user)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return user.Avatar, err
+	}
+
+	return modules.PersonalDataSource{
+		Name: "uploads",
+		Export: func(ctx context.Context, subject modules.DataSubject, archive modules.PersonalDataArchive) error {
+			avatar, err := findAvatar(ctx, subject)
+			if err != nil || avatar == nil {
+				return err
+			}
+
+			for _, size := range avatar.Sizes {
+				reader, _, err := app.Storage.Get(ctx, AvatarKey(subject.ID.Hex(), avatar.Version, size))
+				if errors.Is(err, storage.ErrNotFound) {
+					continue
+				}
+				if err != nil {
+					return err
+				}
+
+				err = archive.AddFile(fmt.Sprintf("uploads/avatar/%d.png", size), reader)
+				reader.Close()
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Erase: func(ctx context.Context, subject modules.DataSubject) error {
+			avatar, err := findAvatar(ctx, subject)
+			if err != nil || avatar == nil {
+				return err
+			}
+
+			for _, size := range avatar.Sizes {
+				if err := app.Storage.Delete(ctx, AvatarKey(subject.ID.Hex(), avatar.Version, size)); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,266 @@
+package me
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/HasanNugroho/starter-golang/internal/app"
+	"github.com/HasanNugroho/starter-golang/internal/core/attributes"
+	"github.com/HasanNugroho/starter-golang/internal/core/entities"
+	"github.com/HasanNugroho/starter-golang/internal/core/users"
+	"github.com/HasanNugroho/starter-golang/internal/shared/patch"
+	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type MeService struct {
+	repo       users.IUserRepository
+	attributes attributes.IAttributeService
+	app        *app.Apps
+}
+
+func NewMeService(app *app.Apps, repo users.IUserRepository, attributes attributes.IAttributeService) *MeService {
+	return &MeService{
+		repo:       repo,
+		attributes: attributes,
+		app:        app,
+	}
+}
+
+func (m *MeService) Profile(ctx echo.Context) (ProfileModel, error) {
+	user, err := m.currentUser(ctx)
+	if err != nil {
+		return ProfileModel{}, err
+	}
+
+	profile := ProfileModel{
+		ID:          user.ID,
+		Email:       user.Email,
+		Name:        user.Name,
+		Preferences: user.Preferences,
+		Attributes:  user.Attributes,
+		Roles:       user.RolesData,
+		Permissions: user.Permissions(),
+		CreatedAt:   user.CreatedAt,
+		UpdatedAt:   user.UpdatedAt,
+	}
+
+	if user.PendingEmail != nil && user.PendingEmail.ExpiresAt.After(time.Now()) {
+		profile.PendingEmail = user.PendingEmail.Email
+	}
+
+	if user.Avatar != nil {
+		if profile.Avatar, err = m.avatarURLs(user.ID.Hex(), user.Avatar); err != nil {
+			return ProfileModel{}, err
+		}
+	}
+
+	return profile, nil
+}
+
+func (m *MeService) Update(ctx echo.Context, contentType string, body []byte) error {
+	user, err := m.currentUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	current := ProfileUpdateModel{
+		Name:        user.Name,
+		Preferences: user.Preferences,
+		Attributes:  attributes.Plain(user.Attributes),
+	}
+
+	var updated ProfileUpdateModel
+	if err := patch.Apply(contentType, current, body, &updated); err != nil {
+		return err
+	}
+
+	changes, err := patch.Diff(current, updated)
+	if err != nil {
+		return err
+	}
+
+	_, changed := changes.Set["attributes"]
+	_, removed := changes.Unset["attributes"]
+	if changed || removed {
+		values, err := m.attributes.Validate(ctx, user.Attributes, updated.Attributes, true)
+		if err != nil {
+			return err
+		}
+		delete(changes.Unset, "attributes")
+		changes.Set["attributes"] = values
+	}
+
+	if changes.Empty() {
+		return nil
+	}
+
+	return m.repo.Patch(ctx, user.ID.Hex(), changes)
+}
+
+func (m *MeService) ChangePassword(ctx echo.Context, payload *ChangePasswordModel) (TokenResponse, error) {
+	user, err := m.currentUser(ctx)
+	if err != nil {
+		return TokenResponse{}, err
+	}
+
+	if !utils.VerifyPassword(user.Password, []byte(payload.CurrentPassword)) {
+		return TokenResponse{}, utils.NewBadRequest("current password is incorrect")
+	}
+
+	password, err := utils.HashPassword([]byte(payload.NewPassword))
+	if err != nil {
+		return TokenResponse{}, err
+	}
+
+	changes := patch.Changes{Set: bson.M{"password": password}}
+	if err := m.repo.Patch(ctx, user.ID.Hex(), changes); err != nil {
+		return TokenResponse{}, err
+	}
+
+	// Sign out every other session, this one continues with a fresh token pair
+	if err := utils.RevokeUserTokens(m.app, user.ID.Hex()); err != nil {
+		return TokenResponse{}, err
+	}
+
+	claims, err := m.attributes.TokenClaims(ctx, user.Attributes)
+	if err != nil {
+		return TokenResponse{}, err
+	}
+
+	tokenPayload := user.TokenPayload(claims)
+
+	var jkt string
+	if claims, ok := ctx.Get("claims").(jwt.MapClaims); ok {
+		jkt = utils.BoundThumbprint(claims)
+	}
+
+	accessToken, refreshToken, err := utils.GenerateAuthToken(m.app, utils.IdentityClaims(m.app, tokenPayload), jkt, user.GrantsExpireAt())
+	if err != nil {
+		return TokenResponse{}, err
+	}
+
+	return TokenResponse{
+		Token:        accessToken,
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+func (m *MeService) RequestEmailChange(ctx echo.Context, payload *ChangeEmailModel) error {
+	user, err := m.currentUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !utils.VerifyPassword(user.Password, []byte(payload.CurrentPassword)) {
+		return utils.NewBadRequest("current password is incorrect")
+	}
+
+	if payload.Email == user.Email {
+		return utils.NewBadRequest("new email is the same as the current one")
+	}
+
+	if _, err := m.repo.FindByEmail(ctx, payload.Email); err == nil {
+		return utils.NewConflict("email already exists")
+	}
+
+	confirmToken, confirmKey, err := utils.NewSecretToken()
+	if err != nil {
+		return err
+	}
+
+	cancelToken, cancelKey, err := utils.NewSecretToken()
+	if err != nil {
+		return err
+	}
+
+	expiration := time.Hour * time.Duration(m.app.Config.Security.EmailChangeExpired)
+	if expiration <= 0 {
+		expiration = 24 * time.Hour
+	}
+
+	pending := entities.PendingEmail{
+		Email:      payload.Email,
+		ConfirmKey: confirmKey,
+		CancelKey:  cancelKey,
+		ExpiresAt:  time.Now().Add(expiration),
+	}
+
+	changes := patch.Changes{Set: bson.M{"pending_email": pending}}
+	if err := m.repo.Patch(ctx, user.ID.Hex(), changes); err != nil {
+		return err
+	}
+
+	c := ctx.Request().Context()
+	err = m.app.Mailer.Send(c, payload.Email, "Confirm your new email address",
+		fmt.Sprintf("Hi %s,\n\nUse this token to confirm %s as the new email address of your account:\n\n%s\n\nThe token expires at %s.",
+			user.Name, payload.Email, confirmToken, pending.ExpiresAt.Format(time.RFC1123)))
+	if err != nil {
+		return utils.NewInternal("failed to send confirmation email")
+	}
+
+	err = m.app.Mailer.Send(c, user.Email, "Your email address is being changed",
+		fmt.Sprintf("Hi %s,\n\nA change of your account email to %s was requested. If this was not you, use this token to cancel it and change your password:\n\n%s",
+			user.Name, payload.Email, cancelToken))
+	if err != nil {
+		return utils.NewInternal("failed to send notification email")
+	}
+
+	return nil
+}
+
+func (m *MeService) ConfirmEmailChange(ctx echo.Context, token string) error {
+	user, err := m.pendingEmailChange(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	if user.PendingEmail.ConfirmKey != utils.SecretKey(token) {
+		return utils.NewBadRequest("email change token is invalid or expired")
+	}
+
+	changes := patch.Changes{
+		Set:   bson.M{"email": user.PendingEmail.Email},
+		Unset: bson.M{"pending_email": ""},
+	}
+
+	return m.repo.Patch(ctx, user.ID.Hex(), changes)
+}
+
+func (m *MeService) CancelEmailChange(ctx echo.Context, token string) error {
+	user, err := m.pendingEmailChange(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	if user.PendingEmail.CancelKey != utils.SecretKey(token) {
+		return utils.NewBadRequest("email change token is invalid or expired")
+	}
+
+	changes := patch.Changes{
+		Set:   bson.M{},
+		Unset: bson.M{"pending_email": ""},
+	}
+
+	return m.repo.Patch(ctx, user.ID.Hex(), changes)
+}
+
+func (m *MeService) currentUser(ctx echo.Context) (users.UserModel, error) {
+	userID := utils.CurrentUserID(ctx)
+	if userID == "" {
+		return users.UserModel{}, utils.NewUnauthorized("Unauthorized")
+	}
+
+	return m.repo.FindById(ctx, userID)
+}
+
+func (m *MeService) pendingEmailChange(ctx echo.Context, token string) (users.UserModel, error) {
+	user, err := m.repo.FindByEmailChangeKey(ctx, utils.SecretKey(token))
+	if err != nil || user.PendingEmail == nil || user.PendingEmail.ExpiresAt.Before(time.Now()) {
+		return users.UserModel{}, utils.NewBadRequest("email change token is invalid or expired")
+	}
+
+	return user, nil
+}
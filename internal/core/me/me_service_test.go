@@ -0,0 +1,226 @@
+package me_test
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/HasanNugroho/starter-golang/config"
+	"github.com/HasanNugroho/starter-golang/internal/app"
+	"github.com/HasanNugroho/starter-golang/internal/core/attributes"
+	"github.com/HasanNugroho/starter-golang/internal/core/entities"
+	"github.com/HasanNugroho/starter-golang/internal/core/me"
+	"github.com/HasanNugroho/starter-golang/internal/core/roles"
+	"github.com/HasanNugroho/starter-golang/internal/core/users"
+	"github.com/HasanNugroho/starter-golang/internal/shared/patch"
+	"github.com/HasanNugroho/starter-golang/internal/shared/storage"
+	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type MockUserRepo struct {
+	users.IUserRepository
+	mock.Mock
+}
+
+func (m *MockUserRepo) FindById(ctx echo.Context, id string) (users.UserModel, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(users.UserModel), args.Error(1)
+}
+
+func (m *MockUserRepo) FindByEmailChangeKey(ctx echo.Context, key string) (users.UserModel, error) {
+	args := m.Called(ctx, key)
+	return args.Get(0).(users.UserModel), args.Error(1)
+}
+
+func (m *MockUserRepo) Patch(ctx echo.Context, id string, changes patch.Changes) error {
+	args := m.Called(ctx, id, changes)
+	return args.Error(0)
+}
+
+// StubAttributeRepo serves a fixed set of attribute definitions
+type StubAttributeRepo struct {
+	attributes.IAttributeRepository
+	definitions []entities.AttributeDefinition
+}
+
+func (s *StubAttributeRepo) FindAll(ctx echo.Context) ([]entities.AttributeDefinition, error) {
+	return s.definitions, nil
+}
+
+const userID = "67f759abe02e4b2f3c2f69b6"
+
+func newTestContext() echo.Context {
+	ctx := echo.New().NewContext(nil, nil)
+	ctx.Set("claims", jwt.MapClaims{"data": map[string]interface{}{"id": userID}})
+	return ctx
+}
+
+func newTestService(repo users.IUserRepository) *me.MeService {
+	apps := &app.Apps{Config: &config.Config{}}
+	return me.NewMeService(apps, repo, attributes.NewAttributeService(apps, &StubAttributeRepo{}))
+}
+
+func TestMeService_Profile_MergesPermissions(t *testing.T) {
+	mockRepo := new(MockUserRepo)
+	service := newTestService(mockRepo)
+	ctx := newTestContext()
+
+	objectId, _ := bson.ObjectIDFromHex(userID)
+	mockRepo.On("FindById", ctx, userID).Return(users.UserModel{
+		ID:    objectId,
+		Email: "john@example.com",
+		RolesData: []roles.RoleModel{
+			{Name: "editor", Permissions: []string{"users:read", "users:update"}},
+			{Name: "viewer", Permissions: []string{"users:read"}},
+		},
+	}, nil)
+
+	profile, err := service.Profile(ctx)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "john@example.com", profile.Email)
+	assert.Equal(t, []string{"users:read", "users:update"}, profile.Permissions)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestMeService_ChangePassword_WrongCurrentPassword(t *testing.T) {
+	mockRepo := new(MockUserRepo)
+	service := newTestService(mockRepo)
+	ctx := newTestContext()
+
+	hashed, _ := utils.HashPassword([]byte("secret123"))
+	mockRepo.On("FindById", ctx, userID).Return(users.UserModel{Password: hashed}, nil)
+
+	_, err := service.ChangePassword(ctx, &me.ChangePasswordModel{CurrentPassword: "wrong", NewPassword: "newsecret"})
+
+	assert.IsType(t, &utils.BadRequestError{}, err)
+	mockRepo.AssertNotCalled(t, "Patch", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestMeService_ConfirmEmailChange_RejectsCancelToken(t *testing.T) {
+	mockRepo := new(MockUserRepo)
+	service := newTestService(mockRepo)
+	ctx := newTestContext()
+
+	objectId, _ := bson.ObjectIDFromHex(userID)
+	mockRepo.On("FindByEmailChangeKey", ctx, utils.SecretKey("cancel")).Return(users.UserModel{
+		ID: objectId,
+		PendingEmail: &entities.PendingEmail{
+			Email:      "new@example.com",
+			ConfirmKey: utils.SecretKey("confirm"),
+			CancelKey:  utils.SecretKey("cancel"),
+			ExpiresAt:  time.Now().Add(time.Hour),
+		},
+	}, nil)
+
+	err := service.ConfirmEmailChange(ctx, "cancel")
+
+	assert.IsType(t, &utils.BadRequestError{}, err)
+	mockRepo.AssertNotCalled(t, "Patch", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func newAvatarTestService(t *testing.T, repo users.IUserRepository) (*me.MeService, storage.Storage) {
+	store, err := storage.NewLocalStorage(t.TempDir())
+	assert.NoError(t, err)
+
+	logger := zerolog.Nop()
+	apps := &app.Apps{
+		Config:  &config.Config{},
+		Log:     &logger,
+		Storage: store,
+		Files:   storage.NewURLSigner(store, "secret", "/api/v1/files", time.Hour),
+	}
+	return me.NewMeService(apps, repo, attributes.NewAttributeService(apps, &StubAttributeRepo{})), store
+}
+
+func newAvatarTestContext() echo.Context {
+	request := httptest.NewRequest(http.MethodPut, "/api/v1/me/avatar", nil)
+	ctx := echo.New().NewContext(request, httptest.NewRecorder())
+	ctx.Set("claims", jwt.MapClaims{"data": map[string]interface{}{"id": userID}})
+	return ctx
+}
+
+func encodePNG(width int, height int) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	var buffer bytes.Buffer
+	png.Encode(&buffer, img)
+	return buffer.Bytes()
+}
+
+func TestMeService_UploadAvatar_StoresSizesAndReplacesPrevious(t *testing.T) {
+	mockRepo := new(MockUserRepo)
+	service, store := newAvatarTestService(t, mockRepo)
+	ctx := newAvatarTestContext()
+
+	objectId, _ := bson.ObjectIDFromHex(userID)
+	previous := &entities.Avatar{Version: "old", Sizes: []int{64}}
+	oldKey := me.AvatarKey(userID, "old", 64)
+	assert.NoError(t, store.Put(ctx.Request().Context(), oldKey, bytes.NewReader([]byte("old")), 3, "image/png"))
+
+	mockRepo.On("FindById", ctx, userID).Return(users.UserModel{ID: objectId, Avatar: previous}, nil)
+	mockRepo.On("Patch", ctx, userID, mock.MatchedBy(func(changes patch.Changes) bool {
+		avatar, ok := changes.Set["avatar"].(entities.Avatar)
+		return ok && avatar.Version != "old" && len(avatar.Sizes) == len(me.AvatarSizes)
+	})).Return(nil)
+
+	urls, err := service.UploadAvatar(ctx, bytes.NewReader(encodePNG(300, 200)))
+
+	assert.NoError(t, err)
+	assert.Len(t, urls, len(me.AvatarSizes))
+	assert.Contains(t, urls["128"], "/api/v1/files/avatars/"+userID+"/")
+	assert.Contains(t, urls["128"], "signature=")
+
+	avatar := mockRepo.Calls[1].Arguments.Get(2).(patch.Changes).Set["avatar"].(entities.Avatar)
+	for _, size := range me.AvatarSizes {
+		reader, object, err := store.Get(ctx.Request().Context(), me.AvatarKey(userID, avatar.Version, size))
+		assert.NoError(t, err)
+		thumb, err := png.Decode(reader)
+		reader.Close()
+		assert.NoError(t, err)
+		assert.Equal(t, image.Rect(0, 0, size, size), thumb.Bounds())
+		assert.Equal(t, "image/png", object.ContentType)
+	}
+
+	_, _, err = store.Get(ctx.Request().Context(), oldKey)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestMeService_UploadAvatar_Validation(t *testing.T) {
+	objectId, _ := bson.ObjectIDFromHex(userID)
+
+	tests := []struct {
+		name    string
+		body    []byte
+		message string
+	}{
+		{"not an image", []byte("hello, this is plain text"), "avatar must be a JPEG, PNG or GIF image"},
+		{"too small", encodePNG(32, 200), "avatar must be at least 64x64 pixels"},
+		{"too large", encodePNG(5000, 64), "avatar must be at most 4096x4096 pixels"},
+		{"file too big", append(encodePNG(64, 64), make([]byte, 2048*1024)...), "avatar must be at most 2048 KB"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockUserRepo)
+			service, _ := newAvatarTestService(t, mockRepo)
+			ctx := newAvatarTestContext()
+			mockRepo.On("FindById", ctx, userID).Return(users.UserModel{ID: objectId}, nil)
+
+			_, err := service.UploadAvatar(ctx, bytes.NewReader(tt.body))
+
+			assert.EqualError(t, err, "bad request: "+tt.message)
+			mockRepo.AssertNotCalled(t, "Patch", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
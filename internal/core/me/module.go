@@ -0,0 +1,51 @@
+package me
+
+import (
+	"fmt"
+
+	"github.com/HasanNugroho/starter-golang/internal/app"
+	"github.com/HasanNugroho/starter-golang/internal/core/attributes"
+	"github.com/HasanNugroho/starter-golang/internal/core/users"
+	"github.com/HasanNugroho/starter-golang/internal/shared/middleware"
+	"github.com/labstack/echo/v4"
+	echomw "github.com/labstack/echo/v4/middleware"
+)
+
+type MeModule struct {
+	Handler *MeHandler
+}
+
+func NewMeModule(app *app.Apps) *MeModule {
+	userRepository := users.NewUserRepository(app)
+	meService := NewMeService(app, userRepository, attributes.NewAttributeService(app, attributes.NewAttributeRepository(app)))
+	meHandler := NewMeHandler(meService)
+	return &MeModule{
+		Handler: meHandler,
+	}
+}
+
+func (m *MeModule) Register(app *app.Apps) error {
+	app.Log.Info().Msg("Me Module Initialized")
+
+	app.PersonalData.Register(uploadsDataSource(app))
+	return nil
+}
+
+func (m *MeModule) Route(router *echo.Group, app *app.Apps) {
+	meRoutes := router.Group("/v1/me")
+	{
+		// Email change links are opened from the mailbox, the token is the credential
+		meRoutes.POST("/email/confirm", m.Handler.ConfirmEmail)
+		meRoutes.POST("/email/cancel", m.Handler.CancelEmail)
+
+		meRoutes.Use(middleware.AuthMiddleware(app))
+		meRoutes.GET("", m.Handler.Profile)
+		meRoutes.PATCH("", m.Handler.Update)
+		meRoutes.POST("/password", m.Handler.ChangePassword)
+		meRoutes.POST("/email", m.Handler.ChangeEmail)
+		// Leave room for the multipart envelope around the image
+		avatarLimit := fmt.Sprintf("%dK", avatarMaxSize(app)/1024+64)
+		meRoutes.PUT("/avatar", m.Handler.UploadAvatar, echomw.BodyLimit(avatarLimit))
+		meRoutes.DELETE("/avatar", m.Handler.DeleteAvatar)
+	}
+}
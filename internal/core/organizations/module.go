@@ -0,0 +1,66 @@
+package organizations
+
+import (
+	"github.com/HasanNugroho/starter-golang/internal/app"
+	"github.com/HasanNugroho/starter-golang/internal/shared/middleware"
+	"github.com/HasanNugroho/starter-golang/internal/shared/permission"
+	"github.com/labstack/echo/v4"
+)
+
+// permissions are the permissions the module declares
+var permissions = []permission.Definition{
+	{Name: "organizations:create", Description: "Create organizations", Category: "organizations", Risk: permission.RiskHigh, DependsOn: []string{"organizations:read"}},
+	{Name: "organizations:read", Description: "List and view organizations", Category: "organizations", Risk: permission.RiskLow},
+	{Name: "organizations:update", Description: "Rename organizations and change their slug", Category: "organizations", Risk: permission.RiskMedium, DependsOn: []string{"organizations:read"}},
+	{Name: "organizations:members", Description: "Invite organization members and change or remove their roles", Category: "organizations", Risk: permission.RiskHigh, DependsOn: []string{"organizations:read", "roles:read"}},
+}
+
+type OrganizationModule struct {
+	Handler    *OrganizationHandler
+	repository *OrganizationRepository
+}
+
+func NewOrganizationModule(app *app.Apps) *OrganizationModule {
+	organizationRepository := NewOrganizationRepository(app)
+	organizationService := NewOrganizationService(app, organizationRepository)
+	return &OrganizationModule{
+		Handler:    NewOrganizationHandler(organizationService),
+		repository: organizationRepository,
+	}
+}
+
+func (m *OrganizationModule) Register(app *app.Apps) error {
+	app.Log.Info().Msg("Organization Module Initialized")
+
+	if err := app.Permissions.Declare(permissions...); err != nil {
+		return err
+	}
+
+	app.Schema.Declare(organizationSchema)
+	app.Schema.Declare(invitationSchema)
+	app.PersonalData.Register(organizationsDataSource(app))
+
+	// The authentication middleware resolves X-Tenant through the memberships
+	tenants := NewTenantCache(app, m.repository)
+	app.Tenants = tenants
+	app.Bus.On(permission.ChangedEvent, tenants.Forget)
+
+	return nil
+}
+
+func (m *OrganizationModule) Route(router *echo.Group, app *app.Apps) {
+	route := router.Group("/v1/organizations")
+	{
+		route.Use(middleware.AuthMiddleware(app))
+		route.POST("", m.Handler.Create, middleware.CheckAccess([]string{"organizations:create"}))
+		route.GET("", m.Handler.FindAll, middleware.CheckAccess([]string{"organizations:read"}))
+		route.GET("/mine", m.Handler.FindMine)
+		route.POST("/invitations/accept", m.Handler.Accept)
+		route.GET("/:id", m.Handler.FindById, middleware.CheckAccess([]string{"organizations:read"}))
+		route.PUT("/:id", m.Handler.Update, middleware.CheckAccess([]string{"organizations:update"}), middleware.RequireIfMatch())
+		route.GET("/:id/members", m.Handler.FindMembers, middleware.CheckAccess([]string{"organizations:read", "organizations:members"}))
+		route.PUT("/:id/members/:userId", m.Handler.SetMemberRoles, middleware.CheckAccess([]string{"organizations:members"}))
+		route.DELETE("/:id/members/:userId", m.Handler.RemoveMember, middleware.CheckAccess([]string{"organizations:members"}))
+		route.POST("/:id/invitations", m.Handler.Invite, middleware.CheckAccess([]string{"organizations:members"}))
+	}
+}
@@ -0,0 +1,297 @@
+package organizations
+
+import (
+	"github.com/HasanNugroho/starter-golang/internal/shared/query"
+	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+type OrganizationHandler struct {
+	organizationService IOrganizationService
+	validate            *validator.Validate
+}
+
+func NewOrganizationHandler(os IOrganizationService) *OrganizationHandler {
+	return &OrganizationHandler{
+		organizationService: os,
+		validate:            validator.New(),
+	}
+}
+
+// CreateOrganization godoc
+// @Summary      Create an organization
+// @Description  Create an organization, members are added by invitation
+// @Tags         organizations
+// @Accept       json
+// @Produce      json
+// @Param        organization  body  OrganizationUpdateModel  true  "Organization Data"
+// @Success      201  {object}  shared.Response
+// @Failure      400  {object}  shared.Response
+// @Failure      409  {object}  shared.Response
+// @Router       /organizations [post]
+// @Security ApiKeyAuth
+func (c *OrganizationHandler) Create(ctx echo.Context) error {
+	var organization OrganizationUpdateModel
+	ctx.Bind(&organization)
+
+	if err := c.validate.Struct(organization); err != nil {
+		return utils.NewBadRequest(err.Error())
+	}
+
+	if err := c.organizationService.Create(ctx, &organization); err != nil {
+		return err
+	}
+
+	utils.SendSuccess(ctx, 201, "organization created successfully", nil)
+	return nil
+}
+
+// FindAllOrganizations godoc
+// @Summary      Get all organizations
+// @Description  Retrieve the organizations, only the active tenant is listed inside of one
+// @Tags         organizations
+// @Accept       json
+// @Produce      json
+// @Param limit query int false "total data per-page" minimum(1) maximum(100) default(10)
+// @Param page query int false "page" minimum(1) default(1)
+// @Param cursor query string false "opaque cursor from next_cursor or prev_cursor, send empty to start cursor pagination"
+// @Param search query string false "keyword matched against name and slug"
+// @Param sort query string false "comma separated fields, prefix with - for descending" example(name)
+// @Param filter[field][operator] query string false "filter on name, slug, created_at or updated_at"
+// @Success      200     {object}  shared.Response{data=shared.DataWithPagination{items=[]OrganizationModel}}
+// @Failure      500     {object}  shared.Response
+// @Router       /organizations [get]
+// @Security ApiKeyAuth
+func (c *OrganizationHandler) FindAll(ctx echo.Context) error {
+	q, err := query.Parse(ctx.QueryParams(), OrganizationQuerySchema)
+	if err != nil {
+		return err
+	}
+
+	organizations, err := c.organizationService.FindAll(ctx, q)
+	if err != nil {
+		return err
+	}
+
+	utils.SendSuccess(ctx, 200, "organizations retrieved successfully", organizations)
+	return nil
+}
+
+// FindMyOrganizations godoc
+// @Summary      Get my organizations
+// @Description  Retrieve the organizations the current user is a member of, any of them can be sent as X-Tenant
+// @Tags         organizations
+// @Accept       json
+// @Produce      json
+// @Success      200  {object}  shared.Response{data=[]OrganizationModel}
+// @Router       /organizations/mine [get]
+// @Security ApiKeyAuth
+func (c *OrganizationHandler) FindMine(ctx echo.Context) error {
+	organizations, err := c.organizationService.FindMine(ctx)
+	if err != nil {
+		return err
+	}
+
+	utils.SendSuccess(ctx, 200, "organizations retrieved successfully", organizations)
+	return nil
+}
+
+// FindOrganization godoc
+// @Summary      Get organization
+// @Description  Retrieve an organization by ID
+// @Tags         organizations
+// @Accept       json
+// @Produce      json
+// @Param id path string true "id"
+// @Success      200     {object}  shared.Response{data=OrganizationModel}
+// @Header       200  {string}  ETag  "version of the returned document"
+// @Failure      404     {object}  shared.Response
+// @Router       /organizations/{id} [get]
+// @Security ApiKeyAuth
+func (c *OrganizationHandler) FindById(ctx echo.Context) error {
+	organization, err := c.organizationService.FindById(ctx, ctx.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	utils.SetETag(ctx, organization.Version)
+	utils.SendSuccess(ctx, 200, "organization retrieved successfully", organization)
+	return nil
+}
+
+// UpdateOrganization godoc
+// @Summary      Update organization
+// @Description  Replace the name and slug of an organization
+// @Tags         organizations
+// @Accept       json
+// @Produce      json
+// @Param id path string true "id"
+// @Param If-Match header string true "ETag from the last read, or * to skip the check"
+// @Param        organization  body  OrganizationUpdateModel  true  "Organization Data"
+// @Success      200  {object}  shared.Response
+// @Failure      400  {object}  shared.Response
+// @Failure      404  {object}  shared.Response
+// @Failure      409  {object}  shared.Response
+// @Failure      412  {object}  shared.Response
+// @Failure      428  {object}  shared.Response
+// @Router       /organizations/{id} [put]
+// @Security ApiKeyAuth
+func (c *OrganizationHandler) Update(ctx echo.Context) error {
+	var organization OrganizationUpdateModel
+	ctx.Bind(&organization)
+
+	if err := c.validate.Struct(organization); err != nil {
+		return utils.NewBadRequest(err.Error())
+	}
+
+	if err := c.organizationService.Update(ctx, ctx.Param("id"), &organization); err != nil {
+		return err
+	}
+
+	utils.SendSuccess(ctx, 200, "organization updated successfully", nil)
+	return nil
+}
+
+// FindOrganizationMembers godoc
+// @Summary      Get organization members
+// @Description  Retrieve the members of an organization with their roles there
+// @Tags         organizations
+// @Accept       json
+// @Produce      json
+// @Param id path string true "id"
+// @Param limit query int false "total data per-page" minimum(1) maximum(100) default(10)
+// @Param page query int false "page" minimum(1) default(1)
+// @Param cursor query string false "opaque cursor from next_cursor or prev_cursor, send empty to start cursor pagination"
+// @Param search query string false "keyword matched against email and name"
+// @Param sort query string false "comma separated fields, prefix with - for descending" example(name)
+// @Param filter[field][operator] query string false "filter on email, name or status"
+// @Success      200     {object}  shared.Response{data=shared.DataWithPagination{items=[]MemberModel}}
+// @Failure      404     {object}  shared.Response
+// @Router       /organizations/{id}/members [get]
+// @Security ApiKeyAuth
+func (c *OrganizationHandler) FindMembers(ctx echo.Context) error {
+	q, err := query.Parse(ctx.QueryParams(), MemberQuerySchema)
+	if err != nil {
+		return err
+	}
+
+	members, err := c.organizationService.FindMembers(ctx, ctx.Param("id"), q)
+	if err != nil {
+		return err
+	}
+
+	utils.SendSuccess(ctx, 200, "organization members retrieved successfully", members)
+	return nil
+}
+
+// SetOrganizationMemberRoles godoc
+// @Summary      Set member roles
+// @Description  Replace the roles a member holds in the organization, the roles must belong to it
+// @Tags         organizations
+// @Accept       json
+// @Produce      json
+// @Param id path string true "id"
+// @Param userId path string true "user id"
+// @Param        roles  body  MemberRolesModel  true  "Roles of the member"
+// @Success      200  {object}  shared.Response
+// @Failure      400  {object}  shared.Response
+// @Failure      404  {object}  shared.Response
+// @Router       /organizations/{id}/members/{userId} [put]
+// @Security ApiKeyAuth
+func (c *OrganizationHandler) SetMemberRoles(ctx echo.Context) error {
+	var payload MemberRolesModel
+	ctx.Bind(&payload)
+
+	if err := c.validate.Struct(payload); err != nil {
+		return utils.NewBadRequest(err.Error())
+	}
+
+	if err := c.organizationService.SetMemberRoles(ctx, ctx.Param("id"), ctx.Param("userId"), &payload); err != nil {
+		return err
+	}
+
+	utils.SendSuccess(ctx, 200, "organization member updated successfully", nil)
+	return nil
+}
+
+// RemoveOrganizationMember godoc
+// @Summary      Remove organization member
+// @Description  Remove a user from an organization
+// @Tags         organizations
+// @Accept       json
+// @Produce      json
+// @Param id path string true "id"
+// @Param userId path string true "user id"
+// @Success      200  {object}  shared.Response
+// @Failure      404  {object}  shared.Response
+// @Router       /organizations/{id}/members/{userId} [delete]
+// @Security ApiKeyAuth
+func (c *OrganizationHandler) RemoveMember(ctx echo.Context) error {
+	if err := c.organizationService.RemoveMember(ctx, ctx.Param("id"), ctx.Param("userId")); err != nil {
+		return err
+	}
+
+	utils.SendSuccess(ctx, 200, "organization member removed successfully", nil)
+	return nil
+}
+
+// InviteOrganizationMember godoc
+// @Summary      Invite member
+// @Description  Email an invitation to join the organization with the given roles
+// @Tags         organizations
+// @Accept       json
+// @Produce      json
+// @Param id path string true "id"
+// @Param        invitation  body  InvitationCreateModel  true  "Invitation Data"
+// @Success      201  {object}  shared.Response{data=entities.Invitation}
+// @Failure      400  {object}  shared.Response
+// @Failure      404  {object}  shared.Response
+// @Router       /organizations/{id}/invitations [post]
+// @Security ApiKeyAuth
+func (c *OrganizationHandler) Invite(ctx echo.Context) error {
+	var payload InvitationCreateModel
+	ctx.Bind(&payload)
+
+	if err := c.validate.Struct(payload); err != nil {
+		return utils.NewBadRequest(err.Error())
+	}
+
+	invitation, err := c.organizationService.Invite(ctx, ctx.Param("id"), &payload)
+	if err != nil {
+		return err
+	}
+
+	utils.SendSuccess(ctx, 201, "invitation sent successfully", invitation)
+	return nil
+}
+
+// AcceptInvitation godoc
+// @Summary      Accept invitation
+// @Description  Join the organization an invitation addressed to the current user's email invites to
+// @Tags         organizations
+// @Accept       json
+// @Produce      json
+// @Param        invitation  body  InvitationAcceptModel  true  "Invitation token"
+// @Success      200  {object}  shared.Response{data=OrganizationModel}
+// @Failure      400  {object}  shared.Response
+// @Failure      403  {object}  shared.Response
+// @Failure      409  {object}  shared.Response
+// @Router       /organizations/invitations/accept [post]
+// @Security ApiKeyAuth
+func (c *OrganizationHandler) Accept(ctx echo.Context) error {
+	var payload InvitationAcceptModel
+	ctx.Bind(&payload)
+
+	if err := c.validate.Struct(payload); err != nil {
+		return utils.NewBadRequest(err.Error())
+	}
+
+	organization, err := c.organizationService.Accept(ctx, payload.Token)
+	if err != nil {
+		return err
+	}
+
+	utils.SendSuccess(ctx, 200, "invitation accepted successfully", organization)
+	return nil
+}
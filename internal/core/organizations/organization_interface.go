@@ -0,0 +1,39 @@
+package organizations
+
+import (
+	"github.com/HasanNugroho/starter-golang/internal/core/entities"
+	shared "github.com/HasanNugroho/starter-golang/internal/shared/model"
+	"github.com/HasanNugroho/starter-golang/internal/shared/query"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type IOrganizationRepository interface {
+	Create(ctx echo.Context, organization *entities.Organization) error
+	FindById(ctx echo.Context, id string) (OrganizationModel, error)
+	FindAll(ctx echo.Context, q *query.Query) (query.Page[OrganizationModel], error)
+	FindByUser(ctx echo.Context, userID string) ([]OrganizationModel, error)
+	Update(ctx echo.Context, id string, organization *entities.Organization) error
+	FindMembers(ctx echo.Context, id bson.ObjectID, q *query.Query) (query.Page[MemberModel], error)
+	CountRoles(ctx echo.Context, id bson.ObjectID, ids []bson.ObjectID) (int64, error)
+	UserEmail(ctx echo.Context, userID string) (string, error)
+	AddMember(ctx echo.Context, id bson.ObjectID, userID string, roles []bson.ObjectID) error
+	SetMemberRoles(ctx echo.Context, id bson.ObjectID, userID string, roles []bson.ObjectID) error
+	RemoveMember(ctx echo.Context, id bson.ObjectID, userID string) error
+	CreateInvitation(ctx echo.Context, invitation *entities.Invitation) error
+	FindInvitation(ctx echo.Context, key string) (entities.Invitation, error)
+	AcceptInvitation(ctx echo.Context, id bson.ObjectID) error
+}
+
+type IOrganizationService interface {
+	Create(ctx echo.Context, payload *OrganizationUpdateModel) error
+	FindById(ctx echo.Context, id string) (OrganizationModel, error)
+	FindAll(ctx echo.Context, q *query.Query) (shared.DataWithPagination, error)
+	FindMine(ctx echo.Context) ([]OrganizationModel, error)
+	Update(ctx echo.Context, id string, payload *OrganizationUpdateModel) error
+	FindMembers(ctx echo.Context, id string, q *query.Query) (shared.DataWithPagination, error)
+	SetMemberRoles(ctx echo.Context, id string, userID string, payload *MemberRolesModel) error
+	RemoveMember(ctx echo.Context, id string, userID string) error
+	Invite(ctx echo.Context, id string, payload *InvitationCreateModel) (entities.Invitation, error)
+	Accept(ctx echo.Context, token string) (OrganizationModel, error)
+}
@@ -0,0 +1,71 @@
+package organizations
+
+import (
+	"time"
+
+	"github.com/HasanNugroho/starter-golang/internal/core/entities"
+	"github.com/HasanNugroho/starter-golang/internal/shared/query"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type OrganizationModel struct {
+	ID        bson.ObjectID `bson:"_id" json:"id"`
+	Name      string        `bson:"name" json:"name"`
+	Slug      string        `bson:"slug" json:"slug"`
+	Version   int64         `bson:"version" json:"version"`
+	CreatedAt time.Time     `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time     `bson:"updated_at" json:"updated_at"`
+}
+
+type OrganizationUpdateModel struct {
+	Name string `json:"name" validate:"required,max=100"`
+	Slug string `json:"slug" validate:"required,max=63"`
+}
+
+// MemberModel is a user listed as a member of an organization, with the roles
+// of the membership
+type MemberModel struct {
+	ID          bson.ObjectID         `bson:"_id" json:"id"`
+	Email       string                `bson:"email" json:"email"`
+	Name        string                `bson:"name" json:"name"`
+	Status      string                `bson:"status" json:"status"`
+	Memberships []entities.Membership `bson:"memberships" json:"-"`
+	Roles       []bson.ObjectID       `bson:"-" json:"roles"`
+	JoinedAt    time.Time             `bson:"-" json:"joined_at"`
+}
+
+type MemberRolesModel struct {
+	Roles []string `json:"roles" validate:"required"`
+}
+
+type InvitationCreateModel struct {
+	Email string   `json:"email" validate:"required,email"`
+	Roles []string `json:"roles"`
+}
+
+type InvitationAcceptModel struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// OrganizationQuerySchema whitelists the fields usable to filter and sort organizations
+var OrganizationQuerySchema = query.Schema{
+	Fields: map[string]query.Field{
+		"name":       {Type: query.String, Sortable: true},
+		"slug":       {Type: query.String, Sortable: true},
+		"created_at": {Type: query.Time, Sortable: true},
+		"updated_at": {Type: query.Time, Sortable: true},
+	},
+	Search:      []string{"name", "slug"},
+	DefaultSort: "name",
+}
+
+// MemberQuerySchema whitelists the fields usable to filter and sort the members of an organization
+var MemberQuerySchema = query.Schema{
+	Fields: map[string]query.Field{
+		"email":  {Type: query.String, Sortable: true},
+		"name":   {Type: query.String, Sortable: true},
+		"status": {Type: query.String, Operators: []query.Operator{query.Eq, query.Ne, query.In, query.Nin}},
+	},
+	Search:      []string{"email", "name"},
+	DefaultSort: "name",
+}
@@ -0,0 +1,163 @@
+package organizations
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/HasanNugroho/starter-golang/internal/app"
+	"github.com/HasanNugroho/starter-golang/internal/core/entities"
+	"github.com/HasanNugroho/starter-golang/internal/shared/modules"
+	"github.com/HasanNugroho/starter-golang/internal/shared/permission"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// MembershipPersonalDataModel is an organization the user belongs to in a personal data archive
+type MembershipPersonalDataModel struct {
+	Organization string    `json:"organization"`
+	Slug         string    `json:"slug"`
+	Roles        []string  `json:"roles"`
+	JoinedAt     time.Time `json:"joined_at"`
+}
+
+// InvitationPersonalDataModel is an invitation sent to the user's email, the
+// key is a credential and never exported
+type InvitationPersonalDataModel struct {
+	Organization string     `json:"organization"`
+	CreatedAt    time.Time  `json:"created_at"`
+	ExpiresAt    time.Time  `json:"expires_at"`
+	AcceptedAt   *time.Time `json:"accepted_at,omitempty"`
+}
+
+// OrganizationsPersonalDataModel is the organizations.json of a personal data archive
+type OrganizationsPersonalDataModel struct {
+	Memberships []MembershipPersonalDataModel `json:"memberships"`
+	Invitations []InvitationPersonalDataModel `json:"invitations"`
+}
+
+// organizationsDataSource declares the memberships stored on the users and the
+// invitations sent to their email as personal data
+func organizationsDataSource(app *app.Apps) modules.PersonalDataSource {
+	users := app.DB.Collection("users")
+	invitations := app.DB.Collection("invitations")
+	organizations := app.DB.Collection("organizations")
+	roles := app.DB.Collection("roles")
+
+	// names maps the ids of the collection to their name and slug, if any
+	names := func(ctx context.Context, collection *mongo.Collection, ids []bson.ObjectID) (map[bson.ObjectID]bson.M, error) {
+		found := map[bson.ObjectID]bson.M{}
+		if len(ids) == 0 {
+			return found, nil
+		}
+
+		cursor, err := collection.Find(ctx, bson.M{"_id": bson.M{"$in": ids}}, options.Find().SetProjection(bson.M{"name": 1, "slug": 1}))
+		if err != nil {
+			return nil, err
+		}
+
+		var docs []bson.M
+		if err := cursor.All(ctx, &docs); err != nil {
+			return nil, err
+		}
+		for _, doc := range docs {
+			if id, ok := doc["_id"].(bson.ObjectID); ok {
+				found[id] = doc
+			}
+		}
+		return found, nil
+	}
+	field := func(doc bson.M, key string) string {
+		value, _ := doc[key].(string)
+		return value
+	}
+
+	return modules.PersonalDataSource{
+		Name: "organizations",
+		Export: func(ctx context.Context, subject modules.DataSubject, archive modules.PersonalDataArchive) error {
+			var user struct {
+				Memberships []entities.Membership `bson:"memberships"`
+			}
+			opts := options.FindOne().SetProjection(bson.M{"memberships": 1})
+			err := users.FindOne(ctx, bson.M{"_id": subject.ID}, opts).Decode(&user)
+			if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+				return err
+			}
+
+			var invited []entities.Invitation
+			if subject.Email != "" {
+				cursor, err := invitations.Find(ctx, bson.M{"email": subject.Email})
+				if err != nil {
+					return err
+				}
+				if err := cursor.All(ctx, &invited); err != nil {
+					return err
+				}
+			}
+
+			organizationIDs := []bson.ObjectID{}
+			roleIDs := []bson.ObjectID{}
+			for _, membership := range user.Memberships {
+				organizationIDs = append(organizationIDs, membership.Organization)
+				roleIDs = append(roleIDs, membership.Roles...)
+			}
+			for _, invitation := range invited {
+				organizationIDs = append(organizationIDs, invitation.Organization)
+			}
+
+			organizationNames, err := names(ctx, organizations, organizationIDs)
+			if err != nil {
+				return err
+			}
+			roleNames, err := names(ctx, roles, roleIDs)
+			if err != nil {
+				return err
+			}
+
+			exported := OrganizationsPersonalDataModel{
+				Memberships: []MembershipPersonalDataModel{},
+				Invitations: []InvitationPersonalDataModel{},
+			}
+			for _, membership := range user.Memberships {
+				entry := MembershipPersonalDataModel{
+					Organization: field(organizationNames[membership.Organization], "name"),
+					Slug:         field(organizationNames[membership.Organization], "slug"),
+					Roles:        []string{},
+					JoinedAt:     membership.JoinedAt,
+				}
+				for _, role := range membership.Roles {
+					entry.Roles = append(entry.Roles, field(roleNames[role], "name"))
+				}
+				exported.Memberships = append(exported.Memberships, entry)
+			}
+			for _, invitation := range invited {
+				exported.Invitations = append(exported.Invitations, InvitationPersonalDataModel{
+					Organization: field(organizationNames[invitation.Organization], "name"),
+					CreatedAt:    invitation.CreatedAt,
+					ExpiresAt:    invitation.ExpiresAt,
+					AcceptedAt:   invitation.AcceptedAt,
+				})
+			}
+
+			return archive.AddJSON("organizations.json", exported)
+		},
+		Erase: func(ctx context.Context, subject modules.DataSubject) error {
+			if _, err := users.UpdateOne(ctx, bson.M{"_id": subject.ID}, bson.M{"$unset": bson.M{"memberships": ""}}); err != nil {
+				return err
+			}
+			app.Bus.Emit(permission.ChangedEvent, permission.Changed{UserIDs: []string{subject.ID.Hex()}})
+
+			// Invitations the user sent stay valid for their recipients
+			if _, err := invitations.UpdateMany(ctx, bson.M{"invited_by": subject.ID}, bson.M{"$unset": bson.M{"invited_by": ""}}); err != nil {
+				return err
+			}
+
+			if subject.Email == "" {
+				return nil
+			}
+			_, err := invitations.DeleteMany(ctx, bson.M{"email": subject.Email})
+			return err
+		},
+	}
+}
@@ -0,0 +1,411 @@
+package organizations
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/HasanNugroho/starter-golang/internal/app"
+	"github.com/HasanNugroho/starter-golang/internal/core/entities"
+	"github.com/HasanNugroho/starter-golang/internal/shared/query"
+	"github.com/HasanNugroho/starter-golang/internal/shared/tenant"
+	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+type OrganizationRepository struct {
+	app         *app.Apps
+	collection  *mongo.Collection
+	invitations *mongo.Collection
+	users       *mongo.Collection
+	roles       *mongo.Collection
+	groups      *mongo.Collection
+}
+
+func NewOrganizationRepository(app *app.Apps) *OrganizationRepository {
+	return &OrganizationRepository{
+		app:         app,
+		collection:  app.DB.Collection("organizations"),
+		invitations: app.DB.Collection("invitations"),
+		users:       app.DB.Collection("users"),
+		roles:       app.DB.Collection("roles"),
+		groups:      app.DB.Collection("groups"),
+	}
+}
+
+func (r *OrganizationRepository) Create(ctx echo.Context, organization *entities.Organization) error {
+	c := ctx.Request().Context()
+
+	if _, err := r.collection.InsertOne(c, organization); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return utils.NewConflict("slug already taken")
+		}
+		return utils.NewInternal("failed to create organization")
+	}
+
+	return nil
+}
+
+// FindById finds the organization, only the active tenant is visible inside of one
+func (r *OrganizationRepository) FindById(ctx echo.Context, id string) (OrganizationModel, error) {
+	c := ctx.Request().Context()
+
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return OrganizationModel{}, utils.NewBadRequest("invalid id format")
+	}
+
+	if current := tenant.ID(ctx); current != nil && *current != objectID {
+		return OrganizationModel{}, utils.NewNotFound("data not found")
+	}
+
+	var organization OrganizationModel
+	if err := r.collection.FindOne(c, bson.M{"_id": objectID}).Decode(&organization); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return OrganizationModel{}, utils.NewNotFound("data not found")
+		}
+		return OrganizationModel{}, utils.NewInternal("failed to query data")
+	}
+
+	return organization, nil
+}
+
+func (r *OrganizationRepository) FindAll(ctx echo.Context, q *query.Query) (query.Page[OrganizationModel], error) {
+	c := ctx.Request().Context()
+
+	if current := tenant.ID(ctx); current != nil {
+		q = q.Where(bson.M{"_id": *current})
+	}
+
+	return query.Find[OrganizationModel](c, r.collection, q, []byte(r.app.Config.Security.CursorKey()))
+}
+
+// FindByUser lists the organizations the user is a member of
+func (r *OrganizationRepository) FindByUser(ctx echo.Context, userID string) ([]OrganizationModel, error) {
+	c := ctx.Request().Context()
+
+	user, err := r.findUser(c, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	organizations := []OrganizationModel{}
+	if len(user.Memberships) == 0 {
+		return organizations, nil
+	}
+
+	ids := make([]bson.ObjectID, len(user.Memberships))
+	for i, membership := range user.Memberships {
+		ids[i] = membership.Organization
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}})
+	cursor, err := r.collection.Find(c, bson.M{"_id": bson.M{"$in": ids}}, opts)
+	if err != nil {
+		return nil, utils.NewInternal("failed to query data")
+	}
+	if err := cursor.All(c, &organizations); err != nil {
+		return nil, utils.NewInternal("failed to decode data")
+	}
+
+	return organizations, nil
+}
+
+func (r *OrganizationRepository) Update(ctx echo.Context, id string, organization *entities.Organization) error {
+	c := ctx.Request().Context()
+
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return utils.NewBadRequest("invalid id format")
+	}
+
+	filter := bson.M{"_id": objectID}
+	result, err := r.collection.UpdateOne(c, utils.VersionedFilter(ctx, filter), query.BumpVersion(bson.M{
+		"$set": bson.M{
+			"name":       organization.Name,
+			"slug":       organization.Slug,
+			"updated_at": time.Now(),
+		}}))
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return utils.NewConflict("slug already taken")
+		}
+		return utils.NewInternal("failed to update data")
+	}
+
+	if result.MatchedCount == 0 {
+		return utils.NotMatched(ctx, c, r.collection, filter)
+	}
+
+	return nil
+}
+
+func (r *OrganizationRepository) FindMembers(ctx echo.Context, id bson.ObjectID, q *query.Query) (query.Page[MemberModel], error) {
+	c := ctx.Request().Context()
+
+	return query.Find[MemberModel](c, r.users, q.WithoutDeleted().Where(bson.M{tenant.MemberField: id}), []byte(r.app.Config.Security.CursorKey()))
+}
+
+// CountRoles counts the roles among ids the organization owns and that are not deleted
+func (r *OrganizationRepository) CountRoles(ctx echo.Context, id bson.ObjectID, ids []bson.ObjectID) (int64, error) {
+	c := ctx.Request().Context()
+
+	count, err := r.roles.CountDocuments(c, bson.M{"_id": bson.M{"$in": ids}, tenant.Field: id, "deleted_at": nil})
+	if err != nil {
+		return 0, utils.NewInternal("failed to query data")
+	}
+	return count, nil
+}
+
+// UserEmail returns the email of the user
+func (r *OrganizationRepository) UserEmail(ctx echo.Context, userID string) (string, error) {
+	user, err := r.findUser(ctx.Request().Context(), userID)
+	if err != nil {
+		return "", err
+	}
+	return user.Email, nil
+}
+
+// AddMember makes the user a member of the organization with the roles
+func (r *OrganizationRepository) AddMember(ctx echo.Context, id bson.ObjectID, userID string, roles []bson.ObjectID) error {
+	c := ctx.Request().Context()
+
+	objectUserID, err := bson.ObjectIDFromHex(userID)
+	if err != nil {
+		return utils.NewBadRequest("invalid user id")
+	}
+
+	membership := entities.Membership{Organization: id, Roles: roles, JoinedAt: time.Now()}
+	filter := bson.M{"_id": objectUserID, "deleted_at": nil, tenant.MemberField: bson.M{"$ne": id}}
+	result, err := r.users.UpdateOne(c, filter, query.BumpVersion(bson.M{
+		"$push": bson.M{"memberships": membership},
+	}))
+	if err != nil {
+		return utils.NewInternal("failed to add organization member")
+	}
+
+	if result.MatchedCount == 0 {
+		return utils.NewConflict("user is already a member of the organization")
+	}
+
+	return nil
+}
+
+// SetMemberRoles replaces the roles of the user's membership
+func (r *OrganizationRepository) SetMemberRoles(ctx echo.Context, id bson.ObjectID, userID string, roles []bson.ObjectID) error {
+	c := ctx.Request().Context()
+
+	objectUserID, err := bson.ObjectIDFromHex(userID)
+	if err != nil {
+		return utils.NewBadRequest("invalid user id")
+	}
+
+	filter := bson.M{"_id": objectUserID, "deleted_at": nil, tenant.MemberField: id}
+	result, err := r.users.UpdateOne(c, filter, query.BumpVersion(bson.M{
+		"$set": bson.M{"memberships.$.roles": roles},
+	}))
+	if err != nil {
+		return utils.NewInternal("failed to update organization member")
+	}
+
+	if result.MatchedCount == 0 {
+		return utils.NewNotFound("user is not a member of the organization")
+	}
+
+	return nil
+}
+
+func (r *OrganizationRepository) RemoveMember(ctx echo.Context, id bson.ObjectID, userID string) error {
+	c := ctx.Request().Context()
+
+	objectUserID, err := bson.ObjectIDFromHex(userID)
+	if err != nil {
+		return utils.NewBadRequest("invalid user id")
+	}
+
+	result, err := r.users.UpdateOne(c, bson.M{"_id": objectUserID, tenant.MemberField: id}, query.BumpVersion(bson.M{
+		"$pull": bson.M{"memberships": bson.M{"organization": id}},
+	}))
+	if err != nil {
+		return utils.NewInternal("failed to remove organization member")
+	}
+
+	if result.MatchedCount == 0 {
+		return utils.NewNotFound("user is not a member of the organization")
+	}
+
+	return nil
+}
+
+func (r *OrganizationRepository) CreateInvitation(ctx echo.Context, invitation *entities.Invitation) error {
+	c := ctx.Request().Context()
+
+	result, err := r.invitations.InsertOne(c, invitation)
+	if err != nil {
+		return utils.NewInternal("failed to create invitation")
+	}
+
+	invitation.ID, _ = result.InsertedID.(bson.ObjectID)
+	return nil
+}
+
+// FindInvitation finds the pending invitation stored under the key
+func (r *OrganizationRepository) FindInvitation(ctx echo.Context, key string) (entities.Invitation, error) {
+	c := ctx.Request().Context()
+
+	filter := bson.M{"key": key, "accepted_at": nil, "expires_at": bson.M{"$gt": time.Now()}}
+
+	var invitation entities.Invitation
+	if err := r.invitations.FindOne(c, filter).Decode(&invitation); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return entities.Invitation{}, utils.NewBadRequest("invitation is invalid or expired")
+		}
+		return entities.Invitation{}, utils.NewInternal("failed to query data")
+	}
+
+	return invitation, nil
+}
+
+// AcceptInvitation marks the invitation as used, it can only be accepted once
+func (r *OrganizationRepository) AcceptInvitation(ctx echo.Context, id bson.ObjectID) error {
+	c := ctx.Request().Context()
+
+	result, err := r.invitations.UpdateOne(c, bson.M{"_id": id, "accepted_at": nil}, bson.M{
+		"$set": bson.M{"accepted_at": time.Now()},
+	})
+	if err != nil {
+		return utils.NewInternal("failed to accept invitation")
+	}
+
+	if result.MatchedCount == 0 {
+		return utils.NewBadRequest("invitation is invalid or expired")
+	}
+
+	return nil
+}
+
+// Resolve finds the organization by id or slug and what the membership of the
+// user grants there, following the parents of the membership roles
+func (r *OrganizationRepository) Resolve(ctx context.Context, userID string, ref string) (tenant.Tenant, error) {
+	filter := bson.M{"slug": ref}
+	if id, err := bson.ObjectIDFromHex(ref); err == nil {
+		filter = bson.M{"_id": id}
+	}
+
+	var organization entities.Organization
+	if err := r.collection.FindOne(ctx, filter).Decode(&organization); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return tenant.Tenant{}, utils.NewNotFound("organization not found")
+		}
+		return tenant.Tenant{}, utils.NewInternal("failed to query data")
+	}
+
+	current := tenant.Tenant{ID: organization.ID, Slug: organization.Slug}
+
+	objectUserID, err := bson.ObjectIDFromHex(userID)
+	if err != nil {
+		return tenant.Tenant{}, utils.NewUnauthorized("invalid user id")
+	}
+
+	var user struct {
+		Memberships []entities.Membership `bson:"memberships"`
+		Groups      []bson.ObjectID       `bson:"groups"`
+	}
+	opts := options.FindOne().SetProjection(bson.M{"memberships.$": 1, "groups": 1})
+	err = r.users.FindOne(ctx, bson.M{"_id": objectUserID, "deleted_at": nil, tenant.MemberField: organization.ID}, opts).Decode(&user)
+	if err == mongo.ErrNoDocuments || (err == nil && len(user.Memberships) == 0) {
+		return current, nil
+	}
+	if err != nil {
+		return tenant.Tenant{}, utils.NewInternal("failed to query data")
+	}
+
+	current.Member = true
+	current.Permissions = []string{}
+
+	// Groups of the organization grant their roles to the members in them
+	roleIDs := user.Memberships[0].Roles
+	if len(user.Groups) > 0 {
+		cursor, err := r.groups.Find(ctx, bson.M{"_id": bson.M{"$in": user.Groups}, tenant.Field: organization.ID})
+		if err != nil {
+			return tenant.Tenant{}, utils.NewInternal("failed to query data")
+		}
+		var groups []entities.Group
+		if err := cursor.All(ctx, &groups); err != nil {
+			return tenant.Tenant{}, utils.NewInternal("failed to decode data")
+		}
+		for _, group := range groups {
+			roleIDs = append(roleIDs, group.Roles...)
+		}
+	}
+	if len(roleIDs) == 0 {
+		return current, nil
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"_id": bson.M{"$in": roleIDs}, "deleted_at": nil}}},
+		{{
+			Key: "$graphLookup",
+			Value: bson.D{
+				{Key: "from", Value: "roles"},
+				{Key: "startWith", Value: "$parents"},
+				{Key: "connectFromField", Value: "parents"},
+				{Key: "connectToField", Value: "_id"},
+				{Key: "restrictSearchWithMatch", Value: query.NotDeleted()},
+				{Key: "as", Value: "ancestors"},
+			},
+		}},
+	}
+
+	cursor, err := r.roles.Aggregate(ctx, pipeline)
+	if err != nil {
+		return tenant.Tenant{}, utils.NewInternal("failed to query data")
+	}
+	defer cursor.Close(ctx)
+
+	var roles []struct {
+		Permissions []string `bson:"permissions"`
+		Ancestors   []struct {
+			Permissions []string `bson:"permissions"`
+		} `bson:"ancestors"`
+	}
+	if err := cursor.All(ctx, &roles); err != nil {
+		return tenant.Tenant{}, utils.NewInternal("failed to decode data")
+	}
+
+	grant := func(permissions []string) {
+		for _, permission := range permissions {
+			if !slices.Contains(current.Permissions, permission) {
+				current.Permissions = append(current.Permissions, permission)
+			}
+		}
+	}
+	for _, role := range roles {
+		grant(role.Permissions)
+		for _, ancestor := range role.Ancestors {
+			grant(ancestor.Permissions)
+		}
+	}
+
+	return current, nil
+}
+
+func (r *OrganizationRepository) findUser(ctx context.Context, userID string) (entities.User, error) {
+	objectID, err := bson.ObjectIDFromHex(userID)
+	if err != nil {
+		return entities.User{}, utils.NewBadRequest("invalid user id")
+	}
+
+	var user entities.User
+	if err := r.users.FindOne(ctx, bson.M{"_id": objectID, "deleted_at": nil}).Decode(&user); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return entities.User{}, utils.NewNotFound("user not found")
+		}
+		return entities.User{}, utils.NewInternal("failed to query data")
+	}
+
+	return user, nil
+}
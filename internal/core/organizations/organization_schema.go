@@ -0,0 +1,46 @@
+package organizations
+
+import (
+	"github.com/HasanNugroho/starter-golang/internal/shared/modules"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// organizationSchema declares the organizations collection
+var organizationSchema = modules.CollectionSchema{
+	Name: "organizations",
+	Indexes: []modules.IndexSpec{
+		{Name: "slug_unique", Keys: bson.D{{Key: "slug", Value: 1}}, Unique: true},
+	},
+	Validator: bson.M{
+		"bsonType": "object",
+		"required": bson.A{"name", "slug"},
+		"properties": bson.M{
+			"name":    bson.M{"bsonType": "string", "minLength": 1},
+			"slug":    bson.M{"bsonType": "string", "pattern": slugPattern},
+			"version": bson.M{"bsonType": bson.A{"int", "long"}},
+		},
+	},
+}
+
+// invitationSchema declares the invitations collection, invitations are
+// removed a week after they expire
+var invitationSchema = modules.CollectionSchema{
+	Name: "invitations",
+	Indexes: []modules.IndexSpec{
+		{Name: "key_unique", Keys: bson.D{{Key: "key", Value: 1}}, Unique: true},
+		{Name: "organization_email", Keys: bson.D{{Key: "organization", Value: 1}, {Key: "email", Value: 1}}},
+		{Name: "expires_at_ttl", Keys: bson.D{{Key: "expires_at", Value: 1}}, TTL: invitationRetention},
+	},
+	Validator: bson.M{
+		"bsonType": "object",
+		"required": bson.A{"organization", "email", "key", "expires_at"},
+		"properties": bson.M{
+			"organization": bson.M{"bsonType": "objectId"},
+			"email":        bson.M{"bsonType": "string", "minLength": 1},
+			"roles":        bson.M{"bsonType": bson.A{"array", "null"}, "items": bson.M{"bsonType": "objectId"}},
+			"key":          bson.M{"bsonType": "string", "minLength": 1},
+			"expires_at":   bson.M{"bsonType": "date"},
+			"accepted_at":  bson.M{"bsonType": bson.A{"date", "null"}},
+		},
+	},
+}
@@ -0,0 +1,290 @@
+package organizations
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/HasanNugroho/starter-golang/internal/app"
+	"github.com/HasanNugroho/starter-golang/internal/core/entities"
+	shared "github.com/HasanNugroho/starter-golang/internal/shared/model"
+	"github.com/HasanNugroho/starter-golang/internal/shared/permission"
+	"github.com/HasanNugroho/starter-golang/internal/shared/query"
+	"github.com/HasanNugroho/starter-golang/internal/shared/tenant"
+	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// slugPattern keeps slugs usable in URLs and the X-Tenant header
+const slugPattern = `^[a-z0-9]+(-[a-z0-9]+)*$`
+
+// invitationRetention is how long invitations are kept after they expire
+const invitationRetention = 7 * 24 * time.Hour
+
+var slugFormat = regexp.MustCompile(slugPattern)
+
+type OrganizationService struct {
+	repo IOrganizationRepository
+	app  *app.Apps
+}
+
+func NewOrganizationService(app *app.Apps, repo IOrganizationRepository) *OrganizationService {
+	return &OrganizationService{
+		repo: repo,
+		app:  app,
+	}
+}
+
+func (s *OrganizationService) Create(ctx echo.Context, payload *OrganizationUpdateModel) error {
+	if err := outsideTenant(ctx); err != nil {
+		return err
+	}
+
+	if err := checkSlug(payload.Slug); err != nil {
+		return err
+	}
+
+	organization := entities.Organization{
+		Name:      payload.Name,
+		Slug:      payload.Slug,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if createdBy, err := bson.ObjectIDFromHex(utils.CurrentUserID(ctx)); err == nil {
+		organization.CreatedBy = &createdBy
+	}
+
+	return s.repo.Create(ctx, &organization)
+}
+
+func (s *OrganizationService) FindById(ctx echo.Context, id string) (OrganizationModel, error) {
+	return s.repo.FindById(ctx, id)
+}
+
+func (s *OrganizationService) FindAll(ctx echo.Context, q *query.Query) (shared.DataWithPagination, error) {
+	page, err := s.repo.FindAll(ctx, q)
+	if err != nil {
+		return shared.DataWithPagination{}, err
+	}
+
+	return page.Response(q), nil
+}
+
+// FindMine lists the organizations the current user can switch to
+func (s *OrganizationService) FindMine(ctx echo.Context) ([]OrganizationModel, error) {
+	return s.repo.FindByUser(ctx, utils.CurrentUserID(ctx))
+}
+
+func (s *OrganizationService) Update(ctx echo.Context, id string, payload *OrganizationUpdateModel) error {
+	current, err := s.repo.FindById(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := utils.CheckVersion(ctx, current.Version); err != nil {
+		return err
+	}
+
+	if err := checkSlug(payload.Slug); err != nil {
+		return err
+	}
+
+	if err := s.repo.Update(ctx, id, &entities.Organization{Name: payload.Name, Slug: payload.Slug}); err != nil {
+		return err
+	}
+
+	// Tenants are cached by the slug they were asked for
+	if payload.Slug != current.Slug {
+		s.app.Bus.Emit(permission.ChangedEvent, permission.Changed{})
+	}
+	return nil
+}
+
+func (s *OrganizationService) FindMembers(ctx echo.Context, id string, q *query.Query) (shared.DataWithPagination, error) {
+	organization, err := s.repo.FindById(ctx, id)
+	if err != nil {
+		return shared.DataWithPagination{}, err
+	}
+
+	page, err := s.repo.FindMembers(ctx, organization.ID, q)
+	if err != nil {
+		return shared.DataWithPagination{}, err
+	}
+
+	// Only the membership of this organization is listed
+	for i, member := range page.Items {
+		for _, membership := range member.Memberships {
+			if membership.Organization == organization.ID {
+				page.Items[i].Roles = membership.Roles
+				page.Items[i].JoinedAt = membership.JoinedAt
+			}
+		}
+		page.Items[i].Memberships = nil
+	}
+
+	return page.Response(q), nil
+}
+
+// SetMemberRoles replaces the roles of a member. Permissions of a tenant are
+// resolved on every request, so tokens do not need to be revoked, only the
+// cached tenants of the member are dropped.
+func (s *OrganizationService) SetMemberRoles(ctx echo.Context, id string, userID string, payload *MemberRolesModel) error {
+	organization, err := s.repo.FindById(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	roleIDs, err := s.roleIDs(ctx, organization.ID, payload.Roles)
+	if err != nil {
+		return err
+	}
+
+	return s.changed(s.repo.SetMemberRoles(ctx, organization.ID, userID, roleIDs), userID)
+}
+
+func (s *OrganizationService) RemoveMember(ctx echo.Context, id string, userID string) error {
+	organization, err := s.repo.FindById(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return s.changed(s.repo.RemoveMember(ctx, organization.ID, userID), userID)
+}
+
+// Invite emails a one-time token to the address. Whoever signs in with that
+// address can accept it and joins with the given roles.
+func (s *OrganizationService) Invite(ctx echo.Context, id string, payload *InvitationCreateModel) (entities.Invitation, error) {
+	organization, err := s.repo.FindById(ctx, id)
+	if err != nil {
+		return entities.Invitation{}, err
+	}
+
+	roleIDs, err := s.roleIDs(ctx, organization.ID, payload.Roles)
+	if err != nil {
+		return entities.Invitation{}, err
+	}
+
+	token, key, err := utils.NewSecretToken()
+	if err != nil {
+		return entities.Invitation{}, err
+	}
+
+	expiration := time.Hour * time.Duration(s.app.Config.Security.InvitationExpired)
+	if expiration <= 0 {
+		expiration = 72 * time.Hour
+	}
+
+	now := time.Now()
+	invitation := entities.Invitation{
+		Organization: organization.ID,
+		Email:        strings.ToLower(payload.Email),
+		Roles:        roleIDs,
+		Key:          key,
+		ExpiresAt:    now.Add(expiration),
+		CreatedAt:    now,
+	}
+	if invitedBy, err := bson.ObjectIDFromHex(utils.CurrentUserID(ctx)); err == nil {
+		invitation.InvitedBy = &invitedBy
+	}
+
+	if err := s.repo.CreateInvitation(ctx, &invitation); err != nil {
+		return entities.Invitation{}, err
+	}
+
+	err = s.app.Mailer.Send(ctx.Request().Context(), invitation.Email, "You are invited to join "+organization.Name,
+		fmt.Sprintf("Hi,\n\nYou are invited to join %s. Sign in with this email address and use this token to accept the invitation:\n\n%s\n\nThe token expires at %s.",
+			organization.Name, token, invitation.ExpiresAt.Format(time.RFC1123)))
+	if err != nil {
+		return entities.Invitation{}, utils.NewInternal("failed to send invitation email")
+	}
+
+	return invitation, nil
+}
+
+// Accept makes the current user a member of the organization the token
+// invites to, the invitation must be addressed to the user's email
+func (s *OrganizationService) Accept(ctx echo.Context, token string) (OrganizationModel, error) {
+	invitation, err := s.repo.FindInvitation(ctx, utils.SecretKey(token))
+	if err != nil {
+		return OrganizationModel{}, err
+	}
+
+	userID := utils.CurrentUserID(ctx)
+	email, err := s.repo.UserEmail(ctx, userID)
+	if err != nil {
+		return OrganizationModel{}, err
+	}
+
+	if !strings.EqualFold(email, invitation.Email) {
+		return OrganizationModel{}, utils.NewForbidden("the invitation is addressed to another email")
+	}
+
+	if err := s.repo.AddMember(ctx, invitation.Organization, userID, invitation.Roles); err != nil {
+		return OrganizationModel{}, err
+	}
+
+	if err := s.repo.AcceptInvitation(ctx, invitation.ID); err != nil {
+		return OrganizationModel{}, err
+	}
+	s.app.Bus.Emit(permission.ChangedEvent, permission.Changed{UserIDs: []string{userID}})
+
+	return s.repo.FindById(ctx, invitation.Organization.Hex())
+}
+
+// outsideTenant refuses creating organizations inside a tenant, organizations
+// do not nest and a tenant's roles must not grant creating new ones
+func outsideTenant(ctx echo.Context) error {
+	if tenant.ID(ctx) != nil {
+		return utils.NewForbidden("organizations are created outside of an organization")
+	}
+	return nil
+}
+
+// changed reports the changed membership of the user once it is saved
+func (s *OrganizationService) changed(err error, userID string) error {
+	if err != nil {
+		return err
+	}
+
+	s.app.Bus.Emit(permission.ChangedEvent, permission.Changed{UserIDs: []string{userID}})
+	return nil
+}
+
+// roleIDs converts the role ids of a request, every role must be owned by the organization
+func (s *OrganizationService) roleIDs(ctx echo.Context, id bson.ObjectID, ids []string) ([]bson.ObjectID, error) {
+	roleIDs := make([]bson.ObjectID, 0, len(ids))
+	for _, value := range ids {
+		roleID, err := bson.ObjectIDFromHex(value)
+		if err != nil {
+			return nil, utils.NewBadRequest("invalid role id")
+		}
+		if !slices.Contains(roleIDs, roleID) {
+			roleIDs = append(roleIDs, roleID)
+		}
+	}
+
+	if len(roleIDs) == 0 {
+		return roleIDs, nil
+	}
+
+	count, err := s.repo.CountRoles(ctx, id, roleIDs)
+	if err != nil {
+		return nil, err
+	}
+	if count != int64(len(roleIDs)) {
+		return nil, utils.NewBadRequest("roles not found in the organization")
+	}
+
+	return roleIDs, nil
+}
+
+// checkSlug rejects slugs that could not be told apart from an organization id
+func checkSlug(slug string) error {
+	if _, err := bson.ObjectIDFromHex(slug); err == nil || !slugFormat.MatchString(slug) {
+		return utils.NewBadRequest("slug must be lowercase letters, digits and dashes and can not be an id")
+	}
+	return nil
+}
@@ -0,0 +1,248 @@
+package organizations_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/HasanNugroho/starter-golang/config"
+	"github.com/HasanNugroho/starter-golang/internal/app"
+	"github.com/HasanNugroho/starter-golang/internal/core/entities"
+	"github.com/HasanNugroho/starter-golang/internal/core/organizations"
+	"github.com/HasanNugroho/starter-golang/internal/shared/modules"
+	"github.com/HasanNugroho/starter-golang/internal/shared/permission"
+	"github.com/HasanNugroho/starter-golang/internal/shared/tenant"
+	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type MockOrganizationRepo struct {
+	organizations.IOrganizationRepository
+	mock.Mock
+}
+
+func (m *MockOrganizationRepo) Create(ctx echo.Context, organization *entities.Organization) error {
+	args := m.Called(ctx, organization)
+	return args.Error(0)
+}
+
+func (m *MockOrganizationRepo) FindById(ctx echo.Context, id string) (organizations.OrganizationModel, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(organizations.OrganizationModel), args.Error(1)
+}
+
+func (m *MockOrganizationRepo) CountRoles(ctx echo.Context, id bson.ObjectID, ids []bson.ObjectID) (int64, error) {
+	args := m.Called(ctx, id, ids)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockOrganizationRepo) UserEmail(ctx echo.Context, userID string) (string, error) {
+	args := m.Called(ctx, userID)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockOrganizationRepo) AddMember(ctx echo.Context, id bson.ObjectID, userID string, roles []bson.ObjectID) error {
+	args := m.Called(ctx, id, userID, roles)
+	return args.Error(0)
+}
+
+func (m *MockOrganizationRepo) SetMemberRoles(ctx echo.Context, id bson.ObjectID, userID string, roles []bson.ObjectID) error {
+	args := m.Called(ctx, id, userID, roles)
+	return args.Error(0)
+}
+
+func (m *MockOrganizationRepo) CreateInvitation(ctx echo.Context, invitation *entities.Invitation) error {
+	args := m.Called(ctx, invitation)
+	return args.Error(0)
+}
+
+func (m *MockOrganizationRepo) FindInvitation(ctx echo.Context, key string) (entities.Invitation, error) {
+	args := m.Called(ctx, key)
+	return args.Get(0).(entities.Invitation), args.Error(1)
+}
+
+func (m *MockOrganizationRepo) AcceptInvitation(ctx echo.Context, id bson.ObjectID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+type fakeMailer struct {
+	to   string
+	body string
+}
+
+func (m *fakeMailer) Send(ctx context.Context, to string, subject string, body string) error {
+	m.to, m.body = to, body
+	return nil
+}
+
+func newTestService(repo organizations.IOrganizationRepository, mailer *fakeMailer) *organizations.OrganizationService {
+	return organizations.NewOrganizationService(&app.Apps{Config: &config.Config{}, Mailer: mailer, Bus: modules.EventNew()}, repo)
+}
+
+func newTestContext(userID string) echo.Context {
+	ctx := echo.New().NewContext(httptest.NewRequest("POST", "/", nil), httptest.NewRecorder())
+	ctx.Set("claims", jwt.MapClaims{"data": map[string]interface{}{"id": userID}})
+	return ctx
+}
+
+func TestOrganizationService_Create_RejectsIdSlug(t *testing.T) {
+	mockRepo := new(MockOrganizationRepo)
+	service := newTestService(mockRepo, &fakeMailer{})
+	ctx := newTestContext(bson.NewObjectID().Hex())
+
+	for _, slug := range []string{bson.NewObjectID().Hex(), "Acme", "acme--corp", "-acme"} {
+		err := service.Create(ctx, &organizations.OrganizationUpdateModel{Name: "Acme", Slug: slug})
+		assert.Error(t, err, slug)
+	}
+	mockRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestOrganizationService_Create(t *testing.T) {
+	mockRepo := new(MockOrganizationRepo)
+	service := newTestService(mockRepo, &fakeMailer{})
+	userID := bson.NewObjectID()
+	ctx := newTestContext(userID.Hex())
+
+	mockRepo.On("Create", ctx, mock.MatchedBy(func(organization *entities.Organization) bool {
+		return organization.Slug == "acme-corp" && organization.CreatedBy != nil && *organization.CreatedBy == userID
+	})).Return(nil)
+
+	err := service.Create(ctx, &organizations.OrganizationUpdateModel{Name: "Acme", Slug: "acme-corp"})
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestOrganizationService_Create_InsideTenant(t *testing.T) {
+	mockRepo := new(MockOrganizationRepo)
+	service := newTestService(mockRepo, &fakeMailer{})
+	ctx := newTestContext(bson.NewObjectID().Hex())
+	tenant.Set(ctx, tenant.Tenant{ID: bson.NewObjectID(), Slug: "acme", Member: true})
+
+	err := service.Create(ctx, &organizations.OrganizationUpdateModel{Name: "Globex", Slug: "globex"})
+
+	assert.Equal(t, utils.NewForbidden("organizations are created outside of an organization"), err)
+	mockRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestOrganizationService_SetMemberRoles_ForeignRole(t *testing.T) {
+	mockRepo := new(MockOrganizationRepo)
+	service := newTestService(mockRepo, &fakeMailer{})
+	ctx := newTestContext(bson.NewObjectID().Hex())
+	organization := organizations.OrganizationModel{ID: bson.NewObjectID(), Name: "Acme"}
+	roleID := bson.NewObjectID()
+
+	mockRepo.On("FindById", ctx, organization.ID.Hex()).Return(organization, nil)
+	mockRepo.On("CountRoles", ctx, organization.ID, []bson.ObjectID{roleID}).Return(int64(0), nil)
+
+	err := service.SetMemberRoles(ctx, organization.ID.Hex(), bson.NewObjectID().Hex(), &organizations.MemberRolesModel{Roles: []string{roleID.Hex()}})
+
+	assert.Error(t, err)
+	mockRepo.AssertNotCalled(t, "SetMemberRoles", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestOrganizationService_SetMemberRoles_ForgetsTenant(t *testing.T) {
+	mockRepo := new(MockOrganizationRepo)
+	apps := &app.Apps{Config: &config.Config{}, Bus: modules.EventNew()}
+	service := organizations.NewOrganizationService(apps, mockRepo)
+	ctx := newTestContext(bson.NewObjectID().Hex())
+	organization := organizations.OrganizationModel{ID: bson.NewObjectID(), Name: "Acme"}
+	userID := bson.NewObjectID().Hex()
+	roleID := bson.NewObjectID()
+
+	events := make(chan permission.Changed, 1)
+	apps.Bus.On(permission.ChangedEvent, func(payload any) {
+		events <- payload.(permission.Changed)
+	})
+
+	mockRepo.On("FindById", ctx, organization.ID.Hex()).Return(organization, nil)
+	mockRepo.On("CountRoles", ctx, organization.ID, []bson.ObjectID{roleID}).Return(int64(1), nil)
+	mockRepo.On("SetMemberRoles", ctx, organization.ID, userID, []bson.ObjectID{roleID}).Return(nil)
+
+	err := service.SetMemberRoles(ctx, organization.ID.Hex(), userID, &organizations.MemberRolesModel{Roles: []string{roleID.Hex()}})
+	assert.NoError(t, err)
+
+	// The cached tenants of the member are dropped
+	select {
+	case changed := <-events:
+		assert.Equal(t, []string{userID}, changed.UserIDs)
+	case <-time.After(time.Second):
+		t.Fatal("permission.Changed was not emitted")
+	}
+}
+
+func TestOrganizationService_Invite_SendsToken(t *testing.T) {
+	mockRepo := new(MockOrganizationRepo)
+	mailer := &fakeMailer{}
+	service := newTestService(mockRepo, mailer)
+	ctx := newTestContext(bson.NewObjectID().Hex())
+	organization := organizations.OrganizationModel{ID: bson.NewObjectID(), Name: "Acme"}
+
+	var stored *entities.Invitation
+	mockRepo.On("FindById", ctx, organization.ID.Hex()).Return(organization, nil)
+	mockRepo.On("CreateInvitation", ctx, mock.Anything).Run(func(args mock.Arguments) {
+		stored = args.Get(1).(*entities.Invitation)
+	}).Return(nil)
+
+	invitation, err := service.Invite(ctx, organization.ID.Hex(), &organizations.InvitationCreateModel{Email: "Jane@Example.com"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "jane@example.com", invitation.Email)
+	assert.Equal(t, "jane@example.com", mailer.to)
+	assert.NotNil(t, stored)
+
+	// Only the hash of the token is stored, the token itself is mailed
+	assert.NotContains(t, mailer.body, stored.Key)
+	var token string
+	for _, line := range strings.Split(mailer.body, "\n") {
+		if line != "" && utils.SecretKey(line) == stored.Key {
+			token = line
+		}
+	}
+	assert.NotEmpty(t, token)
+}
+
+func TestOrganizationService_Accept_OtherEmail(t *testing.T) {
+	mockRepo := new(MockOrganizationRepo)
+	service := newTestService(mockRepo, &fakeMailer{})
+	userID := bson.NewObjectID().Hex()
+	ctx := newTestContext(userID)
+	invitation := entities.Invitation{ID: bson.NewObjectID(), Organization: bson.NewObjectID(), Email: "jane@example.com"}
+
+	mockRepo.On("FindInvitation", ctx, utils.SecretKey("token")).Return(invitation, nil)
+	mockRepo.On("UserEmail", ctx, userID).Return("john@example.com", nil)
+
+	_, err := service.Accept(ctx, "token")
+
+	assert.Error(t, err)
+	mockRepo.AssertNotCalled(t, "AddMember", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestOrganizationService_Accept(t *testing.T) {
+	mockRepo := new(MockOrganizationRepo)
+	service := newTestService(mockRepo, &fakeMailer{})
+	userID := bson.NewObjectID().Hex()
+	ctx := newTestContext(userID)
+	roleID := bson.NewObjectID()
+	invitation := entities.Invitation{ID: bson.NewObjectID(), Organization: bson.NewObjectID(), Email: "jane@example.com", Roles: []bson.ObjectID{roleID}}
+	organization := organizations.OrganizationModel{ID: invitation.Organization, Name: "Acme"}
+
+	mockRepo.On("FindInvitation", ctx, utils.SecretKey("token")).Return(invitation, nil)
+	mockRepo.On("UserEmail", ctx, userID).Return("Jane@example.com", nil)
+	mockRepo.On("AddMember", ctx, invitation.Organization, userID, []bson.ObjectID{roleID}).Return(nil)
+	mockRepo.On("AcceptInvitation", ctx, invitation.ID).Return(nil)
+	mockRepo.On("FindById", ctx, invitation.Organization.Hex()).Return(organization, nil)
+
+	result, err := service.Accept(ctx, "token")
+
+	assert.NoError(t, err)
+	assert.Equal(t, organization.ID, result.ID)
+	mockRepo.AssertExpectations(t)
+}
@@ -0,0 +1,129 @@
+package organizations
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/HasanNugroho/starter-golang/internal/app"
+	"github.com/HasanNugroho/starter-golang/internal/shared/permission"
+	"github.com/HasanNugroho/starter-golang/internal/shared/tenant"
+	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
+	"github.com/redis/go-redis/v9"
+)
+
+// tenantCachePrefix prefixes the Redis hashes of the resolved tenants, the user
+// id follows and the hash is keyed by the id or slug the user asked for
+const tenantCachePrefix = "user_tenants:"
+
+// tenantCacheTTL bounds how long tenants are cached when AUTHZ_CACHE_TTL is not set
+const tenantCacheTTL = 10 * time.Minute
+
+// forgetBatch is how many cached entries are dropped per Redis call
+const forgetBatch = 500
+
+// TenantCache resolves the X-Tenant of every request from Redis, asking the
+// repository on a miss. Entries are dropped on permission.ChangedEvent, which
+// membership, role and group changes emit.
+type TenantCache struct {
+	app      *app.Apps
+	resolver tenant.Resolver
+	ttl      time.Duration
+}
+
+func NewTenantCache(app *app.Apps, resolver tenant.Resolver) *TenantCache {
+	ttl := time.Duration(app.Config.Security.AuthzCacheTTL) * time.Minute
+	if ttl <= 0 {
+		ttl = tenantCacheTTL
+	}
+
+	return &TenantCache{
+		app:      app,
+		resolver: resolver,
+		ttl:      ttl,
+	}
+}
+
+// Resolve returns the organization and what the membership of the user grants
+// there, see OrganizationRepository.Resolve
+func (t *TenantCache) Resolve(ctx context.Context, userID string, ref string) (tenant.Tenant, error) {
+	if !t.app.Config.Redis.Enabled {
+		return t.resolver.Resolve(ctx, userID, ref)
+	}
+
+	key := tenantCachePrefix + userID
+	if cached, err := t.app.Redis.HGet(ctx, key, ref).Bytes(); err == nil {
+		var current tenant.Tenant
+		if err := json.Unmarshal(cached, &current); err == nil {
+			return current, nil
+		}
+	}
+
+	// Read before the database, so a change landing in between is noticed
+	generation, generationErr := utils.GrantsGeneration(ctx, t.app, userID)
+
+	current, err := t.resolver.Resolve(ctx, userID, ref)
+	if err != nil {
+		return tenant.Tenant{}, err
+	}
+
+	if generationErr != nil {
+		return current, nil
+	}
+
+	fill := current
+	fill.Generation = generation
+	encoded, err := json.Marshal(fill)
+	if err == nil {
+		err = utils.StoreGrants(ctx, t.app, userID, generation, func(pipe redis.Pipeliner) {
+			pipe.HSet(ctx, key, ref, encoded)
+			pipe.Expire(ctx, key, t.ttl)
+		})
+	}
+	if err != nil {
+		return current, nil
+	}
+	return fill, nil
+}
+
+// Forget drops the cached tenants of the users a permission.Changed names,
+// every cached entry when it names none. Their generation moves first, so
+// resolutions already running are not stored.
+func (t *TenantCache) Forget(payload any) {
+	changed, ok := payload.(permission.Changed)
+	if !ok || !t.app.Config.Redis.Enabled {
+		return
+	}
+
+	ctx := context.Background()
+	if err := utils.ChangeGrants(ctx, t.app, changed.UserIDs...); err != nil {
+		t.app.Log.Error().Err(err).Msg("Failed to change the grants generation")
+	}
+
+	if len(changed.UserIDs) > 0 {
+		keys := make([]string, len(changed.UserIDs))
+		for i, userID := range changed.UserIDs {
+			keys[i] = tenantCachePrefix + userID
+		}
+		if err := t.app.Redis.Del(ctx, keys...).Err(); err != nil {
+			t.app.Log.Error().Err(err).Msg("Failed to drop cached tenants")
+		}
+		return
+	}
+
+	keys := make([]string, 0, forgetBatch)
+	iter := t.app.Redis.Scan(ctx, 0, tenantCachePrefix+"*", forgetBatch).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+		if len(keys) == forgetBatch {
+			t.app.Redis.Del(ctx, keys...)
+			keys = keys[:0]
+		}
+	}
+	if len(keys) > 0 {
+		t.app.Redis.Del(ctx, keys...)
+	}
+	if err := iter.Err(); err != nil {
+		t.app.Log.Error().Err(err).Msg("Failed to drop cached tenants")
+	}
+}
@@ -0,0 +1,31 @@
+package permissions
+
+import (
+	"github.com/HasanNugroho/starter-golang/internal/app"
+	"github.com/HasanNugroho/starter-golang/internal/shared/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+type PermissionModule struct {
+	Handler *PermissionHandler
+}
+
+func NewPermissionModule(app *app.Apps) *PermissionModule {
+	return &PermissionModule{
+		Handler: NewPermissionHandler(app),
+	}
+}
+
+func (m *PermissionModule) Register(app *app.Apps) error {
+	app.Log.Info().Msg("Permission Module Initialized")
+	return nil
+}
+
+func (m *PermissionModule) Route(router *echo.Group, app *app.Apps) {
+	route := router.Group("/v1/permissions")
+	{
+		route.Use(middleware.AuthMiddleware(app))
+		// Role editors list the permissions a role may be granted
+		route.GET("", m.Handler.FindAll, middleware.CheckAccess([]string{"roles:read", "roles:create", "roles:update"}))
+	}
+}
@@ -0,0 +1,43 @@
+package permissions
+
+import (
+	"github.com/HasanNugroho/starter-golang/internal/app"
+	"github.com/HasanNugroho/starter-golang/internal/shared/permission"
+	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
+	"github.com/labstack/echo/v4"
+)
+
+type PermissionHandler struct {
+	registry *permission.Registry
+}
+
+func NewPermissionHandler(app *app.Apps) *PermissionHandler {
+	return &PermissionHandler{registry: app.Permissions}
+}
+
+// FindAllPermissions godoc
+// @Summary      Get permissions
+// @Description  Retrieve every declared permission grouped by category, with its description, risk and dependencies, to build role editors
+// @Tags         permissions
+// @Accept       json
+// @Produce      json
+// @Param        category  query  string  false  "Only return this category"
+// @Success      200  {object}  shared.Response{data=[]permission.Category}
+// @Router       /permissions [get]
+// @Security ApiKeyAuth
+func (h *PermissionHandler) FindAll(ctx echo.Context) error {
+	categories := h.registry.Categories()
+
+	if name := ctx.QueryParam("category"); name != "" {
+		filtered := []permission.Category{}
+		for _, category := range categories {
+			if category.Name == name {
+				filtered = append(filtered, category)
+			}
+		}
+		categories = filtered
+	}
+
+	utils.SendSuccess(ctx, 200, "permissions retrieved successfully", categories)
+	return nil
+}
@@ -0,0 +1,67 @@
+package policies
+
+import (
+	"context"
+	"time"
+
+	"github.com/HasanNugroho/starter-golang/internal/app"
+	"github.com/HasanNugroho/starter-golang/internal/shared/middleware"
+	"github.com/HasanNugroho/starter-golang/internal/shared/permission"
+	"github.com/labstack/echo/v4"
+)
+
+// permissions are the permissions the module declares
+var permissions = []permission.Definition{
+	{Name: "policies:read", Description: "List and view access policies", Category: "policies", Risk: permission.RiskLow},
+	{Name: "policies:manage", Description: "Create, change and delete access policies", Category: "policies", Risk: permission.RiskCritical, DependsOn: []string{"policies:read"}},
+}
+
+type PolicyModule struct {
+	Handler *PolicyHandler
+	service *PolicyService
+}
+
+func NewPolicyModule(app *app.Apps) *PolicyModule {
+	policyService := NewPolicyService(app, NewPolicyRepository(app))
+	return &PolicyModule{
+		Handler: NewPolicyHandler(policyService),
+		service: policyService,
+	}
+}
+
+func (m *PolicyModule) Register(app *app.Apps) error {
+	app.Log.Info().Msg("Policy Module Initialized")
+
+	if err := app.Permissions.Declare(permissions...); err != nil {
+		return err
+	}
+
+	app.Schema.Declare(policySchema)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := m.service.Reload(ctx); err != nil {
+		app.Log.Error().Msg("❌ Failed to load access policies: " + err.Error())
+	}
+
+	// Pick up policies changed through other instances
+	interval := time.Duration(app.Config.Security.PolicyReloadInterval) * time.Minute
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	app.Scheduler.Every("policies:reload", interval, m.service.Reload)
+
+	return nil
+}
+
+func (m *PolicyModule) Route(router *echo.Group, app *app.Apps) {
+	route := router.Group("/v1/policies")
+	{
+		route.Use(middleware.AuthMiddleware(app))
+		route.GET("", m.Handler.FindAll, middleware.CheckAccess([]string{"policies:read", "policies:manage"}))
+		route.GET("/:name", m.Handler.FindByName, middleware.CheckAccess([]string{"policies:read", "policies:manage"}))
+		route.POST("", m.Handler.Create, middleware.CheckAccess([]string{"policies:manage"}))
+		route.PUT("/:name", m.Handler.Update, middleware.CheckAccess([]string{"policies:manage"}))
+		route.DELETE("/:name", m.Handler.Delete, middleware.CheckAccess([]string{"policies:manage"}))
+	}
+}
@@ -0,0 +1,137 @@
+package policies
+
+import (
+	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+type PolicyHandler struct {
+	policyService IPolicyService
+	validate      *validator.Validate
+}
+
+func NewPolicyHandler(ps IPolicyService) *PolicyHandler {
+	return &PolicyHandler{
+		policyService: ps,
+		validate:      validator.New(),
+	}
+}
+
+// CreatePolicy godoc
+// @Summary      Create an access policy
+// @Description  Create a policy granting or denying actions on the resources its condition holds for, e.g. principal.id == resource.id
+// @Tags         policies
+// @Accept       json
+// @Produce      json
+// @Param        policy  body  PolicyCreateModel  true  "Policy"
+// @Success      201  {object}  shared.Response
+// @Failure      400  {object}  shared.Response
+// @Failure      409  {object}  shared.Response
+// @Router       /policies [post]
+// @Security ApiKeyAuth
+func (c *PolicyHandler) Create(ctx echo.Context) error {
+	var payload PolicyCreateModel
+	ctx.Bind(&payload)
+
+	if err := c.validate.Struct(payload); err != nil {
+		return utils.NewBadRequest(err.Error())
+	}
+
+	if err := c.policyService.Create(ctx, &payload); err != nil {
+		return err
+	}
+
+	utils.SendSuccess(ctx, 201, "policy created successfully", nil)
+	return nil
+}
+
+// FindAllPolicies godoc
+// @Summary      Get access policies
+// @Description  Retrieve the policies managed through the API, policies of the policy file are not listed
+// @Tags         policies
+// @Accept       json
+// @Produce      json
+// @Success      200  {object}  shared.Response{data=[]entities.Policy}
+// @Failure      500  {object}  shared.Response
+// @Router       /policies [get]
+// @Security ApiKeyAuth
+func (c *PolicyHandler) FindAll(ctx echo.Context) error {
+	policies, err := c.policyService.FindAll(ctx)
+	if err != nil {
+		return err
+	}
+
+	utils.SendSuccess(ctx, 200, "policies retrieved successfully", policies)
+	return nil
+}
+
+// FindPolicy godoc
+// @Summary      Get access policy
+// @Description  Retrieve a policy by name
+// @Tags         policies
+// @Accept       json
+// @Produce      json
+// @Param name path string true "policy name"
+// @Success      200  {object}  shared.Response{data=entities.Policy}
+// @Failure      404  {object}  shared.Response
+// @Router       /policies/{name} [get]
+// @Security ApiKeyAuth
+func (c *PolicyHandler) FindByName(ctx echo.Context) error {
+	policy, err := c.policyService.FindByName(ctx, ctx.Param("name"))
+	if err != nil {
+		return err
+	}
+
+	utils.SendSuccess(ctx, 200, "policy retrieved successfully", policy)
+	return nil
+}
+
+// UpdatePolicy godoc
+// @Summary      Update access policy
+// @Description  Replace the effect, actions, permission and condition of a policy
+// @Tags         policies
+// @Accept       json
+// @Produce      json
+// @Param name path string true "policy name"
+// @Param        policy  body  PolicyUpdateModel  true  "Policy"
+// @Success      200  {object}  shared.Response
+// @Failure      400  {object}  shared.Response
+// @Failure      404  {object}  shared.Response
+// @Router       /policies/{name} [put]
+// @Security ApiKeyAuth
+func (c *PolicyHandler) Update(ctx echo.Context) error {
+	var payload PolicyUpdateModel
+	ctx.Bind(&payload)
+
+	if err := c.validate.Struct(payload); err != nil {
+		return utils.NewBadRequest(err.Error())
+	}
+
+	if err := c.policyService.Update(ctx, ctx.Param("name"), &payload); err != nil {
+		return err
+	}
+
+	utils.SendSuccess(ctx, 200, "policy updated successfully", nil)
+	return nil
+}
+
+// DeletePolicy godoc
+// @Summary      Delete access policy
+// @Description  Delete a policy by name
+// @Tags         policies
+// @Accept       json
+// @Produce      json
+// @Param name path string true "policy name"
+// @Success      200  {object}  shared.Response
+// @Failure      404  {object}  shared.Response
+// @Router       /policies/{name} [delete]
+// @Security ApiKeyAuth
+func (c *PolicyHandler) Delete(ctx echo.Context) error {
+	if err := c.policyService.Delete(ctx, ctx.Param("name")); err != nil {
+		return err
+	}
+
+	utils.SendSuccess(ctx, 200, "policy deleted successfully", nil)
+	return nil
+}
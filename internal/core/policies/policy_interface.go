@@ -0,0 +1,25 @@
+package policies
+
+import (
+	"context"
+
+	"github.com/HasanNugroho/starter-golang/internal/core/entities"
+	"github.com/labstack/echo/v4"
+)
+
+type IPolicyRepository interface {
+	Create(ctx echo.Context, policy *entities.Policy) error
+	FindAll(ctx context.Context) ([]entities.Policy, error)
+	FindByName(ctx echo.Context, name string) (entities.Policy, error)
+	Update(ctx echo.Context, name string, policy *entities.Policy) error
+	Delete(ctx echo.Context, name string) error
+}
+
+type IPolicyService interface {
+	Create(ctx echo.Context, payload *PolicyCreateModel) error
+	FindAll(ctx echo.Context) ([]entities.Policy, error)
+	FindByName(ctx echo.Context, name string) (entities.Policy, error)
+	Update(ctx echo.Context, name string, payload *PolicyUpdateModel) error
+	Delete(ctx echo.Context, name string) error
+	Reload(ctx context.Context) error
+}
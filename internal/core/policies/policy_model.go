@@ -0,0 +1,15 @@
+package policies
+
+type PolicyCreateModel struct {
+	Name string `json:"name" validate:"required,max=100"`
+	PolicyUpdateModel
+}
+
+// PolicyUpdateModel is the body of a policy, the condition is an expression over
+// principal and resource such as principal.id == resource.id
+type PolicyUpdateModel struct {
+	Effect     string   `json:"effect" validate:"required,oneof=allow deny"`
+	Actions    []string `json:"actions" validate:"required,min=1,dive,required"`
+	Permission string   `json:"permission"`
+	Condition  string   `json:"condition"`
+}
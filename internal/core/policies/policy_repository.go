@@ -0,0 +1,105 @@
+package policies
+
+import (
+	"context"
+	"time"
+
+	"github.com/HasanNugroho/starter-golang/internal/app"
+	"github.com/HasanNugroho/starter-golang/internal/core/entities"
+	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+type PolicyRepository struct {
+	app        *app.Apps
+	collection *mongo.Collection
+}
+
+func NewPolicyRepository(app *app.Apps) *PolicyRepository {
+	return &PolicyRepository{
+		app:        app,
+		collection: app.DB.Collection("policies"),
+	}
+}
+
+func (r *PolicyRepository) Create(ctx echo.Context, policy *entities.Policy) error {
+	c := ctx.Request().Context()
+
+	if _, err := r.collection.InsertOne(c, policy); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return utils.NewConflict("policy already exists")
+		}
+		return utils.NewInternal("failed to create policy")
+	}
+
+	return nil
+}
+
+// FindAll takes a plain context, policies are also loaded outside of requests
+func (r *PolicyRepository) FindAll(ctx context.Context) ([]entities.Policy, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return nil, utils.NewInternal("failed to query data")
+	}
+
+	policies := []entities.Policy{}
+	if err := cursor.All(ctx, &policies); err != nil {
+		return nil, utils.NewInternal("failed to decode data")
+	}
+
+	return policies, nil
+}
+
+func (r *PolicyRepository) FindByName(ctx echo.Context, name string) (entities.Policy, error) {
+	c := ctx.Request().Context()
+
+	var policy entities.Policy
+	if err := r.collection.FindOne(c, bson.M{"name": name}).Decode(&policy); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return entities.Policy{}, utils.NewNotFound("policy not found")
+		}
+		return entities.Policy{}, utils.NewInternal("failed to query data")
+	}
+
+	return policy, nil
+}
+
+func (r *PolicyRepository) Update(ctx echo.Context, name string, policy *entities.Policy) error {
+	c := ctx.Request().Context()
+
+	result, err := r.collection.UpdateOne(c, bson.M{"name": name}, bson.M{
+		"$set": bson.M{
+			"effect":     policy.Effect,
+			"actions":    policy.Actions,
+			"permission": policy.Permission,
+			"condition":  policy.Condition,
+			"updated_at": time.Now(),
+		}})
+	if err != nil {
+		return utils.NewInternal("failed to update policy")
+	}
+
+	if result.MatchedCount == 0 {
+		return utils.NewNotFound("policy not found")
+	}
+
+	return nil
+}
+
+func (r *PolicyRepository) Delete(ctx echo.Context, name string) error {
+	c := ctx.Request().Context()
+
+	result, err := r.collection.DeleteOne(c, bson.M{"name": name})
+	if err != nil {
+		return utils.NewInternal("failed to delete policy")
+	}
+
+	if result.DeletedCount == 0 {
+		return utils.NewNotFound("policy not found")
+	}
+
+	return nil
+}
@@ -0,0 +1,25 @@
+package policies
+
+import (
+	"github.com/HasanNugroho/starter-golang/internal/shared/authz"
+	"github.com/HasanNugroho/starter-golang/internal/shared/modules"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// policySchema declares the collection of access policies
+var policySchema = modules.CollectionSchema{
+	Name: "policies",
+	Indexes: []modules.IndexSpec{
+		{Name: "name_unique", Keys: bson.D{{Key: "name", Value: 1}}, Unique: true},
+	},
+	Validator: bson.M{
+		"bsonType": "object",
+		"required": bson.A{"name", "effect", "actions"},
+		"properties": bson.M{
+			"name":      bson.M{"bsonType": "string", "minLength": 1},
+			"effect":    bson.M{"enum": bson.A{authz.Allow, authz.Deny}},
+			"actions":   bson.M{"bsonType": "array", "minItems": 1, "items": bson.M{"bsonType": "string"}},
+			"condition": bson.M{"bsonType": "string"},
+		},
+	},
+}
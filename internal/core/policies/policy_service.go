@@ -0,0 +1,135 @@
+package policies
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/HasanNugroho/starter-golang/internal/app"
+	"github.com/HasanNugroho/starter-golang/internal/core/entities"
+	"github.com/HasanNugroho/starter-golang/internal/shared/authz"
+	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
+	"github.com/labstack/echo/v4"
+)
+
+type PolicyService struct {
+	repo IPolicyRepository
+	app  *app.Apps
+}
+
+func NewPolicyService(app *app.Apps, repo IPolicyRepository) *PolicyService {
+	return &PolicyService{
+		repo: repo,
+		app:  app,
+	}
+}
+
+func (s *PolicyService) Create(ctx echo.Context, payload *PolicyCreateModel) error {
+	policy := entities.Policy{
+		Name:       payload.Name,
+		Effect:     payload.Effect,
+		Actions:    payload.Actions,
+		Permission: payload.Permission,
+		Condition:  payload.Condition,
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+	}
+	if err := authz.Validate(toAuthz(policy)); err != nil {
+		return utils.NewBadRequest(err.Error())
+	}
+
+	if err := s.repo.Create(ctx, &policy); err != nil {
+		return err
+	}
+
+	return s.Reload(ctx.Request().Context())
+}
+
+func (s *PolicyService) FindAll(ctx echo.Context) ([]entities.Policy, error) {
+	return s.repo.FindAll(ctx.Request().Context())
+}
+
+func (s *PolicyService) FindByName(ctx echo.Context, name string) (entities.Policy, error) {
+	return s.repo.FindByName(ctx, name)
+}
+
+func (s *PolicyService) Update(ctx echo.Context, name string, payload *PolicyUpdateModel) error {
+	policy := entities.Policy{
+		Name:       name,
+		Effect:     payload.Effect,
+		Actions:    payload.Actions,
+		Permission: payload.Permission,
+		Condition:  payload.Condition,
+	}
+	if err := authz.Validate(toAuthz(policy)); err != nil {
+		return utils.NewBadRequest(err.Error())
+	}
+
+	if err := s.repo.Update(ctx, name, &policy); err != nil {
+		return err
+	}
+
+	return s.Reload(ctx.Request().Context())
+}
+
+func (s *PolicyService) Delete(ctx echo.Context, name string) error {
+	if err := s.repo.Delete(ctx, name); err != nil {
+		return err
+	}
+
+	return s.Reload(ctx.Request().Context())
+}
+
+// Reload loads the policies of the policy file and the database into the engine.
+// Other instances pick up changes on their next scheduled reload.
+func (s *PolicyService) Reload(ctx context.Context) error {
+	policies, err := loadPolicyFile(s.app.Config.Security.PolicyFile)
+	if err != nil {
+		return utils.NewInternal(err.Error())
+	}
+
+	stored, err := s.repo.FindAll(ctx)
+	if err != nil {
+		return err
+	}
+	for _, policy := range stored {
+		policies = append(policies, toAuthz(policy))
+	}
+
+	if err := s.app.Authz.Load(policies); err != nil {
+		return utils.NewInternal("failed to load policies: " + err.Error())
+	}
+
+	return nil
+}
+
+// loadPolicyFile reads a JSON array of policies, no path means no policies
+func loadPolicyFile(path string) ([]authz.Policy, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy file: %w", err)
+	}
+
+	var policies []authz.Policy
+	if err := json.Unmarshal(content, &policies); err != nil {
+		return nil, fmt.Errorf("failed to parse policy file: %w", err)
+	}
+
+	return policies, nil
+}
+
+func toAuthz(policy entities.Policy) authz.Policy {
+	return authz.Policy{
+		Name:       policy.Name,
+		Effect:     policy.Effect,
+		Actions:    policy.Actions,
+		Permission: policy.Permission,
+		Condition:  policy.Condition,
+	}
+}
@@ -0,0 +1,72 @@
+package privacy
+
+import (
+	"context"
+	"time"
+
+	"github.com/HasanNugroho/starter-golang/internal/app"
+	"github.com/HasanNugroho/starter-golang/internal/shared/middleware"
+	"github.com/HasanNugroho/starter-golang/internal/shared/permission"
+	"github.com/labstack/echo/v4"
+)
+
+// permissions are the permissions the module declares
+var permissions = []permission.Definition{
+	{Name: "privacy:export", Description: "Export the personal data of users", Category: "privacy", Risk: permission.RiskHigh, DependsOn: []string{"users:read"}},
+	{Name: "privacy:erase", Description: "Erase the personal data of users", Category: "privacy", Risk: permission.RiskCritical, DependsOn: []string{"users:read"}},
+}
+
+type PrivacyModule struct {
+	Handler *PrivacyHandler
+	service *PrivacyService
+}
+
+func NewPrivacyModule(app *app.Apps) *PrivacyModule {
+	privacyRepository := NewPrivacyRepository(app)
+	privacyService := NewPrivacyService(app, privacyRepository)
+	privacyHandler := NewPrivacyHandler(privacyService)
+	return &PrivacyModule{
+		Handler: privacyHandler,
+		service: privacyService,
+	}
+}
+
+func (m *PrivacyModule) Register(app *app.Apps) error {
+	app.Log.Info().Msg("Privacy Module Initialized")
+
+	if err := app.Permissions.Declare(permissions...); err != nil {
+		return err
+	}
+
+	app.Schema.Declare(privacySchema)
+
+	// Run the jobs left behind by a stopped instance
+	app.Scheduler.Every("privacy:jobs", time.Minute, func(ctx context.Context) error {
+		resumed, err := m.service.RunPending(ctx)
+		if err == nil && resumed > 0 {
+			app.Log.Info().Msgf("Ran %d pending privacy jobs", resumed)
+		}
+		return err
+	})
+
+	// Delete export archives once they are no longer downloadable
+	app.Scheduler.Every("privacy:archives", time.Duration(app.Config.DB.PurgeInterval)*time.Minute, func(ctx context.Context) error {
+		expired, err := m.service.ExpireArchives(ctx)
+		if err == nil && expired > 0 {
+			app.Log.Info().Msgf("Deleted %d expired personal data archives", expired)
+		}
+		return err
+	})
+
+	return nil
+}
+
+func (m *PrivacyModule) Route(router *echo.Group, app *app.Apps) {
+	route := router.Group("/v1/privacy")
+	{
+		route.Use(middleware.AuthMiddleware(app))
+		route.POST("/users/:id/export", m.Handler.Export, middleware.CheckAccess([]string{"privacy:export"}))
+		route.POST("/users/:id/erasure", m.Handler.Erase, middleware.CheckAccess([]string{"privacy:erase"}))
+		route.GET("/jobs/:id", m.Handler.FindJob, middleware.CheckAccess([]string{"privacy:export", "privacy:erase"}))
+	}
+}
@@ -0,0 +1,86 @@
+package privacy
+
+import (
+	"net/http"
+
+	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
+	"github.com/labstack/echo/v4"
+)
+
+type PrivacyHandler struct {
+	privacyService IPrivacyService
+}
+
+func NewPrivacyHandler(ps IPrivacyService) *PrivacyHandler {
+	return &PrivacyHandler{
+		privacyService: ps,
+	}
+}
+
+// ExportUserData godoc
+// @Summary      Export personal data
+// @Description  Build a zip archive of everything held about a user (profile, roles, sessions, imports, uploads and any data other features declare) in the background. There is no audit log, archives hold no audit entries. Poll the job for a signed download URL.
+// @Tags         privacy
+// @Accept       json
+// @Produce      json
+// @Param id path string true "user id"
+// @Success      202  {object}  shared.Response{data=entities.PrivacyJob}
+// @Failure      400  {object}  shared.Response
+// @Failure      404  {object}  shared.Response
+// @Failure      500  {object}  shared.Response
+// @Router       /privacy/users/{id}/export [post]
+// @Security ApiKeyAuth
+func (c *PrivacyHandler) Export(ctx echo.Context) error {
+	job, err := c.privacyService.StartExport(ctx, ctx.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	utils.SendSuccess(ctx, http.StatusAccepted, "export started", job)
+	return nil
+}
+
+// EraseUserData godoc
+// @Summary      Erase personal data
+// @Description  Anonymize or delete the personal data of a user in every feature that declares personal data, and delete earlier export archives. Runs in the background and can not be undone.
+// @Tags         privacy
+// @Accept       json
+// @Produce      json
+// @Param id path string true "user id"
+// @Success      202  {object}  shared.Response{data=entities.PrivacyJob}
+// @Failure      400  {object}  shared.Response
+// @Failure      404  {object}  shared.Response
+// @Failure      500  {object}  shared.Response
+// @Router       /privacy/users/{id}/erasure [post]
+// @Security ApiKeyAuth
+func (c *PrivacyHandler) Erase(ctx echo.Context) error {
+	job, err := c.privacyService.StartErasure(ctx, ctx.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	utils.SendSuccess(ctx, http.StatusAccepted, "erasure started", job)
+	return nil
+}
+
+// FindPrivacyJob godoc
+// @Summary      Get privacy job
+// @Description  Retrieve the status of a personal data export or erasure, completed exports include a signed, expiring archive URL
+// @Tags         privacy
+// @Accept       json
+// @Produce      json
+// @Param id path string true "job id"
+// @Success      200  {object}  shared.Response{data=entities.PrivacyJob}
+// @Failure      404  {object}  shared.Response
+// @Failure      500  {object}  shared.Response
+// @Router       /privacy/jobs/{id} [get]
+// @Security ApiKeyAuth
+func (c *PrivacyHandler) FindJob(ctx echo.Context) error {
+	job, err := c.privacyService.FindJob(ctx, ctx.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	utils.SendSuccess(ctx, http.StatusOK, "privacy job retrieved successfully", job)
+	return nil
+}
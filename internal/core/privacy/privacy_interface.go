@@ -0,0 +1,29 @@
+package privacy
+
+import (
+	"context"
+	"time"
+
+	"github.com/HasanNugroho/starter-golang/internal/core/entities"
+	"github.com/HasanNugroho/starter-golang/internal/shared/modules"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type IPrivacyRepository interface {
+	CreateJob(ctx echo.Context, job *entities.PrivacyJob) error
+	FindJob(ctx echo.Context, id string) (entities.PrivacyJob, error)
+	SaveJob(ctx context.Context, job *entities.PrivacyJob) error
+	ClaimJob(ctx context.Context, id bson.ObjectID, lease time.Duration) (entities.PrivacyJob, bool, error)
+	ClaimNext(ctx context.Context, lease time.Duration) (entities.PrivacyJob, bool, error)
+	FindSubject(ctx echo.Context, userID string) (modules.DataSubject, error)
+	Subject(ctx context.Context, userID bson.ObjectID) (modules.DataSubject, error)
+	FindArchives(ctx context.Context, userID bson.ObjectID) ([]entities.PrivacyJob, error)
+	FindExpiredArchives(ctx context.Context, now time.Time) ([]entities.PrivacyJob, error)
+}
+
+type IPrivacyService interface {
+	StartExport(ctx echo.Context, userID string) (entities.PrivacyJob, error)
+	StartErasure(ctx echo.Context, userID string) (entities.PrivacyJob, error)
+	FindJob(ctx echo.Context, id string) (entities.PrivacyJob, error)
+}
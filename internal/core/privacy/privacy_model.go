@@ -0,0 +1,21 @@
+package privacy
+
+const (
+	JobExport  = "export"
+	JobErasure = "erasure"
+)
+
+const (
+	JobPending   = "pending"
+	JobRunning   = "running"
+	JobCompleted = "completed"
+	JobFailed    = "failed"
+	JobExpired   = "expired"
+)
+
+// ArchiveManifest describes a personal data archive, it is stored as manifest.json
+type ArchiveManifest struct {
+	UserID      string   `json:"user_id"`
+	GeneratedAt string   `json:"generated_at"`
+	Sections    []string `json:"sections"`
+}
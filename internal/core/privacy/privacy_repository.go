@@ -0,0 +1,156 @@
+package privacy
+
+import (
+	"context"
+	"time"
+
+	"github.com/HasanNugroho/starter-golang/internal/app"
+	"github.com/HasanNugroho/starter-golang/internal/core/entities"
+	"github.com/HasanNugroho/starter-golang/internal/shared/modules"
+	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+type PrivacyRepository struct {
+	app   *app.Apps
+	jobs  *mongo.Collection
+	users *mongo.Collection
+}
+
+func NewPrivacyRepository(app *app.Apps) *PrivacyRepository {
+	return &PrivacyRepository{
+		app:   app,
+		jobs:  app.DB.Collection("privacy_jobs"),
+		users: app.DB.Collection("users"),
+	}
+}
+
+func (r *PrivacyRepository) CreateJob(ctx echo.Context, job *entities.PrivacyJob) error {
+	c := ctx.Request().Context()
+
+	result, err := r.jobs.InsertOne(c, job)
+	if err != nil {
+		return utils.NewInternal("failed to create privacy job")
+	}
+
+	job.ID = result.InsertedID.(bson.ObjectID)
+	return nil
+}
+
+func (r *PrivacyRepository) FindJob(ctx echo.Context, id string) (entities.PrivacyJob, error) {
+	c := ctx.Request().Context()
+
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return entities.PrivacyJob{}, utils.NewBadRequest("invalid id format")
+	}
+
+	var job entities.PrivacyJob
+	if err := r.jobs.FindOne(c, bson.M{"_id": objectID}).Decode(&job); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return entities.PrivacyJob{}, utils.NewNotFound("data not found")
+		}
+		return entities.PrivacyJob{}, utils.NewInternal("failed to find data")
+	}
+
+	return job, nil
+}
+
+func (r *PrivacyRepository) SaveJob(ctx context.Context, job *entities.PrivacyJob) error {
+	if _, err := r.jobs.ReplaceOne(ctx, bson.M{"_id": job.ID}, job); err != nil {
+		return utils.NewInternal("failed to update privacy job")
+	}
+	return nil
+}
+
+// ClaimJob takes the job for the caller until the lease ends. A job can be
+// claimed while pending or once the lease of its previous worker ended, false
+// means someone else works on it or it is finished.
+func (r *PrivacyRepository) ClaimJob(ctx context.Context, id bson.ObjectID, lease time.Duration) (entities.PrivacyJob, bool, error) {
+	return r.claim(ctx, bson.M{"_id": id}, lease)
+}
+
+// ClaimNext claims any job nobody works on, the oldest first
+func (r *PrivacyRepository) ClaimNext(ctx context.Context, lease time.Duration) (entities.PrivacyJob, bool, error) {
+	return r.claim(ctx, bson.M{}, lease)
+}
+
+func (r *PrivacyRepository) claim(ctx context.Context, filter bson.M, lease time.Duration) (entities.PrivacyJob, bool, error) {
+	now := time.Now()
+	filter["$or"] = bson.A{
+		bson.M{"status": JobPending},
+		bson.M{"status": JobRunning, "lease_until": bson.M{"$lt": now}},
+		// Started before jobs had leases
+		bson.M{"status": JobRunning, "lease_until": nil},
+	}
+	update := bson.M{
+		"$set": bson.M{"status": JobRunning, "lease_until": now.Add(lease)},
+		"$inc": bson.M{"attempts": 1},
+	}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "created_at", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	var job entities.PrivacyJob
+	if err := r.jobs.FindOneAndUpdate(ctx, filter, update, opts).Decode(&job); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return entities.PrivacyJob{}, false, nil
+		}
+		return entities.PrivacyJob{}, false, utils.NewInternal("failed to claim privacy job")
+	}
+	return job, true, nil
+}
+
+// FindSubject loads the user a request is about, deleted users included since
+// their data is kept until the purge
+func (r *PrivacyRepository) FindSubject(ctx echo.Context, userID string) (modules.DataSubject, error) {
+	objectID, err := bson.ObjectIDFromHex(userID)
+	if err != nil {
+		return modules.DataSubject{}, utils.NewBadRequest("invalid id format")
+	}
+
+	return r.Subject(ctx.Request().Context(), objectID)
+}
+
+// Subject loads the data subject of a job
+func (r *PrivacyRepository) Subject(c context.Context, objectID bson.ObjectID) (modules.DataSubject, error) {
+	var user struct {
+		Email string `bson:"email"`
+	}
+	opts := options.FindOne().SetProjection(bson.M{"email": 1})
+	if err := r.users.FindOne(c, bson.M{"_id": objectID}, opts).Decode(&user); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return modules.DataSubject{}, utils.NewNotFound("user not found")
+		}
+		return modules.DataSubject{}, utils.NewInternal("failed to find data")
+	}
+
+	return modules.DataSubject{ID: objectID, Email: user.Email}, nil
+}
+
+// FindArchives returns the export jobs of the user whose archive is still stored
+func (r *PrivacyRepository) FindArchives(ctx context.Context, userID bson.ObjectID) ([]entities.PrivacyJob, error) {
+	return r.findJobs(ctx, bson.M{"user_id": userID, "archive_key": bson.M{"$exists": true}})
+}
+
+// FindExpiredArchives returns the export jobs whose archive expired before now
+func (r *PrivacyRepository) FindExpiredArchives(ctx context.Context, now time.Time) ([]entities.PrivacyJob, error) {
+	return r.findJobs(ctx, bson.M{"archive_key": bson.M{"$exists": true}, "expires_at": bson.M{"$lt": now}})
+}
+
+func (r *PrivacyRepository) findJobs(ctx context.Context, filter bson.M) ([]entities.PrivacyJob, error) {
+	cursor, err := r.jobs.Find(ctx, filter)
+	if err != nil {
+		return nil, utils.NewInternal("failed to query data")
+	}
+	defer cursor.Close(ctx)
+
+	jobs := []entities.PrivacyJob{}
+	if err := cursor.All(ctx, &jobs); err != nil {
+		return nil, utils.NewInternal("failed to decode data")
+	}
+	return jobs, nil
+}
@@ -0,0 +1,27 @@
+package privacy
+
+import (
+	"github.com/HasanNugroho/starter-golang/internal/shared/modules"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// privacySchema declares the privacy jobs collection.
+// Archives expire through the scheduler rather than a TTL index, their files must go too.
+var privacySchema = modules.CollectionSchema{
+	Name: "privacy_jobs",
+	Indexes: []modules.IndexSpec{
+		{Name: "user_id", Keys: bson.D{{Key: "user_id", Value: 1}}},
+		{Name: "status_created_at", Keys: bson.D{{Key: "status", Value: 1}, {Key: "created_at", Value: 1}}},
+		{Name: "archive_expires_at", Keys: bson.D{{Key: "expires_at", Value: 1}}, Partial: bson.M{"archive_key": bson.M{"$exists": true}}},
+	},
+	Validator: bson.M{
+		"bsonType": "object",
+		"required": bson.A{"type", "user_id", "status", "created_at"},
+		"properties": bson.M{
+			"type":       bson.M{"enum": bson.A{JobExport, JobErasure}},
+			"user_id":    bson.M{"bsonType": "objectId"},
+			"status":     bson.M{"bsonType": "string"},
+			"created_at": bson.M{"bsonType": "date"},
+		},
+	},
+}
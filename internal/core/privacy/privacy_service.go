@@ -0,0 +1,299 @@
+package privacy
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/HasanNugroho/starter-golang/internal/app"
+	"github.com/HasanNugroho/starter-golang/internal/core/entities"
+	"github.com/HasanNugroho/starter-golang/internal/shared/modules"
+	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// jobLease is how long a worker owns a job, a job still running past it is
+// considered abandoned, e.g. by a restart, and run again
+const jobLease = 30 * time.Minute
+
+// jobAttempts is how many times a job is started before it is failed
+const jobAttempts = 3
+
+type PrivacyService struct {
+	repo IPrivacyRepository
+	app  *app.Apps
+}
+
+func NewPrivacyService(app *app.Apps, repo IPrivacyRepository) *PrivacyService {
+	return &PrivacyService{
+		repo: repo,
+		app:  app,
+	}
+}
+
+// StartExport builds an archive of the personal data of the user in the background
+func (s *PrivacyService) StartExport(ctx echo.Context, userID string) (entities.PrivacyJob, error) {
+	return s.start(ctx, JobExport, userID)
+}
+
+// StartErasure erases the personal data of the user in the background
+func (s *PrivacyService) StartErasure(ctx echo.Context, userID string) (entities.PrivacyJob, error) {
+	return s.start(ctx, JobErasure, userID)
+}
+
+// FindJob returns the job with a signed download URL once its archive is ready
+func (s *PrivacyService) FindJob(ctx echo.Context, id string) (entities.PrivacyJob, error) {
+	job, err := s.repo.FindJob(ctx, id)
+	if err != nil {
+		return entities.PrivacyJob{}, err
+	}
+
+	if job.Status == JobCompleted && job.ArchiveKey != "" {
+		if job.ArchiveURL, err = s.app.Files.URL(job.ArchiveKey); err != nil {
+			return entities.PrivacyJob{}, err
+		}
+	}
+
+	return job, nil
+}
+
+// RunPending runs the jobs nobody works on: those whose worker stopped before
+// finishing and those not started yet. Exports and erasures are safe to run again.
+func (s *PrivacyService) RunPending(ctx context.Context) (int, error) {
+	for count := 0; ; count++ {
+		job, claimed, err := s.repo.ClaimNext(ctx, jobLease)
+		if err != nil || !claimed {
+			return count, err
+		}
+		s.run(ctx, job)
+	}
+}
+
+// ExpireArchives deletes the archives that are past their retention
+func (s *PrivacyService) ExpireArchives(ctx context.Context) (int, error) {
+	jobs, err := s.repo.FindExpiredArchives(ctx, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range jobs {
+		if err := s.removeArchive(ctx, &jobs[i], JobExpired); err != nil {
+			return i, err
+		}
+	}
+	return len(jobs), nil
+}
+
+func (s *PrivacyService) start(ctx echo.Context, jobType string, userID string) (entities.PrivacyJob, error) {
+	subject, err := s.repo.FindSubject(ctx, userID)
+	if err != nil {
+		return entities.PrivacyJob{}, err
+	}
+
+	job := entities.PrivacyJob{
+		Type:      jobType,
+		UserID:    subject.ID,
+		Status:    JobPending,
+		Sections:  []string{},
+		CreatedAt: time.Now(),
+	}
+	if requestedBy, err := bson.ObjectIDFromHex(utils.CurrentUserID(ctx)); err == nil {
+		job.RequestedBy = &requestedBy
+	}
+
+	if err := s.repo.CreateJob(ctx, &job); err != nil {
+		return entities.PrivacyJob{}, err
+	}
+
+	// Start right away, RunPending picks the job up if this instance stops first
+	go func() {
+		ctx := context.Background()
+		claimed, ok, err := s.repo.ClaimJob(ctx, job.ID, jobLease)
+		if err != nil {
+			s.app.Log.Error().Err(err).Msgf("Failed to start privacy job %s", job.ID.Hex())
+		}
+		if ok {
+			s.run(ctx, claimed)
+		}
+	}()
+
+	return job, nil
+}
+
+// run works on a claimed job until it completes or fails
+func (s *PrivacyService) run(ctx context.Context, job entities.PrivacyJob) {
+	var err error
+	if job.Attempts > jobAttempts {
+		err = fmt.Errorf("gave up after %d attempts", jobAttempts)
+	} else {
+		err = s.process(ctx, &job)
+	}
+
+	if err != nil {
+		s.app.Log.Error().Err(err).Msgf("Privacy %s %s failed", job.Type, job.ID.Hex())
+		job.Status = JobFailed
+		job.Error = err.Error()
+	} else {
+		job.Status = JobCompleted
+	}
+
+	finishedAt := time.Now()
+	job.FinishedAt = &finishedAt
+	job.LeaseUntil = nil
+	s.save(ctx, &job)
+}
+
+func (s *PrivacyService) process(ctx context.Context, job *entities.PrivacyJob) error {
+	subject, err := s.repo.Subject(ctx, job.UserID)
+	if err != nil {
+		return err
+	}
+
+	// A job run again starts over
+	job.Sections = []string{}
+	if job.Type == JobExport {
+		return s.export(ctx, job, subject)
+	}
+	return s.erase(ctx, job, subject)
+}
+
+// export writes every registered source into a zip archive and stores it.
+// Any failing source fails the export, a partial archive would mislead the subject.
+func (s *PrivacyService) export(ctx context.Context, job *entities.PrivacyJob, subject modules.DataSubject) error {
+	file, err := os.CreateTemp("", "privacy-export-*.zip")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	archive := &zipArchive{writer: zip.NewWriter(file)}
+	for _, source := range s.app.PersonalData.Sources() {
+		if source.Export == nil {
+			continue
+		}
+		if err := source.Export(ctx, subject, archive); err != nil {
+			return fmt.Errorf("export %s: %w", source.Name, err)
+		}
+		job.Sections = append(job.Sections, source.Name)
+	}
+
+	manifest := ArchiveManifest{
+		UserID:      subject.ID.Hex(),
+		GeneratedAt: time.Now().Format(time.RFC3339),
+		Sections:    job.Sections,
+	}
+	if err := archive.AddJSON("manifest.json", manifest); err != nil {
+		return err
+	}
+	if err := archive.writer.Close(); err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("privacy/%s/%s.zip", subject.ID.Hex(), job.ID.Hex())
+	if err := s.app.Storage.Put(ctx, key, file, info.Size(), "application/zip"); err != nil {
+		return err
+	}
+
+	retention := time.Duration(s.app.Config.DB.DataExportRetention) * 24 * time.Hour
+	if retention <= 0 {
+		retention = 7 * 24 * time.Hour
+	}
+	expiresAt := time.Now().Add(retention)
+	job.ArchiveKey = key
+	job.ExpiresAt = &expiresAt
+	return nil
+}
+
+// erase runs every registered source in reverse registration order, so features
+// built on top of others erase their data while the data they depend on still exists.
+// A failing source does not stop the others, the job reports every failure.
+func (s *PrivacyService) erase(ctx context.Context, job *entities.PrivacyJob, subject modules.DataSubject) error {
+	var failures []string
+
+	// Earlier exports are copies of the personal data as well
+	archives, err := s.repo.FindArchives(ctx, subject.ID)
+	if err != nil {
+		failures = append(failures, "archives: "+err.Error())
+	}
+	for i := range archives {
+		if err := s.removeArchive(ctx, &archives[i], JobExpired); err != nil {
+			failures = append(failures, "archives: "+err.Error())
+		}
+	}
+
+	sources := s.app.PersonalData.Sources()
+	for i := len(sources) - 1; i >= 0; i-- {
+		source := sources[i]
+		if source.Erase == nil {
+			continue
+		}
+		if err := source.Erase(ctx, subject); err != nil {
+			failures = append(failures, source.Name+": "+err.Error())
+			continue
+		}
+		job.Sections = append(job.Sections, source.Name)
+	}
+
+	if len(failures) > 0 {
+		return errors.New("erase " + strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+func (s *PrivacyService) removeArchive(ctx context.Context, job *entities.PrivacyJob, status string) error {
+	if err := s.app.Storage.Delete(ctx, job.ArchiveKey); err != nil {
+		return err
+	}
+
+	job.ArchiveKey = ""
+	job.Status = status
+	return s.repo.SaveJob(ctx, job)
+}
+
+func (s *PrivacyService) save(ctx context.Context, job *entities.PrivacyJob) {
+	if err := s.repo.SaveJob(ctx, job); err != nil {
+		s.app.Log.Error().Err(err).Msgf("Failed to save privacy job %s", job.ID.Hex())
+	}
+}
+
+// zipArchive writes a personal data archive as a zip file
+type zipArchive struct {
+	writer *zip.Writer
+}
+
+func (a *zipArchive) AddJSON(name string, value any) error {
+	w, err := a.writer.Create(name)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+func (a *zipArchive) AddFile(name string, body io.Reader) error {
+	w, err := a.writer.Create(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(w, body)
+	return err
+}
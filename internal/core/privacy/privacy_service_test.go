@@ -0,0 +1,210 @@
+package privacy
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/HasanNugroho/starter-golang/config"
+	"github.com/HasanNugroho/starter-golang/internal/app"
+	"github.com/HasanNugroho/starter-golang/internal/core/entities"
+	"github.com/HasanNugroho/starter-golang/internal/shared/modules"
+	"github.com/HasanNugroho/starter-golang/internal/shared/storage"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type MockPrivacyRepo struct {
+	IPrivacyRepository
+	mock.Mock
+}
+
+func (m *MockPrivacyRepo) SaveJob(ctx context.Context, job *entities.PrivacyJob) error {
+	args := m.Called(ctx, job)
+	return args.Error(0)
+}
+
+func (m *MockPrivacyRepo) FindArchives(ctx context.Context, userID bson.ObjectID) ([]entities.PrivacyJob, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]entities.PrivacyJob), args.Error(1)
+}
+
+func (m *MockPrivacyRepo) ClaimNext(ctx context.Context, lease time.Duration) (entities.PrivacyJob, bool, error) {
+	args := m.Called(ctx, lease)
+	return args.Get(0).(entities.PrivacyJob), args.Bool(1), args.Error(2)
+}
+
+func (m *MockPrivacyRepo) Subject(ctx context.Context, userID bson.ObjectID) (modules.DataSubject, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).(modules.DataSubject), args.Error(1)
+}
+
+func newTestService(t *testing.T, repo IPrivacyRepository, sources ...modules.PersonalDataSource) (*PrivacyService, storage.Storage) {
+	store, err := storage.NewLocalStorage(t.TempDir())
+	require.NoError(t, err)
+
+	registry := modules.NewPersonalDataRegistry()
+	for _, source := range sources {
+		registry.Register(source)
+	}
+
+	logger := zerolog.Nop()
+	return NewPrivacyService(&app.Apps{
+		Config:       &config.Config{},
+		Log:          &logger,
+		Storage:      store,
+		PersonalData: registry,
+	}, repo), store
+}
+
+func TestPrivacyService_Export_BuildsArchive(t *testing.T) {
+	subject := modules.DataSubject{ID: bson.NewObjectID(), Email: "john@example.com"}
+	profile := modules.PersonalDataSource{
+		Name: "profile",
+		Export: func(ctx context.Context, s modules.DataSubject, archive modules.PersonalDataArchive) error {
+			return archive.AddJSON("profile.json", map[string]string{"email": s.Email})
+		},
+	}
+	uploads := modules.PersonalDataSource{
+		Name: "uploads",
+		Export: func(ctx context.Context, s modules.DataSubject, archive modules.PersonalDataArchive) error {
+			return archive.AddFile("uploads/avatar/64.png", strings.NewReader("png"))
+		},
+	}
+	service, store := newTestService(t, new(MockPrivacyRepo), profile, uploads)
+
+	job := entities.PrivacyJob{ID: bson.NewObjectID(), Type: JobExport, UserID: subject.ID}
+	require.NoError(t, service.export(context.Background(), &job, subject))
+
+	assert.Equal(t, []string{"profile", "uploads"}, job.Sections)
+	assert.NotNil(t, job.ExpiresAt)
+	assert.WithinDuration(t, time.Now().Add(7*24*time.Hour), *job.ExpiresAt, time.Minute)
+
+	reader, _, err := store.Get(context.Background(), job.ArchiveKey)
+	require.NoError(t, err)
+	data, _ := io.ReadAll(reader)
+	reader.Close()
+
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+
+	files := map[string]string{}
+	for _, file := range archive.File {
+		r, _ := file.Open()
+		content, _ := io.ReadAll(r)
+		r.Close()
+		files[file.Name] = string(content)
+	}
+
+	assert.Contains(t, files["profile.json"], "john@example.com")
+	assert.Equal(t, "png", files["uploads/avatar/64.png"])
+
+	var manifest ArchiveManifest
+	require.NoError(t, json.Unmarshal([]byte(files["manifest.json"]), &manifest))
+	assert.Equal(t, subject.ID.Hex(), manifest.UserID)
+	assert.Equal(t, []string{"profile", "uploads"}, manifest.Sections)
+}
+
+func TestPrivacyService_Export_FailsOnSourceError(t *testing.T) {
+	broken := modules.PersonalDataSource{
+		Name: "sessions",
+		Export: func(ctx context.Context, s modules.DataSubject, archive modules.PersonalDataArchive) error {
+			return errors.New("redis down")
+		},
+	}
+	service, _ := newTestService(t, new(MockPrivacyRepo), broken)
+
+	job := entities.PrivacyJob{ID: bson.NewObjectID(), Type: JobExport}
+	err := service.export(context.Background(), &job, modules.DataSubject{ID: bson.NewObjectID()})
+
+	assert.EqualError(t, err, "export sessions: redis down")
+	assert.Empty(t, job.ArchiveKey)
+}
+
+func TestPrivacyService_Erase_ReverseOrderAndRemovesArchives(t *testing.T) {
+	subject := modules.DataSubject{ID: bson.NewObjectID(), Email: "john@example.com"}
+
+	var order []string
+	source := func(name string, err error) modules.PersonalDataSource {
+		return modules.PersonalDataSource{
+			Name: name,
+			Erase: func(ctx context.Context, s modules.DataSubject) error {
+				order = append(order, name)
+				return err
+			},
+		}
+	}
+
+	mockRepo := new(MockPrivacyRepo)
+	service, store := newTestService(t, mockRepo,
+		source("profile", nil),
+		source("sessions", errors.New("redis down")),
+		source("uploads", nil),
+	)
+
+	ctx := context.Background()
+	archiveKey := "privacy/" + subject.ID.Hex() + "/old.zip"
+	require.NoError(t, store.Put(ctx, archiveKey, strings.NewReader("zip"), 3, "application/zip"))
+
+	mockRepo.On("FindArchives", ctx, subject.ID).Return([]entities.PrivacyJob{{ArchiveKey: archiveKey, Status: JobCompleted}}, nil)
+	mockRepo.On("SaveJob", ctx, mock.MatchedBy(func(job *entities.PrivacyJob) bool {
+		return job.ArchiveKey == "" && job.Status == JobExpired
+	})).Return(nil)
+
+	job := entities.PrivacyJob{ID: bson.NewObjectID(), Type: JobErasure}
+	err := service.erase(ctx, &job, subject)
+
+	assert.EqualError(t, err, "erase sessions: redis down")
+	assert.Equal(t, []string{"uploads", "sessions", "profile"}, order)
+	assert.Equal(t, []string{"uploads", "profile"}, job.Sections)
+
+	_, _, err = store.Get(ctx, archiveKey)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPrivacyService_RunPending_ResumesAbandonedJobs(t *testing.T) {
+	subject := modules.DataSubject{ID: bson.NewObjectID(), Email: "john@example.com"}
+	var erased []string
+	profile := modules.PersonalDataSource{
+		Name: "profile",
+		Erase: func(ctx context.Context, s modules.DataSubject) error {
+			erased = append(erased, s.Email)
+			return nil
+		},
+	}
+
+	mockRepo := new(MockPrivacyRepo)
+	service, _ := newTestService(t, mockRepo, profile)
+	ctx := context.Background()
+
+	abandoned := entities.PrivacyJob{ID: bson.NewObjectID(), Type: JobErasure, UserID: subject.ID, Status: JobRunning, Sections: []string{"profile"}, Attempts: 2}
+	exhausted := entities.PrivacyJob{ID: bson.NewObjectID(), Type: JobErasure, UserID: subject.ID, Status: JobRunning, Attempts: jobAttempts + 1}
+	mockRepo.On("ClaimNext", ctx, jobLease).Return(abandoned, true, nil).Once()
+	mockRepo.On("ClaimNext", ctx, jobLease).Return(exhausted, true, nil).Once()
+	mockRepo.On("ClaimNext", ctx, jobLease).Return(entities.PrivacyJob{}, false, nil).Once()
+	mockRepo.On("Subject", ctx, subject.ID).Return(subject, nil).Once()
+	mockRepo.On("FindArchives", ctx, subject.ID).Return([]entities.PrivacyJob{}, nil)
+	mockRepo.On("SaveJob", ctx, mock.MatchedBy(func(job *entities.PrivacyJob) bool {
+		return job.ID == abandoned.ID && job.Status == JobCompleted && job.LeaseUntil == nil && len(job.Sections) == 1
+	})).Return(nil).Once()
+	mockRepo.On("SaveJob", ctx, mock.MatchedBy(func(job *entities.PrivacyJob) bool {
+		return job.ID == exhausted.ID && job.Status == JobFailed
+	})).Return(nil).Once()
+
+	count, err := service.RunPending(ctx)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, []string{"john@example.com"}, erased)
+	mockRepo.AssertExpectations(t)
+}
@@ -1,13 +1,32 @@
 package roles
 
 import (
+	"context"
+	"time"
+
 	"github.com/HasanNugroho/starter-golang/internal/app"
 	"github.com/HasanNugroho/starter-golang/internal/shared/middleware"
+	"github.com/HasanNugroho/starter-golang/internal/shared/modules"
+	"github.com/HasanNugroho/starter-golang/internal/shared/permission"
 	"github.com/labstack/echo/v4"
 )
 
+// permissions are the permissions the module declares
+var permissions = []permission.Definition{
+	{Name: "roles:create", Description: "Create roles", Category: "roles", Risk: permission.RiskHigh, DependsOn: []string{"roles:read"}},
+	{Name: "roles:read", Description: "List and view roles with their permissions", Category: "roles", Risk: permission.RiskLow},
+	{Name: "roles:update", Description: "Change the name, parents and permissions of roles", Category: "roles", Risk: permission.RiskHigh, DependsOn: []string{"roles:read"}},
+	{Name: "roles:delete", Description: "Move roles to the trash", Category: "roles", Risk: permission.RiskHigh, DependsOn: []string{"roles:read"}},
+	{Name: "roles:assign", Description: "Assign roles to users", Category: "roles", Risk: permission.RiskHigh, DependsOn: []string{"users:read"}},
+	{Name: "roles:unassign", Description: "Remove roles from users", Category: "roles", Risk: permission.RiskMedium, DependsOn: []string{"users:read"}},
+	{Name: "roles:trash", Description: "List deleted roles", Category: "roles", Risk: permission.RiskLow},
+	{Name: "roles:restore", Description: "Restore deleted roles", Category: "roles", Risk: permission.RiskHigh, DependsOn: []string{"roles:trash"}},
+}
+
 type RoleModule struct {
-	Handler *RoleHandler
+	Handler    *RoleHandler
+	service    *RoleService
+	repository *RoleRepository
 }
 
 func NewRoleModule(app *app.Apps) *RoleModule {
@@ -15,24 +34,43 @@ func NewRoleModule(app *app.Apps) *RoleModule {
 	roleService := NewRoleService(app, roleRepository)
 	roleHandler := NewRoleHandler(roleService)
 	return &RoleModule{
-		Handler: roleHandler,
+		Handler:    roleHandler,
+		service:    roleService,
+		repository: roleRepository,
 	}
 }
 
 func (u *RoleModule) Register(app *app.Apps) error {
 	app.Log.Info().Msg("Role Module Initialized")
 
-	permission := []string{
-		"roles:unassign",
-		"roles:read",
-		"roles:update",
-		"roles:delete",
-		"roles:assign",
-		"roles:unassign",
+	if err := app.Permissions.Declare(permissions...); err != nil {
+		return err
+	}
+
+	app.Schema.Declare(roleSchema)
+	app.PersonalData.Register(assignmentsDataSource(app))
+
+	// Take expired time-bound assignments away and end the sessions built on them
+	sweepInterval := time.Duration(app.Config.Security.RoleSweepInterval) * time.Minute
+	if sweepInterval <= 0 {
+		sweepInterval = time.Minute
 	}
+	app.Scheduler.Every("roles:assignments", sweepInterval, u.service.SweepAssignments)
 
-	// Merge permission
-	app.Config.ModulePermissions = append(app.Config.ModulePermissions, permission...)
+	app.Seeds.Register(modules.Seed{Name: "roles:system", Run: u.service.SeedSystemRoles})
+	app.Seeds.Register(modules.Seed{Name: "roles:bootstrap-admin", Run: u.service.BootstrapAdmin})
+
+	// Hard delete roles once their retention period in the trash has passed
+	if app.Config.DB.SoftDeleteRetention > 0 {
+		retention := time.Duration(app.Config.DB.SoftDeleteRetention) * 24 * time.Hour
+		app.Scheduler.Every("roles:purge", time.Duration(app.Config.DB.PurgeInterval)*time.Minute, func(ctx context.Context) error {
+			purged, err := u.repository.Purge(ctx, time.Now().Add(-retention))
+			if err == nil && purged > 0 {
+				app.Log.Info().Msgf("Purged %d deleted roles", purged)
+			}
+			return err
+		})
+	}
 
 	return nil
 }
@@ -43,11 +81,17 @@ func (a *RoleModule) Route(router *echo.Group, app *app.Apps) {
 		route.Use(middleware.AuthMiddleware(app))
 		route.POST("", a.Handler.Create, middleware.CheckAccess([]string{"roles:create"}))
 		route.GET("", a.Handler.FindAll, middleware.CheckAccess([]string{"roles:read", "roles:assign", "roles:unassign"}))
+		route.GET("/trash", a.Handler.FindDeleted, middleware.CheckAccess([]string{"roles:trash"}))
 		route.GET("/:id", a.Handler.FindById, middleware.CheckAccess([]string{"roles:read", "roles:assign", "roles:unassign"}))
-		route.PUT("/:id", a.Handler.Update, middleware.CheckAccess([]string{"roles:update"}))
-		route.DELETE("/:id", a.Handler.Delete, middleware.CheckAccess([]string{"roles:delete"}))
+		route.GET("/:id/permissions", a.Handler.EffectivePermissions, middleware.CheckAccess([]string{"roles:read"}))
+		route.GET("/:id/usage", a.Handler.Usage, middleware.CheckAccess([]string{"roles:read", "roles:delete"}))
+		route.PUT("/:id", a.Handler.Update, middleware.CheckAccess([]string{"roles:update"}), middleware.RequireIfMatch())
+		route.PATCH("/:id", a.Handler.Patch, middleware.CheckAccess([]string{"roles:update"}), middleware.RequireIfMatch())
+		route.DELETE("/:id", a.Handler.Delete, middleware.CheckAccess([]string{"roles:delete"}), middleware.RequireIfMatch())
+		route.POST("/:id/restore", a.Handler.Restore, middleware.CheckAccess([]string{"roles:restore"}))
 		route.POST("/assign", a.Handler.AssignUser, middleware.CheckAccess([]string{"roles:assign"}))
 		route.POST("/unassign", a.Handler.UnAssignUser, middleware.CheckAccess([]string{"roles:unassign"}))
+		route.GET("/assignments/expiring", a.Handler.ExpiringAssignments, middleware.CheckAccess([]string{"roles:read", "roles:assign"}))
 
 	}
 }
@@ -1,7 +1,11 @@
 package roles
 
 import (
-	shared "github.com/HasanNugroho/starter-golang/internal/shared/model"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/HasanNugroho/starter-golang/internal/shared/query"
 	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
 	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
@@ -54,21 +58,24 @@ func (c *RoleHandler) Create(ctx echo.Context) error {
 // @Tags         roles
 // @Accept       json
 // @Produce      json
-// @Param limit query int false "total data per-page" minimum(1) default(10)
+// @Param limit query int false "total data per-page" minimum(1) maximum(100) default(10)
 // @Param page query int false "page" minimum(1) default(1)
-// @Param search query string false "keyword"
+// @Param cursor query string false "opaque cursor from next_cursor or prev_cursor, send empty to start cursor pagination"
+// @Param include_total query bool false "count total items in cursor mode"
+// @Param search query string false "keyword matched against role name"
+// @Param sort query string false "comma separated fields, prefix with - for descending" example(-created_at,name)
+// @Param filter[field][operator] query string false "filter on name, permissions, created_at or updated_at, e.g. filter[name][startswith]=adm"
 // @Success      200     {object}  shared.Response{data=shared.DataWithPagination{items=[]RoleModel}}
 // @Failure      500     {object}  shared.Response
 // @Router       /roles [get]
 // @Security ApiKeyAuth
 func (c *RoleHandler) FindAll(ctx echo.Context) error {
-	var filter shared.PaginationFilter
-
-	if err := ctx.Bind(&filter); err != nil {
-		return utils.NewBadRequest(err.Error())
+	q, err := query.Parse(ctx.QueryParams(), RoleQuerySchema)
+	if err != nil {
+		return err
 	}
 
-	roles, err := c.roleService.FindAll(ctx, &filter)
+	roles, err := c.roleService.FindAll(ctx, q)
 	if err != nil {
 		return err
 	}
@@ -85,6 +92,7 @@ func (c *RoleHandler) FindAll(ctx echo.Context) error {
 // @Produce      json
 // @Param id path string true "id"
 // @Success      200     {object}  shared.Response{data=RoleModel}
+// @Header       200  {string}  ETag  "version of the returned document"
 // @Failure      500     {object}  shared.Response
 // @Router       /roles/{id} [get]
 // @Security ApiKeyAuth
@@ -100,10 +108,39 @@ func (c *RoleHandler) FindById(ctx echo.Context) error {
 		return err
 	}
 
+	utils.SetETag(ctx, role.Version)
 	utils.SendSuccess(ctx, 200, "role retrieved successfully", role)
 	return nil
 }
 
+// EffectivePermissions godoc
+// @Summary      Get effective permissions of a role
+// @Description  Resolve the permissions a role grants through its parent roles and the roles granting each of them
+// @Tags         roles
+// @Accept       json
+// @Produce      json
+// @Param id path string true "id"
+// @Success      200     {object}  shared.Response{data=RolePermissionsModel}
+// @Failure      400     {object}  shared.Response
+// @Failure      500     {object}  shared.Response
+// @Router       /roles/{id}/permissions [get]
+// @Security ApiKeyAuth
+func (c *RoleHandler) EffectivePermissions(ctx echo.Context) error {
+	id := ctx.Param("id")
+
+	if err := c.validate.Var(id, "required"); err != nil {
+		return utils.NewBadRequest(err.Error())
+	}
+
+	permissions, err := c.roleService.EffectivePermissions(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	utils.SendSuccess(ctx, 200, "role permissions retrieved successfully", permissions)
+	return nil
+}
+
 // Updaterole godoc
 // @Summary      Update role
 // @Description  Update role
@@ -111,11 +148,14 @@ func (c *RoleHandler) FindById(ctx echo.Context) error {
 // @Accept       json
 // @Produce      json
 // @Param id path string true "id"
+// @Param If-Match header string true "ETag from the last read, or * to skip the check"
 // @Param        role  body  RoleUpdateModel  true  "role Data"
 // @Success      201  {object}  shared.Response
 // @Failure      400  {object}  shared.Response
 // @Failure      404  {object}  shared.Response
 // @Failure      500  {object}  shared.Response
+// @Failure      412  {object}  shared.Response
+// @Failure      428  {object}  shared.Response
 // @Router       /roles/{id} [put]
 // @Security ApiKeyAuth
 func (c *RoleHandler) Update(ctx echo.Context) error {
@@ -140,15 +180,87 @@ func (c *RoleHandler) Update(ctx echo.Context) error {
 	return nil
 }
 
+// Patchrole godoc
+// @Summary      Partially update role
+// @Description  Update role fields with a JSON Merge Patch (RFC 7396) or a JSON Patch (RFC 6902)
+// @Tags         roles
+// @Accept       application/merge-patch+json,application/json-patch+json,json
+// @Produce      json
+// @Param id path string true "id"
+// @Param If-Match header string true "ETag from the last read, or * to skip the check"
+// @Param        role  body  RolePatchModel  true  "Merge patch, or an array of patch operations"
+// @Success      200  {object}  shared.Response
+// @Failure      400  {object}  shared.Response
+// @Failure      404  {object}  shared.Response
+// @Failure      500  {object}  shared.Response
+// @Failure      412  {object}  shared.Response
+// @Failure      428  {object}  shared.Response
+// @Router       /roles/{id} [patch]
+// @Security ApiKeyAuth
+func (c *RoleHandler) Patch(ctx echo.Context) error {
+	id := ctx.Param("id")
+
+	if err := c.validate.Var(id, "required"); err != nil {
+		return utils.NewBadRequest("Invalid ID")
+	}
+
+	body, err := io.ReadAll(ctx.Request().Body)
+	if err != nil || len(body) == 0 {
+		return utils.NewBadRequest("patch body is required")
+	}
+
+	if err := c.roleService.Patch(ctx, id, ctx.Request().Header.Get(echo.HeaderContentType), body); err != nil {
+		return err
+	}
+
+	utils.SendSuccess(ctx, 200, "role updated successfully", nil)
+	return nil
+}
+
+// RoleUsage godoc
+// @Summary      Get role usage
+// @Description  Count the users and groups holding a role and the roles inheriting from it
+// @Tags         roles
+// @Accept       json
+// @Produce      json
+// @Param id path string true "id"
+// @Success      200     {object}  shared.Response{data=RoleUsageModel}
+// @Failure      400     {object}  shared.Response
+// @Router       /roles/{id}/usage [get]
+// @Security ApiKeyAuth
+func (c *RoleHandler) Usage(ctx echo.Context) error {
+	id := ctx.Param("id")
+
+	if err := c.validate.Var(id, "required"); err != nil {
+		return utils.NewBadRequest("Invalid ID")
+	}
+
+	usage, err := c.roleService.Usage(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	utils.SendSuccess(ctx, 200, "role usage retrieved successfully", usage)
+	return nil
+}
+
 // Deleterole godoc
 // @Summary      Delete role
-// @Description  Delete role by ID
+// @Description  Delete role by ID. A role still held or inherited is refused unless mode is cascade, taking it away from its holders, or reassign, handing them the replacement role. Cascade and reassign need MongoDB to run as a replica set. Holders have their tokens revoked.
 // @Tags         roles
 // @Accept       json
 // @Produce      json
 // @Param id path string true "id"
-// @Success      200     {object}  shared.Response
+// @Param mode query string false "restrict (default), cascade or reassign"
+// @Param replacement query string false "id of the role holders get in reassign mode"
+// @Param If-Match header string true "ETag from the last read, or * to skip the check"
+// @Success      200     {object}  shared.Response{data=RoleDeleteResultModel}
+// @Failure      400     {object}  shared.Response
+// @Failure      403     {object}  shared.Response
+// @Failure      409     {object}  shared.Response
 // @Failure      500     {object}  shared.Response
+// @Failure      412  {object}  shared.Response
+// @Failure      428  {object}  shared.Response
 // @Router       /roles/{id} [delete]
 // @Security ApiKeyAuth
 func (c *RoleHandler) Delete(ctx echo.Context) error {
@@ -158,18 +270,85 @@ func (c *RoleHandler) Delete(ctx echo.Context) error {
 		return utils.NewBadRequest("Invalid ID")
 	}
 
-	err := c.roleService.Delete(ctx, id)
+	options := RoleDeleteModel{
+		Mode:        ctx.QueryParam("mode"),
+		Replacement: ctx.QueryParam("replacement"),
+	}
+	if err := c.validate.Struct(options); err != nil {
+		return utils.NewBadRequest(err.Error())
+	}
+
+	result, err := c.roleService.Delete(ctx, id, &options)
+	if err != nil {
+		return err
+	}
+
+	utils.SendSuccess(ctx, 200, "role deleted successfully", result)
+	return nil
+}
+
+// FindDeletedRoles godoc
+// @Summary      Get deleted roles
+// @Description  Retrieve a list of soft deleted roles awaiting purge
+// @Tags         roles
+// @Accept       json
+// @Produce      json
+// @Param limit query int false "total data per-page" minimum(1) maximum(100) default(10)
+// @Param page query int false "page" minimum(1) default(1)
+// @Param cursor query string false "opaque cursor from next_cursor or prev_cursor, send empty to start cursor pagination"
+// @Param include_total query bool false "count total items in cursor mode"
+// @Param search query string false "keyword matched against name"
+// @Param sort query string false "comma separated fields, prefix with - for descending" example(-deleted_at)
+// @Param filter[field][operator] query string false "filter on name, deleted_at or deleted_by"
+// @Success      200     {object}  shared.Response{data=shared.DataWithPagination{items=[]RoleTrashModel}}
+// @Failure      500     {object}  shared.Response
+// @Router       /roles/trash [get]
+// @Security ApiKeyAuth
+func (c *RoleHandler) FindDeleted(ctx echo.Context) error {
+	q, err := query.Parse(ctx.QueryParams(), RoleTrashQuerySchema)
+	if err != nil {
+		return err
+	}
+
+	roles, err := c.roleService.FindDeleted(ctx, q)
 	if err != nil {
 		return err
 	}
 
-	utils.SendSuccess(ctx, 200, "role deleted successfully", nil)
+	utils.SendSuccess(ctx, 200, "deleted roles retrieved successfully", roles)
+	return nil
+}
+
+// Restorerole godoc
+// @Summary      Restore role
+// @Description  Restore a soft deleted role by ID
+// @Tags         roles
+// @Accept       json
+// @Produce      json
+// @Param id path string true "id"
+// @Success      200     {object}  shared.Response
+// @Failure      404     {object}  shared.Response
+// @Failure      500     {object}  shared.Response
+// @Router       /roles/{id}/restore [post]
+// @Security ApiKeyAuth
+func (c *RoleHandler) Restore(ctx echo.Context) error {
+	id := ctx.Param("id")
+
+	if err := c.validate.Var(id, "required"); err != nil {
+		return utils.NewBadRequest("Invalid ID")
+	}
+
+	if err := c.roleService.Restore(ctx, id); err != nil {
+		return err
+	}
+
+	utils.SendSuccess(ctx, 200, "role restored successfully", nil)
 	return nil
 }
 
 // Assignrole godoc
 // @Summary      Assign an role
-// @Description  Assign an role
+// @Description  Assign an role, permanently or for the window between starts_at and expires_at. Time-bound assignments need a note justifying them.
 // @Tags         roles
 // @Accept       json
 // @Produce      json
@@ -224,3 +403,32 @@ func (c *RoleHandler) UnAssignUser(ctx echo.Context) error {
 	utils.SendSuccess(ctx, 201, "UnAssign user successfully", nil)
 	return nil
 }
+
+// ExpiringAssignments godoc
+// @Summary      Get expiring role assignments
+// @Description  List the time-bound role assignments ending within the given number of hours, the soonest first
+// @Tags         roles
+// @Accept       json
+// @Produce      json
+// @Param        within  query  int  false  "hours from now, 24 by default and 720 at most"
+// @Success      200  {object}  shared.Response{data=[]RoleAssignmentModel}
+// @Failure      400  {object}  shared.Response
+// @Router       /roles/assignments/expiring [get]
+// @Security ApiKeyAuth
+func (c *RoleHandler) ExpiringAssignments(ctx echo.Context) error {
+	hours := 24
+	if raw := ctx.QueryParam("within"); raw != "" {
+		var err error
+		if hours, err = strconv.Atoi(raw); err != nil || hours < 1 || hours > 720 {
+			return utils.NewBadRequest("within must be a number of hours between 1 and 720")
+		}
+	}
+
+	assignments, err := c.roleService.ExpiringAssignments(ctx, time.Duration(hours)*time.Hour)
+	if err != nil {
+		return err
+	}
+
+	utils.SendSuccess(ctx, 200, "expiring assignments retrieved successfully", assignments)
+	return nil
+}
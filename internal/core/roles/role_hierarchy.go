@@ -0,0 +1,94 @@
+package roles
+
+import (
+	"slices"
+	"sort"
+
+	"github.com/HasanNugroho/starter-golang/internal/core/entities"
+	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// parseParents converts parent role ids, dropping duplicates
+func parseParents(ids []string) ([]bson.ObjectID, error) {
+	parents := make([]bson.ObjectID, 0, len(ids))
+	for _, id := range ids {
+		objectID, err := bson.ObjectIDFromHex(id)
+		if err != nil {
+			return nil, utils.NewBadRequest("invalid parent id " + id)
+		}
+		if !slices.Contains(parents, objectID) {
+			parents = append(parents, objectID)
+		}
+	}
+	return parents, nil
+}
+
+// checkParents rejects parents that do not exist and parents that would make
+// the role inherit from itself. hierarchy holds the parents with every role
+// they inherit from; it includes deleted roles, so a cycle can not be closed
+// later by restoring one of them.
+func checkParents(id bson.ObjectID, parents []bson.ObjectID, hierarchy []entities.Role) error {
+	byID := make(map[bson.ObjectID]entities.Role, len(hierarchy))
+	for _, role := range hierarchy {
+		byID[role.ID] = role
+	}
+
+	for _, parent := range parents {
+		role, ok := byID[parent]
+		if !ok || role.DeletedAt != nil {
+			return utils.NewBadRequest("parent role " + parent.Hex() + " not found")
+		}
+	}
+
+	if _, ok := byID[id]; ok && !id.IsZero() {
+		return utils.NewBadRequest("role can not inherit from itself, the parents would create a cycle")
+	}
+
+	return nil
+}
+
+// permissionTree builds the effective permissions of the root role from its
+// hierarchy. Deleted roles grant nothing and stop the inheritance.
+func permissionTree(root bson.ObjectID, hierarchy []entities.Role) RolePermissionsModel {
+	byID := make(map[bson.ObjectID]entities.Role, len(hierarchy))
+	for _, role := range hierarchy {
+		if role.DeletedAt == nil {
+			byID[role.ID] = role
+		}
+	}
+
+	granted := map[string][]RoleReferenceModel{}
+	var walk func(id bson.ObjectID, path []bson.ObjectID) RoleTreeModel
+	walk = func(id bson.ObjectID, path []bson.ObjectID) RoleTreeModel {
+		role := byID[id]
+		node := RoleTreeModel{ID: role.ID, Name: role.Name, Permissions: role.Permissions}
+
+		reference := RoleReferenceModel{ID: role.ID, Name: role.Name}
+		for _, permission := range role.Permissions {
+			if !slices.Contains(granted[permission], reference) {
+				granted[permission] = append(granted[permission], reference)
+			}
+		}
+
+		path = append(path, id)
+		for _, parent := range role.Parents {
+			// Cycles are rejected on write, the guard only protects against edited data
+			if _, ok := byID[parent]; !ok || slices.Contains(path, parent) {
+				continue
+			}
+			node.Parents = append(node.Parents, walk(parent, path))
+		}
+		return node
+	}
+
+	result := RolePermissionsModel{Role: walk(root, nil), Permissions: []EffectivePermissionModel{}}
+	for permission, roles := range granted {
+		result.Permissions = append(result.Permissions, EffectivePermissionModel{Permission: permission, GrantedBy: roles})
+	}
+	sort.Slice(result.Permissions, func(i, j int) bool {
+		return result.Permissions[i].Permission < result.Permissions[j].Permission
+	})
+
+	return result
+}
@@ -0,0 +1,76 @@
+package roles
+
+import (
+	"testing"
+	"time"
+
+	"github.com/HasanNugroho/starter-golang/internal/core/entities"
+	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestCheckParents(t *testing.T) {
+	admin, editor, viewer := bson.NewObjectID(), bson.NewObjectID(), bson.NewObjectID()
+	deletedAt := time.Now()
+
+	// admin inherits from editor, which inherits from viewer
+	hierarchy := []entities.Role{
+		{ID: editor, Parents: []bson.ObjectID{viewer}},
+		{ID: viewer},
+		{ID: admin, Parents: []bson.ObjectID{editor}, DeletedAt: &deletedAt},
+	}
+
+	assert.NoError(t, checkParents(bson.NilObjectID, []bson.ObjectID{editor}, hierarchy))
+	assert.NoError(t, checkParents(bson.NewObjectID(), []bson.ObjectID{editor}, hierarchy))
+
+	// viewer inheriting from editor closes the loop editor -> viewer -> editor
+	assert.Equal(t, utils.NewBadRequest("role can not inherit from itself, the parents would create a cycle"),
+		checkParents(viewer, []bson.ObjectID{editor}, hierarchy))
+
+	assert.Equal(t, utils.NewBadRequest("parent role "+admin.Hex()+" not found"),
+		checkParents(bson.NilObjectID, []bson.ObjectID{admin}, hierarchy))
+
+	missing := bson.NewObjectID()
+	assert.Equal(t, utils.NewBadRequest("parent role "+missing.Hex()+" not found"),
+		checkParents(bson.NilObjectID, []bson.ObjectID{missing}, hierarchy))
+}
+
+func TestParseParents_Deduplicates(t *testing.T) {
+	id := bson.NewObjectID()
+
+	parents, err := parseParents([]string{id.Hex(), id.Hex()})
+	assert.NoError(t, err)
+	assert.Equal(t, []bson.ObjectID{id}, parents)
+
+	_, err = parseParents([]string{"nope"})
+	assert.Equal(t, utils.NewBadRequest("invalid parent id nope"), err)
+}
+
+func TestPermissionTree(t *testing.T) {
+	admin, editor, viewer, auditor := bson.NewObjectID(), bson.NewObjectID(), bson.NewObjectID(), bson.NewObjectID()
+	deletedAt := time.Now()
+
+	hierarchy := []entities.Role{
+		{ID: admin, Name: "admin", Permissions: []string{"users:delete"}, Parents: []bson.ObjectID{editor, auditor}},
+		{ID: editor, Name: "editor", Permissions: []string{"users:update", "users:read"}, Parents: []bson.ObjectID{viewer}},
+		{ID: viewer, Name: "viewer", Permissions: []string{"users:read"}},
+		{ID: auditor, Name: "auditor", Permissions: []string{"audit:read"}, DeletedAt: &deletedAt},
+	}
+
+	tree := permissionTree(admin, hierarchy)
+
+	assert.Equal(t, RoleTreeModel{
+		ID: admin, Name: "admin", Permissions: []string{"users:delete"},
+		Parents: []RoleTreeModel{{
+			ID: editor, Name: "editor", Permissions: []string{"users:update", "users:read"},
+			Parents: []RoleTreeModel{{ID: viewer, Name: "viewer", Permissions: []string{"users:read"}}},
+		}},
+	}, tree.Role)
+
+	assert.Equal(t, []EffectivePermissionModel{
+		{Permission: "users:delete", GrantedBy: []RoleReferenceModel{{ID: admin, Name: "admin"}}},
+		{Permission: "users:read", GrantedBy: []RoleReferenceModel{{ID: editor, Name: "editor"}, {ID: viewer, Name: "viewer"}}},
+		{Permission: "users:update", GrantedBy: []RoleReferenceModel{{ID: editor, Name: "editor"}}},
+	}, tree.Permissions)
+}
@@ -1,27 +1,55 @@
 package roles
 
 import (
+	"context"
+	"time"
+
 	"github.com/HasanNugroho/starter-golang/internal/core/entities"
 	shared "github.com/HasanNugroho/starter-golang/internal/shared/model"
+	"github.com/HasanNugroho/starter-golang/internal/shared/patch"
+	"github.com/HasanNugroho/starter-golang/internal/shared/query"
 	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/v2/bson"
 )
 
 type IRoleRepository interface {
 	Create(ctx echo.Context, role *entities.Role) error
 	FindById(ctx echo.Context, id string) (RoleModel, error)
-	FindAll(ctx echo.Context, filter *shared.PaginationFilter) ([]RoleModel, int, error)
+	FindHierarchy(ctx echo.Context, ids []bson.ObjectID) ([]entities.Role, error)
+	FindAll(ctx echo.Context, q *query.Query) (query.Page[RoleModel], error)
 	Update(ctx echo.Context, id string, role *entities.Role) error
-	Delete(ctx echo.Context, id string) error
+	Patch(ctx echo.Context, id string, changes patch.Changes) error
+	Delete(ctx echo.Context, id string, deletedBy string) (RoleUsageModel, error)
+	DeleteReferenced(ctx echo.Context, id string, deletedBy string, replacement *bson.ObjectID) error
+	Usage(ctx echo.Context, id bson.ObjectID) (RoleUsageModel, error)
+	Descendants(ctx echo.Context, id bson.ObjectID) ([]bson.ObjectID, error)
+	Holders(ctx echo.Context, ids []bson.ObjectID) ([]bson.ObjectID, error)
+	FindDeleted(ctx echo.Context, q *query.Query) (query.Page[RoleTrashModel], error)
+	FindDeletedById(ctx echo.Context, id string) (RoleModel, error)
+	Restore(ctx echo.Context, id string) error
+	Purge(ctx context.Context, cutoff time.Time) (int64, error)
 	AssignUser(ctx echo.Context, userId string, roleId string) error
 	UnassignUser(ctx echo.Context, userId string, roleId string) error
+	AssignUserFor(ctx echo.Context, userId string, roleId string, assignment entities.RoleAssignment) error
+	ExpireAssignments(ctx context.Context, now time.Time) ([]AssignmentExpired, error)
+	ExpiringAssignments(ctx echo.Context, now time.Time, until time.Time) ([]RoleAssignmentModel, error)
+	FindSystemRole(ctx context.Context, name string) (RoleModel, error)
+	SeedSystemRole(ctx context.Context, role entities.Role) error
+	BootstrapAdmin(ctx context.Context, roleID bson.ObjectID, admin entities.User) (bool, error)
 }
 
 type IRoleService interface {
 	Create(ctx echo.Context, user *RoleUpdateModel) error
 	FindById(ctx echo.Context, id string) (RoleModel, error)
-	FindAll(ctx echo.Context, filter *shared.PaginationFilter) (shared.DataWithPagination, error)
+	EffectivePermissions(ctx echo.Context, id string) (RolePermissionsModel, error)
+	FindAll(ctx echo.Context, q *query.Query) (shared.DataWithPagination, error)
 	Update(ctx echo.Context, id string, user *RoleUpdateModel) error
-	Delete(ctx echo.Context, id string) error
+	Patch(ctx echo.Context, id string, contentType string, body []byte) error
+	Usage(ctx echo.Context, id string) (RoleUsageModel, error)
+	Delete(ctx echo.Context, id string, options *RoleDeleteModel) (RoleDeleteResultModel, error)
+	FindDeleted(ctx echo.Context, q *query.Query) (shared.DataWithPagination, error)
+	Restore(ctx echo.Context, id string) error
 	AssignUser(ctx echo.Context, payload *AssignRoleModel) error
 	UnassignUser(ctx echo.Context, payload *AssignRoleModel) error
+	ExpiringAssignments(ctx echo.Context, within time.Duration) ([]RoleAssignmentModel, error)
 }
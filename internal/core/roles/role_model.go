@@ -1,18 +1,158 @@
 package roles
 
-import "go.mongodb.org/mongo-driver/v2/bson"
+import (
+	"time"
+
+	"github.com/HasanNugroho/starter-golang/internal/shared/query"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
 
 type RoleModel struct {
-	ID          bson.ObjectID `bson:"_id" json:"id"`
-	Name        string        `bson:"name" json:"name"`
-	Permissions []string      `bson:"permissions" json:"permission"`
+	ID           bson.ObjectID   `bson:"_id" json:"id"`
+	Name         string          `bson:"name" json:"name"`
+	Permissions  []string        `bson:"permissions" json:"permission"`
+	Parents      []bson.ObjectID `bson:"parents,omitempty" json:"parents,omitempty"`
+	IsSystem     bool            `bson:"is_system,omitempty" json:"is_system"`
+	Organization *bson.ObjectID  `bson:"organization,omitempty" json:"organization,omitempty"`
+	Version      int64           `bson:"version" json:"version"`
+}
+
+type RoleTrashModel struct {
+	ID          bson.ObjectID  `bson:"_id" json:"id"`
+	Name        string         `bson:"name" json:"name"`
+	Permissions []string       `bson:"permissions" json:"permission"`
+	DeletedAt   time.Time      `bson:"deleted_at" json:"deleted_at"`
+	DeletedBy   *bson.ObjectID `bson:"deleted_by,omitempty" json:"deleted_by,omitempty"`
 }
+
 type RoleUpdateModel struct {
 	Name        string   `json:"name"`
 	Permissions []string `json:"permission"`
+	Parents     []string `json:"parents"`
+}
+
+// RolePatchModel is the patchable document of a role, a null permission or
+// parent list clears it
+type RolePatchModel struct {
+	Name        string          `json:"name" bson:"name" validate:"required"`
+	Permissions []string        `json:"permission,omitempty" bson:"permissions,omitempty"`
+	Parents     []bson.ObjectID `json:"parents,omitempty" bson:"parents,omitempty"`
+}
+
+// RoleTreeModel is a role with the roles it inherits from
+type RoleTreeModel struct {
+	ID          bson.ObjectID   `json:"id"`
+	Name        string          `json:"name"`
+	Permissions []string        `json:"permission"`
+	Parents     []RoleTreeModel `json:"parents,omitempty"`
+}
+
+// RoleReferenceModel names a role a permission is granted by
+type RoleReferenceModel struct {
+	ID   bson.ObjectID `json:"id"`
+	Name string        `json:"name"`
+}
+
+// EffectivePermissionModel is a permission held through a role and the roles granting it
+type EffectivePermissionModel struct {
+	Permission string               `json:"permission"`
+	GrantedBy  []RoleReferenceModel `json:"granted_by"`
+}
+
+// RolePermissionsModel is the effective permission tree of a role
+type RolePermissionsModel struct {
+	Role        RoleTreeModel              `json:"role"`
+	Permissions []EffectivePermissionModel `json:"permissions"`
 }
 
 type AssignRoleModel struct {
 	UserID string `json:"user_id"`
 	RoleID string `json:"role_id"`
+	// StartsAt and ExpiresAt limit the assignment to a time window, Note justifies it
+	StartsAt  *time.Time `json:"starts_at,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Note      string     `json:"note,omitempty" validate:"required_with=StartsAt ExpiresAt,max=500"`
+}
+
+// RoleAssignmentModel is a time-bound assignment with its user and role
+type RoleAssignmentModel struct {
+	UserID     bson.ObjectID  `bson:"user_id" json:"user_id"`
+	Email      string         `bson:"email" json:"email"`
+	Name       string         `bson:"name" json:"name"`
+	RoleID     bson.ObjectID  `bson:"role_id" json:"role_id"`
+	RoleName   string         `bson:"role_name" json:"role_name"`
+	StartsAt   *time.Time     `bson:"starts_at,omitempty" json:"starts_at,omitempty"`
+	ExpiresAt  time.Time      `bson:"expires_at" json:"expires_at"`
+	Note       string         `bson:"note" json:"note"`
+	AssignedBy *bson.ObjectID `bson:"assigned_by,omitempty" json:"assigned_by,omitempty"`
+}
+
+// AssignmentExpiredEvent is emitted on app.Bus for every time-bound assignment
+// the sweeper removes
+const AssignmentExpiredEvent = "role.assignment_expired"
+
+// AssignmentExpired is the payload of AssignmentExpiredEvent
+type AssignmentExpired struct {
+	UserID    string    `json:"user_id"`
+	RoleID    string    `json:"role_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Note      string    `json:"note"`
+}
+
+// RoleQuerySchema whitelists the fields usable to filter and sort roles
+var RoleQuerySchema = query.Schema{
+	Fields: map[string]query.Field{
+		"name":        {Type: query.String, Sortable: true},
+		"permissions": {Type: query.String, Operators: []query.Operator{query.Eq, query.In, query.Nin}},
+		"created_at":  {Type: query.Time, Sortable: true},
+		"updated_at":  {Type: query.Time, Sortable: true},
+	},
+	Search:      []string{"name"},
+	DefaultSort: "name",
+}
+
+// RoleTrashQuerySchema whitelists the fields usable to filter and sort deleted roles
+var RoleTrashQuerySchema = query.Schema{
+	Fields: map[string]query.Field{
+		"name":       {Type: query.String, Sortable: true},
+		"deleted_at": {Type: query.Time, Sortable: true},
+		"deleted_by": {Type: query.ObjectID},
+	},
+	Search:      []string{"name"},
+	DefaultSort: "-deleted_at",
+}
+
+const (
+	// DeleteRestrict refuses to delete a role that is still held or inherited
+	DeleteRestrict = "restrict"
+	// DeleteCascade takes the role away from its users, groups and child roles
+	DeleteCascade = "cascade"
+	// DeleteReassign hands the replacement role to the holders instead
+	DeleteReassign = "reassign"
+)
+
+// RoleDeleteModel tells what happens to the holders of a deleted role
+type RoleDeleteModel struct {
+	Mode        string `query:"mode" validate:"omitempty,oneof=restrict cascade reassign"`
+	Replacement string `query:"replacement" validate:"required_if=Mode reassign,omitempty,mongodb"`
+}
+
+// RoleUsageModel counts what still references a role
+type RoleUsageModel struct {
+	Users    int64 `json:"users"`
+	Groups   int64 `json:"groups"`
+	Children int64 `json:"children"`
+}
+
+// InUse reports whether anything references the role
+func (u RoleUsageModel) InUse() bool {
+	return u.Users > 0 || u.Groups > 0 || u.Children > 0
+}
+
+// RoleDeleteResultModel reports what deleting a role changed
+type RoleDeleteResultModel struct {
+	Mode  string         `json:"mode"`
+	Usage RoleUsageModel `json:"usage"`
+	// AffectedUsers had their tokens revoked since their permissions changed
+	AffectedUsers int `json:"affected_users"`
 }
@@ -0,0 +1,87 @@
+package roles
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/HasanNugroho/starter-golang/internal/app"
+	"github.com/HasanNugroho/starter-golang/internal/core/entities"
+	"github.com/HasanNugroho/starter-golang/internal/shared/modules"
+	"github.com/HasanNugroho/starter-golang/internal/shared/permission"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// AssignmentPersonalDataModel is a time-bound role assignment in a personal
+// data archive, the note is free text written about the user
+type AssignmentPersonalDataModel struct {
+	Role       string     `json:"role"`
+	StartsAt   *time.Time `json:"starts_at,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	Note       string     `json:"note,omitempty"`
+	AssignedAt time.Time  `json:"assigned_at"`
+}
+
+// assignmentsDataSource declares the role assignments stored on the users as personal data
+func assignmentsDataSource(app *app.Apps) modules.PersonalDataSource {
+	users := app.DB.Collection("users")
+	roles := app.DB.Collection("roles")
+
+	return modules.PersonalDataSource{
+		Name: "role_assignments",
+		Export: func(ctx context.Context, subject modules.DataSubject, archive modules.PersonalDataArchive) error {
+			var user struct {
+				RoleAssignments []entities.RoleAssignment `bson:"role_assignments"`
+			}
+			opts := options.FindOne().SetProjection(bson.M{"role_assignments": 1})
+			err := users.FindOne(ctx, bson.M{"_id": subject.ID}, opts).Decode(&user)
+			if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+				return err
+			}
+
+			names := map[bson.ObjectID]string{}
+			if len(user.RoleAssignments) > 0 {
+				ids := make([]bson.ObjectID, 0, len(user.RoleAssignments))
+				for _, assignment := range user.RoleAssignments {
+					ids = append(ids, assignment.Role)
+				}
+
+				cursor, err := roles.Find(ctx, bson.M{"_id": bson.M{"$in": ids}}, options.Find().SetProjection(bson.M{"name": 1}))
+				if err != nil {
+					return err
+				}
+
+				var found []entities.Role
+				if err := cursor.All(ctx, &found); err != nil {
+					return err
+				}
+				for _, role := range found {
+					names[role.ID] = role.Name
+				}
+			}
+
+			exported := []AssignmentPersonalDataModel{}
+			for _, assignment := range user.RoleAssignments {
+				exported = append(exported, AssignmentPersonalDataModel{
+					Role:       names[assignment.Role],
+					StartsAt:   assignment.StartsAt,
+					ExpiresAt:  assignment.ExpiresAt,
+					Note:       assignment.Note,
+					AssignedAt: assignment.AssignedAt,
+				})
+			}
+
+			return archive.AddJSON("role_assignments.json", exported)
+		},
+		Erase: func(ctx context.Context, subject modules.DataSubject) error {
+			if _, err := users.UpdateOne(ctx, bson.M{"_id": subject.ID}, bson.M{"$unset": bson.M{"role_assignments": ""}}); err != nil {
+				return err
+			}
+
+			app.Bus.Emit(permission.ChangedEvent, permission.Changed{UserIDs: []string{subject.ID.Hex()}})
+			return nil
+		},
+	}
+}
@@ -1,34 +1,37 @@
 package roles
 
 import (
+	"context"
+	"errors"
+	"slices"
 	"time"
 
 	"github.com/HasanNugroho/starter-golang/internal/app"
 	"github.com/HasanNugroho/starter-golang/internal/core/entities"
-	shared "github.com/HasanNugroho/starter-golang/internal/shared/model"
+	"github.com/HasanNugroho/starter-golang/internal/shared/patch"
+	"github.com/HasanNugroho/starter-golang/internal/shared/query"
 	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
 	"github.com/labstack/echo/v4"
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
-	"go.mongodb.org/mongo-driver/v2/mongo/options"
 )
 
 type RoleRepository struct {
 	app        *app.Apps
-	collection *mongo.Collection
+	collection query.Collection
 }
 
 func NewRoleRepository(app *app.Apps) *RoleRepository {
 	return &RoleRepository{
 		app:        app,
-		collection: app.DB.Collection("roles"),
+		collection: query.Owned(app.DB.Collection("roles")),
 	}
 }
 
 func (r *RoleRepository) Create(ctx echo.Context, role *entities.Role) error {
 	c := ctx.Request().Context()
 
-	_, err := r.collection.InsertOne(c, role)
+	_, err := r.collection.Unscoped().InsertOne(c, role)
 	if err != nil {
 		if mongo.IsDuplicateKeyError(err) {
 			return utils.NewConflict("data already exists")
@@ -48,8 +51,7 @@ func (r *RoleRepository) FindById(ctx echo.Context, id string) (RoleModel, error
 		return RoleModel{}, utils.NewBadRequest("invalid id format")
 	}
 
-	filter := bson.M{"_id": objectID}
-	err = r.collection.FindOne(c, filter).Decode(&role)
+	err = r.collection.For(ctx).FindOne(c, bson.M{"_id": objectID, "deleted_at": nil}).Decode(&role)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			return RoleModel{}, utils.NewBadRequest("data not found")
@@ -61,33 +63,84 @@ func (r *RoleRepository) FindById(ctx echo.Context, id string) (RoleModel, error
 	return role, nil
 }
 
-func (r *RoleRepository) FindAll(ctx echo.Context, filter *shared.PaginationFilter) ([]RoleModel, int, error) {
+// FindDeletedById finds a role in the trash
+func (r *RoleRepository) FindDeletedById(ctx echo.Context, id string) (RoleModel, error) {
 	c := ctx.Request().Context()
 
-	var roles []RoleModel
-	var totalItems int64
+	var role RoleModel
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return RoleModel{}, utils.NewBadRequest("invalid id format")
+	}
 
-	opts := options.Find().
-		SetSkip(int64((filter.Page - 1) * filter.Limit)).
-		SetLimit(int64(filter.Limit))
-	// opts.SetSort(bson.D{{Key: "created_at", Value: -1}})
+	err = r.collection.For(ctx).FindOne(c, bson.M{"_id": objectID, "deleted_at": bson.M{"$ne": nil}}).Decode(&role)
+	if err == mongo.ErrNoDocuments {
+		return RoleModel{}, utils.NewNotFound("data not found")
+	}
+	if err != nil {
+		return RoleModel{}, utils.NewInternal("failed to find data")
+	}
 
-	cursor, err := r.collection.Find(c, bson.M{}, opts)
+	return role, nil
+}
+
+// FindHierarchy returns the given roles together with every role they inherit
+// from, deleted ones included
+func (r *RoleRepository) FindHierarchy(ctx echo.Context, ids []bson.ObjectID) ([]entities.Role, error) {
+	c := ctx.Request().Context()
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"_id": bson.M{"$in": ids}}}},
+		{{
+			Key: "$graphLookup",
+			Value: bson.D{
+				{Key: "from", Value: "roles"},
+				{Key: "startWith", Value: "$parents"},
+				{Key: "connectFromField", Value: "parents"},
+				{Key: "connectToField", Value: "_id"},
+				{Key: "as", Value: "ancestors"},
+			},
+		}},
+	}
+
+	cursor, err := r.collection.Unscoped().Aggregate(c, pipeline)
 	if err != nil {
-		return nil, 0, utils.NewInternal("failed to query data")
+		return nil, utils.NewInternal("failed to query data")
 	}
 	defer cursor.Close(c)
 
-	if err := cursor.All(c, &roles); err != nil {
-		return nil, 0, utils.NewInternal("failed to decode data")
+	var results []struct {
+		entities.Role `bson:",inline"`
+		Ancestors     []entities.Role `bson:"ancestors"`
+	}
+	if err := cursor.All(c, &results); err != nil {
+		return nil, utils.NewInternal("failed to decode data")
 	}
 
-	totalItems, err = r.collection.CountDocuments(c, bson.M{})
-	if err != nil {
-		return nil, 0, utils.NewInternal("failed to count documents")
+	hierarchy := []entities.Role{}
+	seen := map[bson.ObjectID]bool{}
+	for _, result := range results {
+		for _, role := range append([]entities.Role{result.Role}, result.Ancestors...) {
+			if !seen[role.ID] {
+				seen[role.ID] = true
+				hierarchy = append(hierarchy, role)
+			}
+		}
 	}
 
-	return roles, int(totalItems), nil
+	return hierarchy, nil
+}
+
+func (r *RoleRepository) FindAll(ctx echo.Context, q *query.Query) (query.Page[RoleModel], error) {
+	c := ctx.Request().Context()
+
+	return query.FindIn[RoleModel](c, r.collection.For(ctx), q.WithoutDeleted(), []byte(r.app.Config.Security.CursorKey()))
+}
+
+func (r *RoleRepository) FindDeleted(ctx echo.Context, q *query.Query) (query.Page[RoleTrashModel], error) {
+	c := ctx.Request().Context()
+
+	return query.FindIn[RoleTrashModel](c, r.collection.For(ctx), q.OnlyDeleted(), []byte(r.app.Config.Security.CursorKey()))
 }
 
 func (r *RoleRepository) Update(ctx echo.Context, id string, role *entities.Role) error {
@@ -98,22 +151,31 @@ func (r *RoleRepository) Update(ctx echo.Context, id string, role *entities.Role
 		return utils.NewBadRequest("invalid id format")
 	}
 
-	filter := bson.M{"_id": objectId}
-	result := r.collection.FindOneAndUpdate(c, filter, bson.M{
+	roles := r.collection.For(ctx)
+	filter := bson.M{"_id": objectId, "deleted_at": nil}
+	result, err := roles.UpdateOne(c, utils.VersionedFilter(ctx, filter), query.BumpVersion(bson.M{
 		"$set": bson.M{
 			"name":        role.Name,
 			"permissions": role.Permissions,
+			"parents":     role.Parents,
 			"updated_at":  time.Now(),
-		}})
+		}}))
 
-	if result.Err() != nil {
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return utils.NewConflict("data already exists")
+		}
 		return utils.NewInternal("failed to update data")
 	}
 
+	if result.MatchedCount == 0 {
+		return roles.NotMatched(c, filter)
+	}
+
 	return nil
 }
 
-func (r *RoleRepository) Delete(ctx echo.Context, id string) error {
+func (r *RoleRepository) Patch(ctx echo.Context, id string, changes patch.Changes) error {
 	c := ctx.Request().Context()
 
 	objectId, err := bson.ObjectIDFromHex(id)
@@ -121,16 +183,158 @@ func (r *RoleRepository) Delete(ctx echo.Context, id string) error {
 		return utils.NewBadRequest("invalid id format")
 	}
 
-	filter := bson.M{"_id": objectId}
+	changes.Set["updated_at"] = time.Now()
 
-	result := r.collection.FindOneAndDelete(c, filter)
-	if result.Err() != nil {
-		return utils.NewInternal("failed to delete data")
+	roles := r.collection.For(ctx)
+	filter := bson.M{"_id": objectId, "deleted_at": nil}
+	result, err := roles.UpdateOne(c, utils.VersionedFilter(ctx, filter), query.BumpVersion(changes.Update()))
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return utils.NewConflict("data already exists")
+		}
+		return utils.NewInternal("failed to update data")
+	}
+
+	if result.MatchedCount == 0 {
+		return roles.NotMatched(c, filter)
 	}
 
 	return nil
 }
 
+// errRoleInUse aborts deleting a role still held or inherited
+var errRoleInUse = errors.New("role is in use")
+
+// Delete deletes the role unless it is held or inherited, reporting its usage.
+// The role is deleted before its usage is counted, so an assignment checking
+// the role afterwards finds it gone, and put back when it turns out in use.
+func (r *RoleRepository) Delete(ctx echo.Context, id string, deletedBy string) (RoleUsageModel, error) {
+	c := ctx.Request().Context()
+
+	objectId, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return RoleUsageModel{}, utils.NewBadRequest("invalid id format")
+	}
+
+	roles := r.collection.For(ctx)
+	filter := bson.M{"_id": objectId, "deleted_at": nil}
+
+	var usage RoleUsageModel
+	remove := func(tc context.Context) error {
+		result, err := roles.UpdateOne(tc, utils.VersionedFilter(ctx, filter), query.BumpVersion(query.SoftDelete(deletedBy)))
+		if err != nil {
+			return utils.NewInternal("failed to delete data")
+		}
+		if result.MatchedCount == 0 {
+			return roles.NotMatched(tc, filter)
+		}
+
+		if usage, err = r.usage(tc, objectId); err != nil {
+			return err
+		}
+		if usage.InUse() {
+			return errRoleInUse
+		}
+		return nil
+	}
+
+	if r.app.Transactions {
+		return usage, r.transaction(c, remove)
+	}
+
+	err = remove(c)
+	if errors.Is(err, errRoleInUse) {
+		if _, restoreErr := roles.UpdateOne(c, bson.M{"_id": objectId}, query.BumpVersion(query.Restore())); restoreErr != nil {
+			return usage, utils.NewInternal("failed to restore data")
+		}
+	}
+	return usage, err
+}
+
+func (r *RoleRepository) Restore(ctx echo.Context, id string) error {
+	c := ctx.Request().Context()
+
+	objectId, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return utils.NewBadRequest("invalid id format")
+	}
+
+	roles := r.collection.For(ctx)
+	filter := bson.M{"_id": objectId, "deleted_at": bson.M{"$ne": nil}}
+
+	result, err := roles.UpdateOne(c, filter, query.BumpVersion(query.Restore()))
+	if err != nil {
+		return utils.NewInternal("failed to restore data")
+	}
+
+	if result.MatchedCount == 0 {
+		return utils.NewNotFound("data not found")
+	}
+
+	return nil
+}
+
+// Purge hard deletes roles that have been in the trash since before the cutoff
+// and removes them from the users, groups and roles still referencing them
+func (r *RoleRepository) Purge(ctx context.Context, cutoff time.Time) (int64, error) {
+	cursor, err := r.collection.Unscoped().Find(ctx, query.PurgeBefore(cutoff))
+	if err != nil {
+		return 0, utils.NewInternal("failed to query data")
+	}
+	defer cursor.Close(ctx)
+
+	var expired []RoleModel
+	if err := cursor.All(ctx, &expired); err != nil {
+		return 0, utils.NewInternal("failed to decode data")
+	}
+
+	if len(expired) == 0 {
+		return 0, nil
+	}
+
+	ids := make(bson.A, 0, len(expired))
+	for _, role := range expired {
+		ids = append(ids, role.ID)
+	}
+
+	userCollection := r.app.DB.Collection("users")
+	_, err = userCollection.UpdateMany(ctx, heldBy(bson.M{"$in": ids}), query.BumpVersion(bson.M{
+		"$pull": bson.M{"roles": bson.M{"$in": ids}, "role_assignments": bson.M{"role": bson.M{"$in": ids}}},
+	}))
+	if err != nil {
+		return 0, utils.NewInternal("failed to unassign purged roles")
+	}
+
+	_, err = userCollection.UpdateMany(ctx, bson.M{"memberships.roles": bson.M{"$in": ids}}, query.BumpVersion(bson.M{
+		"$pull": bson.M{"memberships.$[].roles": bson.M{"$in": ids}},
+	}))
+	if err != nil {
+		return 0, utils.NewInternal("failed to unassign purged roles")
+	}
+
+	groupCollection := r.app.DB.Collection("groups")
+	_, err = groupCollection.UpdateMany(ctx, bson.M{"roles": bson.M{"$in": ids}}, query.BumpVersion(bson.M{
+		"$pull": bson.M{"roles": bson.M{"$in": ids}},
+	}))
+	if err != nil {
+		return 0, utils.NewInternal("failed to remove purged roles from groups")
+	}
+
+	_, err = r.collection.Unscoped().UpdateMany(ctx, bson.M{"parents": bson.M{"$in": ids}}, query.BumpVersion(bson.M{
+		"$pull": bson.M{"parents": bson.M{"$in": ids}},
+	}))
+	if err != nil {
+		return 0, utils.NewInternal("failed to remove purged roles from role parents")
+	}
+
+	result, err := r.collection.Unscoped().DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return 0, utils.NewInternal("failed to purge data")
+	}
+
+	return result.DeletedCount, nil
+}
+
 func (r *RoleRepository) AssignUser(ctx echo.Context, userId string, roleId string) error {
 	c := ctx.Request().Context()
 
@@ -145,10 +349,12 @@ func (r *RoleRepository) AssignUser(ctx echo.Context, userId string, roleId stri
 		return utils.NewBadRequest("invalid id format")
 	}
 
-	filter := bson.M{"_id": objectUserID}
-	update := bson.M{
+	// A permanent assignment replaces the time-bound ones of the role
+	filter := bson.M{"_id": objectUserID, "deleted_at": nil}
+	update := query.BumpVersion(bson.M{
 		"$addToSet": bson.M{"roles": objectRoleID},
-	}
+		"$pull":     bson.M{"role_assignments": bson.M{"role": objectRoleID}},
+	})
 
 	_, err = userCollection.UpdateOne(c, filter, update)
 	if err != nil {
@@ -172,12 +378,452 @@ func (r *RoleRepository) UnassignUser(ctx echo.Context, userId string, roleId st
 		return utils.NewBadRequest("invalid id format")
 	}
 	filter := bson.M{"_id": objectUserID}
-	update := bson.M{
-		"$pull": bson.M{"roles": objectRoleID},
-	}
+	update := query.BumpVersion(bson.M{
+		"$pull": bson.M{"roles": objectRoleID, "role_assignments": bson.M{"role": objectRoleID}},
+	})
 	_, err = userCollection.UpdateOne(c, filter, update)
 	if err != nil {
 		return utils.NewInternal("failed to unassign role to user")
 	}
 	return nil
 }
+
+// FindSystemRole returns the system role with the name, deleted or not
+func (r *RoleRepository) FindSystemRole(ctx context.Context, name string) (RoleModel, error) {
+	var role RoleModel
+	err := r.collection.Unscoped().FindOne(ctx, bson.M{"name": name, "is_system": true}).Decode(&role)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return RoleModel{}, utils.NewNotFound("system role " + name + " not found")
+		}
+		return RoleModel{}, utils.NewInternal("failed to find data")
+	}
+
+	return role, nil
+}
+
+// SeedSystemRole inserts a missing system role, or gives back the permissions
+// it lacks and takes it out of the trash. Permissions added by admins are kept.
+func (r *RoleRepository) SeedSystemRole(ctx context.Context, role entities.Role) error {
+	var current entities.Role
+	err := r.collection.Unscoped().FindOne(ctx, bson.M{"name": role.Name, "is_system": true}).Decode(&current)
+	if err == mongo.ErrNoDocuments {
+		role.IsSystem = true
+		role.CreatedAt = time.Now()
+		role.UpdatedAt = role.CreatedAt
+		if _, err := r.collection.Unscoped().InsertOne(ctx, role); err != nil && !mongo.IsDuplicateKeyError(err) {
+			return utils.NewInternal("failed to create system role")
+		}
+		return nil
+	}
+	if err != nil {
+		return utils.NewInternal("failed to find data")
+	}
+
+	var missing []string
+	for _, name := range role.Permissions {
+		if !slices.Contains(current.Permissions, name) {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) == 0 && current.DeletedAt == nil {
+		return nil
+	}
+
+	update := bson.M{"$set": bson.M{"updated_at": time.Now()}}
+	if len(missing) > 0 {
+		update["$addToSet"] = bson.M{"permissions": bson.M{"$each": missing}}
+	}
+	if current.DeletedAt != nil {
+		update["$unset"] = bson.M{"deleted_at": "", "deleted_by": ""}
+	}
+
+	if _, err := r.collection.Unscoped().UpdateOne(ctx, bson.M{"_id": current.ID}, query.BumpVersion(update)); err != nil {
+		return utils.NewInternal("failed to update system role")
+	}
+	return nil
+}
+
+// BootstrapAdmin creates the admin holding the role, unless a user already
+// holds it. An existing account with the email of the admin is never
+// promoted, the insert fails with a conflict instead.
+// It reports whether the admin was created.
+func (r *RoleRepository) BootstrapAdmin(ctx context.Context, roleID bson.ObjectID, admin entities.User) (bool, error) {
+	userCollection := r.app.DB.Collection("users")
+
+	holders, err := userCollection.CountDocuments(ctx, bson.M{"roles": roleID, "deleted_at": nil})
+	if err != nil {
+		return false, utils.NewInternal("failed to count role holders")
+	}
+	if holders > 0 {
+		return false, nil
+	}
+
+	admin.Roles = []bson.ObjectID{roleID}
+	if _, err := userCollection.InsertOne(ctx, admin); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return false, utils.NewConflict("email already exists")
+		}
+		return false, utils.NewInternal("failed to create user")
+	}
+	return true, nil
+}
+
+// Usage counts the users and groups holding the role and the roles inheriting from it
+func (r *RoleRepository) Usage(ctx echo.Context, id bson.ObjectID) (RoleUsageModel, error) {
+	return r.usage(ctx.Request().Context(), id)
+}
+
+func (r *RoleRepository) usage(c context.Context, id bson.ObjectID) (RoleUsageModel, error) {
+	holders := heldBy(id)
+	holders["deleted_at"] = nil
+
+	var usage RoleUsageModel
+	var err error
+	if usage.Users, err = r.app.DB.Collection("users").CountDocuments(c, holders); err != nil {
+		return RoleUsageModel{}, utils.NewInternal("failed to count role holders")
+	}
+	if usage.Groups, err = r.app.DB.Collection("groups").CountDocuments(c, bson.M{"roles": id}); err != nil {
+		return RoleUsageModel{}, utils.NewInternal("failed to count role holders")
+	}
+	if usage.Children, err = r.collection.Unscoped().CountDocuments(c, bson.M{"parents": id, "deleted_at": nil}); err != nil {
+		return RoleUsageModel{}, utils.NewInternal("failed to count child roles")
+	}
+
+	return usage, nil
+}
+
+// Descendants returns the roles inheriting from the role, directly or not
+func (r *RoleRepository) Descendants(ctx echo.Context, id bson.ObjectID) ([]bson.ObjectID, error) {
+	c := ctx.Request().Context()
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"_id": id}}},
+		{{Key: "$graphLookup", Value: bson.M{
+			"from":                    "roles",
+			"startWith":               "$_id",
+			"connectFromField":        "_id",
+			"connectToField":          "parents",
+			"as":                      "descendants",
+			"restrictSearchWithMatch": bson.M{"deleted_at": nil},
+		}}},
+		{{Key: "$project", Value: bson.M{"ids": "$descendants._id"}}},
+	}
+
+	cursor, err := r.collection.Unscoped().Aggregate(c, pipeline)
+	if err != nil {
+		return nil, utils.NewInternal("failed to query data")
+	}
+	defer cursor.Close(c)
+
+	var result []struct {
+		IDs []bson.ObjectID `bson:"ids"`
+	}
+	if err := cursor.All(c, &result); err != nil {
+		return nil, utils.NewInternal("failed to decode data")
+	}
+	if len(result) == 0 {
+		return nil, nil
+	}
+	return result[0].IDs, nil
+}
+
+// Holders returns the users holding one of the roles, directly or through a group
+func (r *RoleRepository) Holders(ctx echo.Context, ids []bson.ObjectID) ([]bson.ObjectID, error) {
+	c := ctx.Request().Context()
+
+	var groups []bson.ObjectID
+	if err := r.app.DB.Collection("groups").Distinct(c, "_id", bson.M{"roles": bson.M{"$in": ids}}).Decode(&groups); err != nil {
+		return nil, utils.NewInternal("failed to query role holders")
+	}
+
+	filter := bson.M{"deleted_at": nil, "$or": bson.A{
+		heldBy(bson.M{"$in": ids}),
+		bson.M{"groups": bson.M{"$in": groups}},
+	}}
+
+	var users []bson.ObjectID
+	if err := r.app.DB.Collection("users").Distinct(c, "_id", filter).Decode(&users); err != nil {
+		return nil, utils.NewInternal("failed to query role holders")
+	}
+	return users, nil
+}
+
+// DeleteReferenced deletes the role and, in the same transaction, takes it
+// away from the users, groups and roles referencing it. A replacement is handed
+// to them instead when given.
+func (r *RoleRepository) DeleteReferenced(ctx echo.Context, id string, deletedBy string, replacement *bson.ObjectID) error {
+	c := ctx.Request().Context()
+
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return utils.NewBadRequest("invalid id format")
+	}
+
+	roles := r.collection.For(ctx)
+	filter := bson.M{"_id": objectID, "deleted_at": nil}
+	return r.transaction(c, func(tc context.Context) error {
+		result, err := roles.UpdateOne(tc, utils.VersionedFilter(ctx, filter), query.BumpVersion(query.SoftDelete(deletedBy)))
+		if err != nil {
+			return utils.NewInternal("failed to delete data")
+		}
+		if result.MatchedCount == 0 {
+			return roles.NotMatched(tc, filter)
+		}
+
+		references := []struct {
+			collection *mongo.Collection
+			field      string
+			filter     bson.M
+		}{
+			{r.app.DB.Collection("users"), "roles", bson.M{"roles": objectID}},
+			{r.app.DB.Collection("groups"), "roles", bson.M{"roles": objectID}},
+			{r.collection.Unscoped(), "parents", bson.M{"parents": objectID, "deleted_at": nil}},
+		}
+		for _, reference := range references {
+			if _, err := reference.collection.UpdateMany(tc, reference.filter, replaceReference(reference.field, objectID, replacement)); err != nil {
+				return utils.NewInternal("failed to update the holders of the role")
+			}
+		}
+
+		_, err = r.app.DB.Collection("users").UpdateMany(tc, bson.M{"role_assignments.role": objectID}, replaceAssignments(objectID, replacement))
+		if err != nil {
+			return utils.NewInternal("failed to update the holders of the role")
+		}
+
+		_, err = r.app.DB.Collection("users").UpdateMany(tc, bson.M{"memberships.roles": objectID}, replaceMemberships(objectID, replacement))
+		if err != nil {
+			return utils.NewInternal("failed to update the holders of the role")
+		}
+		return nil
+	})
+}
+
+// replaceReference is the update removing the id from an array field, adding
+// the replacement when there is one. A pipeline is needed since an update can
+// not both pull from and add to the same field.
+func replaceReference(field string, id bson.ObjectID, replacement *bson.ObjectID) interface{} {
+	if replacement == nil {
+		return query.BumpVersion(bson.M{"$pull": bson.M{field: id}})
+	}
+
+	return mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			field: bson.M{"$setUnion": bson.A{
+				bson.M{"$setDifference": bson.A{"$" + field, bson.A{id}}},
+				bson.A{*replacement},
+			}},
+			"version": bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$version", 0}}, 1}},
+		}}},
+	}
+}
+
+// replaceAssignments is the update removing the time-bound assignments of the
+// role, or handing them over to the replacement with the same window
+func replaceAssignments(id bson.ObjectID, replacement *bson.ObjectID) interface{} {
+	if replacement == nil {
+		return query.BumpVersion(bson.M{"$pull": bson.M{"role_assignments": bson.M{"role": id}}})
+	}
+
+	return mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"role_assignments": bson.M{"$map": bson.M{
+				"input": "$role_assignments",
+				"in": bson.M{"$cond": bson.A{
+					bson.M{"$eq": bson.A{"$$this.role", id}},
+					bson.M{"$mergeObjects": bson.A{"$$this", bson.M{"role": *replacement}}},
+					"$$this",
+				}},
+			}},
+			"version": bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$version", 0}}, 1}},
+		}}},
+	}
+}
+
+// replaceMemberships is the update taking the role of an organization away from
+// its members, or handing them the replacement instead
+func replaceMemberships(id bson.ObjectID, replacement *bson.ObjectID) interface{} {
+	if replacement == nil {
+		return query.BumpVersion(bson.M{"$pull": bson.M{"memberships.$[].roles": id}})
+	}
+
+	return mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"memberships": bson.M{"$map": bson.M{
+				"input": "$memberships",
+				"in": bson.M{"$cond": bson.A{
+					bson.M{"$in": bson.A{id, "$$this.roles"}},
+					bson.M{"$mergeObjects": bson.A{"$$this", bson.M{"roles": bson.M{"$setUnion": bson.A{
+						bson.M{"$setDifference": bson.A{"$$this.roles", bson.A{id}}},
+						bson.A{*replacement},
+					}}}}},
+					"$$this",
+				}},
+			}},
+			"version": bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$version", 0}}, 1}},
+		}}},
+	}
+}
+
+// heldBy matches the users holding the role permanently, for a time window or
+// through an organization membership, role may be an id or an operator such as $in
+func heldBy(role interface{}) bson.M {
+	return bson.M{"$or": bson.A{
+		bson.M{"roles": role},
+		bson.M{"role_assignments.role": role},
+		bson.M{"memberships.roles": role},
+	}}
+}
+
+// transaction runs fn in a transaction. Standalone servers do not support
+// transactions, callers needing one are refused.
+func (r *RoleRepository) transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if !r.app.Transactions {
+		return utils.NewInternal("transactions need a replica set")
+	}
+
+	session, err := r.app.DB.Client().StartSession()
+	if err != nil {
+		return utils.NewInternal("failed to start session")
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(tc context.Context) (interface{}, error) {
+		return nil, fn(tc)
+	})
+	return err
+}
+
+// AssignUserFor gives the role to the user for the window of the assignment,
+// replacing a previous time-bound assignment of the same role
+func (r *RoleRepository) AssignUserFor(ctx echo.Context, userId string, roleId string, assignment entities.RoleAssignment) error {
+	c := ctx.Request().Context()
+
+	userCollection := r.app.DB.Collection("users")
+	objectUserID, err := bson.ObjectIDFromHex(userId)
+	if err != nil {
+		return utils.NewBadRequest("invalid id format")
+	}
+
+	objectRoleID, err := bson.ObjectIDFromHex(roleId)
+	if err != nil {
+		return utils.NewBadRequest("invalid id format")
+	}
+	assignment.Role = objectRoleID
+
+	// The note is user input, $literal keeps a leading $ from reading as a field path
+	filter := bson.M{"_id": objectUserID, "deleted_at": nil}
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"role_assignments": bson.M{"$concatArrays": bson.A{
+				bson.M{"$filter": bson.M{
+					"input": bson.M{"$ifNull": bson.A{"$role_assignments", bson.A{}}},
+					"cond":  bson.M{"$ne": bson.A{"$$this.role", objectRoleID}},
+				}},
+				bson.A{bson.M{"$literal": assignment}},
+			}},
+			"version": bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$version", 0}}, 1}},
+		}}},
+	}
+
+	result, err := userCollection.UpdateOne(c, filter, update)
+	if err != nil {
+		return utils.NewInternal("failed to assign role to user")
+	}
+	if result.MatchedCount == 0 {
+		return utils.NewNotFound("user not found")
+	}
+
+	return nil
+}
+
+// ExpireAssignments removes the time-bound assignments that ended before now
+// and returns them. An assignment removed by another instance in the meantime
+// is left out, so each one is reported once.
+func (r *RoleRepository) ExpireAssignments(ctx context.Context, now time.Time) ([]AssignmentExpired, error) {
+	userCollection := r.app.DB.Collection("users")
+	expired := bson.M{"expires_at": bson.M{"$lte": now}}
+
+	cursor, err := userCollection.Find(ctx, bson.M{"role_assignments": bson.M{"$elemMatch": expired}})
+	if err != nil {
+		return nil, utils.NewInternal("failed to query data")
+	}
+	defer cursor.Close(ctx)
+
+	var users []entities.User
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, utils.NewInternal("failed to decode data")
+	}
+
+	var removed []AssignmentExpired
+	for _, user := range users {
+		var ended []AssignmentExpired
+		for _, assignment := range user.RoleAssignments {
+			if assignment.ExpiresAt != nil && !assignment.ExpiresAt.After(now) {
+				ended = append(ended, AssignmentExpired{
+					UserID:    user.ID.Hex(),
+					RoleID:    assignment.Role.Hex(),
+					ExpiresAt: *assignment.ExpiresAt,
+					Note:      assignment.Note,
+				})
+			}
+		}
+
+		result, err := userCollection.UpdateOne(ctx,
+			bson.M{"_id": user.ID, "role_assignments": bson.M{"$elemMatch": expired}},
+			query.BumpVersion(bson.M{"$pull": bson.M{"role_assignments": expired}}))
+		if err != nil {
+			return removed, utils.NewInternal("failed to remove expired assignments")
+		}
+		if result.ModifiedCount > 0 {
+			removed = append(removed, ended...)
+		}
+	}
+
+	return removed, nil
+}
+
+// ExpiringAssignments returns the time-bound assignments ending between now and
+// until, the soonest first
+func (r *RoleRepository) ExpiringAssignments(ctx echo.Context, now time.Time, until time.Time) ([]RoleAssignmentModel, error) {
+	c := ctx.Request().Context()
+
+	window := bson.M{"$gt": now, "$lte": until}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"deleted_at": nil, "role_assignments.expires_at": window}}},
+		{{Key: "$unwind", Value: "$role_assignments"}},
+		{{Key: "$match", Value: bson.M{"role_assignments.expires_at": window}}},
+		{{Key: "$lookup", Value: bson.M{
+			"from":         "roles",
+			"localField":   "role_assignments.role",
+			"foreignField": "_id",
+			"as":           "role",
+		}}},
+		{{Key: "$project", Value: bson.M{
+			"_id":         0,
+			"user_id":     "$_id",
+			"email":       1,
+			"name":        1,
+			"role_id":     "$role_assignments.role",
+			"role_name":   bson.M{"$first": "$role.name"},
+			"starts_at":   "$role_assignments.starts_at",
+			"expires_at":  "$role_assignments.expires_at",
+			"note":        "$role_assignments.note",
+			"assigned_by": "$role_assignments.assigned_by",
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "expires_at", Value: 1}, {Key: "user_id", Value: 1}}}},
+	}
+
+	cursor, err := r.app.DB.Collection("users").Aggregate(c, pipeline)
+	if err != nil {
+		return nil, utils.NewInternal("failed to query data")
+	}
+	defer cursor.Close(c)
+
+	assignments := []RoleAssignmentModel{}
+	if err := cursor.All(c, &assignments); err != nil {
+		return nil, utils.NewInternal("failed to decode data")
+	}
+
+	return assignments, nil
+}
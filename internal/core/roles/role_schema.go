@@ -0,0 +1,31 @@
+package roles
+
+import (
+	"github.com/HasanNugroho/starter-golang/internal/shared/modules"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// roleSchema declares the roles collection
+var roleSchema = modules.CollectionSchema{
+	Name: "roles",
+	Indexes: []modules.IndexSpec{
+		{Name: "deleted_at", Keys: bson.D{{Key: "deleted_at", Value: 1}}},
+		{Name: "parents", Keys: bson.D{{Key: "parents", Value: 1}}, Sparse: true},
+		{Name: "organization", Keys: bson.D{{Key: "organization", Value: 1}}, Sparse: true},
+		// Instances seeding at the same time can not create a system role twice
+		{Name: "system_name_unique", Keys: bson.D{{Key: "name", Value: 1}}, Unique: true, Partial: bson.M{"is_system": true}},
+	},
+	Validator: bson.M{
+		"bsonType": "object",
+		"required": bson.A{"name", "permissions"},
+		"properties": bson.M{
+			"name":         bson.M{"bsonType": "string", "minLength": 1},
+			"permissions":  bson.M{"bsonType": bson.A{"array", "null"}, "items": bson.M{"bsonType": "string"}},
+			"parents":      bson.M{"bsonType": bson.A{"array", "null"}, "items": bson.M{"bsonType": "objectId"}},
+			"is_system":    bson.M{"bsonType": "bool"},
+			"organization": bson.M{"bsonType": "objectId"},
+			"version":      bson.M{"bsonType": bson.A{"int", "long"}},
+			"deleted_at":   bson.M{"bsonType": bson.A{"date", "null"}},
+		},
+	},
+}
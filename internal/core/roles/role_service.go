@@ -1,13 +1,24 @@
 package roles
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/HasanNugroho/starter-golang/internal/app"
 	"github.com/HasanNugroho/starter-golang/internal/core/entities"
+	"github.com/HasanNugroho/starter-golang/internal/shared/authz"
 	shared "github.com/HasanNugroho/starter-golang/internal/shared/model"
+	"github.com/HasanNugroho/starter-golang/internal/shared/patch"
+	"github.com/HasanNugroho/starter-golang/internal/shared/permission"
+	"github.com/HasanNugroho/starter-golang/internal/shared/query"
+	"github.com/HasanNugroho/starter-golang/internal/shared/tenant"
 	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
 	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/v2/bson"
 )
 
 type RoleService struct {
@@ -23,15 +34,36 @@ func NewRoleService(app *app.Apps, repo IRoleRepository) *RoleService {
 }
 
 func (r *RoleService) Create(ctx echo.Context, user *RoleUpdateModel) error {
-	if len(utils.Intersection(user.Permissions, r.app.Config.ModulePermissions)) < 1 {
+	parents, err := r.checkParents(ctx, bson.NilObjectID, user.Parents)
+	if err != nil {
+		return err
+	}
+
+	// A role inheriting everything it grants needs no permissions of its own
+	if len(parents) == 0 && len(user.Permissions) == 0 {
 		return utils.NewBadRequest("permissions not found")
 	}
+	if err := r.checkRegistered(user.Permissions); err != nil {
+		return err
+	}
 
+	if err := checkTenantPermissions(ctx, user.Permissions); err != nil {
+		return err
+	}
+
+	// Roles created inside a tenant belong to it
 	payload := entities.Role{
-		Name:        user.Name,
-		Permissions: user.Permissions,
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
+		Name:         user.Name,
+		Permissions:  user.Permissions,
+		Parents:      parents,
+		Organization: tenant.ID(ctx),
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}
+
+	created := RoleModel{Name: payload.Name, Permissions: payload.Permissions, Parents: payload.Parents, Organization: payload.Organization}
+	if !r.app.Authz.Allowed(ctx, "roles:create", roleResource(created)) {
+		return utils.NewForbidden("access denied")
 	}
 
 	if err := r.repo.Create(ctx, &payload); err != nil {
@@ -45,22 +77,30 @@ func (r *RoleService) FindById(ctx echo.Context, id string) (RoleModel, error) {
 	return r.repo.FindById(ctx, id)
 }
 
-func (r *RoleService) FindAll(ctx echo.Context, filter *shared.PaginationFilter) (shared.DataWithPagination, error) {
-	users, totalItems, err := r.repo.FindAll(ctx, filter)
+// EffectivePermissions resolves the permissions a role grants through its
+// parents and tells which role each of them comes from
+func (r *RoleService) EffectivePermissions(ctx echo.Context, id string) (RolePermissionsModel, error) {
+	role, err := r.repo.FindById(ctx, id)
 	if err != nil {
-		return shared.DataWithPagination{}, err
+		return RolePermissionsModel{}, err
 	}
 
-	// build pagination meta
-	paginate := utils.BuildPagination(filter, int64(totalItems))
+	hierarchy, err := r.repo.FindHierarchy(ctx, []bson.ObjectID{role.ID})
+	if err != nil {
+		return RolePermissionsModel{}, err
+	}
 
-	// Buat response dengan pagination
-	result := shared.DataWithPagination{
-		Items:  users,
-		Paging: paginate,
+	return permissionTree(role.ID, hierarchy), nil
+}
+
+func (r *RoleService) FindAll(ctx echo.Context, q *query.Query) (shared.DataWithPagination, error) {
+	page, err := r.repo.FindAll(ctx, q)
+	if err != nil {
+		return shared.DataWithPagination{}, err
 	}
 
-	return result, nil
+	// Buat response dengan pagination
+	return page.Response(q), nil
 }
 
 func (r *RoleService) Update(ctx echo.Context, id string, role *RoleUpdateModel) error {
@@ -69,6 +109,14 @@ func (r *RoleService) Update(ctx echo.Context, id string, role *RoleUpdateModel)
 		return err
 	}
 
+	if !r.app.Authz.Allowed(ctx, "roles:update", roleResource(currentRole)) {
+		return utils.NewForbidden("access denied")
+	}
+
+	if err := utils.CheckVersion(ctx, currentRole.Version); err != nil {
+		return err
+	}
+
 	updatedRole := entities.Role{
 		Name: currentRole.Name,
 	}
@@ -78,25 +126,487 @@ func (r *RoleService) Update(ctx echo.Context, id string, role *RoleUpdateModel)
 	}
 
 	if role.Permissions != nil {
-		if len(utils.Intersection(role.Permissions, r.app.Config.ModulePermissions)) < 1 {
+		if len(role.Permissions) == 0 {
 			return utils.NewBadRequest("permissions not found")
 		}
+		if err := r.checkRegistered(role.Permissions); err != nil {
+			return err
+		}
+		if err := checkTenantPermissions(ctx, role.Permissions); err != nil {
+			return err
+		}
 		updatedRole.Permissions = role.Permissions
 	} else {
 		updatedRole.Permissions = currentRole.Permissions
 	}
 
-	return r.repo.Update(ctx, id, &updatedRole)
+	if err := checkSystemRole(currentRole, updatedRole.Name, updatedRole.Permissions); err != nil {
+		return err
+	}
+
+	if role.Parents != nil {
+		parents, err := r.checkParents(ctx, currentRole.ID, role.Parents)
+		if err != nil {
+			return err
+		}
+		updatedRole.Parents = parents
+	} else {
+		updatedRole.Parents = currentRole.Parents
+	}
+
+	if err := r.repo.Update(ctx, id, &updatedRole); err != nil {
+		return err
+	}
+
+	// Holders of the role and of every role inheriting from it are affected
+	r.app.Bus.Emit(permission.ChangedEvent, permission.Changed{})
+	return nil
+}
+
+func (r *RoleService) Patch(ctx echo.Context, id string, contentType string, body []byte) error {
+	currentRole, err := r.repo.FindById(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if !r.app.Authz.Allowed(ctx, "roles:update", roleResource(currentRole)) {
+		return utils.NewForbidden("access denied")
+	}
+
+	if err := utils.CheckVersion(ctx, currentRole.Version); err != nil {
+		return err
+	}
+
+	current := RolePatchModel{
+		Name:        currentRole.Name,
+		Permissions: currentRole.Permissions,
+		Parents:     currentRole.Parents,
+	}
+
+	var patched RolePatchModel
+	if err := patch.Apply(contentType, current, body, &patched); err != nil {
+		return err
+	}
+
+	if err := checkSystemRole(currentRole, patched.Name, patched.Permissions); err != nil {
+		return err
+	}
+
+	if err := r.checkRegistered(patched.Permissions); err != nil {
+		return err
+	}
+	if err := checkTenantPermissions(ctx, patched.Permissions); err != nil {
+		return err
+	}
+
+	if !slices.Equal(current.Parents, patched.Parents) {
+		parents := make([]string, len(patched.Parents))
+		for i, parent := range patched.Parents {
+			parents[i] = parent.Hex()
+		}
+		if patched.Parents, err = r.checkParents(ctx, currentRole.ID, parents); err != nil {
+			return err
+		}
+	}
+
+	changes, err := patch.Diff(current, patched)
+	if err != nil {
+		return err
+	}
+
+	if changes.Empty() {
+		return nil
+	}
+
+	if err := r.repo.Patch(ctx, id, changes); err != nil {
+		return err
+	}
+
+	r.app.Bus.Emit(permission.ChangedEvent, permission.Changed{})
+	return nil
+}
+
+// Usage counts what still references the role
+func (r *RoleService) Usage(ctx echo.Context, id string) (RoleUsageModel, error) {
+	role, err := r.repo.FindById(ctx, id)
+	if err != nil {
+		return RoleUsageModel{}, err
+	}
+
+	return r.repo.Usage(ctx, role.ID)
+}
+
+// Delete moves the role to the trash. By default a role still held or
+// inherited is refused, the cascade and reassign modes take it away from its
+// holders or hand them the replacement instead. Holders lose permissions, so
+// their tokens are revoked.
+func (r *RoleService) Delete(ctx echo.Context, id string, options *RoleDeleteModel) (RoleDeleteResultModel, error) {
+	role, err := r.repo.FindById(ctx, id)
+	if err != nil {
+		return RoleDeleteResultModel{}, err
+	}
+
+	if !r.app.Authz.Allowed(ctx, "roles:delete", roleResource(role)) {
+		return RoleDeleteResultModel{}, utils.NewForbidden("access denied")
+	}
+
+	if role.IsSystem {
+		return RoleDeleteResultModel{}, utils.NewForbidden("system roles can not be deleted")
+	}
+
+	mode := options.Mode
+	if mode == "" {
+		mode = DeleteRestrict
+	}
+
+	result := RoleDeleteResultModel{Mode: mode}
+
+	if mode == DeleteRestrict {
+		usage, err := r.repo.Delete(ctx, id, utils.CurrentUserID(ctx))
+		result.Usage = usage
+		if errors.Is(err, errRoleInUse) {
+			return result, utils.NewConflict(fmt.Sprintf("role is held by %d users and %d groups and inherited by %d roles",
+				usage.Users, usage.Groups, usage.Children))
+		}
+		return result, err
+	}
+
+	// Taking the role away from its holders spans collections
+	if !r.app.Transactions {
+		return result, utils.NewBadRequest("deleting a role in use needs MongoDB to run as a replica set, only restrict mode is available")
+	}
+
+	usage, err := r.repo.Usage(ctx, role.ID)
+	if err != nil {
+		return result, err
+	}
+	result.Usage = usage
+
+	// Users holding a role inheriting from this one lose permissions as well
+	descendants, err := r.repo.Descendants(ctx, role.ID)
+	if err != nil {
+		return result, err
+	}
+
+	var replacement *bson.ObjectID
+	if mode == DeleteReassign {
+		target, err := r.repo.FindById(ctx, options.Replacement)
+		if err != nil {
+			return result, err
+		}
+		if target.ID == role.ID || slices.Contains(descendants, target.ID) {
+			return result, utils.NewBadRequest("the replacement role can not be the deleted role or inherit from it")
+		}
+		replacement = &target.ID
+	}
+
+	holders, err := r.repo.Holders(ctx, append([]bson.ObjectID{role.ID}, descendants...))
+	if err != nil {
+		return result, err
+	}
+
+	if err := r.repo.DeleteReferenced(ctx, id, utils.CurrentUserID(ctx), replacement); err != nil {
+		return result, err
+	}
+
+	// The role is gone at this point, holders whose tokens could not be revoked
+	// lose its permissions when their access token expires
+	result.AffectedUsers = len(holders)
+	changed := permission.Changed{UserIDs: make([]string, 0, len(holders))}
+	for _, holder := range holders {
+		if err := utils.RevokeUserTokens(r.app, holder.Hex()); err != nil {
+			r.app.Log.Warn().Msgf("Failed to revoke the tokens of %s: %s", holder.Hex(), err.Error())
+		}
+		changed.UserIDs = append(changed.UserIDs, holder.Hex())
+	}
+	if len(changed.UserIDs) > 0 {
+		r.app.Bus.Emit(permission.ChangedEvent, changed)
+	}
+
+	return result, nil
+}
+
+func (r *RoleService) FindDeleted(ctx echo.Context, q *query.Query) (shared.DataWithPagination, error) {
+	filter, ok := r.app.Authz.ListFilter(ctx, "roles:trash")
+	if !ok {
+		return shared.DataWithPagination{}, utils.NewForbidden("access denied")
+	}
+
+	page, err := r.repo.FindDeleted(ctx, q.Where(filter))
+	if err != nil {
+		return shared.DataWithPagination{}, err
+	}
+
+	return page.Response(q), nil
 }
 
-func (r *RoleService) Delete(ctx echo.Context, id string) error {
-	return r.repo.Delete(ctx, id)
+// Restore takes the role out of the trash, its remaining holders and heirs get
+// its permissions back
+func (r *RoleService) Restore(ctx echo.Context, id string) error {
+	role, err := r.repo.FindDeletedById(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if !r.app.Authz.Allowed(ctx, "roles:restore", roleResource(role)) {
+		return utils.NewForbidden("access denied")
+	}
+
+	if err := r.repo.Restore(ctx, id); err != nil {
+		return err
+	}
+
+	r.app.Bus.Emit(permission.ChangedEvent, permission.Changed{})
+	return nil
 }
 
+// AssignUser gives the role to the user, permanently or for the window between
+// starts_at and expires_at when either is set
 func (r *RoleService) AssignUser(ctx echo.Context, payload *AssignRoleModel) error {
-	return r.repo.AssignUser(ctx, payload.UserID, payload.RoleID)
+	if tenant.ID(ctx) != nil {
+		return utils.NewBadRequest("roles of an organization are granted through its memberships")
+	}
+
+	// Deleted roles can not be handed out
+	role, err := r.repo.FindById(ctx, payload.RoleID)
+	if err != nil {
+		return err
+	}
+
+	if !r.app.Authz.Allowed(ctx, "roles:assign", roleResource(role)) {
+		return utils.NewForbidden("access denied")
+	}
+
+	if payload.StartsAt == nil && payload.ExpiresAt == nil {
+		return r.assigned(r.repo.AssignUser(ctx, payload.UserID, payload.RoleID), payload.UserID)
+	}
+
+	now := time.Now()
+	if payload.ExpiresAt != nil && !payload.ExpiresAt.After(now) {
+		return utils.NewBadRequest("expires_at must be in the future")
+	}
+	if payload.StartsAt != nil && payload.ExpiresAt != nil && !payload.ExpiresAt.After(*payload.StartsAt) {
+		return utils.NewBadRequest("expires_at must be after starts_at")
+	}
+
+	assignment := entities.RoleAssignment{
+		StartsAt:   payload.StartsAt,
+		ExpiresAt:  payload.ExpiresAt,
+		Note:       payload.Note,
+		AssignedAt: now,
+	}
+	if assignedBy, err := bson.ObjectIDFromHex(utils.CurrentUserID(ctx)); err == nil {
+		assignment.AssignedBy = &assignedBy
+	}
+
+	return r.assigned(r.repo.AssignUserFor(ctx, payload.UserID, payload.RoleID, assignment), payload.UserID)
+}
+
+// ExpiringAssignments lists the time-bound assignments ending within the duration
+func (r *RoleService) ExpiringAssignments(ctx echo.Context, within time.Duration) ([]RoleAssignmentModel, error) {
+	now := time.Now()
+	return r.repo.ExpiringAssignments(ctx, now, now.Add(within))
+}
+
+// SweepAssignments removes the expired time-bound assignments. Holders lose
+// permissions, so their tokens are revoked and an event is emitted for each.
+func (r *RoleService) SweepAssignments(ctx context.Context) error {
+	expired, err := r.repo.ExpireAssignments(ctx, time.Now())
+
+	// The assignments are already removed, a failed revocation must not keep
+	// the other holders or the events back
+	var revokeErrs []error
+	revoked := map[string]bool{}
+	changed := permission.Changed{}
+	for _, assignment := range expired {
+		if !revoked[assignment.UserID] {
+			revoked[assignment.UserID] = true
+			if err := utils.RevokeUserTokens(r.app, assignment.UserID); err != nil {
+				revokeErrs = append(revokeErrs, err)
+			}
+			changed.UserIDs = append(changed.UserIDs, assignment.UserID)
+		}
+		r.app.Bus.Emit(AssignmentExpiredEvent, assignment)
+	}
+	if len(changed.UserIDs) > 0 {
+		r.app.Bus.Emit(permission.ChangedEvent, changed)
+	}
+
+	if err == nil && len(expired) > 0 {
+		r.app.Log.Info().Msgf("Removed %d expired role assignments", len(expired))
+	}
+	return errors.Join(append(revokeErrs, err)...)
 }
 
 func (r *RoleService) UnassignUser(ctx echo.Context, payload *AssignRoleModel) error {
-	return r.repo.UnassignUser(ctx, payload.UserID, payload.RoleID)
+	if tenant.ID(ctx) != nil {
+		return utils.NewBadRequest("roles of an organization are granted through its memberships")
+	}
+
+	role, err := r.repo.FindById(ctx, payload.RoleID)
+	if err != nil {
+		return err
+	}
+
+	if !r.app.Authz.Allowed(ctx, "roles:unassign", roleResource(role)) {
+		return utils.NewForbidden("access denied")
+	}
+
+	return r.assigned(r.repo.UnassignUser(ctx, payload.UserID, payload.RoleID), payload.UserID)
+}
+
+// roleResource exposes a role to access policies, e.g. resource.is_system
+func roleResource(role RoleModel) authz.Resource {
+	parents := make([]string, 0, len(role.Parents))
+	for _, parent := range role.Parents {
+		parents = append(parents, parent.Hex())
+	}
+
+	fields := map[string]interface{}{
+		"name":        role.Name,
+		"permissions": role.Permissions,
+		"parents":     parents,
+		"is_system":   role.IsSystem,
+	}
+	if role.Organization != nil {
+		fields["organization"] = role.Organization.Hex()
+	}
+
+	return authz.Resource{Type: "roles", ID: role.ID.Hex(), Fields: fields}
+}
+
+// assigned reports the changed assignments of the user once they are saved
+func (r *RoleService) assigned(err error, userID string) error {
+	if err != nil {
+		return err
+	}
+
+	r.app.Bus.Emit(permission.ChangedEvent, permission.Changed{UserIDs: []string{userID}})
+	return nil
+}
+
+// SeedSystemRoles creates the system roles or gives back their seeded permissions
+func (r *RoleService) SeedSystemRoles(ctx context.Context) error {
+	for _, role := range systemRoles {
+		if err := r.repo.SeedSystemRole(ctx, role); err != nil {
+			return err
+		}
+	}
+
+	r.app.Bus.Emit(permission.ChangedEvent, permission.Changed{})
+	return nil
+}
+
+// BootstrapAdmin creates the configured admin with the super-admin role while
+// no user holds it, so a fresh deployment has someone able to manage roles.
+// Anyone can register the email of the admin before the first start, so an
+// existing account is left alone and the role has to be granted by hand.
+func (r *RoleService) BootstrapAdmin(ctx context.Context) error {
+	security := r.app.Config.Security
+	email := strings.ToLower(strings.TrimSpace(security.BootstrapAdminEmail))
+	if email == "" {
+		return nil
+	}
+	if len(security.BootstrapAdminPassword) < 6 {
+		return utils.NewBadRequest("the bootstrap admin password needs at least 6 characters")
+	}
+
+	role, err := r.repo.FindSystemRole(ctx, SuperAdmin)
+	if err != nil {
+		return err
+	}
+
+	password, err := utils.HashPassword([]byte(security.BootstrapAdminPassword))
+	if err != nil {
+		return err
+	}
+
+	name := security.BootstrapAdminName
+	if name == "" {
+		name = "Administrator"
+	}
+
+	now := time.Now()
+	bootstrapped, err := r.repo.BootstrapAdmin(ctx, role.ID, entities.User{
+		Email:     email,
+		Name:      name,
+		Password:  password,
+		Status:    utils.StatusActive,
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+	var conflict *utils.ConflictError
+	if errors.As(err, &conflict) {
+		r.app.Log.Error().Msgf("Not bootstrapping the admin, an account with the email %s already exists", email)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if bootstrapped {
+		r.app.Log.Info().Msgf("Created the admin %s with the %s role", email, SuperAdmin)
+		r.app.Bus.Emit(permission.ChangedEvent, permission.Changed{})
+	}
+	return nil
+}
+
+// checkParents validates the parents of the role with the given id, a zero id
+// for a role being created
+func (r *RoleService) checkParents(ctx echo.Context, id bson.ObjectID, ids []string) ([]bson.ObjectID, error) {
+	parents, err := parseParents(ids)
+	if err != nil || len(parents) == 0 {
+		return parents, err
+	}
+
+	hierarchy, err := r.repo.FindHierarchy(ctx, parents)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkParents(id, parents, hierarchy); err != nil {
+		return nil, err
+	}
+
+	// Inheriting across organizations would leak permissions between them
+	for _, role := range hierarchy {
+		if slices.Contains(parents, role.ID) && !sameOrganization(role.Organization, tenant.ID(ctx)) {
+			return nil, utils.NewBadRequest("parent roles must belong to the same organization")
+		}
+	}
+
+	return parents, nil
+}
+
+// checkTenantPermissions keeps the roles of an organization from granting the
+// system permission, their holders could otherwise act outside of it
+func checkTenantPermissions(ctx echo.Context, granted []string) error {
+	if tenant.ID(ctx) == nil {
+		return nil
+	}
+	for _, name := range granted {
+		if permission.Match(name, permission.System) {
+			return utils.NewBadRequest("roles of an organization can not grant " + name)
+		}
+	}
+	return nil
+}
+
+func sameOrganization(a *bson.ObjectID, b *bson.ObjectID) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// checkRegistered rejects the permissions unless every one of them, patterns
+// included, matches a registered module permission
+func (r *RoleService) checkRegistered(granted []string) error {
+	registered := r.app.Permissions.Names()
+	for _, name := range granted {
+		if !permission.Registered(name, registered) {
+			return utils.NewBadRequest("permission " + name + " not found")
+		}
+	}
+	return nil
 }
@@ -0,0 +1,333 @@
+package roles
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/HasanNugroho/starter-golang/config"
+	"github.com/HasanNugroho/starter-golang/internal/app"
+	"github.com/HasanNugroho/starter-golang/internal/core/entities"
+	"github.com/HasanNugroho/starter-golang/internal/shared/authz"
+	"github.com/HasanNugroho/starter-golang/internal/shared/modules"
+	"github.com/HasanNugroho/starter-golang/internal/shared/permission"
+	"github.com/HasanNugroho/starter-golang/internal/shared/tenant"
+	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
+	"github.com/labstack/echo/v4"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type stubRoleRepository struct {
+	IRoleRepository
+	roles       map[string]RoleModel
+	usage       RoleUsageModel
+	descendants []bson.ObjectID
+	holders     []bson.ObjectID
+
+	deleted     bool
+	cascaded    bool
+	replacement *bson.ObjectID
+
+	assigned   bool
+	assignment *entities.RoleAssignment
+	expired    []AssignmentExpired
+}
+
+func (s *stubRoleRepository) AssignUser(ctx echo.Context, userId string, roleId string) error {
+	s.assigned = true
+	return nil
+}
+
+func (s *stubRoleRepository) AssignUserFor(ctx echo.Context, userId string, roleId string, assignment entities.RoleAssignment) error {
+	s.assignment = &assignment
+	return nil
+}
+
+func (s *stubRoleRepository) ExpireAssignments(ctx context.Context, now time.Time) ([]AssignmentExpired, error) {
+	expired := s.expired
+	s.expired = nil
+	return expired, nil
+}
+
+func (s *stubRoleRepository) FindById(ctx echo.Context, id string) (RoleModel, error) {
+	role, ok := s.roles[id]
+	if !ok {
+		return RoleModel{}, utils.NewBadRequest("data not found")
+	}
+	return role, nil
+}
+
+func (s *stubRoleRepository) Usage(ctx echo.Context, id bson.ObjectID) (RoleUsageModel, error) {
+	return s.usage, nil
+}
+
+func (s *stubRoleRepository) Descendants(ctx echo.Context, id bson.ObjectID) ([]bson.ObjectID, error) {
+	return s.descendants, nil
+}
+
+func (s *stubRoleRepository) Holders(ctx echo.Context, ids []bson.ObjectID) ([]bson.ObjectID, error) {
+	return s.holders, nil
+}
+
+func (s *stubRoleRepository) Delete(ctx echo.Context, id string, deletedBy string) (RoleUsageModel, error) {
+	if s.usage.InUse() {
+		return s.usage, errRoleInUse
+	}
+	s.deleted = true
+	return s.usage, nil
+}
+
+func (s *stubRoleRepository) DeleteReferenced(ctx echo.Context, id string, deletedBy string, replacement *bson.ObjectID) error {
+	s.cascaded = true
+	s.replacement = replacement
+	return nil
+}
+
+func newTestContext() echo.Context {
+	ctx := echo.New().NewContext(httptest.NewRequest("DELETE", "/", nil), httptest.NewRecorder())
+	ctx.Set("granted_permissions", []string{"roles:*"})
+	return ctx
+}
+
+// newTestRepository holds an editor role, a replacement and a role inheriting from the editor
+func newTestRepository() (*stubRoleRepository, RoleModel, RoleModel, RoleModel) {
+	editor := RoleModel{ID: bson.NewObjectID(), Name: "editor"}
+	author := RoleModel{ID: bson.NewObjectID(), Name: "author"}
+	child := RoleModel{ID: bson.NewObjectID(), Name: "senior-editor", Parents: []bson.ObjectID{editor.ID}}
+
+	repo := &stubRoleRepository{
+		roles: map[string]RoleModel{
+			editor.ID.Hex(): editor,
+			author.ID.Hex(): author,
+			child.ID.Hex():  child,
+		},
+		usage:       RoleUsageModel{Users: 2, Groups: 1, Children: 1},
+		descendants: []bson.ObjectID{child.ID},
+		holders:     []bson.ObjectID{bson.NewObjectID(), bson.NewObjectID(), bson.NewObjectID()},
+	}
+	return repo, editor, author, child
+}
+
+func newTestService(repo IRoleRepository) *RoleService {
+	return NewRoleService(&app.Apps{Config: &config.Config{}, Bus: modules.EventNew(), Authz: authz.NewEngine(), Transactions: true}, repo)
+}
+
+func TestRoleService_Delete_SystemRole(t *testing.T) {
+	repo, editor, _, _ := newTestRepository()
+	editor.IsSystem = true
+	repo.roles[editor.ID.Hex()] = editor
+
+	_, err := newTestService(repo).Delete(newTestContext(), editor.ID.Hex(), &RoleDeleteModel{Mode: DeleteCascade})
+
+	assert.Equal(t, utils.NewForbidden("system roles can not be deleted"), err)
+	assert.False(t, repo.deleted || repo.cascaded)
+}
+
+func TestRoleService_Delete_Restrict(t *testing.T) {
+	repo, editor, _, _ := newTestRepository()
+	service := newTestService(repo)
+
+	result, err := service.Delete(newTestContext(), editor.ID.Hex(), &RoleDeleteModel{})
+
+	assert.Equal(t, utils.NewConflict("role is held by 2 users and 1 groups and inherited by 1 roles"), err)
+	assert.Equal(t, RoleDeleteResultModel{Mode: DeleteRestrict, Usage: repo.usage}, result)
+	assert.False(t, repo.deleted || repo.cascaded)
+
+	repo.usage = RoleUsageModel{}
+	_, err = service.Delete(newTestContext(), editor.ID.Hex(), &RoleDeleteModel{Mode: DeleteRestrict})
+	assert.NoError(t, err)
+	assert.True(t, repo.deleted)
+}
+
+func TestRoleService_Delete_Cascade(t *testing.T) {
+	repo, editor, _, _ := newTestRepository()
+
+	result, err := newTestService(repo).Delete(newTestContext(), editor.ID.Hex(), &RoleDeleteModel{Mode: DeleteCascade})
+
+	assert.NoError(t, err)
+	assert.True(t, repo.cascaded)
+	assert.Nil(t, repo.replacement)
+	assert.Equal(t, RoleDeleteResultModel{Mode: DeleteCascade, Usage: repo.usage, AffectedUsers: 3}, result)
+}
+
+func TestRoleService_Delete_WithoutTransactions(t *testing.T) {
+	repo, editor, author, _ := newTestRepository()
+	service := newTestService(repo)
+	service.app.Transactions = false
+
+	for _, options := range []RoleDeleteModel{{Mode: DeleteCascade}, {Mode: DeleteReassign, Replacement: author.ID.Hex()}} {
+		_, err := service.Delete(newTestContext(), editor.ID.Hex(), &options)
+		assert.Equal(t, utils.NewBadRequest("deleting a role in use needs MongoDB to run as a replica set, only restrict mode is available"), err)
+	}
+	assert.False(t, repo.cascaded)
+
+	// Unused roles are still deleted
+	repo.usage = RoleUsageModel{}
+	_, err := service.Delete(newTestContext(), editor.ID.Hex(), &RoleDeleteModel{})
+	assert.NoError(t, err)
+	assert.True(t, repo.deleted)
+}
+
+func TestRoleService_Delete_Reassign(t *testing.T) {
+	repo, editor, author, child := newTestRepository()
+	service := newTestService(repo)
+
+	// The child would lose the permissions it is meant to hand over
+	_, err := service.Delete(newTestContext(), editor.ID.Hex(), &RoleDeleteModel{Mode: DeleteReassign, Replacement: child.ID.Hex()})
+	assert.Equal(t, utils.NewBadRequest("the replacement role can not be the deleted role or inherit from it"), err)
+	assert.False(t, repo.cascaded)
+
+	_, err = service.Delete(newTestContext(), editor.ID.Hex(), &RoleDeleteModel{Mode: DeleteReassign, Replacement: bson.NewObjectID().Hex()})
+	assert.Equal(t, utils.NewBadRequest("data not found"), err)
+
+	result, err := service.Delete(newTestContext(), editor.ID.Hex(), &RoleDeleteModel{Mode: DeleteReassign, Replacement: author.ID.Hex()})
+	assert.NoError(t, err)
+	assert.Equal(t, &author.ID, repo.replacement)
+	assert.Equal(t, 3, result.AffectedUsers)
+}
+
+func TestRoleService_AssignUser_Window(t *testing.T) {
+	repo, editor, _, _ := newTestRepository()
+	service := newTestService(repo)
+	userID := bson.NewObjectID().Hex()
+	past, soon, later := time.Now().Add(-time.Hour), time.Now().Add(time.Hour), time.Now().Add(48*time.Hour)
+
+	assert.Equal(t, utils.NewBadRequest("expires_at must be in the future"),
+		service.AssignUser(newTestContext(), &AssignRoleModel{UserID: userID, RoleID: editor.ID.Hex(), ExpiresAt: &past, Note: "on-call"}))
+	assert.Equal(t, utils.NewBadRequest("expires_at must be after starts_at"),
+		service.AssignUser(newTestContext(), &AssignRoleModel{UserID: userID, RoleID: editor.ID.Hex(), StartsAt: &later, ExpiresAt: &soon, Note: "on-call"}))
+	assert.Nil(t, repo.assignment)
+
+	assert.NoError(t, service.AssignUser(newTestContext(), &AssignRoleModel{UserID: userID, RoleID: editor.ID.Hex(), StartsAt: &soon, ExpiresAt: &later, Note: "on-call"}))
+	assert.False(t, repo.assigned)
+	assert.Equal(t, &soon, repo.assignment.StartsAt)
+	assert.Equal(t, &later, repo.assignment.ExpiresAt)
+	assert.Equal(t, "on-call", repo.assignment.Note)
+
+	// Without a window the assignment is permanent
+	assert.NoError(t, service.AssignUser(newTestContext(), &AssignRoleModel{UserID: userID, RoleID: editor.ID.Hex()}))
+	assert.True(t, repo.assigned)
+}
+
+func TestRoleService_SweepAssignments(t *testing.T) {
+	repo, editor, author, _ := newTestRepository()
+	userID := bson.NewObjectID().Hex()
+	repo.expired = []AssignmentExpired{
+		{UserID: userID, RoleID: editor.ID.Hex(), ExpiresAt: time.Now(), Note: "on-call"},
+		{UserID: userID, RoleID: author.ID.Hex(), ExpiresAt: time.Now(), Note: "contract"},
+	}
+
+	log := zerolog.Nop()
+	apps := &app.Apps{Config: &config.Config{}, Bus: modules.EventNew(), Log: &log}
+	events := make(chan AssignmentExpired, 2)
+	apps.Bus.On(AssignmentExpiredEvent, func(payload any) {
+		events <- payload.(AssignmentExpired)
+	})
+
+	assert.NoError(t, NewRoleService(apps, repo).SweepAssignments(context.Background()))
+
+	received := []string{(<-events).RoleID, (<-events).RoleID}
+	assert.ElementsMatch(t, []string{editor.ID.Hex(), author.ID.Hex()}, received)
+}
+
+func TestRoleService_SweepAssignments_RevokeFails(t *testing.T) {
+	repo, editor, author, _ := newTestRepository()
+	repo.expired = []AssignmentExpired{
+		{UserID: bson.NewObjectID().Hex(), RoleID: editor.ID.Hex(), ExpiresAt: time.Now()},
+		{UserID: bson.NewObjectID().Hex(), RoleID: author.ID.Hex(), ExpiresAt: time.Now()},
+	}
+
+	// Nothing listens there, every revocation fails
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	t.Cleanup(func() { client.Close() })
+	log := zerolog.Nop()
+	apps := &app.Apps{Config: &config.Config{}, Bus: modules.EventNew(), Log: &log, Redis: client}
+	apps.Config.Redis.Enabled = true
+	events := make(chan AssignmentExpired, 2)
+	apps.Bus.On(AssignmentExpiredEvent, func(payload any) {
+		events <- payload.(AssignmentExpired)
+	})
+
+	err := NewRoleService(apps, repo).SweepAssignments(context.Background())
+	assert.Equal(t, errors.Join(utils.NewInternal("failed to revoke user tokens"), utils.NewInternal("failed to revoke user tokens")), err)
+
+	// Every holder is still reported
+	received := []string{(<-events).RoleID, (<-events).RoleID}
+	assert.ElementsMatch(t, []string{editor.ID.Hex(), author.ID.Hex()}, received)
+}
+
+func TestRoleService_AssignUser_ForgetsPermissions(t *testing.T) {
+	repo, editor, _, _ := newTestRepository()
+	apps := &app.Apps{Config: &config.Config{}, Bus: modules.EventNew(), Authz: authz.NewEngine(), Transactions: true}
+	userID := bson.NewObjectID().Hex()
+
+	events := make(chan permission.Changed, 2)
+	apps.Bus.On(permission.ChangedEvent, func(payload any) {
+		events <- payload.(permission.Changed)
+	})
+
+	service := NewRoleService(apps, repo)
+	assert.NoError(t, service.AssignUser(newTestContext(), &AssignRoleModel{UserID: userID, RoleID: editor.ID.Hex()}))
+	assert.Equal(t, []string{userID}, (<-events).UserIDs)
+
+	// Holders of a deleted role are forgotten together
+	_, err := service.Delete(newTestContext(), editor.ID.Hex(), &RoleDeleteModel{Mode: DeleteCascade})
+	assert.NoError(t, err)
+	assert.Len(t, (<-events).UserIDs, len(repo.holders))
+}
+
+func TestRoleService_Tenant(t *testing.T) {
+	repo, editor, _, _ := newTestRepository()
+	service := newTestService(repo)
+	ctx := newTestContext()
+	tenant.Set(ctx, tenant.Tenant{ID: bson.NewObjectID(), Slug: "acme", Member: true})
+
+	// Roles of an organization are only held through its memberships
+	err := service.AssignUser(ctx, &AssignRoleModel{UserID: bson.NewObjectID().Hex(), RoleID: editor.ID.Hex()})
+	assert.Equal(t, utils.NewBadRequest("roles of an organization are granted through its memberships"), err)
+	assert.False(t, repo.assigned)
+
+	for _, name := range []string{"*", "manage:system", "manage:*"} {
+		assert.Error(t, checkTenantPermissions(ctx, []string{"users:read", name}), name)
+	}
+	assert.NoError(t, checkTenantPermissions(ctx, []string{"users:read", "users:*"}))
+	assert.NoError(t, checkTenantPermissions(newTestContext(), []string{"*"}))
+}
+
+func TestRoleService_UnknownPermission(t *testing.T) {
+	repo, editor, _, _ := newTestRepository()
+	service := newTestService(repo)
+	service.app.Permissions = permission.NewRegistry()
+	assert.NoError(t, service.app.Permissions.Declare(permission.Definition{
+		Name: "users:read", Description: "Read users", Category: "users", Risk: permission.RiskLow,
+	}))
+
+	// One registered permission does not carry an unknown one along
+	want := utils.NewBadRequest("permission posts:read not found")
+	err := service.Create(newTestContext(), &RoleUpdateModel{Name: "reader", Permissions: []string{"users:read", "posts:read"}})
+	assert.Equal(t, want, err)
+	err = service.Update(newTestContext(), editor.ID.Hex(), &RoleUpdateModel{Permissions: []string{"users:*", "posts:read"}})
+	assert.Equal(t, want, err)
+}
+
+func TestRoleService_PolicyDeny(t *testing.T) {
+	repo, editor, _, _ := newTestRepository()
+	service := newTestService(repo)
+	assert.NoError(t, service.app.Authz.Load([]authz.Policy{
+		{Name: "keep-editor", Effect: authz.Deny, Actions: []string{"roles:*"}, Condition: `resource.name == "editor"`},
+	}))
+	denied := utils.NewForbidden("access denied")
+
+	// Holding roles:* does not get past a deny policy
+	assert.Equal(t, denied, service.Update(newTestContext(), editor.ID.Hex(), &RoleUpdateModel{Name: "writer"}))
+	assert.Equal(t, denied, service.AssignUser(newTestContext(), &AssignRoleModel{UserID: bson.NewObjectID().Hex(), RoleID: editor.ID.Hex()}))
+	_, err := service.Delete(newTestContext(), editor.ID.Hex(), &RoleDeleteModel{})
+	assert.Equal(t, denied, err)
+	assert.False(t, repo.assigned)
+	assert.False(t, repo.deleted)
+}
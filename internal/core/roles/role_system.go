@@ -0,0 +1,43 @@
+package roles
+
+import (
+	"slices"
+
+	"github.com/HasanNugroho/starter-golang/internal/core/entities"
+	"github.com/HasanNugroho/starter-golang/internal/shared/permission"
+	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
+)
+
+// SuperAdmin is the system role granting every permission
+const SuperAdmin = "super-admin"
+
+// systemRoles are seeded on every start. Admins may grant them more
+// permissions, but can not rename, delete or take away the seeded ones.
+var systemRoles = []entities.Role{
+	{Name: SuperAdmin, Permissions: []string{permission.System}},
+	{Name: "viewer", Permissions: []string{"users:read", "roles:read", "groups:read", "attributes:read", "policies:read"}},
+}
+
+// checkSystemRole refuses changes stripping a system role of its name or
+// seeded permissions, other roles pass unchecked
+func checkSystemRole(current RoleModel, name string, permissions []string) error {
+	if !current.IsSystem {
+		return nil
+	}
+
+	if name != current.Name {
+		return utils.NewForbidden("system roles can not be renamed")
+	}
+
+	for _, role := range systemRoles {
+		if role.Name != current.Name {
+			continue
+		}
+		for _, seeded := range role.Permissions {
+			if !slices.Contains(permissions, seeded) {
+				return utils.NewForbidden("permission " + seeded + " can not be removed from system role " + current.Name)
+			}
+		}
+	}
+	return nil
+}
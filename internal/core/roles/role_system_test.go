@@ -0,0 +1,71 @@
+package roles
+
+import (
+	"context"
+	"testing"
+
+	"github.com/HasanNugroho/starter-golang/config"
+	"github.com/HasanNugroho/starter-golang/internal/app"
+	"github.com/HasanNugroho/starter-golang/internal/core/entities"
+	"github.com/HasanNugroho/starter-golang/internal/shared/modules"
+	"github.com/HasanNugroho/starter-golang/internal/shared/permission"
+	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestCheckSystemRole(t *testing.T) {
+	superAdmin := RoleModel{ID: bson.NewObjectID(), Name: SuperAdmin, Permissions: []string{permission.System}, IsSystem: true}
+
+	assert.NoError(t, checkSystemRole(superAdmin, SuperAdmin, []string{permission.System, "users:read"}))
+	assert.Equal(t, utils.NewForbidden("system roles can not be renamed"),
+		checkSystemRole(superAdmin, "root", []string{permission.System}))
+	assert.Equal(t, utils.NewForbidden("permission manage:system can not be removed from system role super-admin"),
+		checkSystemRole(superAdmin, SuperAdmin, []string{"users:*"}))
+
+	// Roles created by admins are free to change, whatever their name
+	custom := RoleModel{ID: bson.NewObjectID(), Name: SuperAdmin, Permissions: []string{permission.System}}
+	assert.NoError(t, checkSystemRole(custom, "root", nil))
+}
+
+func TestRoleService_BootstrapAdmin_Config(t *testing.T) {
+	apps := &app.Apps{Config: &config.Config{}}
+	service := NewRoleService(apps, &stubRoleRepository{})
+
+	// Nothing is bootstrapped without an email, the repository is never reached
+	assert.NoError(t, service.BootstrapAdmin(context.Background()))
+
+	apps.Config.Security.BootstrapAdminEmail = "admin@example.com"
+	apps.Config.Security.BootstrapAdminPassword = "short"
+	assert.Equal(t, utils.NewBadRequest("the bootstrap admin password needs at least 6 characters"),
+		service.BootstrapAdmin(context.Background()))
+}
+
+type bootstrapRoleRepository struct {
+	IRoleRepository
+	err error
+}
+
+func (s *bootstrapRoleRepository) FindSystemRole(ctx context.Context, name string) (RoleModel, error) {
+	return RoleModel{ID: bson.NewObjectID(), Name: name, IsSystem: true}, nil
+}
+
+func (s *bootstrapRoleRepository) BootstrapAdmin(ctx context.Context, roleID bson.ObjectID, admin entities.User) (bool, error) {
+	return s.err == nil, s.err
+}
+
+func TestRoleService_BootstrapAdmin_ExistingAccount(t *testing.T) {
+	log := zerolog.Nop()
+	apps := &app.Apps{Config: &config.Config{}, Bus: modules.EventNew(), Log: &log}
+	apps.Config.Security.BootstrapAdminEmail = "admin@example.com"
+	apps.Config.Security.BootstrapAdminPassword = "secret"
+
+	// An account registered with the email of the admin is left alone without
+	// failing the startup
+	service := NewRoleService(apps, &bootstrapRoleRepository{err: utils.NewConflict("email already exists")})
+	assert.NoError(t, service.BootstrapAdmin(context.Background()))
+
+	service = NewRoleService(apps, &bootstrapRoleRepository{err: utils.NewInternal("failed to create user")})
+	assert.Error(t, service.BootstrapAdmin(context.Background()))
+}
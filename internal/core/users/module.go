@@ -1,37 +1,104 @@
 package users
 
 import (
+	"context"
+	"fmt"
+	"time"
+
 	"github.com/HasanNugroho/starter-golang/internal/app"
+	"github.com/HasanNugroho/starter-golang/internal/core/attributes"
 	"github.com/HasanNugroho/starter-golang/internal/shared/middleware"
+	"github.com/HasanNugroho/starter-golang/internal/shared/permission"
 	"github.com/labstack/echo/v4"
+	echomw "github.com/labstack/echo/v4/middleware"
 )
 
+// permissions are the permissions the module declares
+var permissions = []permission.Definition{
+	{Name: "users:create", Description: "Create users", Category: "users", Risk: permission.RiskMedium},
+	{Name: "users:read", Description: "List and view users", Category: "users", Risk: permission.RiskLow},
+	{Name: "users:update", Description: "Edit user profiles", Category: "users", Risk: permission.RiskMedium, DependsOn: []string{"users:read"}},
+	{Name: "users:delete", Description: "Move users to the trash", Category: "users", Risk: permission.RiskHigh, DependsOn: []string{"users:read"}},
+	{Name: "users:trash", Description: "List deleted users", Category: "users", Risk: permission.RiskLow},
+	{Name: "users:restore", Description: "Restore deleted users", Category: "users", Risk: permission.RiskMedium, DependsOn: []string{"users:trash"}},
+	{Name: "users:import", Description: "Import users from CSV files", Category: "users", Risk: permission.RiskHigh, DependsOn: []string{"users:create"}},
+	{Name: "users:export", Description: "Export users to CSV files", Category: "users", Risk: permission.RiskHigh, DependsOn: []string{"users:read"}},
+	{Name: "users:status", Description: "Activate, suspend and lock users", Category: "users", Risk: permission.RiskHigh, DependsOn: []string{"users:read"}},
+	{Name: permission.System, Description: "Grants every permission", Category: "system", Risk: permission.RiskCritical},
+}
+
 type UserModule struct {
-	Handler *UserHandler
+	Handler       *UserHandler
+	ImportHandler *UserImportHandler
+	repository    *UserRepository
+	importService *UserImportService
 }
 
 func NewUserModule(apps *app.Apps) *UserModule {
 	userRepository := NewUserRepository(apps)
-	userService := NewUserService(userRepository)
+	attributeService := attributes.NewAttributeService(apps, attributes.NewAttributeRepository(apps))
+	userService := NewUserService(apps, userRepository, attributeService)
 	userHandler := NewUserHandler(userService)
+	importService := NewUserImportService(apps, NewUserImportRepository(apps), attributeService)
 	return &UserModule{
-		Handler: userHandler,
+		Handler:       userHandler,
+		ImportHandler: NewUserImportHandler(importService),
+		repository:    userRepository,
+		importService: importService,
 	}
 }
 
 func (u *UserModule) Register(app *app.Apps) error {
 	app.Log.Info().Msg("User Module Initialized")
 
-	permission := []string{
-		"users:create",
-		"users:read",
-		"users:update",
-		"users:delete",
-		"manage:system",
+	if err := app.Permissions.Declare(permissions...); err != nil {
+		return err
+	}
+
+	// Resolve the permissions of each request from the roles instead of the token
+	switch app.Config.Security.AuthzMode {
+	case "", permission.ModeToken:
+	case permission.ModeCache:
+		if !app.Config.Redis.Enabled {
+			app.Log.Warn().Msg("AUTHZ_MODE=cache without Redis loads the permissions of every request from the database")
+		}
+		cache := NewPermissionCache(app, u.repository)
+		app.Grants = cache
+		app.Bus.On(permission.ChangedEvent, cache.Forget)
+	default:
+		return fmt.Errorf("unknown AUTHZ_MODE %q", app.Config.Security.AuthzMode)
+	}
+
+	// Declare the collections of the users module
+	for _, schema := range userSchemas(app) {
+		app.Schema.Declare(schema)
+	}
+
+	// Declare the personal data held by the users module for data-subject requests
+	for _, source := range NewUserPersonalData(app).Sources() {
+		app.PersonalData.Register(source)
 	}
 
-	// Merge permission
-	app.Config.ModulePermissions = append(app.Config.ModulePermissions, permission...)
+	// Fail the imports left behind by a stopped instance, so clients stop polling them
+	app.Scheduler.Every("users:imports", time.Minute, func(ctx context.Context) error {
+		failed, err := u.importService.FailAbandoned(ctx)
+		if err == nil && failed > 0 {
+			app.Log.Warn().Msgf("Failed %d abandoned user imports", failed)
+		}
+		return err
+	})
+
+	// Hard delete users once their retention period in the trash has passed
+	if app.Config.DB.SoftDeleteRetention > 0 {
+		retention := time.Duration(app.Config.DB.SoftDeleteRetention) * 24 * time.Hour
+		app.Scheduler.Every("users:purge", time.Duration(app.Config.DB.PurgeInterval)*time.Minute, func(ctx context.Context) error {
+			purged, err := u.repository.Purge(ctx, time.Now().Add(-retention))
+			if err == nil && purged > 0 {
+				app.Log.Info().Msgf("Purged %d deleted users", purged)
+			}
+			return err
+		})
+	}
 
 	return nil
 }
@@ -41,10 +108,19 @@ func (u *UserModule) Route(router *echo.Group, app *app.Apps) {
 	userRoutes.Use(middleware.AuthMiddleware(app))
 	{
 		userRoutes.POST("", u.Handler.Create, middleware.CheckAccess([]string{"users:create"}))
-		userRoutes.GET("/", u.Handler.FindAll, middleware.CheckAccess([]string{"users:read"}))
-		userRoutes.GET("/:id", u.Handler.FindById, middleware.CheckAccess([]string{"users:read"}))
-		userRoutes.PUT("/:id", u.Handler.Update, middleware.CheckAccess([]string{"users:update"}))
-		userRoutes.DELETE("/:id", u.Handler.Delete, middleware.CheckAccess([]string{"users:delete"}))
-
+		userRoutes.GET("/", u.Handler.FindAll, middleware.Authorize(app, "users:read"))
+		// Leave room for the multipart envelope around the file
+		importLimit := fmt.Sprintf("%dK", importMaxSize(app)/1024+64)
+		userRoutes.POST("/import", u.ImportHandler.Import, middleware.CheckAccess([]string{"users:import"}), echomw.BodyLimit(importLimit))
+		userRoutes.GET("/import/:id", u.ImportHandler.FindJob, middleware.CheckAccess([]string{"users:import"}))
+		userRoutes.GET("/import/:id/report", u.ImportHandler.Report, middleware.CheckAccess([]string{"users:import"}))
+		userRoutes.GET("/export", u.ImportHandler.Export, middleware.CheckAccess([]string{"users:export"}))
+		userRoutes.GET("/trash", u.Handler.FindDeleted, middleware.CheckAccess([]string{"users:trash"}))
+		userRoutes.GET("/:id", u.Handler.FindById, middleware.Authorize(app, "users:read"))
+		userRoutes.PUT("/:id", u.Handler.Update, middleware.Authorize(app, "users:update"), middleware.RequireIfMatch())
+		userRoutes.PATCH("/:id", u.Handler.Patch, middleware.Authorize(app, "users:update"), middleware.RequireIfMatch())
+		userRoutes.PUT("/:id/status", u.Handler.ChangeStatus, middleware.CheckAccess([]string{"users:status"}), middleware.RequireIfMatch())
+		userRoutes.DELETE("/:id", u.Handler.Delete, middleware.CheckAccess([]string{"users:delete"}), middleware.RequireIfMatch())
+		userRoutes.POST("/:id/restore", u.Handler.Restore, middleware.CheckAccess([]string{"users:restore"}))
 	}
 }
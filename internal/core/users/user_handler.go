@@ -1,7 +1,9 @@
 package users
 
 import (
-	shared "github.com/HasanNugroho/starter-golang/internal/shared/model"
+	"io"
+
+	"github.com/HasanNugroho/starter-golang/internal/shared/query"
 	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
 	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
@@ -54,22 +56,33 @@ func (c *UserHandler) Create(ctx echo.Context) error {
 // @Tags         users
 // @Accept       json
 // @Produce      json
-// @Param limit query int false "total data per-page" minimum(1) default(10)
+// @Param limit query int false "total data per-page" minimum(1) maximum(100) default(10)
 // @Param page query int false "page" minimum(1) default(1)
-// @Param search query string false "keyword"
+// @Param cursor query string false "opaque cursor from next_cursor or prev_cursor, send empty to start cursor pagination"
+// @Param include_total query bool false "count total items in cursor mode"
+// @Param search query string false "keyword matched against email and name"
+// @Param sort query string false "comma separated fields, prefix with - for descending" example(-created_at,name)
+// @Param filter[field][operator] query string false "filter on email, name, created_at, updated_at, role_id or status, e.g. filter[email][contains]=x"
+// @Param created_after query string false "created at or after (RFC 3339 or YYYY-MM-DD)"
+// @Param created_before query string false "created before (RFC 3339 or YYYY-MM-DD)"
+// @Param role_id query string false "role id"
+// @Param status query string false "account status" Enums(pending, active, suspended, locked, deactivated)
 // @Success      200     {object}  shared.Response{data=shared.DataWithPagination{items=[]UserModelResponse}}
 // @Failure      500     {object}  shared.Response
 // @Router       /users [get]
 // @Security ApiKeyAuth
 func (c *UserHandler) FindAll(ctx echo.Context) error {
-	var filter shared.PaginationFilter
+	schema, err := c.userService.QuerySchema(ctx)
+	if err != nil {
+		return err
+	}
 
-	// Binding query parameters
-	if err := ctx.Bind(&filter); err != nil {
-		return utils.NewBadRequest(err.Error())
+	q, err := query.Parse(ctx.QueryParams(), schema)
+	if err != nil {
+		return err
 	}
 
-	users, err := c.userService.FindAll(ctx, &filter)
+	users, err := c.userService.FindAll(ctx, q)
 	if err != nil {
 		return err
 	}
@@ -86,6 +99,7 @@ func (c *UserHandler) FindAll(ctx echo.Context) error {
 // @Produce      json
 // @Param id path string true "id"
 // @Success      200     {object}  shared.Response{data=UserModel}
+// @Header       200  {string}  ETag  "version of the returned document"
 // @Failure      500     {object}  shared.Response
 // @Router       /users/{id} [get]
 // @Security ApiKeyAuth
@@ -101,6 +115,7 @@ func (c *UserHandler) FindById(ctx echo.Context) error {
 		return err
 	}
 
+	utils.SetETag(ctx, user.Version)
 	utils.SendSuccess(ctx, 200, "User retrieved successfully", user)
 	return nil
 }
@@ -112,11 +127,14 @@ func (c *UserHandler) FindById(ctx echo.Context) error {
 // @Accept       json
 // @Produce      json
 // @Param id path string true "id"
+// @Param If-Match header string true "ETag from the last read, or * to skip the check"
 // @Param        user  body  UserUpdateModel  true  "User Data"
 // @Success      201  {object}  shared.Response
 // @Failure      400  {object}  shared.Response
 // @Failure      404  {object}  shared.Response
 // @Failure      500  {object}  shared.Response
+// @Failure      412  {object}  shared.Response
+// @Failure      428  {object}  shared.Response
 // @Router       /users/{id} [put]
 // @Security ApiKeyAuth
 func (c *UserHandler) Update(ctx echo.Context) error {
@@ -141,6 +159,82 @@ func (c *UserHandler) Update(ctx echo.Context) error {
 	return nil
 }
 
+// PatchUser godoc
+// @Summary      Partially update user
+// @Description  Update user fields with a JSON Merge Patch (RFC 7396) or a JSON Patch (RFC 6902)
+// @Tags         users
+// @Accept       application/merge-patch+json,application/json-patch+json,json
+// @Produce      json
+// @Param id path string true "id"
+// @Param If-Match header string true "ETag from the last read, or * to skip the check"
+// @Param        user  body  UserPatchModel  true  "Merge patch, or an array of patch operations"
+// @Success      200  {object}  shared.Response
+// @Failure      400  {object}  shared.Response
+// @Failure      404  {object}  shared.Response
+// @Failure      409  {object}  shared.Response
+// @Failure      500  {object}  shared.Response
+// @Failure      412  {object}  shared.Response
+// @Failure      428  {object}  shared.Response
+// @Router       /users/{id} [patch]
+// @Security ApiKeyAuth
+func (c *UserHandler) Patch(ctx echo.Context) error {
+	id := ctx.Param("id")
+	if err := c.validate.Var(id, "required"); err != nil {
+		return utils.NewBadRequest(err.Error())
+	}
+
+	body, err := io.ReadAll(ctx.Request().Body)
+	if err != nil || len(body) == 0 {
+		return utils.NewBadRequest("patch body is required")
+	}
+
+	if err := c.userService.Patch(ctx, id, ctx.Request().Header.Get(echo.HeaderContentType), body); err != nil {
+		return err
+	}
+
+	utils.SendSuccess(ctx, 200, "users updated successfully", nil)
+	return nil
+}
+
+// ChangeUserStatus godoc
+// @Summary      Change user status
+// @Description  Move a user account to pending, active, suspended, locked or deactivated. Accounts that are not active can not log in and their sessions end.
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param id path string true "id"
+// @Param If-Match header string true "ETag from the last read, or * to skip the check"
+// @Param        status  body  UserStatusModel  true  "New status and reason"
+// @Success      200  {object}  shared.Response
+// @Failure      400  {object}  shared.Response
+// @Failure      404  {object}  shared.Response
+// @Failure      409  {object}  shared.Response
+// @Failure      500  {object}  shared.Response
+// @Failure      412  {object}  shared.Response
+// @Failure      428  {object}  shared.Response
+// @Router       /users/{id}/status [put]
+// @Security ApiKeyAuth
+func (c *UserHandler) ChangeStatus(ctx echo.Context) error {
+	id := ctx.Param("id")
+	var payload UserStatusModel
+	ctx.Bind(&payload)
+
+	if err := c.validate.Var(id, "required"); err != nil {
+		return utils.NewBadRequest(err.Error())
+	}
+
+	if err := c.validate.Struct(payload); err != nil {
+		return utils.NewBadRequest(err.Error())
+	}
+
+	if err := c.userService.ChangeStatus(ctx, id, &payload); err != nil {
+		return err
+	}
+
+	utils.SendSuccess(ctx, 200, "user status changed successfully", nil)
+	return nil
+}
+
 // DeleteUser godoc
 // @Summary      Delete user
 // @Description  Delete user by ID
@@ -148,8 +242,11 @@ func (c *UserHandler) Update(ctx echo.Context) error {
 // @Accept       json
 // @Produce      json
 // @Param id path string true "id"
+// @Param If-Match header string true "ETag from the last read, or * to skip the check"
 // @Success      200     {object}  shared.Response
 // @Failure      500     {object}  shared.Response
+// @Failure      412  {object}  shared.Response
+// @Failure      428  {object}  shared.Response
 // @Router       /users/{id} [delete]
 // @Security ApiKeyAuth
 func (c *UserHandler) Delete(ctx echo.Context) error {
@@ -167,3 +264,61 @@ func (c *UserHandler) Delete(ctx echo.Context) error {
 	utils.SendSuccess(ctx, 200, "user deleted successfully", nil)
 	return nil
 }
+
+// FindDeletedUsers godoc
+// @Summary      Get deleted users
+// @Description  Retrieve a list of soft deleted users awaiting purge
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param limit query int false "total data per-page" minimum(1) maximum(100) default(10)
+// @Param page query int false "page" minimum(1) default(1)
+// @Param cursor query string false "opaque cursor from next_cursor or prev_cursor, send empty to start cursor pagination"
+// @Param include_total query bool false "count total items in cursor mode"
+// @Param search query string false "keyword matched against email and name"
+// @Param sort query string false "comma separated fields, prefix with - for descending" example(-deleted_at)
+// @Param filter[field][operator] query string false "filter on email, name, deleted_at or deleted_by"
+// @Success      200     {object}  shared.Response{data=shared.DataWithPagination{items=[]UserTrashModel}}
+// @Failure      500     {object}  shared.Response
+// @Router       /users/trash [get]
+// @Security ApiKeyAuth
+func (c *UserHandler) FindDeleted(ctx echo.Context) error {
+	q, err := query.Parse(ctx.QueryParams(), UserTrashQuerySchema)
+	if err != nil {
+		return err
+	}
+
+	users, err := c.userService.FindDeleted(ctx, q)
+	if err != nil {
+		return err
+	}
+
+	utils.SendSuccess(ctx, 200, "deleted users retrieved successfully", users)
+	return nil
+}
+
+// RestoreUser godoc
+// @Summary      Restore user
+// @Description  Restore a soft deleted user by ID
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param id path string true "id"
+// @Success      200     {object}  shared.Response
+// @Failure      404     {object}  shared.Response
+// @Failure      500     {object}  shared.Response
+// @Router       /users/{id}/restore [post]
+// @Security ApiKeyAuth
+func (c *UserHandler) Restore(ctx echo.Context) error {
+	id := ctx.Param("id")
+	if err := c.validate.Var(id, "required"); err != nil {
+		return utils.NewBadRequest(err.Error())
+	}
+
+	if err := c.userService.Restore(ctx, id); err != nil {
+		return err
+	}
+
+	utils.SendSuccess(ctx, 200, "user restored successfully", nil)
+	return nil
+}
@@ -0,0 +1,195 @@
+package users
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/HasanNugroho/starter-golang/internal/shared/query"
+	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
+	"github.com/labstack/echo/v4"
+)
+
+type UserImportHandler struct {
+	importService IUserImportService
+}
+
+func NewUserImportHandler(is IUserImportService) *UserImportHandler {
+	return &UserImportHandler{
+		importService: is,
+	}
+}
+
+// ImportUsers godoc
+// @Summary      Import users
+// @Description  Upload a CSV (email,name,password,roles columns, roles separated by |, attributes.<key> columns) or NDJSON file of users. The file is processed in the background, rows are validated like created users, custom attributes included, deduplicated by email and optionally given roles, which requires roles:assign.
+// @Tags         users
+// @Accept       multipart/form-data,text/csv,application/x-ndjson
+// @Produce      json
+// @Param        file  formData  file  false  "CSV or NDJSON file, or send it as the raw request body"
+// @Param        format  query  string  false  "csv or ndjson, detected from the file name or content type when empty"
+// @Param        roles  query  string  false  "comma separated role ids assigned to every imported user"
+// @Success      202  {object}  shared.Response{data=entities.ImportJob}
+// @Failure      400  {object}  shared.Response
+// @Failure      403  {object}  shared.Response
+// @Failure      413  {object}  shared.Response
+// @Failure      500  {object}  shared.Response
+// @Router       /users/import [post]
+// @Security ApiKeyAuth
+func (c *UserImportHandler) Import(ctx echo.Context) error {
+	format := strings.ToLower(ctx.QueryParam("format"))
+	contentType := ctx.Request().Header.Get(echo.HeaderContentType)
+
+	var upload io.Reader = ctx.Request().Body
+	if strings.HasPrefix(contentType, echo.MIMEMultipartForm) {
+		fileHeader, err := ctx.FormFile("file")
+		if err != nil {
+			return utils.NewBadRequest("file is required")
+		}
+
+		file, err := fileHeader.Open()
+		if err != nil {
+			return utils.NewBadRequest("failed to read upload")
+		}
+		defer file.Close()
+
+		upload = file
+		if format == "" {
+			format = formatFromName(fileHeader.Filename)
+		}
+	}
+	if format == "" {
+		format = formatFromContentType(contentType)
+	}
+
+	var roles []string
+	if raw := ctx.QueryParam("roles"); raw != "" {
+		for _, role := range strings.Split(raw, ",") {
+			if role = strings.TrimSpace(role); role != "" {
+				roles = append(roles, role)
+			}
+		}
+	}
+
+	job, err := c.importService.StartImport(ctx, format, upload, roles)
+	if err != nil {
+		return err
+	}
+
+	utils.SendSuccess(ctx, http.StatusAccepted, "import started", job)
+	return nil
+}
+
+// FindImport godoc
+// @Summary      Get import job
+// @Description  Retrieve the status and counters of a user import job
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param id path string true "id"
+// @Success      200  {object}  shared.Response{data=entities.ImportJob}
+// @Failure      404  {object}  shared.Response
+// @Failure      500  {object}  shared.Response
+// @Router       /users/import/{id} [get]
+// @Security ApiKeyAuth
+func (c *UserImportHandler) FindJob(ctx echo.Context) error {
+	job, err := c.importService.FindJob(ctx, ctx.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	utils.SendSuccess(ctx, http.StatusOK, "import job retrieved successfully", job)
+	return nil
+}
+
+// ImportReport godoc
+// @Summary      Download import report
+// @Description  Download the per-row result of a user import job
+// @Tags         users
+// @Produce      text/csv,application/x-ndjson
+// @Param id path string true "id"
+// @Param format query string false "csv or ndjson" default(csv)
+// @Success      200  {file}  file
+// @Failure      404  {object}  shared.Response
+// @Failure      500  {object}  shared.Response
+// @Router       /users/import/{id}/report [get]
+// @Security ApiKeyAuth
+func (c *UserImportHandler) Report(ctx echo.Context) error {
+	format := exportFormat(ctx.QueryParam("format"))
+
+	// Check the job exists before the download headers are sent
+	if _, err := c.importService.FindJob(ctx, ctx.Param("id")); err != nil {
+		return err
+	}
+
+	setDownloadHeaders(ctx, "user-import-"+ctx.Param("id"), format)
+	return c.importService.WriteReport(ctx, ctx.Param("id"), format, ctx.Response())
+}
+
+// ExportUsers godoc
+// @Summary      Export users
+// @Description  Stream the users matching the listing filters with their role names
+// @Tags         users
+// @Produce      text/csv,application/x-ndjson
+// @Param format query string false "csv or ndjson" default(csv)
+// @Param search query string false "keyword matched against email and name"
+// @Param sort query string false "comma separated fields, prefix with - for descending" example(-created_at,name)
+// @Param filter[field][operator] query string false "filter on email, name, created_at, updated_at or role_id, e.g. filter[email][contains]=x"
+// @Param created_after query string false "created at or after (RFC 3339 or YYYY-MM-DD)"
+// @Param created_before query string false "created before (RFC 3339 or YYYY-MM-DD)"
+// @Param role_id query string false "role id"
+// @Success      200  {file}  file
+// @Failure      400  {object}  shared.Response
+// @Failure      500  {object}  shared.Response
+// @Router       /users/export [get]
+// @Security ApiKeyAuth
+func (c *UserImportHandler) Export(ctx echo.Context) error {
+	q, err := query.Parse(ctx.QueryParams(), UserQuerySchema)
+	if err != nil {
+		return err
+	}
+
+	format := exportFormat(ctx.QueryParam("format"))
+	setDownloadHeaders(ctx, "users", format)
+	return c.importService.Export(ctx, q, format, ctx.Response())
+}
+
+func formatFromName(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv":
+		return FormatCSV
+	case ".ndjson", ".jsonl":
+		return FormatNDJSON
+	}
+	return ""
+}
+
+func formatFromContentType(contentType string) string {
+	switch {
+	case strings.HasPrefix(contentType, "text/csv"):
+		return FormatCSV
+	case strings.HasPrefix(contentType, "application/x-ndjson"), strings.HasPrefix(contentType, "application/jsonl"):
+		return FormatNDJSON
+	}
+	return ""
+}
+
+func exportFormat(format string) string {
+	if strings.ToLower(format) == FormatNDJSON {
+		return FormatNDJSON
+	}
+	return FormatCSV
+}
+
+func setDownloadHeaders(ctx echo.Context, name string, format string) {
+	contentType := "text/csv; charset=utf-8"
+	if format == FormatNDJSON {
+		contentType = "application/x-ndjson"
+	}
+
+	header := ctx.Response().Header()
+	header.Set(echo.HeaderContentType, contentType)
+	header.Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s.%s"`, name, format))
+}
@@ -0,0 +1,56 @@
+package users
+
+import (
+	"time"
+
+	"github.com/HasanNugroho/starter-golang/internal/core/entities"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+)
+
+const (
+	ImportPending   = "pending"
+	ImportRunning   = "running"
+	ImportCompleted = "completed"
+	ImportFailed    = "failed"
+)
+
+const (
+	RowCreated   = "created"
+	RowDuplicate = "duplicate"
+	RowInvalid   = "invalid"
+	RowFailed    = "failed"
+)
+
+// ImportRow is a user read from an import file, roles are referenced by name.
+// CSV files hold the attributes as text in attributes.<key> columns.
+type ImportRow struct {
+	Email      string                 `json:"email"`
+	Name       string                 `json:"name"`
+	Password   string                 `json:"password"`
+	Roles      []string               `json:"roles"`
+	Attributes map[string]interface{} `json:"attributes"`
+
+	attributeText map[string]string
+}
+
+type UserExportModel struct {
+	ID        bson.ObjectID `bson:"_id" json:"id"`
+	Email     string        `bson:"email" json:"email"`
+	Name      string        `bson:"name" json:"name"`
+	Roles     []string      `bson:"role_names" json:"roles"`
+	CreatedAt time.Time     `bson:"created_at" json:"created_at"`
+}
+
+// UserImportPersonalDataModel is an import job in a personal data archive,
+// with only the rows about the user
+type UserImportPersonalDataModel struct {
+	JobID         bson.ObjectID              `json:"job_id"`
+	CreatedAt     time.Time                  `json:"created_at"`
+	CreatedByUser bool                       `json:"created_by_user"`
+	Rows          []entities.ImportRowResult `json:"rows"`
+}
@@ -0,0 +1,236 @@
+package users
+
+import (
+	"context"
+	"time"
+
+	"github.com/HasanNugroho/starter-golang/internal/app"
+	"github.com/HasanNugroho/starter-golang/internal/core/entities"
+	"github.com/HasanNugroho/starter-golang/internal/shared/query"
+	"github.com/HasanNugroho/starter-golang/internal/shared/tenant"
+	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+type UserImportRepository struct {
+	app     *app.Apps
+	jobs    *mongo.Collection
+	results *mongo.Collection
+	users   *mongo.Collection
+	members query.Collection
+	roles   *mongo.Collection
+}
+
+func NewUserImportRepository(app *app.Apps) *UserImportRepository {
+	return &UserImportRepository{
+		app:     app,
+		jobs:    app.DB.Collection("user_import_jobs"),
+		results: app.DB.Collection("user_import_results"),
+		users:   app.DB.Collection("users"),
+		members: query.Members(app.DB.Collection("users")),
+		roles:   app.DB.Collection("roles"),
+	}
+}
+
+func (r *UserImportRepository) CreateJob(ctx echo.Context, job *entities.ImportJob) error {
+	c := ctx.Request().Context()
+
+	result, err := r.jobs.InsertOne(c, job)
+	if err != nil {
+		return utils.NewInternal("failed to create import job")
+	}
+
+	job.ID = result.InsertedID.(bson.ObjectID)
+	return nil
+}
+
+func (r *UserImportRepository) FindJob(ctx echo.Context, id string) (entities.ImportJob, error) {
+	c := ctx.Request().Context()
+
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return entities.ImportJob{}, utils.NewBadRequest("invalid id format")
+	}
+
+	var job entities.ImportJob
+	if err := r.jobs.FindOne(c, bson.M{"_id": objectID}).Decode(&job); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return entities.ImportJob{}, utils.NewNotFound("data not found")
+		}
+		return entities.ImportJob{}, utils.NewInternal("failed to find data")
+	}
+
+	return job, nil
+}
+
+// SaveJob stores the status and counters of the job, the row results are
+// added with InsertResults
+func (r *UserImportRepository) SaveJob(ctx context.Context, job *entities.ImportJob) error {
+	update := bson.M{"$set": bson.M{
+		"status":      job.Status,
+		"total":       job.Total,
+		"created":     job.Created,
+		"duplicates":  job.Duplicates,
+		"invalid":     job.Invalid,
+		"failed":      job.Failed,
+		"error":       job.Error,
+		"finished_at": job.FinishedAt,
+		"lease_until": job.LeaseUntil,
+	}}
+	if _, err := r.jobs.UpdateOne(ctx, bson.M{"_id": job.ID}, update); err != nil {
+		return utils.NewInternal("failed to update import job")
+	}
+	return nil
+}
+
+// FailAbandoned fails the unfinished jobs whose lease ended before now
+func (r *UserImportRepository) FailAbandoned(ctx context.Context, now time.Time) (int64, error) {
+	filter := bson.M{
+		"status": bson.M{"$in": bson.A{ImportPending, ImportRunning}},
+		"$or": bson.A{
+			bson.M{"lease_until": bson.M{"$lt": now}},
+			// Started before jobs had leases
+			bson.M{"lease_until": nil},
+		},
+	}
+	update := bson.M{
+		"$set":   bson.M{"status": ImportFailed, "error": errImportAbandoned, "finished_at": now},
+		"$unset": bson.M{"lease_until": ""},
+	}
+
+	result, err := r.jobs.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return 0, utils.NewInternal("failed to fail abandoned import jobs")
+	}
+	return result.ModifiedCount, nil
+}
+
+func (r *UserImportRepository) InsertResults(ctx context.Context, results []entities.ImportRowResult) error {
+	if len(results) == 0 {
+		return nil
+	}
+
+	if _, err := r.results.InsertMany(ctx, results); err != nil {
+		return utils.NewInternal("failed to store import results")
+	}
+	return nil
+}
+
+// Results streams the row results of the job in row order
+func (r *UserImportRepository) Results(ctx echo.Context, jobID bson.ObjectID, fn func(entities.ImportRowResult) error) error {
+	c := ctx.Request().Context()
+
+	opts := options.Find().SetSort(bson.D{{Key: "row", Value: 1}})
+	cursor, err := r.results.Find(c, bson.M{"job": jobID}, opts)
+	if err != nil {
+		return utils.NewInternal("failed to query data")
+	}
+	defer cursor.Close(c)
+
+	for cursor.Next(c) {
+		var result entities.ImportRowResult
+		if err := cursor.Decode(&result); err != nil {
+			return utils.NewInternal("failed to decode data")
+		}
+		if err := fn(result); err != nil {
+			return err
+		}
+	}
+
+	if err := cursor.Err(); err != nil {
+		return utils.NewInternal("failed to query data")
+	}
+	return nil
+}
+
+// RoleIDs maps the names of active roles to their IDs
+func (r *UserImportRepository) RoleIDs(ctx context.Context) (map[string]bson.ObjectID, error) {
+	// Imported users get global roles, organizations grant theirs through memberships
+	filter := query.NotDeleted()
+	filter[tenant.Field] = nil
+	cursor, err := r.roles.Find(ctx, filter)
+	if err != nil {
+		return nil, utils.NewInternal("failed to query data")
+	}
+	defer cursor.Close(ctx)
+
+	var roles []entities.Role
+	if err := cursor.All(ctx, &roles); err != nil {
+		return nil, utils.NewInternal("failed to decode data")
+	}
+
+	ids := make(map[string]bson.ObjectID, len(roles))
+	for _, role := range roles {
+		ids[role.Name] = role.ID
+	}
+	return ids, nil
+}
+
+// EmailExists reports whether any user, deleted or not, already uses the email
+func (r *UserImportRepository) EmailExists(ctx context.Context, email string) (bool, error) {
+	count, err := r.users.CountDocuments(ctx, bson.M{"email": email})
+	if err != nil {
+		return false, utils.NewInternal("failed to query data")
+	}
+	return count > 0, nil
+}
+
+func (r *UserImportRepository) InsertUser(ctx context.Context, user *entities.User) error {
+	if _, err := r.users.InsertOne(ctx, user); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return utils.NewConflict("email already exists")
+		}
+		return utils.NewInternal("failed to create user")
+	}
+	return nil
+}
+
+// Export streams every user matching the listing query with their role names
+func (r *UserImportRepository) Export(ctx echo.Context, q *query.Query, fn func(UserExportModel) error) error {
+	c := ctx.Request().Context()
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: q.WithoutDeleted().Filter}},
+		{{Key: "$sort", Value: q.SortBy}},
+		{{
+			Key: "$lookup",
+			Value: bson.D{
+				{Key: "from", Value: "roles"},
+				{Key: "localField", Value: "roles"},
+				{Key: "foreignField", Value: "_id"},
+				{Key: "pipeline", Value: mongo.Pipeline{{{Key: "$match", Value: query.NotDeleted()}}}},
+				{Key: "as", Value: "roles_data"},
+			},
+		}},
+		{{Key: "$project", Value: bson.M{
+			"email":      1,
+			"name":       1,
+			"created_at": 1,
+			"role_names": "$roles_data.name",
+		}}},
+	}
+
+	cursor, err := r.members.For(ctx).Aggregate(c, pipeline)
+	if err != nil {
+		return utils.NewInternal("failed to query data")
+	}
+	defer cursor.Close(c)
+
+	for cursor.Next(c) {
+		var user UserExportModel
+		if err := cursor.Decode(&user); err != nil {
+			return utils.NewInternal("failed to decode data")
+		}
+		if err := fn(user); err != nil {
+			return err
+		}
+	}
+
+	if err := cursor.Err(); err != nil {
+		return utils.NewInternal("failed to query data")
+	}
+	return nil
+}
@@ -0,0 +1,494 @@
+package users
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/HasanNugroho/starter-golang/internal/app"
+	"github.com/HasanNugroho/starter-golang/internal/core/attributes"
+	"github.com/HasanNugroho/starter-golang/internal/core/entities"
+	"github.com/HasanNugroho/starter-golang/internal/shared/query"
+	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// importProgressEvery is how many rows are processed between progress saves
+const importProgressEvery = 100
+
+// attributeColumn prefixes the CSV columns holding attribute values, the key follows
+const attributeColumn = "attributes."
+
+// importLease is how long a job is owned by the instance running it without
+// progress, an unfinished job past it is considered abandoned, e.g. by a restart
+const importLease = 10 * time.Minute
+
+// errImportAbandoned fails the jobs whose instance stopped before finishing them
+const errImportAbandoned = "the import stopped before finishing, upload the file again"
+
+// errImportRoles refuses imports assigning roles without roles:assign
+const errImportRoles = "importing users with roles requires roles:assign"
+
+type UserImportService struct {
+	repo       IUserImportRepository
+	attributes attributes.IAttributeService
+	app        *app.Apps
+	validate   *validator.Validate
+}
+
+func NewUserImportService(app *app.Apps, repo IUserImportRepository, attributes attributes.IAttributeService) *UserImportService {
+	return &UserImportService{
+		repo:       repo,
+		attributes: attributes,
+		app:        app,
+		validate:   validator.New(),
+	}
+}
+
+// StartImport stores the upload and processes it in the background.
+// The roles are assigned to every imported user on top of the roles of the row,
+// both require roles:assign.
+func (s *UserImportService) StartImport(ctx echo.Context, format string, upload io.Reader, roles []string) (entities.ImportJob, error) {
+	if err := outsideTenant(ctx); err != nil {
+		return entities.ImportJob{}, err
+	}
+
+	if format != FormatCSV && format != FormatNDJSON {
+		return entities.ImportJob{}, utils.NewBadRequest("unsupported import format, use csv or ndjson")
+	}
+
+	// users:import alone must not hand out roles, super-admin included
+	assignRoles := s.app.Authz.Holds(ctx, "roles:assign")
+	if len(roles) > 0 && !assignRoles {
+		return entities.ImportJob{}, utils.NewForbidden(errImportRoles)
+	}
+
+	activeRoles, err := s.repo.RoleIDs(ctx.Request().Context())
+	if err != nil {
+		return entities.ImportJob{}, err
+	}
+
+	active := make(map[bson.ObjectID]bool, len(activeRoles))
+	for _, id := range activeRoles {
+		active[id] = true
+	}
+
+	// Rows are checked against the attributes defined when the import starts
+	definitions, err := s.attributes.FindAll(ctx)
+	if err != nil {
+		return entities.ImportJob{}, err
+	}
+
+	roleIDs := []bson.ObjectID{}
+	for _, role := range roles {
+		id, err := bson.ObjectIDFromHex(role)
+		if err != nil || !active[id] {
+			return entities.ImportJob{}, utils.NewBadRequest("role not found " + role)
+		}
+		roleIDs = append(roleIDs, id)
+	}
+
+	file, err := os.CreateTemp("", "user-import-*."+format)
+	if err != nil {
+		return entities.ImportJob{}, utils.NewInternal("failed to store upload")
+	}
+	if _, err := io.Copy(file, upload); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return entities.ImportJob{}, utils.NewBadRequest("failed to read upload")
+	}
+	file.Close()
+
+	if !assignRoles {
+		withRoles, err := rowsWithRoles(format, file.Name())
+		if err != nil || withRoles {
+			os.Remove(file.Name())
+		}
+		if err != nil {
+			return entities.ImportJob{}, utils.NewInternal("failed to read upload")
+		}
+		if withRoles {
+			return entities.ImportJob{}, utils.NewForbidden(errImportRoles)
+		}
+	}
+
+	leaseUntil := time.Now().Add(importLease)
+	job := entities.ImportJob{
+		Format:     format,
+		Status:     ImportPending,
+		Roles:      roleIDs,
+		CreatedAt:  time.Now(),
+		LeaseUntil: &leaseUntil,
+	}
+	if createdBy, err := bson.ObjectIDFromHex(utils.CurrentUserID(ctx)); err == nil {
+		job.CreatedBy = &createdBy
+	}
+
+	if err := s.repo.CreateJob(ctx, &job); err != nil {
+		os.Remove(file.Name())
+		return entities.ImportJob{}, err
+	}
+
+	go s.run(job, file.Name(), definitions)
+
+	return job, nil
+}
+
+func (s *UserImportService) FindJob(ctx echo.Context, id string) (entities.ImportJob, error) {
+	return s.repo.FindJob(ctx, id)
+}
+
+// FailAbandoned fails the jobs left unfinished by a stopped instance. The upload
+// is only stored on the disk of that instance, so the job can not be resumed.
+func (s *UserImportService) FailAbandoned(ctx context.Context) (int64, error) {
+	return s.repo.FailAbandoned(ctx, time.Now())
+}
+
+// WriteReport writes the per-row results of an import job in the given format
+func (s *UserImportService) WriteReport(ctx echo.Context, id string, format string, w io.Writer) error {
+	job, err := s.repo.FindJob(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if format == FormatNDJSON {
+		encoder := json.NewEncoder(w)
+		return s.repo.Results(ctx, job.ID, func(result entities.ImportRowResult) error {
+			return encoder.Encode(result)
+		})
+	}
+
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"row", "email", "status", "message"})
+	err = s.repo.Results(ctx, job.ID, func(result entities.ImportRowResult) error {
+		return writer.Write([]string{strconv.Itoa(result.Row), result.Email, result.Status, result.Message})
+	})
+	if err != nil {
+		return err
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// Export streams the users matching the listing query in the given format
+func (s *UserImportService) Export(ctx echo.Context, q *query.Query, format string, w io.Writer) error {
+	if format == FormatNDJSON {
+		encoder := json.NewEncoder(w)
+		return s.repo.Export(ctx, q, func(user UserExportModel) error {
+			if user.Roles == nil {
+				user.Roles = []string{}
+			}
+			return encoder.Encode(user)
+		})
+	}
+
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"id", "email", "name", "roles", "created_at"})
+	err := s.repo.Export(ctx, q, func(user UserExportModel) error {
+		writer.Write([]string{user.ID.Hex(), user.Email, user.Name, strings.Join(user.Roles, "|"), user.CreatedAt.Format(time.RFC3339)})
+		writer.Flush()
+		return writer.Error()
+	})
+	if err != nil {
+		return err
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func (s *UserImportService) run(job entities.ImportJob, path string, definitions []entities.AttributeDefinition) {
+	defer os.Remove(path)
+
+	ctx := context.Background()
+	job.Status = ImportRunning
+	s.save(ctx, &job)
+
+	if err := s.process(ctx, &job, path, definitions); err != nil {
+		s.app.Log.Error().Err(err).Msgf("User import %s failed", job.ID.Hex())
+		job.Status = ImportFailed
+		job.Error = err.Error()
+	} else {
+		job.Status = ImportCompleted
+	}
+
+	finishedAt := time.Now()
+	job.FinishedAt = &finishedAt
+	s.save(ctx, &job)
+}
+
+func (s *UserImportService) process(ctx context.Context, job *entities.ImportJob, path string, definitions []entities.AttributeDefinition) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	roleIDs, err := s.repo.RoleIDs(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Results are stored per batch together with the progress
+	batch := make([]entities.ImportRowResult, 0, importProgressEvery)
+	flush := func() {
+		if err := s.repo.InsertResults(ctx, batch); err != nil {
+			s.app.Log.Error().Err(err).Msgf("Failed to store results of user import %s", job.ID.Hex())
+		}
+		batch = batch[:0]
+		s.save(ctx, job)
+	}
+	defer flush()
+
+	seen := make(map[string]bool)
+	return readRows(job.Format, file, func(number int, row ImportRow, parseErr error) {
+		result := s.importRow(ctx, job, row, parseErr, roleIDs, definitions, seen)
+		result.Job = job.ID
+		result.Row = number
+		result.CreatedAt = time.Now()
+
+		job.Total++
+		batch = append(batch, result)
+		switch result.Status {
+		case RowCreated:
+			job.Created++
+		case RowDuplicate:
+			job.Duplicates++
+		case RowInvalid:
+			job.Invalid++
+		default:
+			job.Failed++
+		}
+
+		if len(batch) == importProgressEvery {
+			flush()
+		}
+	})
+}
+
+func (s *UserImportService) importRow(ctx context.Context, job *entities.ImportJob, row ImportRow, parseErr error, roleIDs map[string]bson.ObjectID, definitions []entities.AttributeDefinition, seen map[string]bool) entities.ImportRowResult {
+	row.Email = strings.TrimSpace(row.Email)
+	result := entities.ImportRowResult{Email: row.Email}
+
+	if parseErr != nil {
+		result.Status, result.Message = RowInvalid, parseErr.Error()
+		return result
+	}
+
+	user := UserCreateModel{Email: row.Email, Name: strings.TrimSpace(row.Name), Password: row.Password}
+	if err := s.validate.Struct(user); err != nil {
+		result.Status, result.Message = RowInvalid, err.Error()
+		return result
+	}
+
+	// The same checks as UserService.Create, required attributes included
+	values := row.Attributes
+	if row.attributeText != nil {
+		values = attributes.FromText(definitions, row.attributeText)
+	}
+	values, err := attributes.Validate(definitions, nil, values, false)
+	if err != nil {
+		result.Status, result.Message = RowInvalid, err.Error()
+		var invalid *utils.BadRequestError
+		if errors.As(err, &invalid) {
+			result.Message = invalid.Message
+		}
+		return result
+	}
+
+	key := strings.ToLower(user.Email)
+	if seen[key] {
+		result.Status, result.Message = RowDuplicate, "email appears earlier in the file"
+		return result
+	}
+	seen[key] = true
+
+	roles := append([]bson.ObjectID{}, job.Roles...)
+	for _, name := range row.Roles {
+		id, ok := roleIDs[strings.TrimSpace(name)]
+		if !ok {
+			result.Status, result.Message = RowInvalid, "unknown role "+name
+			return result
+		}
+		roles = append(roles, id)
+	}
+
+	exists, err := s.repo.EmailExists(ctx, user.Email)
+	if err != nil {
+		result.Status, result.Message = RowFailed, err.Error()
+		return result
+	}
+	if exists {
+		result.Status, result.Message = RowDuplicate, "email already exists"
+		return result
+	}
+
+	password, err := utils.HashPassword([]byte(user.Password))
+	if err != nil {
+		result.Status, result.Message = RowFailed, err.Error()
+		return result
+	}
+
+	now := time.Now()
+	err = s.repo.InsertUser(ctx, &entities.User{
+		Email:      user.Email,
+		Name:       user.Name,
+		Password:   password,
+		Roles:      roles,
+		Status:     utils.StatusActive,
+		Attributes: values,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	})
+	if err != nil {
+		var conflict *utils.ConflictError
+		if errors.As(err, &conflict) {
+			result.Status, result.Message = RowDuplicate, "email already exists"
+			return result
+		}
+		result.Status, result.Message = RowFailed, err.Error()
+		return result
+	}
+
+	result.Status = RowCreated
+	return result
+}
+
+// importMaxSize returns the largest accepted import upload in bytes
+func importMaxSize(app *app.Apps) int64 {
+	if app.Config.DB.ImportMaxSize <= 0 {
+		return 10 * 1024 * 1024
+	}
+	return int64(app.Config.DB.ImportMaxSize) * 1024
+}
+
+// save stores the progress of the job, renewing its lease until it finishes
+func (s *UserImportService) save(ctx context.Context, job *entities.ImportJob) {
+	job.LeaseUntil = nil
+	if job.FinishedAt == nil {
+		leaseUntil := time.Now().Add(importLease)
+		job.LeaseUntil = &leaseUntil
+	}
+
+	if err := s.repo.SaveJob(ctx, job); err != nil {
+		s.app.Log.Error().Err(err).Msgf("Failed to save user import %s", job.ID.Hex())
+	}
+}
+
+// rowsWithRoles reports whether a row of the stored upload names roles. Rows
+// that can not be parsed are left to the import to report.
+func rowsWithRoles(format string, path string) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	found := false
+	readRows(format, file, func(number int, row ImportRow, err error) {
+		if len(row.Roles) > 0 {
+			found = true
+		}
+	})
+	return found, nil
+}
+
+// readRows streams the rows of a CSV or NDJSON file. Rows are numbered from 1,
+// not counting the CSV header, and rows that can not be parsed are reported with an error.
+func readRows(format string, r io.Reader, fn func(number int, row ImportRow, err error)) error {
+	if format == FormatNDJSON {
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+		number := 0
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" {
+				continue
+			}
+			number++
+
+			var row ImportRow
+			if err := json.Unmarshal([]byte(line), &row); err != nil {
+				fn(number, row, errors.New("invalid JSON"))
+				continue
+			}
+			fn(number, row, nil)
+		}
+		return scanner.Err()
+	}
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return errors.New("csv header is missing")
+	}
+
+	// Attribute keys keep their case, the other columns do not
+	columns := make(map[string]int)
+	attributeColumns := make(map[string]int)
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		if len(name) > len(attributeColumn) && strings.EqualFold(name[:len(attributeColumn)], attributeColumn) {
+			attributeColumns[name[len(attributeColumn):]] = i
+			continue
+		}
+		columns[strings.ToLower(name)] = i
+	}
+	if _, ok := columns["email"]; !ok {
+		return errors.New("csv header has no email column")
+	}
+
+	value := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return record[i]
+		}
+		return ""
+	}
+
+	for number := 1; ; number++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				fn(number, ImportRow{}, errors.New("malformed csv row"))
+				continue
+			}
+			return err
+		}
+
+		row := ImportRow{
+			Email:    value(record, "email"),
+			Name:     value(record, "name"),
+			Password: value(record, "password"),
+		}
+		if roles := value(record, "roles"); roles != "" {
+			row.Roles = strings.Split(roles, "|")
+		}
+		if len(attributeColumns) > 0 {
+			row.attributeText = make(map[string]string, len(attributeColumns))
+			for key, i := range attributeColumns {
+				if i < len(record) {
+					row.attributeText[key] = record[i]
+				}
+			}
+		}
+		fn(number, row, nil)
+	}
+}
@@ -0,0 +1,171 @@
+package users
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/HasanNugroho/starter-golang/internal/app"
+	"github.com/HasanNugroho/starter-golang/internal/core/attributes"
+	"github.com/HasanNugroho/starter-golang/internal/core/entities"
+	"github.com/HasanNugroho/starter-golang/internal/shared/authz"
+	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type readResult struct {
+	number int
+	row    ImportRow
+	err    error
+}
+
+func collectRows(t *testing.T, format string, input string) []readResult {
+	var results []readResult
+	err := readRows(format, strings.NewReader(input), func(number int, row ImportRow, err error) {
+		results = append(results, readResult{number, row, err})
+	})
+	assert.NoError(t, err)
+	return results
+}
+
+func TestReadRows_CSV(t *testing.T) {
+	results := collectRows(t, FormatCSV, "Name,Email,Password,Roles\nJohn,john@example.com,secret1,admin|editor\nJane,jane@example.com,secret2,\n")
+
+	assert.Len(t, results, 2)
+	assert.Equal(t, 1, results[0].number)
+	assert.Equal(t, ImportRow{Email: "john@example.com", Name: "John", Password: "secret1", Roles: []string{"admin", "editor"}}, results[0].row)
+	assert.Nil(t, results[1].row.Roles)
+}
+
+func TestReadRows_CSVWithoutEmailColumn(t *testing.T) {
+	err := readRows(FormatCSV, strings.NewReader("name\nJohn\n"), func(int, ImportRow, error) {})
+
+	assert.Error(t, err)
+}
+
+func TestReadRows_NDJSONReportsInvalidLines(t *testing.T) {
+	results := collectRows(t, FormatNDJSON, "{\"email\":\"john@example.com\",\"roles\":[\"admin\"]}\n\nnot json\n")
+
+	assert.Len(t, results, 2)
+	assert.Equal(t, []string{"admin"}, results[0].row.Roles)
+	assert.NoError(t, results[0].err)
+	assert.Equal(t, 2, results[1].number)
+	assert.Error(t, results[1].err)
+}
+
+type stubImportRepository struct {
+	IUserImportRepository
+	batches  []int
+	saves    int
+	inserted []*entities.User
+	results  []entities.ImportRowResult
+	leases   []*time.Time
+}
+
+func (s *stubImportRepository) EmailExists(ctx context.Context, email string) (bool, error) {
+	return false, nil
+}
+
+func (s *stubImportRepository) InsertUser(ctx context.Context, user *entities.User) error {
+	s.inserted = append(s.inserted, user)
+	return nil
+}
+
+func (s *stubImportRepository) RoleIDs(ctx context.Context) (map[string]bson.ObjectID, error) {
+	return map[string]bson.ObjectID{}, nil
+}
+
+func (s *stubImportRepository) InsertResults(ctx context.Context, results []entities.ImportRowResult) error {
+	if len(results) > 0 {
+		s.batches = append(s.batches, len(results))
+		s.results = append(s.results, results...)
+	}
+	return nil
+}
+
+func (s *stubImportRepository) SaveJob(ctx context.Context, job *entities.ImportJob) error {
+	s.saves++
+	s.leases = append(s.leases, job.LeaseUntil)
+	return nil
+}
+
+func TestUserImportService_StoresResultsInBatches(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.ndjson")
+	require.NoError(t, os.WriteFile(path, []byte(strings.Repeat("not json\n", 250)), 0o600))
+
+	repo := &stubImportRepository{}
+	service := NewUserImportService(&app.Apps{}, repo, nil)
+	job := entities.ImportJob{ID: bson.NewObjectID(), Format: FormatNDJSON}
+
+	require.NoError(t, service.process(context.Background(), &job, path, nil))
+	assert.Equal(t, []int{100, 100, 50}, repo.batches)
+	assert.Equal(t, 3, repo.saves)
+	assert.Equal(t, 250, job.Invalid)
+}
+
+func TestUserImportService_RenewsLease(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.ndjson")
+	require.NoError(t, os.WriteFile(path, []byte(strings.Repeat("not json\n", 150)), 0o600))
+
+	repo := &stubImportRepository{}
+	service := NewUserImportService(&app.Apps{}, repo, nil)
+	job := entities.ImportJob{ID: bson.NewObjectID(), Format: FormatNDJSON, Status: ImportPending}
+
+	service.run(job, path, nil)
+
+	// Every save while running renews the lease, the finished job gives it up
+	require.Len(t, repo.leases, 4)
+	for _, lease := range repo.leases[:3] {
+		require.NotNil(t, lease)
+		assert.WithinDuration(t, time.Now().Add(importLease), *lease, time.Minute)
+	}
+	assert.Nil(t, repo.leases[3])
+}
+
+type stubAttributeService struct {
+	attributes.IAttributeService
+}
+
+func (s stubAttributeService) FindAll(ctx echo.Context) ([]entities.AttributeDefinition, error) {
+	return nil, nil
+}
+
+func TestUserImportService_RolesRequireAssign(t *testing.T) {
+	service := NewUserImportService(&app.Apps{Authz: authz.NewEngine()}, &stubImportRepository{}, stubAttributeService{})
+	ctx := echo.New().NewContext(httptest.NewRequest("POST", "/", nil), httptest.NewRecorder())
+	ctx.Set("granted_permissions", []string{"users:import"})
+
+	// Roles for the whole job
+	_, err := service.StartImport(ctx, FormatCSV, strings.NewReader("email\njohn@example.com\n"), []string{bson.NewObjectID().Hex()})
+	assert.Equal(t, utils.NewForbidden(errImportRoles), err)
+
+	// Roles of a single row
+	_, err = service.StartImport(ctx, FormatCSV, strings.NewReader("email,roles\njohn@example.com,\njane@example.com,super-admin\n"), nil)
+	assert.Equal(t, utils.NewForbidden(errImportRoles), err)
+}
+
+func TestUserImportService_ValidatesAttributes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.csv")
+	input := "email,name,password,Attributes.costCenter\njohn@example.com,John,secret12,42\njane@example.com,Jane,secret12,\n"
+	require.NoError(t, os.WriteFile(path, []byte(input), 0o600))
+
+	repo := &stubImportRepository{}
+	service := NewUserImportService(&app.Apps{}, repo, nil)
+	job := entities.ImportJob{ID: bson.NewObjectID(), Format: FormatCSV}
+	definitions := []entities.AttributeDefinition{{Key: "costCenter", Type: "number", Required: true}}
+
+	require.NoError(t, service.process(context.Background(), &job, path, definitions))
+	require.Len(t, repo.inserted, 1)
+	assert.Equal(t, map[string]interface{}{"costCenter": float64(42)}, repo.inserted[0].Attributes)
+
+	// A row without a required attribute is not imported
+	assert.Equal(t, RowInvalid, repo.results[1].Status)
+	assert.Equal(t, "attribute costCenter is required", repo.results[1].Message)
+}
@@ -1,24 +1,68 @@
 package users
 
 import (
+	"context"
+	"io"
+	"time"
+
 	"github.com/HasanNugroho/starter-golang/internal/core/entities"
 	shared "github.com/HasanNugroho/starter-golang/internal/shared/model"
+	"github.com/HasanNugroho/starter-golang/internal/shared/patch"
+	"github.com/HasanNugroho/starter-golang/internal/shared/query"
 	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/v2/bson"
 )
 
 type IUserRepository interface {
 	Create(ctx echo.Context, user *entities.User) error
 	FindByEmail(ctx echo.Context, email string) (UserModel, error)
 	FindById(ctx echo.Context, id string) (UserModel, error)
-	FindAll(ctx echo.Context, filter *shared.PaginationFilter) ([]UserModelResponse, int, error)
+	FindMember(ctx echo.Context, id string) (UserModel, error)
+	FindByEmailChangeKey(ctx echo.Context, key string) (UserModel, error)
+	FindAll(ctx echo.Context, q *query.Query) (query.Page[UserModelResponse], error)
 	Update(ctx echo.Context, id string, user *entities.User) error
-	Delete(ctx echo.Context, id string) error
+	Patch(ctx echo.Context, id string, changes patch.Changes) error
+	Delete(ctx echo.Context, id string, deletedBy string) (string, error)
+	FindDeleted(ctx echo.Context, q *query.Query) (query.Page[UserTrashModel], error)
+	FindDeletedById(ctx echo.Context, id string) (UserModel, error)
+	Restore(ctx echo.Context, id string) (string, error)
+	Purge(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+// IPermissionSource loads users with their effective roles for the PermissionCache
+type IPermissionSource interface {
+	FindEffective(ctx context.Context, id string) (UserModel, error)
 }
 
 type IUserService interface {
 	Create(ctx echo.Context, user *UserCreateModel) error
 	FindById(ctx echo.Context, id string) (UserModel, error)
-	FindAll(ctx echo.Context, filter *shared.PaginationFilter) (shared.DataWithPagination, error)
+	FindAll(ctx echo.Context, q *query.Query) (shared.DataWithPagination, error)
+	QuerySchema(ctx echo.Context) (query.Schema, error)
 	Update(ctx echo.Context, id string, user *UserUpdateModel) error
+	Patch(ctx echo.Context, id string, contentType string, body []byte) error
+	ChangeStatus(ctx echo.Context, id string, payload *UserStatusModel) error
 	Delete(ctx echo.Context, id string) error
+	FindDeleted(ctx echo.Context, q *query.Query) (shared.DataWithPagination, error)
+	Restore(ctx echo.Context, id string) error
+}
+
+type IUserImportRepository interface {
+	CreateJob(ctx echo.Context, job *entities.ImportJob) error
+	FindJob(ctx echo.Context, id string) (entities.ImportJob, error)
+	SaveJob(ctx context.Context, job *entities.ImportJob) error
+	FailAbandoned(ctx context.Context, now time.Time) (int64, error)
+	InsertResults(ctx context.Context, results []entities.ImportRowResult) error
+	Results(ctx echo.Context, jobID bson.ObjectID, fn func(entities.ImportRowResult) error) error
+	RoleIDs(ctx context.Context) (map[string]bson.ObjectID, error)
+	EmailExists(ctx context.Context, email string) (bool, error)
+	InsertUser(ctx context.Context, user *entities.User) error
+	Export(ctx echo.Context, q *query.Query, fn func(UserExportModel) error) error
+}
+
+type IUserImportService interface {
+	StartImport(ctx echo.Context, format string, upload io.Reader, roles []string) (entities.ImportJob, error)
+	FindJob(ctx echo.Context, id string) (entities.ImportJob, error)
+	WriteReport(ctx echo.Context, id string, format string, w io.Writer) error
+	Export(ctx echo.Context, q *query.Query, format string, w io.Writer) error
 }
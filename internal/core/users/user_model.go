@@ -1,37 +1,179 @@
 package users
 
 import (
+	"slices"
 	"time"
 
+	"github.com/HasanNugroho/starter-golang/internal/core/entities"
 	"github.com/HasanNugroho/starter-golang/internal/core/roles"
+	"github.com/HasanNugroho/starter-golang/internal/shared/query"
 	"go.mongodb.org/mongo-driver/v2/bson"
 )
 
 type UserModel struct {
-	ID        bson.ObjectID     `json:"id" bson:"_id"`
-	Email     string            `json:"email"`
-	Name      string            `json:"name"`
-	Password  string            `json:"password,omitempty"`
-	RolesData []roles.RoleModel `json:"roles_data" bson:"roles_data"`
-	CreatedAt time.Time         `json:"created_at"`
-	UpdatedAt time.Time         `json:"updated_at"`
+	ID              bson.ObjectID             `json:"id" bson:"_id"`
+	Email           string                    `json:"email"`
+	Name            string                    `json:"name"`
+	Password        string                    `json:"password,omitempty"`
+	RolesData       []roles.RoleModel         `json:"roles_data" bson:"roles_data"`
+	RoleAssignments []entities.RoleAssignment `json:"role_assignments,omitempty" bson:"role_assignments,omitempty"`
+	Memberships     []entities.Membership     `json:"memberships,omitempty" bson:"memberships,omitempty"`
+	Status          string                    `json:"status" bson:"status"`
+	StatusReason    string                    `json:"status_reason,omitempty" bson:"status_reason,omitempty"`
+	Preferences     map[string]interface{}    `json:"preferences,omitempty" bson:"preferences,omitempty"`
+	Attributes      map[string]interface{}    `json:"attributes,omitempty" bson:"attributes,omitempty"`
+	PendingEmail    *entities.PendingEmail    `json:"-" bson:"pending_email,omitempty"`
+	Avatar          *entities.Avatar          `json:"-" bson:"avatar,omitempty"`
+	Version         int64                     `json:"version" bson:"version"`
+	CreatedAt       time.Time                 `json:"created_at" bson:"created_at"`
+	UpdatedAt       time.Time                 `json:"updated_at" bson:"updated_at"`
+}
+
+// Permissions returns the distinct permissions of the effective roles, inherited ones included
+func (u UserModel) Permissions() []string {
+	permissions := []string{}
+	for _, role := range u.RolesData {
+		for _, permission := range role.Permissions {
+			if !slices.Contains(permissions, permission) {
+				permissions = append(permissions, permission)
+			}
+		}
+	}
+	return permissions
+}
+
+// GrantsExpireAt returns when the first open time-bound role assignment of the
+// user ends, nil when none does. Access tokens must not outlive it.
+func (u UserModel) GrantsExpireAt() *time.Time {
+	now := time.Now()
+	var earliest *time.Time
+	for _, assignment := range u.RoleAssignments {
+		if assignment.ExpiresAt == nil || !assignment.ExpiresAt.After(now) {
+			continue
+		}
+		if assignment.StartsAt != nil && assignment.StartsAt.After(now) {
+			continue
+		}
+		if earliest == nil || assignment.ExpiresAt.Before(*earliest) {
+			earliest = assignment.ExpiresAt
+		}
+	}
+	return earliest
+}
+
+// TokenPayload is the data about the user the issued tokens carry, attributes
+// are the values flagged for the token
+func (u UserModel) TokenPayload(attributes map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"id":         u.ID.Hex(),
+		"email":      u.Email,
+		"name":       u.Name,
+		"created_at": u.CreatedAt,
+		"permission": u.Permissions(),
+		"roles":      u.RolesData,
+		"attributes": attributes,
+	}
 }
 
 type UserCreateModel struct {
-	Email    string `json:"email" validate:"email"`
-	Name     string `json:"name" validate:""`
-	Password string `json:"password" validate:"min=6"`
+	Email      string                 `json:"email" validate:"email"`
+	Name       string                 `json:"name" validate:""`
+	Password   string                 `json:"password" validate:"min=6"`
+	Attributes map[string]interface{} `json:"attributes"`
 }
 
 type UserUpdateModel struct {
-	Email    string `json:"email" validate:"required,email"`
-	Name     string `json:"name" validate:"required"`
-	Password string `json:"password" validate:"required,min=6"`
+	Email      string                 `json:"email" validate:"required,email"`
+	Name       string                 `json:"name" validate:"required"`
+	Password   string                 `json:"password" validate:"required,min=6"`
+	Attributes map[string]interface{} `json:"attributes"`
+}
+
+// UserPatchModel is the patchable document of a user, the password is write only
+type UserPatchModel struct {
+	Email      string                 `json:"email" bson:"email" validate:"required,email"`
+	Name       string                 `json:"name" bson:"name" validate:"required"`
+	Password   string                 `json:"password,omitempty" bson:"-" validate:"omitempty,min=6"`
+	Attributes map[string]interface{} `json:"attributes,omitempty" bson:"attributes,omitempty"`
 }
 
 type UserModelResponse struct {
-	ID        bson.ObjectID `bson:"_id" json:"id"`
-	Email     string        `bson:"email" json:"email"`
-	Name      string        `bson:"name" json:"name"`
-	CreatedAt time.Time     `bson:"created_at" json:"created_at"`
+	ID         bson.ObjectID          `bson:"_id" json:"id"`
+	Email      string                 `bson:"email" json:"email"`
+	Name       string                 `bson:"name" json:"name"`
+	Status     string                 `bson:"status" json:"status"`
+	Attributes map[string]interface{} `bson:"attributes,omitempty" json:"attributes,omitempty"`
+	CreatedAt  time.Time              `bson:"created_at" json:"created_at"`
+}
+
+type UserStatusModel struct {
+	Status string `json:"status" validate:"required,oneof=pending active suspended locked deactivated"`
+	Reason string `json:"reason" validate:"max=500"`
+}
+
+// UserStatusChanged is the payload of utils.UserStatusChangedEvent
+type UserStatusChanged struct {
+	UserID    string    `json:"user_id"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Reason    string    `json:"reason,omitempty"`
+	ChangedBy string    `json:"changed_by,omitempty"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+type UserTrashModel struct {
+	ID        bson.ObjectID  `bson:"_id" json:"id"`
+	Email     string         `bson:"email" json:"email"`
+	Name      string         `bson:"name" json:"name"`
+	CreatedAt time.Time      `bson:"created_at" json:"created_at"`
+	DeletedAt time.Time      `bson:"deleted_at" json:"deleted_at"`
+	DeletedBy *bson.ObjectID `bson:"deleted_by,omitempty" json:"deleted_by,omitempty"`
+}
+
+// UserPersonalDataModel is the profile of a user in a personal data archive
+type UserPersonalDataModel struct {
+	ID           bson.ObjectID          `json:"id"`
+	Email        string                 `json:"email"`
+	Name         string                 `json:"name"`
+	Status       string                 `json:"status"`
+	StatusReason string                 `json:"status_reason,omitempty"`
+	PendingEmail string                 `json:"pending_email,omitempty"`
+	Preferences  map[string]interface{} `json:"preferences,omitempty"`
+	Attributes   map[string]interface{} `json:"attributes,omitempty"`
+	Avatar       *entities.Avatar       `json:"avatar,omitempty"`
+	CreatedAt    time.Time              `json:"created_at"`
+	UpdatedAt    time.Time              `json:"updated_at"`
+	DeletedAt    *time.Time             `json:"deleted_at,omitempty"`
+}
+
+// UserQuerySchema whitelists the fields usable to filter and sort users
+var UserQuerySchema = query.Schema{
+	Fields: map[string]query.Field{
+		"email":      {Type: query.String, Sortable: true},
+		"name":       {Type: query.String, Sortable: true},
+		"created_at": {Type: query.Time, Sortable: true},
+		"updated_at": {Type: query.Time, Sortable: true},
+		"role_id":    {Name: "roles", Type: query.ObjectID},
+		"status":     {Type: query.String, Operators: []query.Operator{query.Eq, query.Ne, query.In, query.Nin, query.Exists}},
+	},
+	Search:      []string{"email", "name"},
+	DefaultSort: "-created_at",
+	Aliases: map[string]query.Alias{
+		"created_after":  {Field: "created_at", Operator: query.Gte},
+		"created_before": {Field: "created_at", Operator: query.Lt},
+		"role_id":        {Field: "role_id", Operator: query.Eq},
+		"status":         {Field: "status", Operator: query.Eq},
+	},
+}
+
+// UserTrashQuerySchema whitelists the fields usable to filter and sort deleted users
+var UserTrashQuerySchema = query.Schema{
+	Fields: map[string]query.Field{
+		"email":      {Type: query.String, Sortable: true},
+		"name":       {Type: query.String, Sortable: true},
+		"deleted_at": {Type: query.Time, Sortable: true},
+		"deleted_by": {Type: query.ObjectID},
+	},
+	Search:      []string{"email", "name"},
+	DefaultSort: "-deleted_at",
 }
@@ -0,0 +1,147 @@
+package users
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/HasanNugroho/starter-golang/internal/app"
+	"github.com/HasanNugroho/starter-golang/internal/shared/permission"
+	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
+	"github.com/redis/go-redis/v9"
+)
+
+// permissionCachePrefix prefixes the Redis keys of the cached permissions, the user id follows
+const permissionCachePrefix = "user_permissions:"
+
+// permissionCacheTTL bounds how long permissions are cached when AUTHZ_CACHE_TTL is not set
+const permissionCacheTTL = 10 * time.Minute
+
+// forgetBatch is how many cached entries are dropped per Redis call
+const forgetBatch = 500
+
+// PermissionCache resolves the effective permissions of users for
+// AUTHZ_MODE=cache. They are read from Redis and loaded from the database on
+// a miss. Entries are dropped on permission.ChangedEvent and expire when a
+// time-bound assignment of the user starts or ends.
+type PermissionCache struct {
+	app    *app.Apps
+	source IPermissionSource
+	ttl    time.Duration
+}
+
+func NewPermissionCache(app *app.Apps, source IPermissionSource) *PermissionCache {
+	ttl := time.Duration(app.Config.Security.AuthzCacheTTL) * time.Minute
+	if ttl <= 0 {
+		ttl = permissionCacheTTL
+	}
+
+	return &PermissionCache{
+		app:    app,
+		source: source,
+		ttl:    ttl,
+	}
+}
+
+// Effective returns the permissions of the user's roles, inherited ones
+// included. A fill is only cached when the grants of the user did not change
+// while it loaded, see utils.StoreGrants.
+func (p *PermissionCache) Effective(ctx context.Context, userID string) (permission.Grants, error) {
+	if !p.app.Config.Redis.Enabled {
+		user, err := p.source.FindEffective(ctx, userID)
+		if err != nil {
+			return permission.Grants{}, err
+		}
+		return permission.Grants{Permissions: user.Permissions()}, nil
+	}
+
+	key := permissionCachePrefix + userID
+	if cached, err := p.app.Redis.Get(ctx, key).Bytes(); err == nil {
+		var grants permission.Grants
+		if err := json.Unmarshal(cached, &grants); err == nil && grants.Generation != "" {
+			return grants, nil
+		}
+	}
+
+	// Read before the database, so a change landing in between is noticed
+	generation, generationErr := utils.GrantsGeneration(ctx, p.app, userID)
+
+	user, err := p.source.FindEffective(ctx, userID)
+	if err != nil {
+		return permission.Grants{}, err
+	}
+
+	grants := permission.Grants{Permissions: user.Permissions()}
+	if generationErr != nil {
+		return grants, nil
+	}
+
+	now := time.Now()
+	ttl := cacheTTL(user, now, p.ttl)
+	fill := permission.Grants{Permissions: grants.Permissions, Generation: generation, Expires: now.Add(ttl)}
+	encoded, err := json.Marshal(fill)
+	if err == nil {
+		err = utils.StoreGrants(ctx, p.app, userID, generation, func(pipe redis.Pipeliner) {
+			pipe.Set(ctx, key, encoded, ttl)
+		})
+	}
+	if err != nil {
+		return grants, nil
+	}
+	return fill, nil
+}
+
+// Forget drops the cached permissions of the users a permission.Changed names,
+// every cached entry when it names none. Their generation moves first, so fills
+// already loading are not stored.
+func (p *PermissionCache) Forget(payload any) {
+	changed, ok := payload.(permission.Changed)
+	if !ok || !p.app.Config.Redis.Enabled {
+		return
+	}
+
+	ctx := context.Background()
+	if err := utils.ChangeGrants(ctx, p.app, changed.UserIDs...); err != nil {
+		p.app.Log.Error().Err(err).Msg("Failed to change the grants generation")
+	}
+
+	if len(changed.UserIDs) > 0 {
+		keys := make([]string, len(changed.UserIDs))
+		for i, userID := range changed.UserIDs {
+			keys[i] = permissionCachePrefix + userID
+		}
+		if err := p.app.Redis.Del(ctx, keys...).Err(); err != nil {
+			p.app.Log.Error().Err(err).Msg("Failed to drop cached permissions")
+		}
+		return
+	}
+
+	keys := make([]string, 0, forgetBatch)
+	iter := p.app.Redis.Scan(ctx, 0, permissionCachePrefix+"*", forgetBatch).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+		if len(keys) == forgetBatch {
+			p.app.Redis.Del(ctx, keys...)
+			keys = keys[:0]
+		}
+	}
+	if len(keys) > 0 {
+		p.app.Redis.Del(ctx, keys...)
+	}
+	if err := iter.Err(); err != nil {
+		p.app.Log.Error().Err(err).Msg("Failed to drop cached permissions")
+	}
+}
+
+// cacheTTL shortens the ttl to the next start or end of a time-bound
+// assignment, the user's permissions change then without any event
+func cacheTTL(user UserModel, now time.Time, ttl time.Duration) time.Duration {
+	for _, assignment := range user.RoleAssignments {
+		for _, at := range []*time.Time{assignment.StartsAt, assignment.ExpiresAt} {
+			if at != nil && at.After(now) && at.Sub(now) < ttl {
+				ttl = at.Sub(now)
+			}
+		}
+	}
+	return ttl
+}
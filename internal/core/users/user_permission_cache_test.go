@@ -0,0 +1,57 @@
+package users
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/HasanNugroho/starter-golang/config"
+	"github.com/HasanNugroho/starter-golang/internal/app"
+	"github.com/HasanNugroho/starter-golang/internal/core/entities"
+	"github.com/HasanNugroho/starter-golang/internal/core/roles"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type permissionSource struct {
+	user UserModel
+}
+
+func (s permissionSource) FindEffective(ctx context.Context, id string) (UserModel, error) {
+	return s.user, nil
+}
+
+func TestCacheTTL(t *testing.T) {
+	now := time.Now()
+	soon, later, past := now.Add(time.Minute), now.Add(time.Hour), now.Add(-time.Minute)
+
+	assert.Equal(t, 10*time.Minute, cacheTTL(UserModel{}, now, 10*time.Minute))
+
+	// The permissions change when a window opens or closes
+	user := UserModel{RoleAssignments: []entities.RoleAssignment{
+		{StartsAt: &past, ExpiresAt: &later},
+		{StartsAt: &soon},
+	}}
+	assert.Equal(t, time.Minute, cacheTTL(user, now, 10*time.Minute))
+
+	user.RoleAssignments = user.RoleAssignments[:1]
+	assert.Equal(t, 10*time.Minute, cacheTTL(user, now, 10*time.Minute))
+	assert.Equal(t, time.Hour, cacheTTL(user, now, 2*time.Hour))
+}
+
+func TestPermissionCache_RedisUnreachable(t *testing.T) {
+	// Nothing listens there, every Redis call fails
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	t.Cleanup(func() { client.Close() })
+	apps := &app.Apps{Config: &config.Config{}, Redis: client}
+	apps.Config.Redis.Enabled = true
+
+	source := permissionSource{user: UserModel{RolesData: []roles.RoleModel{{Permissions: []string{"users:read"}}}}}
+	grants, err := NewPermissionCache(apps, source).Effective(context.Background(), "user")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"users:read"}, grants.Permissions)
+
+	// Without a generation the grants are not cached, nor their matcher
+	assert.Empty(t, grants.Generation)
+}
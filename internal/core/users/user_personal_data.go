@@ -0,0 +1,192 @@
+package users
+
+import (
+	"context"
+	"time"
+
+	"github.com/HasanNugroho/starter-golang/internal/app"
+	"github.com/HasanNugroho/starter-golang/internal/core/entities"
+	"github.com/HasanNugroho/starter-golang/internal/shared/modules"
+	"github.com/HasanNugroho/starter-golang/internal/shared/query"
+	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// erasedUserName replaces the name of erased users
+const erasedUserName = "Erased user"
+
+// UserPersonalData exports and erases what the users collections hold about a user
+type UserPersonalData struct {
+	app     *app.Apps
+	users   *mongo.Collection
+	roles   *mongo.Collection
+	jobs    *mongo.Collection
+	results *mongo.Collection
+}
+
+func NewUserPersonalData(app *app.Apps) *UserPersonalData {
+	return &UserPersonalData{
+		app:     app,
+		users:   app.DB.Collection("users"),
+		roles:   app.DB.Collection("roles"),
+		jobs:    app.DB.Collection("user_import_jobs"),
+		results: app.DB.Collection("user_import_results"),
+	}
+}
+
+// Sources lists the personal data sources of the users module
+func (p *UserPersonalData) Sources() []modules.PersonalDataSource {
+	return []modules.PersonalDataSource{
+		{Name: "profile", Export: p.exportProfile, Erase: p.eraseProfile},
+		{Name: "user_imports", Export: p.exportImports, Erase: p.eraseImports},
+	}
+}
+
+func (p *UserPersonalData) exportProfile(ctx context.Context, subject modules.DataSubject, archive modules.PersonalDataArchive) error {
+	var user entities.User
+	if err := p.users.FindOne(ctx, bson.M{"_id": subject.ID}).Decode(&user); err != nil {
+		return err
+	}
+
+	profile := UserPersonalDataModel{
+		ID:           user.ID,
+		Email:        user.Email,
+		Name:         user.Name,
+		Status:       utils.NormalizeStatus(user.Status),
+		StatusReason: user.StatusReason,
+		Preferences:  user.Preferences,
+		Attributes:   user.Attributes,
+		Avatar:       user.Avatar,
+		CreatedAt:    user.CreatedAt,
+		UpdatedAt:    user.UpdatedAt,
+		DeletedAt:    user.DeletedAt,
+	}
+	if user.PendingEmail != nil {
+		profile.PendingEmail = user.PendingEmail.Email
+	}
+	if err := archive.AddJSON("profile.json", profile); err != nil {
+		return err
+	}
+
+	roles := []entities.Role{}
+	if len(user.Roles) > 0 {
+		cursor, err := p.roles.Find(ctx, bson.M{"_id": bson.M{"$in": user.Roles}})
+		if err != nil {
+			return err
+		}
+		if err := cursor.All(ctx, &roles); err != nil {
+			return err
+		}
+	}
+
+	return archive.AddJSON("roles.json", roles)
+}
+
+// eraseProfile anonymizes the account instead of deleting it, so references
+// from other documents stay valid while nothing identifies the person anymore
+func (p *UserPersonalData) eraseProfile(ctx context.Context, subject modules.DataSubject) error {
+	now := time.Now()
+	update := bson.M{
+		"$set": bson.M{
+			"email":         "erased-" + subject.ID.Hex() + "@erased.invalid",
+			"name":          erasedUserName,
+			"password":      "",
+			"roles":         []bson.ObjectID{},
+			"status":        utils.StatusDeactivated,
+			"status_reason": "personal data erased",
+			"erased_at":     now,
+			"updated_at":    now,
+		},
+		"$unset": bson.M{
+			"preferences":      "",
+			"pending_email":    "",
+			"groups":           "",
+			"attributes":       "",
+			"avatar":           "",
+			"role_assignments": "",
+		},
+	}
+	if _, err := p.users.UpdateOne(ctx, bson.M{"_id": subject.ID}, query.BumpVersion(update)); err != nil {
+		return err
+	}
+
+	// Keep the account in the trash so the purge job removes it in time
+	if _, err := p.users.UpdateOne(ctx, bson.M{"_id": subject.ID, "deleted_at": nil}, bson.M{"$set": bson.M{"deleted_at": now}}); err != nil {
+		return err
+	}
+
+	utils.CacheUserStatus(p.app, subject.ID.Hex(), utils.StatusDeactivated)
+	return utils.RevokeUserTokens(p.app, subject.ID.Hex())
+}
+
+func (p *UserPersonalData) exportImports(ctx context.Context, subject modules.DataSubject, archive modules.PersonalDataArchive) error {
+	// Only the rows about the subject, other rows are personal data of someone else
+	rows := map[bson.ObjectID][]entities.ImportRowResult{}
+	if subject.Email != "" {
+		opts := options.Find().SetSort(bson.D{{Key: "job", Value: 1}, {Key: "row", Value: 1}})
+		cursor, err := p.results.Find(ctx, bson.M{"email": subject.Email}, opts)
+		if err != nil {
+			return err
+		}
+
+		var results []entities.ImportRowResult
+		if err := cursor.All(ctx, &results); err != nil {
+			return err
+		}
+		for _, result := range results {
+			rows[result.Job] = append(rows[result.Job], result)
+		}
+	}
+
+	jobIDs := make([]bson.ObjectID, 0, len(rows))
+	for id := range rows {
+		jobIDs = append(jobIDs, id)
+	}
+
+	cursor, err := p.jobs.Find(ctx, bson.M{"$or": bson.A{
+		bson.M{"created_by": subject.ID},
+		bson.M{"_id": bson.M{"$in": jobIDs}},
+	}})
+	if err != nil {
+		return err
+	}
+
+	var jobs []entities.ImportJob
+	if err := cursor.All(ctx, &jobs); err != nil {
+		return err
+	}
+
+	exported := []UserImportPersonalDataModel{}
+	for _, job := range jobs {
+		entry := UserImportPersonalDataModel{
+			JobID:         job.ID,
+			CreatedAt:     job.CreatedAt,
+			CreatedByUser: job.CreatedBy != nil && *job.CreatedBy == subject.ID,
+			Rows:          []entities.ImportRowResult{},
+		}
+		if found, ok := rows[job.ID]; ok {
+			entry.Rows = found
+		}
+		exported = append(exported, entry)
+	}
+
+	return archive.AddJSON("user_imports.json", exported)
+}
+
+func (p *UserPersonalData) eraseImports(ctx context.Context, subject modules.DataSubject) error {
+	if _, err := p.jobs.UpdateMany(ctx, bson.M{"created_by": subject.ID}, bson.M{"$unset": bson.M{"created_by": ""}}); err != nil {
+		return err
+	}
+
+	if subject.Email == "" {
+		return nil
+	}
+
+	_, err := p.results.UpdateMany(ctx,
+		bson.M{"email": subject.Email},
+		bson.M{"$set": bson.M{"email": "", "message": ""}},
+	)
+	return err
+}
@@ -1,11 +1,14 @@
 package users
 
 import (
+	"context"
 	"time"
 
 	"github.com/HasanNugroho/starter-golang/internal/app"
 	"github.com/HasanNugroho/starter-golang/internal/core/entities"
-	shared "github.com/HasanNugroho/starter-golang/internal/shared/model"
+	"github.com/HasanNugroho/starter-golang/internal/shared/patch"
+	"github.com/HasanNugroho/starter-golang/internal/shared/query"
+	"github.com/HasanNugroho/starter-golang/internal/shared/tenant"
 	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
 	"github.com/labstack/echo/v4"
 	"go.mongodb.org/mongo-driver/v2/bson"
@@ -26,12 +29,75 @@ func convertBsonAToStringSlice(bsonArray bson.A) []string {
 type UserRepository struct {
 	app        *app.Apps
 	collection *mongo.Collection
+	// members are the users of the active tenant, accounts themselves are global
+	members query.Collection
 }
 
 func NewUserRepository(app *app.Apps) *UserRepository {
 	return &UserRepository{
 		app:        app,
 		collection: app.DB.Collection("users"),
+		members:    query.Members(app.DB.Collection("users")),
+	}
+}
+
+// maxDate stands for the missing end of an open-ended assignment
+var maxDate = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+
+// effectiveRolesLookup fills roles_data with the effective roles of the matched users:
+// the roles assigned to them directly, the time-bound assignments whose window is
+// open, the roles of every global group they belong to and every role those inherit
+// from. A deleted role stops the inheritance. Groups of an organization only grant
+// their roles inside of it, see the tenant resolver.
+func effectiveRolesLookup() mongo.Pipeline {
+	openAssignments := bson.D{{Key: "$map", Value: bson.D{
+		{Key: "input", Value: bson.D{{Key: "$filter", Value: bson.D{
+			{Key: "input", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$role_assignments", bson.A{}}}}},
+			{Key: "cond", Value: bson.D{{Key: "$and", Value: bson.A{
+				bson.D{{Key: "$lte", Value: bson.A{bson.D{{Key: "$ifNull", Value: bson.A{"$$this.starts_at", "$$NOW"}}}, "$$NOW"}}},
+				bson.D{{Key: "$gt", Value: bson.A{bson.D{{Key: "$ifNull", Value: bson.A{"$$this.expires_at", maxDate}}}, "$$NOW"}}},
+			}}}},
+		}}}},
+		{Key: "in", Value: "$$this.role"},
+	}}}
+
+	groupRoles := bson.D{{Key: "$reduce", Value: bson.D{
+		{Key: "input", Value: "$groups_data.roles"},
+		{Key: "initialValue", Value: bson.A{}},
+		{Key: "in", Value: bson.D{{Key: "$setUnion", Value: bson.A{"$$value", bson.D{{Key: "$ifNull", Value: bson.A{"$$this", bson.A{}}}}}}}},
+	}}}
+
+	return mongo.Pipeline{
+		{{
+			Key: "$lookup",
+			Value: bson.D{
+				{Key: "from", Value: "groups"},
+				{Key: "localField", Value: "groups"},
+				{Key: "foreignField", Value: "_id"},
+				{Key: "pipeline", Value: mongo.Pipeline{
+					{{Key: "$match", Value: bson.M{tenant.Field: nil}}},
+					{{Key: "$project", Value: bson.D{{Key: "roles", Value: 1}}}},
+				}},
+				{Key: "as", Value: "groups_data"},
+			},
+		}},
+		{{Key: "$set", Value: bson.D{{Key: "effective_roles", Value: bson.D{{Key: "$setUnion", Value: bson.A{
+			bson.D{{Key: "$ifNull", Value: bson.A{"$roles", bson.A{}}}},
+			openAssignments,
+			groupRoles,
+		}}}}}}},
+		{{
+			Key: "$graphLookup",
+			Value: bson.D{
+				{Key: "from", Value: "roles"},
+				{Key: "startWith", Value: "$effective_roles"},
+				{Key: "connectFromField", Value: "parents"},
+				{Key: "connectToField", Value: "_id"},
+				{Key: "restrictSearchWithMatch", Value: query.NotDeleted()},
+				{Key: "as", Value: "roles_data"},
+			},
+		}},
+		{{Key: "$unset", Value: bson.A{"groups_data", "effective_roles"}}},
 	}
 }
 
@@ -51,19 +117,10 @@ func (u *UserRepository) Create(ctx echo.Context, user *entities.User) error {
 func (u *UserRepository) FindByEmail(ctx echo.Context, email string) (UserModel, error) {
 	c := ctx.Request().Context()
 
-	pipeline := mongo.Pipeline{
-		{{Key: "$match", Value: bson.D{{Key: "email", Value: email}}}},
-		{{
-			Key: "$lookup",
-			Value: bson.D{
-				{Key: "from", Value: "roles"},
-				{Key: "localField", Value: "roles"},
-				{Key: "foreignField", Value: "_id"},
-				{Key: "as", Value: "roles_data"},
-			},
-		}},
+	pipeline := append(mongo.Pipeline{
+		{{Key: "$match", Value: bson.D{{Key: "email", Value: email}, {Key: "deleted_at", Value: nil}}}},
 		{{Key: "$limit", Value: 1}},
-	}
+	}, effectiveRolesLookup()...)
 
 	cursor, err := u.collection.Aggregate(c, pipeline)
 	if err != nil {
@@ -84,28 +141,49 @@ func (u *UserRepository) FindByEmail(ctx echo.Context, email string) (UserModel,
 }
 
 func (u *UserRepository) FindById(ctx echo.Context, id string) (UserModel, error) {
-	c := ctx.Request().Context()
+	return u.FindEffective(ctx.Request().Context(), id)
+}
 
+// FindMember finds the user with the effective roles among the members of the
+// active tenant, FindById finds any user
+func (u *UserRepository) FindMember(ctx echo.Context, id string) (UserModel, error) {
+	return findEffective(ctx.Request().Context(), u.members.For(ctx).Aggregate, id, query.NotDeleted())
+}
+
+// FindEffective finds the user with the effective roles outside of a request,
+// see PermissionCache
+func (u *UserRepository) FindEffective(c context.Context, id string) (UserModel, error) {
+	return findEffective(c, u.aggregate, id, query.NotDeleted())
+}
+
+// FindDeletedById finds a user in the trash with the effective roles
+func (u *UserRepository) FindDeletedById(ctx echo.Context, id string) (UserModel, error) {
+	return findEffective(ctx.Request().Context(), u.aggregate, id, query.OnlyDeleted())
+}
+
+func (u *UserRepository) aggregate(c context.Context, pipeline mongo.Pipeline) (*mongo.Cursor, error) {
+	return u.collection.Aggregate(c, pipeline)
+}
+
+// findEffective finds the user with the id in the given trash state, see
+// query.NotDeleted and query.OnlyDeleted
+func findEffective(c context.Context, aggregate func(context.Context, mongo.Pipeline) (*mongo.Cursor, error), id string, state bson.M) (UserModel, error) {
 	objectID, err := bson.ObjectIDFromHex(id)
 	if err != nil {
 		return UserModel{}, utils.NewBadRequest("invalid user id")
 	}
 
-	pipeline := mongo.Pipeline{
-		{{Key: "$match", Value: bson.D{{Key: "_id", Value: objectID}}}},
-		{{
-			Key: "$lookup",
-			Value: bson.D{
-				{Key: "from", Value: "roles"},
-				{Key: "localField", Value: "roles"},
-				{Key: "foreignField", Value: "_id"},
-				{Key: "as", Value: "roles_data"},
-			},
-		}},
-		{{Key: "$limit", Value: 1}},
+	match := bson.M{"_id": objectID}
+	for key, value := range state {
+		match[key] = value
 	}
 
-	cursor, err := u.collection.Aggregate(c, pipeline)
+	pipeline := append(mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$limit", Value: 1}},
+	}, effectiveRolesLookup()...)
+
+	cursor, err := aggregate(c, pipeline)
 	if err != nil {
 		return UserModel{}, utils.NewInternal("failed to query data")
 	}
@@ -123,32 +201,39 @@ func (u *UserRepository) FindById(ctx echo.Context, id string) (UserModel, error
 	return users[0], nil
 }
 
-func (u *UserRepository) FindAll(ctx echo.Context, filter *shared.PaginationFilter) ([]UserModelResponse, int, error) {
+// FindByEmailChangeKey finds the user with a pending email change matching the confirm or cancel key
+func (u *UserRepository) FindByEmailChangeKey(ctx echo.Context, key string) (UserModel, error) {
 	c := ctx.Request().Context()
-	var roles []UserModelResponse
-	var totalItems int64
-
-	opts := options.Find().
-		SetSkip(int64((filter.Page - 1) * filter.Limit)).
-		SetLimit(int64(filter.Limit))
-	// opts.SetSort(bson.D{{Key: "created_at", Value: -1}})
 
-	cursor, err := u.collection.Find(c, bson.M{}, opts)
-	if err != nil {
-		return []UserModelResponse{}, 0, utils.NewInternal("failed to query data")
+	filter := bson.M{
+		"deleted_at": nil,
+		"$or": bson.A{
+			bson.M{"pending_email.confirm_key": key},
+			bson.M{"pending_email.cancel_key": key},
+		},
 	}
-	defer cursor.Close(c)
 
-	if err := cursor.All(c, &roles); err != nil {
-		return []UserModelResponse{}, 0, utils.NewInternal("failed decode data")
+	var user UserModel
+	if err := u.collection.FindOne(c, filter).Decode(&user); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return UserModel{}, utils.NewNotFound("data not found")
+		}
+		return UserModel{}, utils.NewInternal("failed to query data")
 	}
 
-	totalItems, err = u.collection.CountDocuments(c, bson.M{})
-	if err != nil {
-		return []UserModelResponse{}, 0, utils.NewInternal("failed count user")
-	}
+	return user, nil
+}
+
+func (u *UserRepository) FindAll(ctx echo.Context, q *query.Query) (query.Page[UserModelResponse], error) {
+	c := ctx.Request().Context()
+
+	return query.FindIn[UserModelResponse](c, u.members.For(ctx), q.WithoutDeleted(), []byte(u.app.Config.Security.CursorKey()))
+}
+
+func (u *UserRepository) FindDeleted(ctx echo.Context, q *query.Query) (query.Page[UserTrashModel], error) {
+	c := ctx.Request().Context()
 
-	return roles, int(totalItems), nil
+	return query.FindIn[UserTrashModel](c, u.members.For(ctx), q.OnlyDeleted(), []byte(u.app.Config.Security.CursorKey()))
 }
 
 func (u *UserRepository) Update(ctx echo.Context, id string, user *entities.User) error {
@@ -159,23 +244,34 @@ func (u *UserRepository) Update(ctx echo.Context, id string, user *entities.User
 		return utils.NewBadRequest("invalid user id")
 	}
 
-	filter := bson.M{"_id": objectId}
-	err = u.collection.FindOneAndUpdate(c, filter, bson.M{
-		"$set": bson.M{
-			"name":       user.Name,
-			"email":      user.Email,
-			"password":   user.Password,
-			"updated_at": time.Now(),
-		}}).Err()
+	set := bson.M{
+		"name":       user.Name,
+		"email":      user.Email,
+		"password":   user.Password,
+		"updated_at": time.Now(),
+	}
+	if user.Attributes != nil {
+		set["attributes"] = user.Attributes
+	}
+
+	filter := bson.M{"_id": objectId, "deleted_at": nil}
+	result, err := u.collection.UpdateOne(c, utils.VersionedFilter(ctx, filter), query.BumpVersion(bson.M{"$set": set}))
 
 	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return utils.NewConflict("email already exists")
+		}
 		return utils.NewInternal("failed to update user")
 	}
 
+	if result.MatchedCount == 0 {
+		return utils.NotMatched(ctx, c, u.collection, filter)
+	}
+
 	return nil
 }
 
-func (u *UserRepository) Delete(ctx echo.Context, id string) error {
+func (u *UserRepository) Patch(ctx echo.Context, id string, changes patch.Changes) error {
 	c := ctx.Request().Context()
 
 	objectId, err := bson.ObjectIDFromHex(id)
@@ -183,12 +279,80 @@ func (u *UserRepository) Delete(ctx echo.Context, id string) error {
 		return utils.NewBadRequest("invalid user id")
 	}
 
-	filter := bson.M{"_id": objectId}
+	changes.Set["updated_at"] = time.Now()
 
-	result := u.collection.FindOneAndDelete(c, filter)
-	if result.Err() != nil {
+	filter := bson.M{"_id": objectId, "deleted_at": nil}
+	result, err := u.collection.UpdateOne(c, utils.VersionedFilter(ctx, filter), query.BumpVersion(changes.Update()))
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return utils.NewConflict("email already exists")
+		}
 		return utils.NewInternal("failed to update user")
 	}
 
+	if result.MatchedCount == 0 {
+		return utils.NotMatched(ctx, c, u.collection, filter)
+	}
+
 	return nil
 }
+
+// Delete moves the user to the trash and returns the status the user had
+func (u *UserRepository) Delete(ctx echo.Context, id string, deletedBy string) (string, error) {
+	c := ctx.Request().Context()
+
+	objectId, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return "", utils.NewBadRequest("invalid user id")
+	}
+
+	filter := bson.M{"_id": objectId, "deleted_at": nil}
+	var user struct {
+		Status string `bson:"status"`
+	}
+	opts := options.FindOneAndUpdate().SetProjection(bson.M{"status": 1})
+	err = u.collection.FindOneAndUpdate(c, utils.VersionedFilter(ctx, filter), query.BumpVersion(query.SoftDelete(deletedBy)), opts).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return "", utils.NotMatched(ctx, c, u.collection, filter)
+	}
+	if err != nil {
+		return "", utils.NewInternal("failed to delete user")
+	}
+
+	return utils.NormalizeStatus(user.Status), nil
+}
+
+// Restore takes the user out of the trash and returns the status the user is back in
+func (u *UserRepository) Restore(ctx echo.Context, id string) (string, error) {
+	c := ctx.Request().Context()
+
+	objectId, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return "", utils.NewBadRequest("invalid user id")
+	}
+
+	filter := bson.M{"_id": objectId, "deleted_at": bson.M{"$ne": nil}}
+	var user struct {
+		Status string `bson:"status"`
+	}
+	opts := options.FindOneAndUpdate().SetProjection(bson.M{"status": 1})
+	err = u.collection.FindOneAndUpdate(c, filter, query.BumpVersion(query.Restore()), opts).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return "", utils.NewNotFound("data not found")
+	}
+	if err != nil {
+		return "", utils.NewInternal("failed to restore user")
+	}
+
+	return utils.NormalizeStatus(user.Status), nil
+}
+
+// Purge hard deletes users that have been in the trash since before the cutoff
+func (u *UserRepository) Purge(ctx context.Context, cutoff time.Time) (int64, error) {
+	result, err := u.collection.DeleteMany(ctx, query.PurgeBefore(cutoff))
+	if err != nil {
+		return 0, utils.NewInternal("failed to purge users")
+	}
+
+	return result.DeletedCount, nil
+}
@@ -0,0 +1,113 @@
+package users
+
+import (
+	"time"
+
+	"github.com/HasanNugroho/starter-golang/internal/app"
+	"github.com/HasanNugroho/starter-golang/internal/shared/modules"
+	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// userSchemas declares the collections of the users module.
+// The unique email index also covers deleted users, so restoring one never
+// collides with an account created in the meantime.
+func userSchemas(app *app.Apps) []modules.CollectionSchema {
+	retention := time.Duration(app.Config.DB.ImportJobRetention) * 24 * time.Hour
+	if retention <= 0 {
+		retention = 30 * 24 * time.Hour
+	}
+
+	return []modules.CollectionSchema{
+		{
+			Name: "users",
+			Indexes: []modules.IndexSpec{
+				{Name: "email_unique", Keys: bson.D{{Key: "email", Value: 1}}, Unique: true},
+				{Name: "deleted_at_created_at", Keys: bson.D{{Key: "deleted_at", Value: 1}, {Key: "created_at", Value: -1}}},
+				{Name: "roles", Keys: bson.D{{Key: "roles", Value: 1}}},
+				{Name: "role_assignments_role", Keys: bson.D{{Key: "role_assignments.role", Value: 1}}, Sparse: true},
+				{Name: "role_assignments_expires_at", Keys: bson.D{{Key: "role_assignments.expires_at", Value: 1}}, Sparse: true},
+				{Name: "groups", Keys: bson.D{{Key: "groups", Value: 1}}, Sparse: true},
+				{Name: "memberships_organization", Keys: bson.D{{Key: "memberships.organization", Value: 1}}, Sparse: true},
+				{Name: "memberships_roles", Keys: bson.D{{Key: "memberships.roles", Value: 1}}, Sparse: true},
+				{Name: "pending_email_confirm_key", Keys: bson.D{{Key: "pending_email.confirm_key", Value: 1}}, Sparse: true},
+				{Name: "pending_email_cancel_key", Keys: bson.D{{Key: "pending_email.cancel_key", Value: 1}}, Sparse: true},
+			},
+			Validator: bson.M{
+				"bsonType": "object",
+				"required": bson.A{"email", "name", "password"},
+				"properties": bson.M{
+					"email":    bson.M{"bsonType": "string", "pattern": `^[^@\s]+@[^@\s]+$`},
+					"name":     bson.M{"bsonType": "string"},
+					"password": bson.M{"bsonType": "string"},
+					"roles":    bson.M{"bsonType": bson.A{"array", "null"}, "items": bson.M{"bsonType": "objectId"}},
+					"role_assignments": bson.M{"bsonType": "array", "items": bson.M{
+						"bsonType": "object",
+						"required": bson.A{"role", "assigned_at"},
+						"properties": bson.M{
+							"role":       bson.M{"bsonType": "objectId"},
+							"starts_at":  bson.M{"bsonType": "date"},
+							"expires_at": bson.M{"bsonType": "date"},
+							"note":       bson.M{"bsonType": "string"},
+						},
+					}},
+					"groups": bson.M{"bsonType": "array", "items": bson.M{"bsonType": "objectId"}},
+					"memberships": bson.M{"bsonType": "array", "items": bson.M{
+						"bsonType": "object",
+						"required": bson.A{"organization", "roles"},
+						"properties": bson.M{
+							"organization": bson.M{"bsonType": "objectId"},
+							"roles":        bson.M{"bsonType": "array", "items": bson.M{"bsonType": "objectId"}},
+							"joined_at":    bson.M{"bsonType": "date"},
+						},
+					}},
+					"attributes": bson.M{"bsonType": "object"},
+					"status": bson.M{"enum": bson.A{
+						utils.StatusPending,
+						utils.StatusActive,
+						utils.StatusSuspended,
+						utils.StatusLocked,
+						utils.StatusDeactivated,
+					}},
+					"version":    bson.M{"bsonType": bson.A{"int", "long"}},
+					"deleted_at": bson.M{"bsonType": bson.A{"date", "null"}},
+				},
+			},
+		},
+		{
+			Name: "user_import_jobs",
+			Indexes: []modules.IndexSpec{
+				{Name: "created_by", Keys: bson.D{{Key: "created_by", Value: 1}}, Sparse: true},
+				{Name: "finished_at_ttl", Keys: bson.D{{Key: "finished_at", Value: 1}}, TTL: retention},
+			},
+			Validator: bson.M{
+				"bsonType": "object",
+				"required": bson.A{"format", "status", "created_at"},
+				"properties": bson.M{
+					"format":     bson.M{"bsonType": "string"},
+					"status":     bson.M{"bsonType": "string"},
+					"created_at": bson.M{"bsonType": "date"},
+				},
+			},
+		},
+		{
+			Name: "user_import_results",
+			Indexes: []modules.IndexSpec{
+				{Name: "job_row", Keys: bson.D{{Key: "job", Value: 1}, {Key: "row", Value: 1}}},
+				{Name: "email", Keys: bson.D{{Key: "email", Value: 1}}},
+				{Name: "created_at_ttl", Keys: bson.D{{Key: "created_at", Value: 1}}, TTL: retention},
+			},
+			Validator: bson.M{
+				"bsonType": "object",
+				"required": bson.A{"job", "row", "status", "created_at"},
+				"properties": bson.M{
+					"job":        bson.M{"bsonType": "objectId"},
+					"row":        bson.M{"bsonType": bson.A{"int", "long"}},
+					"email":      bson.M{"bsonType": "string"},
+					"status":     bson.M{"bsonType": "string"},
+					"created_at": bson.M{"bsonType": "date"},
+				},
+			},
+		},
+	}
+}
@@ -1,28 +1,43 @@
 package users
 
 import (
+	"fmt"
 	"time"
 
+	"github.com/HasanNugroho/starter-golang/internal/app"
+	"github.com/HasanNugroho/starter-golang/internal/core/attributes"
 	"github.com/HasanNugroho/starter-golang/internal/core/entities"
+	"github.com/HasanNugroho/starter-golang/internal/shared/authz"
 	shared "github.com/HasanNugroho/starter-golang/internal/shared/model"
+	"github.com/HasanNugroho/starter-golang/internal/shared/patch"
+	"github.com/HasanNugroho/starter-golang/internal/shared/query"
+	"github.com/HasanNugroho/starter-golang/internal/shared/tenant"
 	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
 	"github.com/labstack/echo/v4"
 	"go.mongodb.org/mongo-driver/v2/bson"
 )
 
 type UserService struct {
-	repo IUserRepository
+	repo       IUserRepository
+	attributes attributes.IAttributeService
+	app        *app.Apps
 }
 
-func NewUserService(repo IUserRepository) *UserService {
+func NewUserService(app *app.Apps, repo IUserRepository, attributes attributes.IAttributeService) *UserService {
 	return &UserService{
-		repo: repo,
+		repo:       repo,
+		attributes: attributes,
+		app:        app,
 	}
 }
 
+// Create relies on the unique email index, a taken email fails the insert with a conflict
 func (u *UserService) Create(ctx echo.Context, user *UserCreateModel) error {
-	_, err := u.repo.FindByEmail(ctx, user.Email)
+	if err := outsideTenant(ctx); err != nil {
+		return err
+	}
 
+	values, err := u.attributes.Validate(ctx, nil, user.Attributes, false)
 	if err != nil {
 		return err
 	}
@@ -33,10 +48,12 @@ func (u *UserService) Create(ctx echo.Context, user *UserCreateModel) error {
 	}
 
 	payload := entities.User{
-		Email:    user.Email,
-		Name:     user.Name,
-		Roles:    []bson.ObjectID{},
-		Password: password,
+		Email:      user.Email,
+		Name:       user.Name,
+		Roles:      []bson.ObjectID{},
+		Password:   password,
+		Status:     utils.StatusActive,
+		Attributes: values,
 	}
 
 	if err = u.repo.Create(ctx, &payload); err != nil {
@@ -47,33 +64,57 @@ func (u *UserService) Create(ctx echo.Context, user *UserCreateModel) error {
 }
 
 func (u *UserService) FindById(ctx echo.Context, id string) (UserModel, error) {
-	return u.repo.FindById(ctx, id)
+	user, err := u.repo.FindMember(ctx, id)
+	if err != nil {
+		return UserModel{}, err
+	}
+
+	if !u.app.Authz.Allowed(ctx, "users:read", userResource(user)) {
+		return UserModel{}, utils.NewForbidden("access denied")
+	}
+
+	return user, nil
 }
 
-func (u *UserService) FindAll(ctx echo.Context, filter *shared.PaginationFilter) (shared.DataWithPagination, error) {
-	users, totalItems, err := u.repo.FindAll(ctx, filter)
+// QuerySchema is the listing schema of users extended with their custom attributes
+func (u *UserService) QuerySchema(ctx echo.Context) (query.Schema, error) {
+	return u.attributes.QuerySchema(ctx, UserQuerySchema)
+}
+
+func (u *UserService) FindAll(ctx echo.Context, q *query.Query) (shared.DataWithPagination, error) {
+	// Principals granted users:read by policies only see the users they hold for
+	filter, ok := u.app.Authz.ListFilter(ctx, "users:read")
+	if !ok {
+		return shared.DataWithPagination{}, utils.NewForbidden("access denied")
+	}
+
+	page, err := u.repo.FindAll(ctx, q.Where(filter))
 	if err != nil {
 		return shared.DataWithPagination{}, err
 	}
 
-	// build pagination meta
-	paginate := utils.BuildPagination(filter, int64(totalItems))
-
 	// Buat response dengan pagination
-	result := shared.DataWithPagination{
-		Items:  users,
-		Paging: paginate,
-	}
-
-	return result, nil
+	return page.Response(q), nil
 }
 
 func (u *UserService) Update(ctx echo.Context, id string, user *UserUpdateModel) error {
+	if err := outsideTenant(ctx); err != nil {
+		return err
+	}
+
 	existingUser, err := u.repo.FindById(ctx, id)
 	if err != nil {
 		return err
 	}
 
+	if !u.app.Authz.Allowed(ctx, "users:update", userResource(existingUser)) {
+		return utils.NewForbidden("access denied")
+	}
+
+	if err := utils.CheckVersion(ctx, existingUser.Version); err != nil {
+		return err
+	}
+
 	updatedUser := entities.User{
 		Email:     user.Email,
 		Name:      user.Name,
@@ -82,6 +123,13 @@ func (u *UserService) Update(ctx echo.Context, id string, user *UserUpdateModel)
 		UpdatedAt: time.Now(),
 	}
 
+	// Omitted attributes stay as they are
+	if user.Attributes != nil {
+		if updatedUser.Attributes, err = u.attributes.Validate(ctx, existingUser.Attributes, user.Attributes, false); err != nil {
+			return err
+		}
+	}
+
 	if user.Password != "" {
 		hashedPassword, err := utils.HashPassword([]byte(user.Password))
 		if err != nil {
@@ -97,6 +145,224 @@ func (u *UserService) Update(ctx echo.Context, id string, user *UserUpdateModel)
 	return nil
 }
 
+func (u *UserService) Patch(ctx echo.Context, id string, contentType string, body []byte) error {
+	if err := outsideTenant(ctx); err != nil {
+		return err
+	}
+
+	existingUser, err := u.repo.FindById(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if !u.app.Authz.Allowed(ctx, "users:update", userResource(existingUser)) {
+		return utils.NewForbidden("access denied")
+	}
+
+	if err := utils.CheckVersion(ctx, existingUser.Version); err != nil {
+		return err
+	}
+
+	current := UserPatchModel{
+		Email:      existingUser.Email,
+		Name:       existingUser.Name,
+		Attributes: attributes.Plain(existingUser.Attributes),
+	}
+
+	var patched UserPatchModel
+	if err := patch.Apply(contentType, current, body, &patched); err != nil {
+		return err
+	}
+
+	changes, err := patch.Diff(current, patched)
+	if err != nil {
+		return err
+	}
+
+	if _, changed := changes.Set["attributes"]; changed {
+		values, err := u.attributes.Validate(ctx, existingUser.Attributes, patched.Attributes, false)
+		if err != nil {
+			return err
+		}
+		changes.Set["attributes"] = values
+	} else if _, removed := changes.Unset["attributes"]; removed {
+		if _, err := u.attributes.Validate(ctx, existingUser.Attributes, nil, false); err != nil {
+			return err
+		}
+	}
+
+	if patched.Password != "" {
+		hashedPassword, err := utils.HashPassword([]byte(patched.Password))
+		if err != nil {
+			return err
+		}
+		changes.Set["password"] = hashedPassword
+	}
+
+	if changes.Empty() {
+		return nil
+	}
+
+	return u.repo.Patch(ctx, id, changes)
+}
+
+func (u *UserService) ChangeStatus(ctx echo.Context, id string, payload *UserStatusModel) error {
+	if err := outsideTenant(ctx); err != nil {
+		return err
+	}
+
+	existingUser, err := u.repo.FindById(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if !u.app.Authz.Allowed(ctx, "users:status", userResource(existingUser)) {
+		return utils.NewForbidden("access denied")
+	}
+
+	if err := utils.CheckVersion(ctx, existingUser.Version); err != nil {
+		return err
+	}
+
+	from := utils.NormalizeStatus(existingUser.Status)
+	if !utils.CanTransition(from, payload.Status) {
+		return utils.NewConflict(fmt.Sprintf("can not change account status from %s to %s", from, payload.Status))
+	}
+
+	changes := patch.Changes{Set: bson.M{"status": payload.Status}, Unset: bson.M{}}
+	if payload.Reason != "" {
+		changes.Set["status_reason"] = payload.Reason
+	} else {
+		changes.Unset["status_reason"] = ""
+	}
+
+	if err := u.repo.Patch(ctx, id, changes); err != nil {
+		return err
+	}
+
+	utils.CacheUserStatus(u.app, id, payload.Status)
+
+	// Sessions of an account that is no longer active are ended right away
+	if payload.Status != utils.StatusActive {
+		if err := utils.RevokeUserTokens(u.app, id); err != nil {
+			return err
+		}
+	}
+
+	u.emitStatusChanged(ctx, id, from, payload.Status, payload.Reason)
+	return nil
+}
+
 func (u *UserService) Delete(ctx echo.Context, id string) error {
-	return u.repo.Delete(ctx, id)
+	if err := outsideTenant(ctx); err != nil {
+		return err
+	}
+
+	existingUser, err := u.repo.FindById(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if !u.app.Authz.Allowed(ctx, "users:delete", userResource(existingUser)) {
+		return utils.NewForbidden("access denied")
+	}
+
+	from, err := u.repo.Delete(ctx, id, utils.CurrentUserID(ctx))
+	if err != nil {
+		return err
+	}
+
+	utils.CacheUserStatus(u.app, id, utils.StatusDeactivated)
+
+	// A deleted user must not keep using the tokens issued before
+	if err := utils.RevokeUserTokens(u.app, id); err != nil {
+		return err
+	}
+
+	u.emitStatusChanged(ctx, id, from, utils.StatusDeactivated, "deleted")
+	return nil
+}
+
+func (u *UserService) FindDeleted(ctx echo.Context, q *query.Query) (shared.DataWithPagination, error) {
+	filter, ok := u.app.Authz.ListFilter(ctx, "users:trash")
+	if !ok {
+		return shared.DataWithPagination{}, utils.NewForbidden("access denied")
+	}
+
+	page, err := u.repo.FindDeleted(ctx, q.Where(filter))
+	if err != nil {
+		return shared.DataWithPagination{}, err
+	}
+
+	return page.Response(q), nil
+}
+
+func (u *UserService) Restore(ctx echo.Context, id string) error {
+	if err := outsideTenant(ctx); err != nil {
+		return err
+	}
+
+	deletedUser, err := u.repo.FindDeletedById(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if !u.app.Authz.Allowed(ctx, "users:restore", userResource(deletedUser)) {
+		return utils.NewForbidden("access denied")
+	}
+
+	to, err := u.repo.Restore(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	utils.ForgetUserStatus(u.app, id)
+	u.emitStatusChanged(ctx, id, utils.StatusDeactivated, to, "restored")
+	return nil
+}
+
+// emitStatusChanged announces a status transition, a deleted account counts as deactivated
+func (u *UserService) emitStatusChanged(ctx echo.Context, id string, from string, to string, reason string) {
+	if from == to {
+		return
+	}
+
+	u.app.Bus.Emit(utils.UserStatusChangedEvent, UserStatusChanged{
+		UserID:    id,
+		From:      from,
+		To:        to,
+		Reason:    reason,
+		ChangedBy: utils.CurrentUserID(ctx),
+		ChangedAt: time.Now(),
+	})
+}
+
+// outsideTenant refuses account changes inside a tenant. Accounts are shared by
+// every organization their owner belongs to, an organization only manages its
+// memberships.
+func outsideTenant(ctx echo.Context) error {
+	if tenant.ID(ctx) != nil {
+		return utils.NewForbidden("user accounts are managed outside of an organization")
+	}
+	return nil
+}
+
+// userResource exposes a user to access policies, e.g. resource.attributes.department
+func userResource(user UserModel) authz.Resource {
+	roles := make([]string, 0, len(user.RolesData))
+	for _, role := range user.RolesData {
+		roles = append(roles, role.ID.Hex())
+	}
+
+	return authz.Resource{
+		Type: "users",
+		ID:   user.ID.Hex(),
+		Fields: map[string]interface{}{
+			"email":      user.Email,
+			"name":       user.Name,
+			"status":     utils.NormalizeStatus(user.Status),
+			"roles":      roles,
+			"attributes": user.Attributes,
+		},
+	}
 }
@@ -1,12 +1,26 @@
 package users_test
 
 import (
+	"context"
 	"errors"
+	"net/url"
 	"testing"
+	"time"
 
+	"github.com/HasanNugroho/starter-golang/config"
+	"github.com/HasanNugroho/starter-golang/internal/app"
+
+	"github.com/HasanNugroho/starter-golang/internal/core/attributes"
 	"github.com/HasanNugroho/starter-golang/internal/core/entities"
+	"github.com/HasanNugroho/starter-golang/internal/core/roles"
 	"github.com/HasanNugroho/starter-golang/internal/core/users"
+	"github.com/HasanNugroho/starter-golang/internal/shared/authz"
 	shared "github.com/HasanNugroho/starter-golang/internal/shared/model"
+	"github.com/HasanNugroho/starter-golang/internal/shared/modules"
+	"github.com/HasanNugroho/starter-golang/internal/shared/patch"
+	"github.com/HasanNugroho/starter-golang/internal/shared/query"
+	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -21,13 +35,22 @@ func (m *MockUserRepo) FindByEmail(ctx echo.Context, email string) (users.UserMo
 	panic("not implemented")
 }
 
+func (m *MockUserRepo) FindByEmailChangeKey(ctx echo.Context, key string) (users.UserModel, error) {
+	panic("not implemented")
+}
+
 func (m *MockUserRepo) Update(ctx echo.Context, id string, user *entities.User) error {
 	panic("not implemented")
 }
 
-func (m *MockUserRepo) FindAll(ctx echo.Context, filter *shared.PaginationFilter) ([]users.UserModelResponse, int, error) {
+func (m *MockUserRepo) Patch(ctx echo.Context, id string, changes patch.Changes) error {
+	args := m.Called(ctx, id, changes)
+	return args.Error(0)
+}
+
+func (m *MockUserRepo) FindAll(ctx echo.Context, filter *query.Query) (query.Page[users.UserModelResponse], error) {
 	args := m.Called(ctx, filter)
-	return args.Get(0).([]users.UserModelResponse), args.Int(1), args.Error(2)
+	return args.Get(0).(query.Page[users.UserModelResponse]), args.Error(1)
 }
 
 func (m *MockUserRepo) FindById(ctx echo.Context, id string) (users.UserModel, error) {
@@ -35,27 +58,79 @@ func (m *MockUserRepo) FindById(ctx echo.Context, id string) (users.UserModel, e
 	return args.Get(0).(users.UserModel), args.Error(1)
 }
 
-func (m *MockUserRepo) Delete(ctx echo.Context, id string) error {
+func (m *MockUserRepo) FindMember(ctx echo.Context, id string) (users.UserModel, error) {
 	args := m.Called(ctx, id)
-	return args.Error(0)
+	return args.Get(0).(users.UserModel), args.Error(1)
+}
+
+func (m *MockUserRepo) Delete(ctx echo.Context, id string, deletedBy string) (string, error) {
+	args := m.Called(ctx, id, deletedBy)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockUserRepo) FindDeleted(ctx echo.Context, filter *query.Query) (query.Page[users.UserTrashModel], error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).(query.Page[users.UserTrashModel]), args.Error(1)
+}
+
+func (m *MockUserRepo) FindDeletedById(ctx echo.Context, id string) (users.UserModel, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(users.UserModel), args.Error(1)
+}
+
+func (m *MockUserRepo) Restore(ctx echo.Context, id string) (string, error) {
+	args := m.Called(ctx, id)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockUserRepo) Purge(ctx context.Context, cutoff time.Time) (int64, error) {
+	panic("not implemented")
 }
 
 func (m *MockUserRepo) Create(ctx echo.Context, user *entities.User) error {
 	args := m.Called(ctx, user)
-	return args.Error(1)
+	return args.Error(0)
+}
+
+// StubAttributeRepo serves a fixed set of attribute definitions
+type StubAttributeRepo struct {
+	attributes.IAttributeRepository
+	definitions []entities.AttributeDefinition
+}
+
+func (s *StubAttributeRepo) FindAll(ctx echo.Context) ([]entities.AttributeDefinition, error) {
+	return s.definitions, nil
+}
+
+func newTestService(app *app.Apps, repo users.IUserRepository) *users.UserService {
+	return users.NewUserService(app, repo, attributes.NewAttributeService(app, &StubAttributeRepo{}))
 }
 
 func newTestContext() echo.Context {
-	return echo.New().NewContext(nil, nil)
+	return newTestContextWith([]interface{}{"users:read", "users:update", "users:status", "users:delete", "users:restore", "users:trash"}, nil)
 }
 
-func newPaginationFilter() *shared.PaginationFilter {
-	return &shared.PaginationFilter{Limit: 10, Page: 1}
+// newTestContextWith authenticates the request with the given permissions and attributes
+func newTestContextWith(permissions []interface{}, attributes map[string]interface{}) echo.Context {
+	ctx := echo.New().NewContext(nil, nil)
+	ctx.Set("claims", jwt.MapClaims{"data": map[string]interface{}{
+		"permission": permissions,
+		"attributes": attributes,
+	}})
+	return ctx
+}
+
+func newTestApp() *app.Apps {
+	return &app.Apps{Config: &config.Config{}, Bus: modules.EventNew(), Authz: authz.NewEngine()}
+}
+
+func newPaginationFilter() *query.Query {
+	return &query.Query{PaginationFilter: shared.PaginationFilter{Limit: 10, Page: 1}}
 }
 
 func TestUserService_FindAll_Success(t *testing.T) {
 	mockRepo := new(MockUserRepo)
-	service := users.NewUserService(mockRepo)
+	service := newTestService(newTestApp(), mockRepo)
 	ctx := newTestContext()
 	filter := newPaginationFilter()
 
@@ -66,7 +141,7 @@ func TestUserService_FindAll_Success(t *testing.T) {
 
 	mockRepo.
 		On("FindAll", ctx, filter).
-		Return(expectedUsers, expectedTotal, nil)
+		Return(query.Page[users.UserModelResponse]{Items: expectedUsers, Total: int64(expectedTotal)}, nil)
 
 	result, err := service.FindAll(ctx, filter)
 
@@ -79,13 +154,13 @@ func TestUserService_FindAll_Success(t *testing.T) {
 
 func TestUserService_FindAll_Error(t *testing.T) {
 	mockRepo := new(MockUserRepo)
-	service := users.NewUserService(mockRepo)
+	service := newTestService(newTestApp(), mockRepo)
 	ctx := newTestContext()
 	filter := newPaginationFilter()
 
 	mockRepo.
 		On("FindAll", ctx, filter).
-		Return([]users.UserModelResponse{}, 0, errors.New("db error"))
+		Return(query.Page[users.UserModelResponse]{}, errors.New("db error"))
 
 	result, err := service.FindAll(ctx, filter)
 
@@ -98,7 +173,7 @@ func TestUserService_FindAll_Error(t *testing.T) {
 
 func TestUserService_FindById_Success(t *testing.T) {
 	mockRepo := new(MockUserRepo)
-	service := users.NewUserService(mockRepo)
+	service := newTestService(newTestApp(), mockRepo)
 	ctx := newTestContext()
 
 	objectId, _ := bson.ObjectIDFromHex("67f759abe02e4b2f3c2f69b6")
@@ -109,7 +184,7 @@ func TestUserService_FindById_Success(t *testing.T) {
 		Password: "hashedpassword",
 	}
 
-	mockRepo.On("FindById", ctx, objectId.Hex()).
+	mockRepo.On("FindMember", ctx, objectId.Hex()).
 		Return(expectedUsers, nil)
 
 	result, err := service.FindById(ctx, objectId.Hex())
@@ -123,12 +198,12 @@ func TestUserService_FindById_Success(t *testing.T) {
 
 func TestUserService_FindById_Error(t *testing.T) {
 	mockRepo := new(MockUserRepo)
-	service := users.NewUserService(mockRepo)
+	service := newTestService(newTestApp(), mockRepo)
 	ctx := newTestContext()
 
 	objectId, _ := bson.ObjectIDFromHex("67f759abe02e4b2f3c2f69b6")
 
-	mockRepo.On("FindById", ctx, objectId.Hex()).
+	mockRepo.On("FindMember", ctx, objectId.Hex()).
 		Return(users.UserModel{}, errors.New("user not found"))
 
 	result, err := service.FindById(ctx, objectId.Hex())
@@ -138,3 +213,305 @@ func TestUserService_FindById_Error(t *testing.T) {
 
 	mockRepo.AssertExpectations(t)
 }
+
+func TestUserService_Delete_Success(t *testing.T) {
+	mockRepo := new(MockUserRepo)
+	testApp := newTestApp()
+	service := newTestService(testApp, mockRepo)
+	ctx := newTestContext()
+
+	events := make(chan users.UserStatusChanged, 1)
+	testApp.Bus.On(utils.UserStatusChangedEvent, func(payload any) {
+		events <- payload.(users.UserStatusChanged)
+	})
+
+	objectId, _ := bson.ObjectIDFromHex("67f759abe02e4b2f3c2f69b6")
+	mockRepo.On("FindById", ctx, objectId.Hex()).Return(users.UserModel{ID: objectId}, nil)
+	mockRepo.On("Delete", ctx, objectId.Hex(), "").Return(utils.StatusActive, nil)
+
+	err := service.Delete(ctx, objectId.Hex())
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+
+	select {
+	case event := <-events:
+		assert.Equal(t, utils.StatusActive, event.From)
+		assert.Equal(t, utils.StatusDeactivated, event.To)
+	case <-time.After(time.Second):
+		t.Fatal("status change event was not emitted")
+	}
+}
+
+func TestUserService_Restore_EmitsEvent(t *testing.T) {
+	mockRepo := new(MockUserRepo)
+	testApp := newTestApp()
+	service := newTestService(testApp, mockRepo)
+	ctx := newTestContext()
+
+	events := make(chan users.UserStatusChanged, 1)
+	testApp.Bus.On(utils.UserStatusChangedEvent, func(payload any) {
+		events <- payload.(users.UserStatusChanged)
+	})
+
+	objectId, _ := bson.ObjectIDFromHex("67f759abe02e4b2f3c2f69b6")
+	mockRepo.On("FindDeletedById", ctx, objectId.Hex()).Return(users.UserModel{ID: objectId}, nil)
+	mockRepo.On("Restore", ctx, objectId.Hex()).Return(utils.StatusSuspended, nil)
+
+	err := service.Restore(ctx, objectId.Hex())
+
+	assert.NoError(t, err)
+
+	select {
+	case event := <-events:
+		assert.Equal(t, utils.StatusDeactivated, event.From)
+		assert.Equal(t, utils.StatusSuspended, event.To)
+	case <-time.After(time.Second):
+		t.Fatal("status change event was not emitted")
+	}
+}
+
+func TestUserService_Restore_Error(t *testing.T) {
+	mockRepo := new(MockUserRepo)
+	service := newTestService(newTestApp(), mockRepo)
+	ctx := newTestContext()
+
+	objectId, _ := bson.ObjectIDFromHex("67f759abe02e4b2f3c2f69b6")
+	mockRepo.On("FindDeletedById", ctx, objectId.Hex()).Return(users.UserModel{ID: objectId}, nil)
+	mockRepo.On("Restore", ctx, objectId.Hex()).Return("", errors.New("data not found"))
+
+	err := service.Restore(ctx, objectId.Hex())
+
+	assert.Error(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUserService_Patch_OnlyChangedFields(t *testing.T) {
+	mockRepo := new(MockUserRepo)
+	service := newTestService(newTestApp(), mockRepo)
+	ctx := newTestContext()
+
+	objectId, _ := bson.ObjectIDFromHex("67f759abe02e4b2f3c2f69b6")
+	mockRepo.On("FindById", ctx, objectId.Hex()).
+		Return(users.UserModel{ID: objectId, Name: "John Doe", Email: "john@example.com", Password: "hashedpassword"}, nil)
+	mockRepo.On("Patch", ctx, objectId.Hex(), patch.Changes{Set: bson.M{"name": "Jane Doe"}, Unset: bson.M{}}).
+		Return(nil)
+
+	err := service.Patch(ctx, objectId.Hex(), patch.MergePatchContentType, []byte(`{"name":"Jane Doe"}`))
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUserService_ChangeStatus_EmitsEvent(t *testing.T) {
+	mockRepo := new(MockUserRepo)
+	testApp := newTestApp()
+	service := newTestService(testApp, mockRepo)
+	ctx := newTestContext()
+
+	events := make(chan users.UserStatusChanged, 1)
+	testApp.Bus.On(utils.UserStatusChangedEvent, func(payload any) {
+		events <- payload.(users.UserStatusChanged)
+	})
+
+	objectId, _ := bson.ObjectIDFromHex("67f759abe02e4b2f3c2f69b6")
+	mockRepo.On("FindById", ctx, objectId.Hex()).
+		Return(users.UserModel{ID: objectId}, nil)
+	mockRepo.On("Patch", ctx, objectId.Hex(), patch.Changes{Set: bson.M{"status": utils.StatusSuspended, "status_reason": "abuse"}, Unset: bson.M{}}).
+		Return(nil)
+
+	err := service.ChangeStatus(ctx, objectId.Hex(), &users.UserStatusModel{Status: utils.StatusSuspended, Reason: "abuse"})
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+
+	select {
+	case event := <-events:
+		assert.Equal(t, utils.StatusActive, event.From)
+		assert.Equal(t, utils.StatusSuspended, event.To)
+		assert.Equal(t, "abuse", event.Reason)
+	case <-time.After(time.Second):
+		t.Fatal("status change event was not emitted")
+	}
+}
+
+func TestUserService_ChangeStatus_InvalidTransition(t *testing.T) {
+	mockRepo := new(MockUserRepo)
+	service := newTestService(newTestApp(), mockRepo)
+	ctx := newTestContext()
+
+	objectId, _ := bson.ObjectIDFromHex("67f759abe02e4b2f3c2f69b6")
+	mockRepo.On("FindById", ctx, objectId.Hex()).
+		Return(users.UserModel{ID: objectId, Status: utils.StatusDeactivated}, nil)
+
+	err := service.ChangeStatus(ctx, objectId.Hex(), &users.UserStatusModel{Status: utils.StatusLocked})
+
+	assert.IsType(t, &utils.ConflictError{}, err)
+	mockRepo.AssertNotCalled(t, "Patch", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUserService_Patch_StaleVersion(t *testing.T) {
+	mockRepo := new(MockUserRepo)
+	service := newTestService(newTestApp(), mockRepo)
+	ctx := newTestContext()
+	utils.SetExpectedVersion(ctx, 2)
+
+	objectId, _ := bson.ObjectIDFromHex("67f759abe02e4b2f3c2f69b6")
+	mockRepo.On("FindById", ctx, objectId.Hex()).
+		Return(users.UserModel{ID: objectId, Name: "John Doe", Email: "john@example.com", Version: 3}, nil)
+
+	err := service.Patch(ctx, objectId.Hex(), patch.MergePatchContentType, []byte(`{"name":"Jane Doe"}`))
+
+	var preconditionErr *utils.PreconditionFailedError
+	assert.ErrorAs(t, err, &preconditionErr)
+	mockRepo.AssertNotCalled(t, "Patch", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUserService_Create_DuplicateEmail(t *testing.T) {
+	mockRepo := new(MockUserRepo)
+	service := newTestService(newTestApp(), mockRepo)
+	ctx := newTestContext()
+
+	mockRepo.
+		On("Create", ctx, mock.MatchedBy(func(user *entities.User) bool {
+			return user.Email == "john@example.com" && user.Password != "secret"
+		})).
+		Return(utils.NewConflict("email already exists"))
+
+	err := service.Create(ctx, &users.UserCreateModel{Email: "john@example.com", Name: "John", Password: "secret"})
+
+	assert.Equal(t, utils.NewConflict("email already exists"), err)
+	mockRepo.AssertNotCalled(t, "FindByEmail", mock.Anything, mock.Anything)
+	mockRepo.AssertExpectations(t)
+}
+
+// departmentPolicy lets holders of users:read:department read and update the users of their department
+var departmentPolicy = authz.Policy{
+	Name:       "same-department",
+	Effect:     authz.Allow,
+	Actions:    []string{"users:read", "users:update"},
+	Permission: "users:read:department",
+	Condition:  "principal.attributes.department == resource.attributes.department",
+}
+
+func TestUserService_Patch_PolicyOtherDepartment(t *testing.T) {
+	mockRepo := new(MockUserRepo)
+	testApp := newTestApp()
+	assert.NoError(t, testApp.Authz.Load([]authz.Policy{departmentPolicy}))
+	service := newTestService(testApp, mockRepo)
+	ctx := newTestContextWith([]interface{}{"users:read:department"}, map[string]interface{}{"department": "sales"})
+
+	objectId := bson.NewObjectID()
+	mockRepo.On("FindById", ctx, objectId.Hex()).
+		Return(users.UserModel{ID: objectId, Name: "John Doe", Attributes: map[string]interface{}{"department": "finance"}}, nil)
+
+	err := service.Patch(ctx, objectId.Hex(), patch.MergePatchContentType, []byte(`{"name":"Jane Doe"}`))
+
+	assert.Equal(t, utils.NewForbidden("access denied"), err)
+	mockRepo.AssertNotCalled(t, "Patch", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUserService_FindAll_PolicyFilter(t *testing.T) {
+	mockRepo := new(MockUserRepo)
+	testApp := newTestApp()
+	assert.NoError(t, testApp.Authz.Load([]authz.Policy{departmentPolicy}))
+	service := newTestService(testApp, mockRepo)
+	ctx := newTestContextWith([]interface{}{"users:read:department"}, map[string]interface{}{"department": "sales"})
+	filter := newPaginationFilter()
+
+	mockRepo.On("FindAll", ctx, filter).Return(query.Page[users.UserModelResponse]{}, nil)
+
+	_, err := service.FindAll(ctx, filter)
+
+	assert.NoError(t, err)
+	assert.Equal(t, bson.M{"attributes.department": "sales"}, filter.Filter)
+}
+
+// otherDepartmentPolicy keeps principals away from the users of other departments
+var otherDepartmentPolicy = authz.Policy{
+	Name:      "other-department",
+	Effect:    authz.Deny,
+	Actions:   []string{"users:*"},
+	Condition: "principal.attributes.department != resource.attributes.department",
+}
+
+func TestUserService_Delete_PolicyOtherDepartment(t *testing.T) {
+	mockRepo := new(MockUserRepo)
+	testApp := newTestApp()
+	assert.NoError(t, testApp.Authz.Load([]authz.Policy{otherDepartmentPolicy}))
+	service := newTestService(testApp, mockRepo)
+	ctx := newTestContextWith([]interface{}{"users:*"}, map[string]interface{}{"department": "sales"})
+
+	objectId := bson.NewObjectID()
+	other := users.UserModel{ID: objectId, Attributes: map[string]interface{}{"department": "finance"}}
+	mockRepo.On("FindById", ctx, objectId.Hex()).Return(other, nil)
+	mockRepo.On("FindDeletedById", ctx, objectId.Hex()).Return(other, nil)
+
+	assert.Equal(t, utils.NewForbidden("access denied"), service.Delete(ctx, objectId.Hex()))
+	assert.Equal(t, utils.NewForbidden("access denied"), service.Restore(ctx, objectId.Hex()))
+	assert.Equal(t, utils.NewForbidden("access denied"), service.ChangeStatus(ctx, objectId.Hex(), &users.UserStatusModel{Status: utils.StatusSuspended}))
+	mockRepo.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "Restore", mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "Patch", mock.Anything, mock.Anything, mock.Anything)
+
+	// The trash only lists the users of the department
+	filter := newPaginationFilter()
+	mockRepo.On("FindDeleted", ctx, filter).Return(query.Page[users.UserTrashModel]{}, nil)
+	_, err := service.FindDeleted(ctx, filter)
+	assert.NoError(t, err)
+	assert.Equal(t, bson.M{"$nor": bson.A{bson.M{"attributes.department": bson.M{"$ne": "sales"}}}}, filter.Filter)
+}
+
+func TestUserService_FindAll_WithoutGrant(t *testing.T) {
+	mockRepo := new(MockUserRepo)
+	service := newTestService(newTestApp(), mockRepo)
+	ctx := newTestContextWith([]interface{}{"roles:read"}, nil)
+
+	_, err := service.FindAll(ctx, newPaginationFilter())
+
+	assert.Equal(t, utils.NewForbidden("access denied"), err)
+	mockRepo.AssertNotCalled(t, "FindAll", mock.Anything, mock.Anything)
+}
+
+func TestUserQuerySchema_StatusFilter(t *testing.T) {
+	q, err := query.Parse(url.Values{"status": {utils.StatusSuspended}}, users.UserQuerySchema)
+
+	assert.NoError(t, err)
+	assert.Equal(t, bson.M{"$eq": utils.StatusSuspended}, q.Filter["status"])
+}
+
+func TestUserModel_TokenPayload(t *testing.T) {
+	user := users.UserModel{
+		ID:    bson.NewObjectID(),
+		Email: "john@example.com",
+		Name:  "John",
+		RolesData: []roles.RoleModel{
+			{Permissions: []string{"users:read"}},
+			{Permissions: []string{"users:read", "users:update"}},
+		},
+	}
+
+	payload := user.TokenPayload(map[string]interface{}{"locale": "en"})
+
+	assert.Equal(t, user.ID.Hex(), payload["id"])
+	assert.Equal(t, "john@example.com", payload["email"])
+	assert.Equal(t, []string{"users:read", "users:update"}, payload["permission"])
+	assert.Equal(t, map[string]interface{}{"locale": "en"}, payload["attributes"])
+}
+
+func TestUserModel_GrantsExpireAt(t *testing.T) {
+	now := time.Now()
+	past, soon, later := now.Add(-time.Hour), now.Add(time.Hour), now.Add(2*time.Hour)
+
+	user := users.UserModel{}
+	assert.Nil(t, user.GrantsExpireAt())
+
+	// Ended and upcoming windows carry no permissions yet
+	user.RoleAssignments = []entities.RoleAssignment{
+		{ExpiresAt: &past},
+		{StartsAt: &soon, ExpiresAt: &soon},
+		{ExpiresAt: &later},
+		{StartsAt: &past},
+	}
+	assert.Equal(t, &later, user.GrantsExpireAt())
+}
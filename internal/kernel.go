@@ -1,13 +1,27 @@
 package internal
 
 import (
+	"context"
+	"time"
+
 	"github.com/HasanNugroho/starter-golang/config"
 	"github.com/HasanNugroho/starter-golang/internal/app"
+	"github.com/HasanNugroho/starter-golang/internal/core/attributes"
 	"github.com/HasanNugroho/starter-golang/internal/core/auth"
+	"github.com/HasanNugroho/starter-golang/internal/core/files"
+	"github.com/HasanNugroho/starter-golang/internal/core/groups"
+	"github.com/HasanNugroho/starter-golang/internal/core/me"
+	"github.com/HasanNugroho/starter-golang/internal/core/organizations"
+	"github.com/HasanNugroho/starter-golang/internal/core/permissions"
+	"github.com/HasanNugroho/starter-golang/internal/core/policies"
+	"github.com/HasanNugroho/starter-golang/internal/core/privacy"
 	"github.com/HasanNugroho/starter-golang/internal/core/roles"
 	"github.com/HasanNugroho/starter-golang/internal/core/users"
+	"github.com/HasanNugroho/starter-golang/internal/shared/authz"
 	"github.com/HasanNugroho/starter-golang/internal/shared/middleware"
 	"github.com/HasanNugroho/starter-golang/internal/shared/modules"
+	"github.com/HasanNugroho/starter-golang/internal/shared/permission"
+	"github.com/HasanNugroho/starter-golang/internal/shared/storage"
 	"github.com/labstack/echo/v4"
 	"github.com/redis/go-redis/v9"
 	"github.com/rs/zerolog/log"
@@ -21,6 +35,34 @@ var (
 )
 
 func AppsInit(router *echo.Echo) *app.Apps {
+	app := Bootstrap(router)
+
+	// Create the collections, indexes and validators declared by the modules.
+	// Services rely on the unique indexes to reject duplicates, so the API
+	// does not start without them.
+	if app.DB != nil && !app.Config.DB.SkipSchemaApply {
+		if _, err := app.Schema.Apply(context.Background(), app.DB, app.Log); err != nil {
+			app.Log.Fatal().Msg("❌ Failed to apply collection schema: " + err.Error())
+		}
+	}
+
+	// Seed the system roles and the bootstrap admin, routes and permission
+	// checks rely on them
+	if app.DB != nil {
+		if err := app.Seeds.Run(context.Background(), app.Log); err != nil {
+			app.Log.Fatal().Msg("❌ Failed to seed: " + err.Error())
+		}
+	}
+
+	// Start background jobs registered by the modules
+	app.Scheduler.Start(context.Background())
+
+	return app
+}
+
+// Bootstrap loads the configuration, connects the services and registers
+// the modules without starting any background work
+func Bootstrap(router *echo.Echo) *app.Apps {
 	// Initialize configuration
 	appConfig, err := config.LoadConfig()
 	if err != nil {
@@ -31,12 +73,18 @@ func AppsInit(router *echo.Echo) *app.Apps {
 	logApps := config.InitLogger(appConfig)
 
 	// Initialize Database if enabled
+	transactions := false
 	if appConfig.DB.Enabled {
 		mongodb, err = appConfig.DB.InitMongo()
 		if err != nil {
 			logApps.Fatal().Msg(err.Error())
 			panic(1)
 		}
+
+		transactions = config.SupportsTransactions(context.Background(), mongodb)
+		if !transactions {
+			logApps.Warn().Msg("MongoDB is not a replica set, roles in use can not be deleted")
+		}
 	}
 
 	// Initialize Redis if enabled
@@ -58,13 +106,35 @@ func AppsInit(router *echo.Echo) *app.Apps {
 		}
 	}
 
+	// Initialize file storage
+	fileStorage, err := storage.New(appConfig.Storage)
+	if err != nil {
+		logApps.Fatal().Msg(err.Error())
+		panic(1)
+	}
+
+	urlExpiration := time.Minute * time.Duration(appConfig.Storage.URLExpiration)
+	if urlExpiration <= 0 {
+		urlExpiration = time.Hour
+	}
+
 	app := &app.Apps{
-		Config: appConfig,
-		Log:    logApps,
-		DB:     mongodb,
-		Redis:  redisClient,
-		Bus:    modules.EventNew(),
-		Router: router,
+		Config:       appConfig,
+		Log:          logApps,
+		DB:           mongodb,
+		Redis:        redisClient,
+		Bus:          modules.EventNew(),
+		Scheduler:    modules.NewScheduler(logApps),
+		Mailer:       modules.NewMailer(appConfig.Mail, logApps),
+		Storage:      fileStorage,
+		Files:        storage.NewURLSigner(fileStorage, appConfig.Security.FileURLKey(), "/api/v1/files", urlExpiration),
+		PersonalData: modules.NewPersonalDataRegistry(),
+		Schema:       modules.NewSchemaRegistry(),
+		Seeds:        modules.NewSeedRegistry(),
+		Authz:        authz.NewEngine(),
+		Permissions:  permission.NewRegistry(),
+		Transactions: transactions,
+		Router:       router,
 	}
 
 	app.Router.Use(middleware.SetCORS(app.Config), middleware.SecurityMiddleware(app.Config))
@@ -82,6 +152,23 @@ func InitModules(app *app.Apps) {
 	app.RegisterFeature(users.NewUserModule(app))
 	app.RegisterFeature(auth.NewAuthModule(app))
 	app.RegisterFeature(roles.NewRoleModule(app))
+	app.RegisterFeature(permissions.NewPermissionModule(app))
+	app.RegisterFeature(groups.NewGroupModule(app))
+	app.RegisterFeature(organizations.NewOrganizationModule(app))
+	app.RegisterFeature(attributes.NewAttributeModule(app))
+	app.RegisterFeature(policies.NewPolicyModule(app))
+	app.RegisterFeature(me.NewMeModule(app))
+	app.RegisterFeature(files.NewFileModule(app))
+	app.RegisterFeature(privacy.NewPrivacyModule(app))
 
-	app.InitFeatures()
+	if err := app.InitFeatures(); err != nil {
+		app.Log.Fatal().Msg("❌ " + err.Error())
+		panic(1)
+	}
+
+	// Every permission a route requires must have been declared by a feature
+	if err := app.Permissions.Verify(middleware.RequiredPermissions()); err != nil {
+		app.Log.Fatal().Msg("❌ " + err.Error())
+		panic(1)
+	}
 }
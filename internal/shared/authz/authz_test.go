@@ -0,0 +1,200 @@
+package authz_test
+
+import (
+	"testing"
+
+	"github.com/HasanNugroho/starter-golang/internal/shared/authz"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+var (
+	alice  = authz.NewPrincipal("u1", []string{"profile:edit"}, map[string]interface{}{"department": "sales", "level": 3})
+	userID = bson.NewObjectID()
+	target = authz.Resource{Type: "users", ID: userID.Hex(), Fields: map[string]interface{}{
+		"status":     "active",
+		"roles":      []string{"r1", "r2"},
+		"attributes": map[string]interface{}{"department": "sales", "level": int64(2)},
+	}}
+)
+
+func TestCompile_Errors(t *testing.T) {
+	for _, source := range []string{
+		"principal.id ==",
+		"user.id == resource.id",
+		"principal == resource.id",
+		`resource.status == "active`,
+		"resource.status in [1, 2",
+		"(principal.id == resource.id",
+		"resource.id == principal.id principal.id",
+		"resource.status # 1",
+	} {
+		_, err := authz.Compile(source)
+		assert.Error(t, err, source)
+	}
+}
+
+func TestExpression_Eval(t *testing.T) {
+	tests := []struct {
+		condition string
+		want      bool
+	}{
+		{"", true},
+		{"principal.attributes.department == resource.attributes.department", true},
+		{"principal.id == resource.id", false},
+		{`resource.status in ["active", "pending"]`, true},
+		{`resource.status != "active" || resource.attributes.level < principal.attributes.level`, true},
+		{`!(resource.status == "active") && true`, false},
+		{"resource.attributes.level >= 2 && resource.attributes.level <= 2.5", true},
+		{`"r2" in resource.roles`, true},
+		{"resource.attributes.missing == null", true},
+		{`resource.attributes.level > "1"`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.condition, func(t *testing.T) {
+			expression, err := authz.Compile(tt.condition)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, expression.Eval(alice, target))
+		})
+	}
+}
+
+func TestExpression_Filter(t *testing.T) {
+	tests := []struct {
+		condition string
+		want      bson.M
+	}{
+		{"principal.attributes.department == resource.attributes.department", bson.M{"attributes.department": "sales"}},
+		{"resource.id == principal.id", bson.M{"_id": "u1"}},
+		{"principal.attributes.level > resource.attributes.level", bson.M{"attributes.level": bson.M{"$lt": 3.0}}},
+		{`resource.status in ["active", "pending"]`, bson.M{"status": bson.M{"$in": []interface{}{"active", "pending"}}}},
+		{`"admin" in resource.roles`, bson.M{"roles": "admin"}},
+		{`!(resource.status == "locked")`, bson.M{"$nor": bson.A{bson.M{"status": "locked"}}}},
+		{`principal.attributes.department == "sales" || resource.status == "active"`, bson.M{}},
+		{`principal.attributes.department == "hr" && resource.status == "active"`, authz.Nothing()},
+		{`resource.status == "active" && principal.id == "u1"`, bson.M{"status": "active"}},
+		{`resource.status == "active" || resource.attributes.level == 1`, bson.M{"$or": bson.A{
+			bson.M{"status": "active"},
+			bson.M{"attributes.level": 1.0},
+		}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.condition, func(t *testing.T) {
+			expression, err := authz.Compile(tt.condition)
+			assert.NoError(t, err)
+
+			filter, err := expression.Filter(alice)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, filter)
+		})
+	}
+
+	expression, _ := authz.Compile("resource.id == resource.attributes.owner")
+	_, err := expression.Filter(alice)
+	assert.Error(t, err)
+}
+
+func TestExpression_FilterConvertsIDs(t *testing.T) {
+	principal := authz.NewPrincipal(userID.Hex(), nil, nil)
+	expression, _ := authz.Compile("resource.id == principal.id")
+
+	filter, err := expression.Filter(principal)
+
+	assert.NoError(t, err)
+	assert.Equal(t, bson.M{"_id": userID}, filter)
+}
+
+func newEngine(t *testing.T, policies ...authz.Policy) *authz.Engine {
+	engine := authz.NewEngine()
+	assert.NoError(t, engine.Load(policies))
+	return engine
+}
+
+func TestEngine_Can(t *testing.T) {
+	engine := newEngine(t,
+		authz.Policy{Name: "own-profile", Effect: authz.Allow, Actions: []string{"users:update"}, Permission: "profile:edit", Condition: "principal.id == resource.id"},
+		authz.Policy{Name: "locked", Effect: authz.Deny, Actions: []string{"users:*"}, Condition: `resource.status == "locked"`},
+	)
+
+	own := authz.Resource{Type: "users", ID: "u1", Fields: map[string]interface{}{"status": "active"}}
+	other := authz.Resource{Type: "users", ID: "u2", Fields: map[string]interface{}{"status": "active"}}
+	locked := authz.Resource{Type: "users", ID: "u3", Fields: map[string]interface{}{"status": "locked"}}
+	admin := authz.NewPrincipal("u9", []string{"users:*"}, nil)
+	stranger := authz.NewPrincipal("u1", []string{"users:read"}, nil)
+
+	assert.True(t, engine.Can(alice, "users:update", own))
+	assert.False(t, engine.Can(alice, "users:update", other))
+	assert.False(t, engine.Can(alice, "users:read", own))
+	assert.False(t, engine.Can(stranger, "users:update", own), "the policy requires profile:edit")
+
+	assert.True(t, engine.Can(admin, "users:update", other))
+	assert.False(t, engine.Can(admin, "users:update", locked), "deny wins over permissions")
+
+	assert.True(t, engine.Permits(alice, "users:update"))
+	assert.False(t, engine.Permits(alice, "users:delete"))
+}
+
+func TestEngine_Filter(t *testing.T) {
+	engine := newEngine(t,
+		authz.Policy{Name: "department", Effect: authz.Allow, Actions: []string{"users:read"}, Condition: "resource.attributes.department == principal.attributes.department"},
+		authz.Policy{Name: "own", Effect: authz.Allow, Actions: []string{"users:read"}, Condition: "resource.id == principal.id"},
+		authz.Policy{Name: "locked", Effect: authz.Deny, Actions: []string{"users:read"}, Condition: `resource.status == "locked"`},
+	)
+
+	filter, ok := engine.Filter(alice, "users:read")
+	assert.True(t, ok)
+	assert.Equal(t, bson.M{"$and": bson.A{
+		bson.M{"$or": bson.A{bson.M{"attributes.department": "sales"}, bson.M{"_id": "u1"}}},
+		bson.M{"$nor": bson.A{bson.M{"status": "locked"}}},
+	}}, filter)
+
+	filter, ok = engine.Filter(authz.NewPrincipal("u9", []string{"users:read"}, nil), "users:read")
+	assert.True(t, ok)
+	assert.Equal(t, bson.M{"$nor": bson.A{bson.M{"status": "locked"}}}, filter)
+
+	_, ok = engine.Filter(alice, "roles:read")
+	assert.False(t, ok)
+}
+
+func TestEngine_LoadRejectsInvalid(t *testing.T) {
+	engine := newEngine(t, authz.Policy{Name: "ok", Effect: authz.Allow, Actions: []string{"users:read"}})
+
+	err := engine.Load([]authz.Policy{{Name: "broken", Effect: authz.Allow, Actions: []string{"users:read"}, Condition: "resource.id =="}})
+
+	assert.Error(t, err)
+	assert.Len(t, engine.Policies(), 1)
+}
+
+func TestEngine_AllowedCachesPerRequest(t *testing.T) {
+	engine := newEngine(t, authz.Policy{Name: "own", Effect: authz.Allow, Actions: []string{"users:update"}, Condition: "principal.id == resource.id"})
+	ctx := echo.New().NewContext(nil, nil)
+	ctx.Set("claims", map[string]interface{}{})
+
+	resource := authz.Resource{Type: "users", ID: "u1"}
+	assert.False(t, engine.Allowed(ctx, "users:update", resource))
+
+	// Loading a policy granting everything does not change decisions already made
+	assert.NoError(t, engine.Load([]authz.Policy{{Name: "all", Effect: authz.Allow, Actions: []string{"users:update"}}}))
+	assert.False(t, engine.Allowed(ctx, "users:update", resource))
+	assert.True(t, engine.Allowed(ctx, "users:update", authz.Resource{Type: "users", ID: "u2"}))
+}
+
+func TestCurrentPrincipal_GrantedPermissions(t *testing.T) {
+	ctx := echo.New().NewContext(nil, nil)
+	ctx.Set("claims", jwt.MapClaims{"data": map[string]interface{}{"id": "u1", "permission": []interface{}{"users:read"}}})
+
+	assert.Equal(t, []string{"users:read"}, authz.CurrentPrincipal(ctx).Permissions)
+
+	// Permissions resolved by the middleware win over the token, which may not carry any
+	ctx = echo.New().NewContext(nil, nil)
+	ctx.Set("claims", jwt.MapClaims{"data": map[string]interface{}{"id": "u1"}})
+	ctx.Set("granted_permissions", []string{"users:update"})
+
+	principal := authz.CurrentPrincipal(ctx)
+	assert.Equal(t, "u1", principal.ID)
+	assert.Equal(t, []string{"users:update"}, principal.Permissions)
+}
@@ -0,0 +1,263 @@
+// Package authz decides whether a principal may perform an action on a
+// resource. Permissions granted through roles allow an action on every
+// resource, policies grant or deny it on the resources matching a condition
+// over principal and resource attributes.
+package authz
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/HasanNugroho/starter-golang/internal/shared/permission"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+const (
+	// Allow grants the action when the condition holds
+	Allow = "allow"
+	// Deny refuses the action when the condition holds, even to permission holders
+	Deny = "deny"
+)
+
+// Policy grants or denies actions on the resources its condition holds for
+type Policy struct {
+	Name string `json:"name" bson:"name"`
+	// Effect is Allow or Deny
+	Effect string `json:"effect" bson:"effect"`
+	// Actions are permission patterns, e.g. users:update or users:*
+	Actions []string `json:"actions" bson:"actions"`
+	// Permission, when set, limits the policy to principals holding it
+	Permission string `json:"permission,omitempty" bson:"permission,omitempty"`
+	// Condition is an expression over principal and resource, see Compile
+	Condition string `json:"condition" bson:"condition"`
+}
+
+// Principal is the authenticated subject of a request
+type Principal struct {
+	ID          string
+	Permissions []string
+	Attributes  map[string]interface{}
+
+	matcher *permission.Matcher
+}
+
+// NewPrincipal prepares a principal, compiling its permissions once
+func NewPrincipal(id string, permissions []string, attributes map[string]interface{}) Principal {
+	return Principal{ID: id, Permissions: permissions, Attributes: attributes, matcher: permission.Compile(permissions)}
+}
+
+func (p Principal) allows(action string) bool {
+	if p.matcher == nil {
+		return permission.Compile(p.Permissions).Allows(action)
+	}
+	return p.matcher.Allows(action)
+}
+
+func (p Principal) fields() map[string]interface{} {
+	return map[string]interface{}{
+		"id":          p.ID,
+		"permissions": normalize(p.Permissions),
+		"attributes":  p.Attributes,
+	}
+}
+
+// Resource is the object of an action. Fields mirror the stored document, so
+// resource.attributes.department reads Fields["attributes"]["department"].
+type Resource struct {
+	Type   string
+	ID     string
+	Fields map[string]interface{}
+}
+
+func (r Resource) fields() map[string]interface{} {
+	fields := make(map[string]interface{}, len(r.Fields)+1)
+	for key, value := range r.Fields {
+		fields[key] = value
+	}
+	fields["id"] = r.ID
+	return fields
+}
+
+type compiledPolicy struct {
+	Policy
+	condition *Expression
+}
+
+func (p compiledPolicy) applies(principal Principal, action string) bool {
+	if p.Permission != "" && !principal.allows(p.Permission) {
+		return false
+	}
+	for _, pattern := range p.Actions {
+		if permission.Match(pattern, action) {
+			return true
+		}
+	}
+	return false
+}
+
+// Engine evaluates policies, it is safe for concurrent use
+type Engine struct {
+	mu       sync.RWMutex
+	policies []compiledPolicy
+}
+
+func NewEngine() *Engine {
+	return &Engine{}
+}
+
+// Load replaces the policies. Nothing changes when one of them is invalid.
+func (e *Engine) Load(policies []Policy) error {
+	compiled := make([]compiledPolicy, 0, len(policies))
+	for _, policy := range policies {
+		if err := Validate(policy); err != nil {
+			return err
+		}
+		condition, _ := Compile(policy.Condition)
+		compiled = append(compiled, compiledPolicy{Policy: policy, condition: condition})
+	}
+
+	e.mu.Lock()
+	e.policies = compiled
+	e.mu.Unlock()
+	return nil
+}
+
+// Policies returns the loaded policies
+func (e *Engine) Policies() []Policy {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	policies := make([]Policy, len(e.policies))
+	for i, policy := range e.policies {
+		policies[i] = policy.Policy
+	}
+	return policies
+}
+
+// Validate checks the effect, actions and condition of a policy
+func Validate(policy Policy) error {
+	if policy.Effect != Allow && policy.Effect != Deny {
+		return fmt.Errorf("policy %s: effect must be %s or %s", policy.Name, Allow, Deny)
+	}
+	if len(policy.Actions) == 0 {
+		return fmt.Errorf("policy %s: at least one action is required", policy.Name)
+	}
+	if _, err := Compile(policy.Condition); err != nil {
+		return fmt.Errorf("policy %s: %w", policy.Name, err)
+	}
+	return nil
+}
+
+func (e *Engine) applicable(principal Principal, action string) []compiledPolicy {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	var policies []compiledPolicy
+	for _, policy := range e.policies {
+		if policy.applies(principal, action) {
+			policies = append(policies, policy)
+		}
+	}
+	return policies
+}
+
+// Permits reports whether the principal may perform the action on some
+// resources, through a permission or an allow policy. Routes use it to turn
+// away principals early, the resource is checked with Can.
+func (e *Engine) Permits(principal Principal, action string) bool {
+	if principal.allows(action) {
+		return true
+	}
+	for _, policy := range e.applicable(principal, action) {
+		if policy.Effect == Allow {
+			return true
+		}
+	}
+	return false
+}
+
+// Can reports whether the principal may perform the action on the resource.
+// A matching deny policy always wins, then a permission covering the action or
+// a matching allow policy grants it.
+func (e *Engine) Can(principal Principal, action string, resource Resource) bool {
+	policies := e.applicable(principal, action)
+	for _, policy := range policies {
+		if policy.Effect == Deny && policy.condition.Eval(principal, resource) {
+			return false
+		}
+	}
+
+	if principal.allows(action) {
+		return true
+	}
+
+	for _, policy := range policies {
+		if policy.Effect == Allow && policy.condition.Eval(principal, resource) {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter returns the MongoDB filter selecting the resources the principal may
+// perform the action on, for list queries. ok is false when the principal may
+// not perform the action on any resource.
+func (e *Engine) Filter(principal Principal, action string) (filter bson.M, ok bool) {
+	policies := e.applicable(principal, action)
+
+	var allowed bson.A
+	if !principal.allows(action) {
+		granted := false
+		for _, policy := range policies {
+			if policy.Effect != Allow {
+				continue
+			}
+			granted = true
+			// A condition that can not be translated grants nothing in lists
+			condition, err := policy.condition.Filter(principal)
+			if err != nil {
+				continue
+			}
+			allowed = append(allowed, condition)
+		}
+		if !granted {
+			return nil, false
+		}
+		if len(allowed) == 0 {
+			return Nothing(), true
+		}
+	}
+
+	var denied bson.A
+	for _, policy := range policies {
+		if policy.Effect != Deny {
+			continue
+		}
+		condition, err := policy.condition.Filter(principal)
+		if err != nil {
+			// Without a filter the denied resources can not be left out
+			return Nothing(), true
+		}
+		denied = append(denied, condition)
+	}
+
+	clauses := bson.A{}
+	switch len(allowed) {
+	case 0:
+	case 1:
+		clauses = append(clauses, allowed[0])
+	default:
+		clauses = append(clauses, bson.M{"$or": allowed})
+	}
+	if len(denied) > 0 {
+		clauses = append(clauses, bson.M{"$nor": denied})
+	}
+
+	switch len(clauses) {
+	case 0:
+		return bson.M{}, true
+	case 1:
+		return clauses[0].(bson.M), true
+	}
+	return bson.M{"$and": clauses}, true
+}
@@ -0,0 +1,223 @@
+package authz
+
+import (
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Nothing is a filter no document matches
+func Nothing() bson.M {
+	return bson.M{"_id": bson.M{"$exists": false}}
+}
+
+// term is a translated condition: a filter, or a constant when the condition
+// does not depend on the resource
+type term struct {
+	always bool
+	never  bool
+	filter bson.M
+}
+
+// Filter translates the condition into a MongoDB filter selecting the resources
+// it holds for, with the values of the principal filled in. Resource paths map
+// to document fields, resource.id to _id. Conditions comparing two resource
+// fields can not be translated.
+func (e *Expression) Filter(principal Principal) (bson.M, error) {
+	result, err := translate(e.root, env{principal: principal})
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case result.always:
+		return bson.M{}, nil
+	case result.never:
+		return Nothing(), nil
+	}
+	return result.filter, nil
+}
+
+func constant(value bool) term {
+	return term{always: value, never: !value}
+}
+
+func translate(n node, env env) (term, error) {
+	switch n := n.(type) {
+	case not:
+		inner, err := translate(n.operand, env)
+		if err != nil || inner.filter == nil {
+			return term{always: inner.never, never: inner.always}, err
+		}
+		return term{filter: bson.M{"$nor": bson.A{inner.filter}}}, nil
+
+	case logical:
+		left, err := translate(n.left, env)
+		if err != nil {
+			return term{}, err
+		}
+		right, err := translate(n.right, env)
+		if err != nil {
+			return term{}, err
+		}
+		return combine(n.operator, left, right), nil
+
+	case comparison:
+		return translateComparison(n, env)
+
+	case path:
+		if n.root == "resource" {
+			return term{filter: bson.M{field(n): true}}, nil
+		}
+	}
+
+	if references(n, "resource") {
+		return term{}, fmt.Errorf("unsupported resource expression")
+	}
+	value, err := n.eval(env)
+	if err != nil {
+		return term{}, err
+	}
+	result, _ := value.(bool)
+	return constant(result), nil
+}
+
+func combine(operator string, left term, right term) term {
+	if operator == "&&" {
+		if left.never || right.never {
+			return constant(false)
+		}
+		if left.always {
+			return right
+		}
+		if right.always {
+			return left
+		}
+		return term{filter: bson.M{"$and": bson.A{left.filter, right.filter}}}
+	}
+
+	if left.always || right.always {
+		return constant(true)
+	}
+	if left.never {
+		return right
+	}
+	if right.never {
+		return left
+	}
+	return term{filter: bson.M{"$or": bson.A{left.filter, right.filter}}}
+}
+
+// flipped is the operator with its operands swapped
+var flipped = map[string]string{"==": "==", "!=": "!=", "<": ">", "<=": ">=", ">": "<", ">=": "<="}
+
+func translateComparison(c comparison, env env) (term, error) {
+	leftResource := references(c.left, "resource")
+	rightResource := references(c.right, "resource")
+
+	switch {
+	case !leftResource && !rightResource:
+		return constant(compare(c.operator, mustEval(c.left, env), mustEval(c.right, env))), nil
+
+	case leftResource && rightResource:
+		return term{}, fmt.Errorf("comparing two resource fields can not be filtered")
+	}
+
+	operator, resourceSide, valueSide := c.operator, c.left, c.right
+	if rightResource {
+		resourceSide, valueSide = c.right, c.left
+		if operator == "in" {
+			// value in resource.list matches documents whose array holds the value
+			p, ok := resourceSide.(path)
+			if !ok {
+				return term{}, fmt.Errorf("unsupported resource expression")
+			}
+			return term{filter: bson.M{field(p): documentValue(p, mustEval(valueSide, env))}}, nil
+		}
+		operator = flipped[operator]
+	}
+
+	p, ok := resourceSide.(path)
+	if !ok {
+		return term{}, fmt.Errorf("unsupported resource expression")
+	}
+	value := mustEval(valueSide, env)
+
+	switch operator {
+	case "==":
+		return term{filter: bson.M{field(p): documentValue(p, value)}}, nil
+	case "!=":
+		return term{filter: bson.M{field(p): bson.M{"$ne": documentValue(p, value)}}}, nil
+	case "in":
+		items, ok := value.([]interface{})
+		if !ok {
+			return constant(false), nil
+		}
+		return term{filter: bson.M{field(p): bson.M{"$in": documentValue(p, items)}}}, nil
+	}
+
+	// Ordering only applies to numbers and strings, as in Eval
+	switch value.(type) {
+	case float64, string:
+	default:
+		return constant(false), nil
+	}
+	operators := map[string]string{"<": "$lt", "<=": "$lte", ">": "$gt", ">=": "$gte"}
+	return term{filter: bson.M{field(p): bson.M{operators[operator]: value}}}, nil
+}
+
+func mustEval(n node, env env) interface{} {
+	value, _ := n.eval(env)
+	return value
+}
+
+// references reports whether the node reads a path under the root
+func references(n node, root string) bool {
+	switch n := n.(type) {
+	case path:
+		return n.root == root
+	case list:
+		for _, item := range n.items {
+			if references(item, root) {
+				return true
+			}
+		}
+	case not:
+		return references(n.operand, root)
+	case logical:
+		return references(n.left, root) || references(n.right, root)
+	case comparison:
+		return references(n.left, root) || references(n.right, root)
+	}
+	return false
+}
+
+// field is the document field of a resource path
+func field(p path) string {
+	if len(p.segments) == 1 && p.segments[0] == "id" {
+		return "_id"
+	}
+	return strings.Join(p.segments, ".")
+}
+
+// documentValue converts ids compared with _id back to object ids
+func documentValue(p path, value interface{}) interface{} {
+	if field(p) != "_id" {
+		return value
+	}
+
+	switch v := value.(type) {
+	case string:
+		if id, err := bson.ObjectIDFromHex(v); err == nil {
+			return id
+		}
+	case []interface{}:
+		ids := make(bson.A, len(v))
+		for i, item := range v {
+			ids[i] = documentValue(p, item)
+		}
+		return ids
+	}
+	return value
+}
@@ -0,0 +1,223 @@
+package authz
+
+import (
+	"encoding/json"
+	"reflect"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type node interface {
+	eval(env env) (interface{}, error)
+}
+
+type literal struct {
+	value interface{}
+}
+
+func (l literal) eval(env) (interface{}, error) {
+	return l.value, nil
+}
+
+type list struct {
+	items []node
+}
+
+func (l list) eval(env env) (interface{}, error) {
+	values := make([]interface{}, 0, len(l.items))
+	for _, item := range l.items {
+		value, err := item.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+type path struct {
+	root     string
+	segments []string
+}
+
+func (p path) eval(env env) (interface{}, error) {
+	if p.root == "principal" {
+		return lookup(env.principal.fields(), p.segments), nil
+	}
+	return lookup(env.resource.fields(), p.segments), nil
+}
+
+// lookup walks nested documents, a missing field is null
+func lookup(document map[string]interface{}, segments []string) interface{} {
+	var current interface{} = document
+	for _, segment := range segments {
+		switch value := current.(type) {
+		case map[string]interface{}:
+			current = value[segment]
+		case bson.M:
+			current = value[segment]
+		case bson.D:
+			current = nil
+			for _, element := range value {
+				if element.Key == segment {
+					current = element.Value
+				}
+			}
+		default:
+			return nil
+		}
+	}
+	return normalize(current)
+}
+
+type not struct {
+	operand node
+}
+
+func (n not) eval(env env) (interface{}, error) {
+	value, err := n.operand.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	result, _ := value.(bool)
+	return !result, nil
+}
+
+type logical struct {
+	operator string
+	left     node
+	right    node
+}
+
+func (l logical) eval(env env) (interface{}, error) {
+	left, err := l.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	result, _ := left.(bool)
+
+	// Short circuit like the filter translation does
+	if l.operator == "&&" && !result || l.operator == "||" && result {
+		return result, nil
+	}
+
+	right, err := l.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	result, _ = right.(bool)
+	return result, nil
+}
+
+type comparison struct {
+	operator string
+	left     node
+	right    node
+}
+
+func (c comparison) eval(env env) (interface{}, error) {
+	left, err := c.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	right, err := c.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	return compare(c.operator, left, right), nil
+}
+
+func compare(operator string, left interface{}, right interface{}) bool {
+	switch operator {
+	case "==":
+		return equal(left, right)
+	case "!=":
+		return !equal(left, right)
+	case "in":
+		items, ok := right.([]interface{})
+		if !ok {
+			return false
+		}
+		for _, item := range items {
+			if equal(left, item) {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Ordering only applies to two numbers or two strings
+	switch l := left.(type) {
+	case float64:
+		r, ok := right.(float64)
+		return ok && ordered(operator, l < r, l == r)
+	case string:
+		r, ok := right.(string)
+		return ok && ordered(operator, l < r, l == r)
+	}
+	return false
+}
+
+func ordered(operator string, less bool, same bool) bool {
+	switch operator {
+	case "<":
+		return less
+	case "<=":
+		return less || same
+	case ">":
+		return !less && !same
+	case ">=":
+		return !less
+	}
+	return false
+}
+
+func equal(left interface{}, right interface{}) bool {
+	return reflect.DeepEqual(left, right)
+}
+
+// normalize brings stored and decoded values to the types literals use: numbers
+// are float64, ids hex strings and dates RFC 3339 strings
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case int:
+		return float64(v)
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case float32:
+		return float64(v)
+	case json.Number:
+		number, _ := v.Float64()
+		return number
+	case bson.ObjectID:
+		return v.Hex()
+	case bson.DateTime:
+		return v.Time().UTC().Format(time.RFC3339Nano)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	case bson.A:
+		return normalize([]interface{}(v))
+	case []string:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = item
+		}
+		return items
+	case []bson.ObjectID:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = item.Hex()
+		}
+		return items
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = normalize(item)
+		}
+		return items
+	}
+	return value
+}
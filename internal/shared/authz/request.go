@@ -0,0 +1,88 @@
+package authz
+
+import (
+	"github.com/HasanNugroho/starter-golang/internal/shared/permission"
+	"github.com/HasanNugroho/starter-golang/internal/shared/tenant"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+const (
+	principalKey = "authz.principal"
+	decisionsKey = "authz.decisions"
+)
+
+// CurrentPrincipal builds the principal of the request from its token claims
+// and the membership of the active tenant, once per request
+func CurrentPrincipal(ctx echo.Context) Principal {
+	if principal, ok := ctx.Get(principalKey).(Principal); ok {
+		return principal
+	}
+
+	claims, _ := ctx.Get("claims").(jwt.MapClaims)
+	data, _ := claims["data"].(map[string]interface{})
+
+	id, _ := data["id"].(string)
+	attributes, _ := data["attributes"].(map[string]interface{})
+	// The authentication middleware leaves the permissions it granted, they may
+	// not be in the token
+	permissions, ok := ctx.Get("granted_permissions").([]string)
+	if ok {
+		permissions = append([]string{}, permissions...)
+	} else {
+		raw, _ := data["permission"].([]interface{})
+		permissions = make([]string, 0, len(raw))
+		for _, value := range raw {
+			if name, ok := value.(string); ok {
+				permissions = append(permissions, name)
+			}
+		}
+	}
+	if current, ok := tenant.Current(ctx); ok {
+		permissions = append(permissions, current.Permissions...)
+	}
+
+	principal := Principal{ID: id, Permissions: permissions, Attributes: attributes}
+	// Reuse the permissions the authentication middleware compiled for the token
+	if matcher, ok := ctx.Get("permissions").(*permission.Matcher); ok {
+		principal.matcher = matcher
+	} else {
+		principal.matcher = permission.Compile(permissions)
+	}
+
+	ctx.Set(principalKey, principal)
+	return principal
+}
+
+// Allowed reports whether the principal of the request may perform the action
+// on the resource. Decisions are cached for the rest of the request.
+func (e *Engine) Allowed(ctx echo.Context, action string, resource Resource) bool {
+	decisions, ok := ctx.Get(decisionsKey).(map[string]bool)
+	if !ok {
+		decisions = map[string]bool{}
+		ctx.Set(decisionsKey, decisions)
+	}
+
+	key := action + "\x00" + resource.Type + "\x00" + resource.ID
+	if allowed, ok := decisions[key]; ok {
+		return allowed
+	}
+
+	allowed := e.Can(CurrentPrincipal(ctx), action, resource)
+	decisions[key] = allowed
+	return allowed
+}
+
+// ListFilter returns the filter restricting a list query to the resources the
+// principal of the request may perform the action on, see Filter
+func (e *Engine) ListFilter(ctx echo.Context, action string) (bson.M, bool) {
+	return e.Filter(CurrentPrincipal(ctx), action)
+}
+
+// Holds reports whether the permissions of the request's principal cover the
+// permission, policies aside. Services check it for what an action hands out
+// beyond its own route, e.g. roles:assign for users created with roles.
+func (e *Engine) Holds(ctx echo.Context, name string) bool {
+	return CurrentPrincipal(ctx).allows(name)
+}
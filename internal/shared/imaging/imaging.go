@@ -0,0 +1,67 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// SquareThumbnail crops the centre square of the image and scales it to size x size.
+// Each target pixel averages the source pixels it covers, so downscaling stays smooth.
+func SquareThumbnail(src image.Image, size int) *image.NRGBA {
+	bounds := src.Bounds()
+	side := min(bounds.Dx(), bounds.Dy())
+	crop := image.Rect(0, 0, side, side).Add(image.Pt(
+		bounds.Min.X+(bounds.Dx()-side)/2,
+		bounds.Min.Y+(bounds.Dy()-side)/2,
+	))
+
+	// Work on a plain NRGBA copy so pixel access does not go through the color model
+	source := image.NewNRGBA(image.Rect(0, 0, side, side))
+	draw.Draw(source, source.Bounds(), src, crop.Min, draw.Src)
+
+	dst := image.NewNRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		y0, y1 := span(y, size, side)
+		for x := 0; x < size; x++ {
+			x0, x1 := span(x, size, side)
+			dst.SetNRGBA(x, y, average(source, x0, y0, x1, y1))
+		}
+	}
+	return dst
+}
+
+// span returns the source pixels [from, to) covered by target pixel i, always at least one
+func span(i int, size int, side int) (int, int) {
+	from := i * side / size
+	to := (i + 1) * side / size
+	if to <= from {
+		to = from + 1
+	}
+	return from, to
+}
+
+func average(img *image.NRGBA, x0, y0, x1, y1 int) color.NRGBA {
+	var r, g, b, a, count uint64
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			c := img.NRGBAAt(x, y)
+			// Weight the colour by alpha so transparent pixels do not darken the edges
+			r += uint64(c.R) * uint64(c.A)
+			g += uint64(c.G) * uint64(c.A)
+			b += uint64(c.B) * uint64(c.A)
+			a += uint64(c.A)
+			count++
+		}
+	}
+
+	if a == 0 {
+		return color.NRGBA{}
+	}
+	return color.NRGBA{
+		R: uint8(r / a),
+		G: uint8(g / a),
+		B: uint8(b / a),
+		A: uint8(a / count),
+	}
+}
@@ -0,0 +1,53 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSquareThumbnail_CropsCentre(t *testing.T) {
+	// 300x100: red, green and blue thirds, the green centre square survives the crop
+	src := image.NewNRGBA(image.Rect(0, 0, 300, 100))
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 300; x++ {
+			switch {
+			case x < 100:
+				src.SetNRGBA(x, y, color.NRGBA{R: 255, A: 255})
+			case x < 200:
+				src.SetNRGBA(x, y, color.NRGBA{G: 255, A: 255})
+			default:
+				src.SetNRGBA(x, y, color.NRGBA{B: 255, A: 255})
+			}
+		}
+	}
+
+	thumb := SquareThumbnail(src, 32)
+
+	assert.Equal(t, image.Rect(0, 0, 32, 32), thumb.Bounds())
+	assert.Equal(t, color.NRGBA{G: 255, A: 255}, thumb.NRGBAAt(0, 0))
+	assert.Equal(t, color.NRGBA{G: 255, A: 255}, thumb.NRGBAAt(31, 31))
+}
+
+func TestSquareThumbnail_AveragesAndUpscales(t *testing.T) {
+	// Black and white columns average to grey when halved
+	src := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			value := uint8(0)
+			if x%2 == 1 {
+				value = 254
+			}
+			src.SetNRGBA(x, y, color.NRGBA{R: value, G: value, B: value, A: 255})
+		}
+	}
+
+	assert.Equal(t, color.NRGBA{R: 127, G: 127, B: 127, A: 255}, SquareThumbnail(src, 2).NRGBAAt(0, 0))
+
+	large := SquareThumbnail(src, 8)
+	assert.Equal(t, image.Rect(0, 0, 8, 8), large.Bounds())
+	assert.Equal(t, color.NRGBA{A: 255}, large.NRGBAAt(0, 0))
+	assert.Equal(t, color.NRGBA{R: 254, G: 254, B: 254, A: 255}, large.NRGBAAt(2, 0))
+}
@@ -12,7 +12,7 @@ import (
 func AuthMiddleware(app *app.Apps) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			tokenString := c.Request().Header.Get("Authorization")
+			tokenString, scheme := utils.ExtractToken(c.Request().Header.Get("Authorization"))
 
 			token, err := utils.ValidateToken(app, tokenString)
 			if err != nil || !token.Valid {
@@ -21,13 +21,59 @@ func AuthMiddleware(app *app.Apps) echo.MiddlewareFunc {
 
 			}
 
-			if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-				c.Set("claims", claims)
-			} else {
+			claims, ok := token.Claims.(jwt.MapClaims)
+			if !ok {
 				utils.SendError(c, http.StatusUnauthorized, "Unauthorized", nil)
 				return nil
 			}
 
+			// Refresh tokens only renew access tokens, they never authenticate a request
+			if !utils.IsTokenType(claims, utils.TokenTypeAccess) {
+				utils.SendError(c, http.StatusUnauthorized, "Unauthorized", nil)
+				return nil
+			}
+
+			// Exchanged tokens restricted to another audience are not valid here
+			if !utils.AcceptsAudience(app, claims) {
+				utils.SendError(c, http.StatusUnauthorized, "Unauthorized", nil)
+				return nil
+			}
+
+			// DPoP-bound tokens must be presented with a proof from the bound key
+			jkt := utils.BoundThumbprint(claims)
+			if jkt != "" || scheme == utils.DPoPScheme {
+				proof, err := utils.VerifyDPoPProof(app, c.Request().Header.Get(utils.DPoPHeader), c.Request().Method, utils.DPoPRequestURL(c), tokenString)
+				if jkt == "" || scheme != utils.DPoPScheme || err != nil || proof.Thumbprint != jkt {
+					c.Response().Header().Set("WWW-Authenticate", `DPoP error="invalid_dpop_proof"`)
+					utils.SendError(c, http.StatusUnauthorized, "Unauthorized", nil)
+					return nil
+				}
+			}
+
+			// Tokens stop working as soon as the account leaves the active status
+			data, _ := claims["data"].(map[string]interface{})
+			userID, _ := data["id"].(string)
+			status, err := utils.CurrentUserStatus(app, userID)
+			if err != nil {
+				utils.SendError(c, http.StatusUnauthorized, "Unauthorized", nil)
+				return nil
+			}
+			if message := utils.AccountStatusMessage(status); message != "" {
+				utils.SendError(c, http.StatusForbidden, message, nil)
+				return nil
+			}
+
+			c.Set("claims", claims)
+			granted, err := compilePermissions(app, c, tokenString, userID, claims)
+			if err != nil {
+				utils.SendError(c, http.StatusUnauthorized, "Unauthorized", nil)
+				return nil
+			}
+
+			if err := enterTenant(app, c, userID, granted, data); err != nil {
+				return err
+			}
+
 			return next(c)
 		}
 	}
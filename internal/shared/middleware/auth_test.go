@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/HasanNugroho/starter-golang/config"
+	"github.com/HasanNugroho/starter-golang/internal/app"
+	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthMiddleware_RefreshToken(t *testing.T) {
+	apps := &app.Apps{Config: &config.Config{}}
+	apps.Config.Security.JWTSecretKey = "secret"
+
+	for _, typ := range []interface{}{utils.TokenTypeRefresh, nil} {
+		claims := jwt.MapClaims{
+			"data": map[string]interface{}{"id": "user"},
+			"exp":  time.Now().Add(time.Minute).Unix(),
+		}
+		if typ != nil {
+			claims[utils.TokenTypeClaim] = typ
+		}
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("secret"))
+		require.NoError(t, err)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+
+		// Only access tokens reach the handler
+		called := false
+		err = AuthMiddleware(apps)(func(c echo.Context) error {
+			called = true
+			return nil
+		})(echo.New().NewContext(req, rec))
+		assert.NoError(t, err)
+		assert.False(t, called)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	}
+}
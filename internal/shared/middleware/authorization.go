@@ -2,54 +2,101 @@ package middleware
 
 import (
 	"net/http"
-	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/HasanNugroho/starter-golang/internal/app"
+	"github.com/HasanNugroho/starter-golang/internal/shared/permission"
 	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/labstack/echo/v4"
 )
 
-func CheckAccess(permission []string) echo.MiddlewareFunc {
-	return func(next echo.HandlerFunc) echo.HandlerFunc {
-		return func(c echo.Context) error {
-			claimsRaw := c.Get("claims")
-			if claimsRaw == nil {
-				utils.SendError(c, http.StatusForbidden, "No claims found", nil)
-				return nil
-			}
+const (
+	// permissionsKey holds the compiled permissions of the request token
+	permissionsKey = "permissions"
+	// grantedKey holds the permissions the matcher was compiled from, before the tenant's
+	grantedKey = "granted_permissions"
+	// matcherKey holds the cachedKey of the request's matcher when it is cached
+	matcherKey = "permissions_matcher"
+)
 
-			claims, ok := claimsRaw.(jwt.MapClaims)
-			if !ok {
-				utils.SendError(c, http.StatusForbidden, "Invalid claims format", nil)
-				return nil
-			}
+// cachedKey is the key a matcher is cached under in matchers and when it expires
+type cachedKey struct {
+	key     string
+	expires time.Time
+}
 
-			data, ok := claims["data"].(map[string]interface{})
-			if !ok {
-				utils.SendError(c, http.StatusForbidden, "Invalid data in claims", nil)
-				return nil
-			}
+// matchers caches the compiled permissions of recently used tokens
+var matchers = permission.NewCache(10000)
+
+// required collects the permissions routes require, see RequiredPermissions
+var required = struct {
+	sync.Mutex
+	names map[string]struct{}
+}{names: map[string]struct{}{}}
+
+func requirePermissions(permissions ...string) {
+	required.Lock()
+	defer required.Unlock()
+	for _, name := range permissions {
+		required.names[name] = struct{}{}
+	}
+}
+
+// RequiredPermissions returns every permission a route built with CheckAccess
+// or Authorize requires, so startup can check they are all declared
+func RequiredPermissions() []string {
+	required.Lock()
+	defer required.Unlock()
 
-			rawRoles, ok := data["permission"].([]interface{})
+	names := make([]string, 0, len(required.names))
+	for name := range required.names {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CheckAccess allows the request when the token grants one of the permissions.
+// Granted permissions may be patterns such as users:* and required ones may
+// name route parameters, e.g. projects:{id}:tasks:update.
+func CheckAccess(permissions []string) echo.MiddlewareFunc {
+	requirePermissions(permissions...)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			matcher, ok := c.Get(permissionsKey).(*permission.Matcher)
 			if !ok {
-				utils.SendError(c, http.StatusForbidden, "Roles not found or wrong format", nil)
-				return nil
-			}
+				claimsRaw := c.Get("claims")
+				if claimsRaw == nil {
+					utils.SendError(c, http.StatusForbidden, "No claims found", nil)
+					return nil
+				}
 
-			var roles []string
-			for _, role := range rawRoles {
-				if str, ok := role.(string); ok {
-					roles = append(roles, str)
+				claims, ok := claimsRaw.(jwt.MapClaims)
+				if !ok {
+					utils.SendError(c, http.StatusForbidden, "Invalid claims format", nil)
+					return nil
+				}
+
+				data, ok := claims["data"].(map[string]interface{})
+				if !ok {
+					utils.SendError(c, http.StatusForbidden, "Invalid data in claims", nil)
+					return nil
 				}
-			}
 
-			// Allow if manage:system exists
-			if slices.Contains(roles, "manage:system") {
-				return next(c)
+				granted, ok := grantedPermissions(data)
+				if !ok {
+					utils.SendError(c, http.StatusForbidden, "Roles not found or wrong format", nil)
+					return nil
+				}
+				matcher = permission.Compile(granted)
 			}
 
-			// Otherwise, check if any permission matches
-			if len(utils.Intersection(roles, permission)) < 1 {
+			if !matcher.AllowsAny(resolvePermissions(c, permissions)) {
 				utils.SendError(c, http.StatusForbidden, "Access denied", nil)
 				return nil
 			}
@@ -58,3 +105,123 @@ func CheckAccess(permission []string) echo.MiddlewareFunc {
 		}
 	}
 }
+
+// compilePermissions stores the compiled permissions of a verified token in
+// the request, reusing the matcher compiled for the same token before. With
+// app.Grants set they are resolved for the user instead of read from the token.
+func compilePermissions(app *app.Apps, c echo.Context, tokenString string, userID string, claims jwt.MapClaims) ([]string, error) {
+	if app.Grants != nil {
+		return resolveGrants(app, c, userID, claims)
+	}
+
+	data, _ := claims["data"].(map[string]interface{})
+	granted, ok := grantedPermissions(data)
+	if !ok {
+		return nil, nil
+	}
+	c.Set(grantedKey, granted)
+
+	expires := time.Now().Add(time.Minute)
+	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+		expires = exp.Time
+	}
+
+	// The signature identifies the token without keeping all of it in memory
+	key := tokenString[strings.LastIndex(tokenString, ".")+1:]
+	c.Set(permissionsKey, matchers.Get(key, expires, granted))
+	c.Set(matcherKey, cachedKey{key: key, expires: expires})
+	return granted, nil
+}
+
+// resolveGrants compiles the effective permissions of the user, reusing the
+// matcher compiled for the same generation of their grants. Exchanged tokens
+// keep their scope, narrowed to what the user still holds.
+func resolveGrants(app *app.Apps, c echo.Context, userID string, claims jwt.MapClaims) ([]string, error) {
+	grants, err := app.Grants.Effective(c.Request().Context(), userID)
+	if err != nil {
+		return nil, err
+	}
+
+	key := "grants:" + userID + ":" + grants.Generation
+	granted := grants.Permissions
+	if _, exchanged := claims["scope"].(string); exchanged {
+		data, _ := claims["data"].(map[string]interface{})
+		scope, _ := grantedPermissions(data)
+		held := grantsMatcher(key, grants, granted)
+
+		granted = make([]string, 0, len(scope))
+		for _, name := range scope {
+			if held.Allows(name) {
+				granted = append(granted, name)
+			}
+		}
+		key += ":" + strings.Join(scope, " ")
+	}
+
+	c.Set(grantedKey, granted)
+	c.Set(permissionsKey, grantsMatcher(key, grants, granted))
+	if grants.Generation != "" {
+		c.Set(matcherKey, cachedKey{key: key, expires: grants.Expires})
+	}
+	return granted, nil
+}
+
+// grantsMatcher compiles the granted permissions, once per key while the
+// grants have a generation
+func grantsMatcher(key string, grants permission.Grants, granted []string) *permission.Matcher {
+	if grants.Generation == "" {
+		return permission.Compile(granted)
+	}
+	return matchers.Get(key, grants.Expires, granted)
+}
+
+// grantedPermissions reads the permission claim of the token. Access tokens
+// refreshed before the claim name was fixed carry it as permissions, they are
+// accepted until they expire.
+func grantedPermissions(data map[string]interface{}) ([]string, bool) {
+	raw, ok := data["permission"].([]interface{})
+	if !ok {
+		if raw, ok = data["permissions"].([]interface{}); !ok {
+			return nil, false
+		}
+	}
+
+	granted := make([]string, 0, len(raw))
+	for _, value := range raw {
+		if str, ok := value.(string); ok {
+			granted = append(granted, str)
+		}
+	}
+	return granted, true
+}
+
+// resolvePermissions fills the {param} segments of the required permissions
+// with route parameters. A parameter that would add segments or a wildcard
+// leaves the permission unsatisfiable.
+func resolvePermissions(c echo.Context, permissions []string) []string {
+	resolved := make([]string, 0, len(permissions))
+	for _, required := range permissions {
+		if !strings.Contains(required, "{") {
+			resolved = append(resolved, required)
+			continue
+		}
+
+		segments := strings.Split(required, permission.Separator)
+		valid := true
+		for i, segment := range segments {
+			if !strings.HasPrefix(segment, "{") || !strings.HasSuffix(segment, "}") {
+				continue
+			}
+			value := c.Param(segment[1 : len(segment)-1])
+			if value == "" || strings.Contains(value, permission.Separator) || value == permission.Wildcard {
+				valid = false
+				break
+			}
+			segments[i] = value
+		}
+		if valid {
+			resolved = append(resolved, strings.Join(segments, permission.Separator))
+		}
+	}
+	return resolved
+}
@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/HasanNugroho/starter-golang/internal/shared/permission"
+	"github.com/HasanNugroho/starter-golang/internal/shared/tenant"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestGrantedPermissions(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    map[string]interface{}
+		want    []string
+		present bool
+	}{
+		{"permission claim", map[string]interface{}{"permission": []interface{}{"users:read"}}, []string{"users:read"}, true},
+		{"refreshed before the claim fix", map[string]interface{}{"permissions": []interface{}{"users:read"}}, []string{"users:read"}, true},
+		{"identity only", map[string]interface{}{"id": "user"}, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			granted, ok := grantedPermissions(tt.data)
+			assert.Equal(t, tt.present, ok)
+			assert.Equal(t, tt.want, granted)
+		})
+	}
+}
+
+func TestGrantsMatcher(t *testing.T) {
+	grants := permission.Grants{Permissions: []string{"users:read"}, Generation: "0.1", Expires: time.Now().Add(time.Minute)}
+
+	// The same generation reuses the compiled matcher
+	first := grantsMatcher("grants:user:0.1", grants, grants.Permissions)
+	assert.Same(t, first, grantsMatcher("grants:user:0.1", grants, grants.Permissions))
+	assert.True(t, first.Allows("users:read"))
+
+	// A new generation compiles what the user holds now
+	grants.Generation = "0.2"
+	grants.Permissions = []string{"users:update"}
+	second := grantsMatcher("grants:user:0.2", grants, grants.Permissions)
+	assert.NotSame(t, first, second)
+	assert.False(t, second.Allows("users:read"))
+
+	// Grants without a generation are compiled every time
+	grants.Generation = ""
+	assert.NotSame(t, grantsMatcher("grants:user:", grants, grants.Permissions), grantsMatcher("grants:user:", grants, grants.Permissions))
+}
+
+func TestTenantMatcher(t *testing.T) {
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+	current := tenant.Tenant{ID: bson.NewObjectID(), Member: true, Permissions: []string{"projects:read"}, Generation: "0.1"}
+	granted := []string{"users:read", "projects:read"}
+
+	// Without a cached request matcher the permissions are compiled every time
+	assert.NotSame(t, tenantMatcher(c, current, granted), tenantMatcher(c, current, granted))
+
+	// The same membership reuses the compiled matcher
+	c.Set(matcherKey, cachedKey{key: "grants:user:0.1", expires: time.Now().Add(time.Minute)})
+	first := tenantMatcher(c, current, granted)
+	assert.Same(t, first, tenantMatcher(c, current, granted))
+	assert.True(t, first.Allows("projects:read"))
+
+	// Another tenant or generation compiles its own
+	other := current
+	other.ID = bson.NewObjectID()
+	assert.NotSame(t, first, tenantMatcher(c, other, granted))
+	current.Generation = "0.2"
+	assert.NotSame(t, first, tenantMatcher(c, current, granted))
+
+	// Memberships that were not cached are compiled every time
+	current.Generation = ""
+	assert.NotSame(t, tenantMatcher(c, current, granted), tenantMatcher(c, current, granted))
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/HasanNugroho/starter-golang/internal/app"
+	"github.com/HasanNugroho/starter-golang/internal/shared/authz"
+	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
+	"github.com/labstack/echo/v4"
+)
+
+// Authorize lets the request through when a permission or an access policy may
+// grant the action. Unlike CheckAccess the handler still has to check the
+// resource itself, with app.Authz.Allowed or app.Authz.ListFilter.
+func Authorize(app *app.Apps, action string) echo.MiddlewareFunc {
+	requirePermissions(action)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !app.Authz.Permits(authz.CurrentPrincipal(c), action) {
+				utils.SendError(c, http.StatusForbidden, "Access denied", nil)
+				return nil
+			}
+
+			return next(c)
+		}
+	}
+}
@@ -15,8 +15,8 @@ func SetCORS(config *config.Config) echo.MiddlewareFunc {
 	corsConfig := middleware.CORSConfig{
 		AllowOrigins:     allowOrigins,
 		AllowMethods:     []string{echo.GET, echo.POST, echo.PUT, echo.DELETE, echo.OPTIONS, echo.PATCH},
-		AllowHeaders:     []string{"Accept", "Authorization", "Content-Type", "Origin", "X-Requested-With"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Accept", "Authorization", "Content-Type", "Origin", "X-Requested-With", "DPoP", "If-Match"},
+		ExposeHeaders:    []string{"Content-Length", "WWW-Authenticate", "ETag"},
 		AllowCredentials: true,
 	}
 
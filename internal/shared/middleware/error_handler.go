@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/HasanNugroho/starter-golang/internal/app"
@@ -36,8 +37,14 @@ func ErrorHandler(app *app.Apps) echo.MiddlewareFunc {
 				utils.SendError(c, http.StatusNotFound, e.Message, nil)
 			case *utils.ConflictError:
 				utils.SendError(c, http.StatusConflict, e.Message, nil)
+			case *utils.PreconditionFailedError:
+				utils.SendError(c, http.StatusPreconditionFailed, e.Message, nil)
+			case *utils.PreconditionRequiredError:
+				utils.SendError(c, http.StatusPreconditionRequired, e.Message, nil)
 			case *utils.InternalError:
 				utils.SendError(c, http.StatusInternalServerError, e.Message, nil)
+			case *echo.HTTPError:
+				utils.SendError(c, e.Code, fmt.Sprint(e.Message), nil)
 			default:
 				app.Log.Error().Err(err).Msg("Unhandled error")
 				utils.SendError(c, http.StatusInternalServerError, "Internal Server Error", nil)
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
+	"github.com/labstack/echo/v4"
+)
+
+// RequireIfMatch makes a write conditional on the version the client last read.
+// The If-Match header must carry the ETag of that read, or * to skip the check.
+func RequireIfMatch() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			header := ctx.Request().Header.Get("If-Match")
+			if header == "" {
+				return utils.NewPreconditionRequired("If-Match header is required, send the ETag of the resource")
+			}
+
+			if header != "*" {
+				version, ok := utils.ParseETag(header)
+				if !ok {
+					return utils.NewPreconditionFailed("If-Match header is not a valid ETag")
+				}
+				utils.SetExpectedVersion(ctx, version)
+			}
+
+			return next(ctx)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"slices"
+
+	"github.com/HasanNugroho/starter-golang/internal/app"
+	"github.com/HasanNugroho/starter-golang/internal/shared/permission"
+	"github.com/HasanNugroho/starter-golang/internal/shared/tenant"
+	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
+	"github.com/labstack/echo/v4"
+)
+
+// enterTenant makes the organization named by the X-Tenant header, or else by
+// the token, the active tenant of the request. Members get the permissions of
+// their roles there on top of the global ones, system admins may enter any
+// organization with their global permissions only.
+func enterTenant(app *app.Apps, c echo.Context, userID string, granted []string, data map[string]interface{}) error {
+	ref := c.Request().Header.Get(tenant.Header)
+	if ref == "" {
+		ref, _ = data[tenant.Claim].(string)
+	}
+	if ref == "" {
+		return nil
+	}
+
+	if app.Tenants == nil {
+		return utils.NewBadRequest("organizations are not enabled")
+	}
+
+	current, err := app.Tenants.Resolve(c.Request().Context(), userID, ref)
+	if err != nil {
+		return err
+	}
+
+	if !current.Member {
+		matcher, ok := c.Get(permissionsKey).(*permission.Matcher)
+		if !ok || !matcher.Allows(permission.System) {
+			return utils.NewForbidden("not a member of the organization")
+		}
+	} else if len(current.Permissions) > 0 {
+		c.Set(permissionsKey, tenantMatcher(c, current, slices.Concat(granted, current.Permissions)))
+	}
+
+	tenant.Set(c, current)
+	return nil
+}
+
+// tenantMatcher compiles the permissions of a member, once per tenant while
+// both the matcher of the request and the membership are cached
+func tenantMatcher(c echo.Context, current tenant.Tenant, granted []string) *permission.Matcher {
+	cached, ok := c.Get(matcherKey).(cachedKey)
+	if !ok || current.Generation == "" {
+		return permission.Compile(granted)
+	}
+
+	key := cached.key + "|tenant:" + current.ID.Hex() + ":" + current.Generation
+	return matchers.Get(key, cached.expires, granted)
+}
@@ -15,6 +15,8 @@ type Pagination struct {
 }
 
 type DataWithPagination struct {
-	Items  interface{} `json:"items"`
-	Paging Pagination  `json:"paging"`
+	Items      interface{} `json:"items"`
+	Paging     Pagination  `json:"paging"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+	PrevCursor string      `json:"prev_cursor,omitempty"`
 }
@@ -0,0 +1,66 @@
+package modules
+
+import (
+	"context"
+	"fmt"
+	"net/smtp"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+type MailConfig struct {
+	Host     string `mapstructure:"SMTP_HOST"`
+	Port     int    `mapstructure:"SMTP_PORT"`
+	Username string `mapstructure:"SMTP_USERNAME"`
+	Password string `mapstructure:"SMTP_PASSWORD"`
+	From     string `mapstructure:"MAIL_FROM"`
+}
+
+// Mailer sends plain text emails to users
+type Mailer interface {
+	Send(ctx context.Context, to string, subject string, body string) error
+}
+
+// NewMailer returns an SMTP mailer, or one that only logs messages when no SMTP host is set
+func NewMailer(config MailConfig, log *zerolog.Logger) Mailer {
+	if config.Host == "" {
+		log.Warn().Msg("⚠️ SMTP is not configured. Emails will only be logged.")
+		return &LogMailer{log: log}
+	}
+	return &SMTPMailer{config: config}
+}
+
+type SMTPMailer struct {
+	config MailConfig
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, to string, subject string, body string) error {
+	var auth smtp.Auth
+	if m.config.Username != "" {
+		auth = smtp.PlainAuth("", m.config.Username, m.config.Password, m.config.Host)
+	}
+
+	message := strings.Join([]string{
+		"From: " + m.config.From,
+		"To: " + to,
+		"Subject: " + subject,
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		body,
+	}, "\r\n")
+
+	addr := fmt.Sprintf("%s:%d", m.config.Host, m.config.Port)
+	return smtp.SendMail(addr, auth, m.config.From, []string{to}, []byte(message))
+}
+
+// LogMailer writes emails to the log, meant for development
+type LogMailer struct {
+	log *zerolog.Logger
+}
+
+func (m *LogMailer) Send(ctx context.Context, to string, subject string, body string) error {
+	m.log.Info().Str("to", to).Str("subject", subject).Msg(body)
+	return nil
+}
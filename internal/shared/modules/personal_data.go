@@ -0,0 +1,58 @@
+package modules
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// DataSubject identifies the user a data export or erasure is about.
+// Erasure runs every source against the same subject, so sources can still
+// match on the email after the profile itself has been anonymized.
+type DataSubject struct {
+	ID    bson.ObjectID
+	Email string
+}
+
+// PersonalDataArchive collects the exported data of a subject
+type PersonalDataArchive interface {
+	// AddJSON stores the value as an indented JSON document under the name
+	AddJSON(name string, value any) error
+	// AddFile copies a file into the archive under the name
+	AddFile(name string, body io.Reader) error
+}
+
+// PersonalDataSource is personal data held by a feature, usually a collection.
+// Export writes everything about the subject into the archive, Erase deletes
+// or anonymizes it.
+type PersonalDataSource struct {
+	Name   string
+	Export func(ctx context.Context, subject DataSubject, archive PersonalDataArchive) error
+	Erase  func(ctx context.Context, subject DataSubject) error
+}
+
+// PersonalDataRegistry lists every source of personal data features declare
+type PersonalDataRegistry struct {
+	lock    sync.RWMutex
+	sources []PersonalDataSource
+}
+
+func NewPersonalDataRegistry() *PersonalDataRegistry {
+	return &PersonalDataRegistry{}
+}
+
+// Register declares a source, sources are exported and erased in registration order
+func (r *PersonalDataRegistry) Register(source PersonalDataSource) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.sources = append(r.sources, source)
+}
+
+// Sources returns the registered sources
+func (r *PersonalDataRegistry) Sources() []PersonalDataSource {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return append([]PersonalDataSource{}, r.sources...)
+}
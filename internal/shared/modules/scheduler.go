@@ -0,0 +1,75 @@
+package modules
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// ScheduledJob is a background task run periodically by the Scheduler
+type ScheduledJob func(ctx context.Context) error
+
+type scheduledEntry struct {
+	name     string
+	interval time.Duration
+	job      ScheduledJob
+}
+
+// Scheduler runs registered jobs on fixed intervals in the background
+type Scheduler struct {
+	log     *zerolog.Logger
+	entries []scheduledEntry
+	lock    sync.Mutex
+	started bool
+}
+
+func NewScheduler(log *zerolog.Logger) *Scheduler {
+	return &Scheduler{log: log}
+}
+
+// Every registers a job to run on the given interval once the scheduler starts
+func (s *Scheduler) Every(name string, interval time.Duration, job ScheduledJob) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	entry := scheduledEntry{name: name, interval: interval, job: job}
+	s.entries = append(s.entries, entry)
+
+	if s.started {
+		go s.run(context.Background(), entry)
+	}
+}
+
+// Start launches every registered job until the context is cancelled
+func (s *Scheduler) Start(ctx context.Context) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.started = true
+	for _, entry := range s.entries {
+		go s.run(ctx, entry)
+	}
+}
+
+func (s *Scheduler) run(ctx context.Context, entry scheduledEntry) {
+	if entry.interval <= 0 {
+		s.log.Warn().Msgf("⚠️ Scheduled job %s has no interval, skipping", entry.name)
+		return
+	}
+
+	ticker := time.NewTicker(entry.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := entry.job(ctx); err != nil {
+				s.log.Error().Err(err).Msgf("Scheduled job %s failed", entry.name)
+			}
+		}
+	}
+}
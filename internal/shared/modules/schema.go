@@ -0,0 +1,349 @@
+package modules
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// IndexSpec is an index a feature needs on one of its collections.
+// Keys use 1 and -1 for ascending and descending fields and "text" for text indexes.
+type IndexSpec struct {
+	Name   string
+	Keys   bson.D
+	Unique bool
+	Sparse bool
+	// TTL removes documents once the date in the indexed field is older, zero disables it
+	TTL     time.Duration
+	Partial bson.M
+}
+
+// CollectionSchema declares a collection with its indexes and $jsonSchema validator
+type CollectionSchema struct {
+	Name    string
+	Indexes []IndexSpec
+	// Validator is the $jsonSchema document, nil leaves the collection unvalidated
+	Validator bson.M
+}
+
+// SchemaReport lists what applying or checking the schema found
+type SchemaReport struct {
+	Collections []string
+	Validators  []string
+	Indexes     []string
+	Drift       []string
+}
+
+// Pending reports whether a check found changes left to apply
+func (r SchemaReport) Pending() bool {
+	return len(r.Collections) > 0 || len(r.Validators) > 0 || len(r.Indexes) > 0
+}
+
+// SchemaRegistry collects the collections features declare, so they are
+// created with their indexes and validators before the API serves requests
+type SchemaRegistry struct {
+	lock        sync.RWMutex
+	collections []CollectionSchema
+}
+
+func NewSchemaRegistry() *SchemaRegistry {
+	return &SchemaRegistry{}
+}
+
+// Declare adds a collection. Declaring a collection twice merges the indexes,
+// the last non-nil validator wins.
+func (r *SchemaRegistry) Declare(schema CollectionSchema) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for i := range r.collections {
+		if r.collections[i].Name != schema.Name {
+			continue
+		}
+		r.collections[i].Indexes = append(r.collections[i].Indexes, schema.Indexes...)
+		if schema.Validator != nil {
+			r.collections[i].Validator = schema.Validator
+		}
+		return
+	}
+	r.collections = append(r.collections, schema)
+}
+
+// Collections returns the declared collections
+func (r *SchemaRegistry) Collections() []CollectionSchema {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return append([]CollectionSchema{}, r.collections...)
+}
+
+// Apply creates missing collections and indexes and updates validators.
+// It is idempotent, existing indexes are never dropped: an index that differs
+// from its declaration or is not declared at all is reported as drift.
+func (r *SchemaRegistry) Apply(ctx context.Context, db *mongo.Database, log *zerolog.Logger) (SchemaReport, error) {
+	return r.sync(ctx, db, log, true)
+}
+
+// Check reports what Apply would change and the drift without touching the database
+func (r *SchemaRegistry) Check(ctx context.Context, db *mongo.Database, log *zerolog.Logger) (SchemaReport, error) {
+	return r.sync(ctx, db, log, false)
+}
+
+func (r *SchemaRegistry) sync(ctx context.Context, db *mongo.Database, log *zerolog.Logger, apply bool) (SchemaReport, error) {
+	report := SchemaReport{}
+
+	specs, err := db.ListCollectionSpecifications(ctx, bson.M{})
+	if err != nil {
+		return report, err
+	}
+	existing := map[string]bson.Raw{}
+	for _, spec := range specs {
+		existing[spec.Name] = spec.Options
+	}
+
+	var errs []error
+	for _, schema := range r.Collections() {
+		if err := syncCollection(ctx, db, log, schema, existing, apply, &report); err != nil {
+			log.Error().Err(err).Msgf("❌ Failed to apply schema of %s", schema.Name)
+			errs = append(errs, fmt.Errorf("%s: %w", schema.Name, err))
+		}
+	}
+
+	for _, drift := range report.Drift {
+		log.Warn().Msgf("⚠️ Schema drift: %s", drift)
+	}
+
+	return report, errors.Join(errs...)
+}
+
+func syncCollection(ctx context.Context, db *mongo.Database, log *zerolog.Logger, schema CollectionSchema, existing map[string]bson.Raw, apply bool, report *SchemaReport) error {
+	current, exists := existing[schema.Name]
+
+	if !exists {
+		report.Collections = append(report.Collections, schema.Name)
+		if apply {
+			opts := options.CreateCollection()
+			if schema.Validator != nil {
+				opts.SetValidator(bson.M{"$jsonSchema": schema.Validator}).
+					SetValidationLevel("moderate").
+					SetValidationAction("error")
+			}
+			if err := db.CreateCollection(ctx, schema.Name, opts); err != nil {
+				return err
+			}
+			log.Info().Msgf("✅ Created collection %s", schema.Name)
+		}
+	} else if schema.Validator != nil {
+		desired := bson.M{"$jsonSchema": schema.Validator}
+		validator, hasValidator := current.Lookup("validator").DocumentOK()
+		if !hasValidator || !sameDocument(desired, validator) {
+			if hasValidator {
+				report.Drift = append(report.Drift, fmt.Sprintf("validator of %s differs from its declaration", schema.Name))
+			}
+			report.Validators = append(report.Validators, schema.Name)
+			if apply {
+				command := bson.D{
+					{Key: "collMod", Value: schema.Name},
+					{Key: "validator", Value: desired},
+					{Key: "validationLevel", Value: "moderate"},
+					{Key: "validationAction", Value: "error"},
+				}
+				if err := db.RunCommand(ctx, command).Err(); err != nil {
+					return err
+				}
+				log.Info().Msgf("✅ Updated validator of %s", schema.Name)
+			}
+		}
+	}
+
+	var indexes []storedIndex
+	if exists {
+		cursor, err := db.Collection(schema.Name).Indexes().List(ctx)
+		if err != nil {
+			return err
+		}
+		if err := cursor.All(ctx, &indexes); err != nil {
+			return err
+		}
+	}
+
+	declared := map[string]bool{"_id_": true}
+	for _, index := range schema.Indexes {
+		declared[index.Name] = true
+
+		current, found := findIndex(indexes, index)
+		if found {
+			if current.Name != index.Name {
+				declared[current.Name] = true
+				report.Drift = append(report.Drift, fmt.Sprintf("index %s.%s exists as %s", schema.Name, index.Name, current.Name))
+			} else if diff := indexDiff(index, current); diff == "ttl" && index.TTL > 0 && current.ExpireAfterSeconds != nil {
+				// A changed retention is safe to apply in place
+				report.Indexes = append(report.Indexes, schema.Name+"."+index.Name)
+				if apply {
+					command := bson.D{
+						{Key: "collMod", Value: schema.Name},
+						{Key: "index", Value: bson.M{"name": index.Name, "expireAfterSeconds": int32(index.TTL / time.Second)}},
+					}
+					if err := db.RunCommand(ctx, command).Err(); err != nil {
+						return fmt.Errorf("index %s: %w", index.Name, err)
+					}
+					log.Info().Msgf("✅ Updated expiration of index %s.%s", schema.Name, index.Name)
+				}
+			} else if diff != "" {
+				report.Drift = append(report.Drift, fmt.Sprintf("index %s.%s differs from its declaration (%s)", schema.Name, index.Name, diff))
+			}
+			continue
+		}
+
+		report.Indexes = append(report.Indexes, schema.Name+"."+index.Name)
+		if apply {
+			if _, err := db.Collection(schema.Name).Indexes().CreateOne(ctx, indexModel(index)); err != nil {
+				return fmt.Errorf("index %s: %w", index.Name, err)
+			}
+			log.Info().Msgf("✅ Created index %s.%s", schema.Name, index.Name)
+		}
+	}
+
+	for _, current := range indexes {
+		if !declared[current.Name] {
+			report.Drift = append(report.Drift, fmt.Sprintf("index %s.%s is not declared", schema.Name, current.Name))
+		}
+	}
+
+	return nil
+}
+
+// storedIndex is an index as listed by the server. mongo.IndexSpecification
+// leaves out the partial filter.
+type storedIndex struct {
+	Name                    string   `bson:"name"`
+	KeysDocument            bson.Raw `bson:"key"`
+	ExpireAfterSeconds      *int32   `bson:"expireAfterSeconds"`
+	Sparse                  *bool    `bson:"sparse"`
+	Unique                  *bool    `bson:"unique"`
+	PartialFilterExpression bson.Raw `bson:"partialFilterExpression"`
+}
+
+func indexModel(index IndexSpec) mongo.IndexModel {
+	opts := options.Index().SetName(index.Name)
+	if index.Unique {
+		opts.SetUnique(true)
+	}
+	if index.Sparse {
+		opts.SetSparse(true)
+	}
+	if index.TTL > 0 {
+		opts.SetExpireAfterSeconds(int32(index.TTL / time.Second))
+	}
+	if index.Partial != nil {
+		opts.SetPartialFilterExpression(index.Partial)
+	}
+	return mongo.IndexModel{Keys: index.Keys, Options: opts}
+}
+
+// findIndex matches a declared index by name, then by keys since the server
+// refuses a second index on the same keys under another name
+func findIndex(indexes []storedIndex, index IndexSpec) (storedIndex, bool) {
+	for _, current := range indexes {
+		if current.Name == index.Name {
+			return current, true
+		}
+	}
+	if isTextIndex(index.Keys) {
+		return storedIndex{}, false
+	}
+	for _, current := range indexes {
+		if sameKeys(index.Keys, current.KeysDocument) {
+			return current, true
+		}
+	}
+	return storedIndex{}, false
+}
+
+// indexDiff describes how an existing index differs from its declaration
+func indexDiff(index IndexSpec, current storedIndex) string {
+	// The server stores text indexes under internal keys, only the name is comparable
+	if !isTextIndex(index.Keys) && !sameKeys(index.Keys, current.KeysDocument) {
+		return "keys"
+	}
+	if index.Unique != (current.Unique != nil && *current.Unique) {
+		return "unique"
+	}
+	if index.Sparse != (current.Sparse != nil && *current.Sparse) {
+		return "sparse"
+	}
+	if (index.Partial != nil) != (current.PartialFilterExpression != nil) ||
+		(index.Partial != nil && !sameDocument(index.Partial, current.PartialFilterExpression)) {
+		return "partial"
+	}
+
+	ttl := int32(index.TTL / time.Second)
+	if (index.TTL > 0) != (current.ExpireAfterSeconds != nil) ||
+		(current.ExpireAfterSeconds != nil && *current.ExpireAfterSeconds != ttl) {
+		return "ttl"
+	}
+	return ""
+}
+
+func isTextIndex(keys bson.D) bool {
+	for _, key := range keys {
+		if key.Value == "text" {
+			return true
+		}
+	}
+	return false
+}
+
+// sameKeys compares index keys in order, the server may return 1 as an int32, int64 or double
+func sameKeys(keys bson.D, raw bson.Raw) bool {
+	var current bson.D
+	if err := bson.Unmarshal(raw, &current); err != nil || len(current) != len(keys) {
+		return false
+	}
+
+	for i, key := range keys {
+		if current[i].Key != key.Key || fmt.Sprint(keyDirection(current[i].Value)) != fmt.Sprint(keyDirection(key.Value)) {
+			return false
+		}
+	}
+	return true
+}
+
+func keyDirection(value any) any {
+	switch v := value.(type) {
+	case int:
+		return float64(v)
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	default:
+		return v
+	}
+}
+
+// sameDocument compares documents regardless of key order and numeric types
+func sameDocument(declared any, raw bson.Raw) bool {
+	return reflect.DeepEqual(normalizeDocument(declared), normalizeDocument(raw))
+}
+
+func normalizeDocument(doc any) any {
+	data, err := bson.MarshalExtJSON(doc, false, false)
+	if err != nil {
+		return nil
+	}
+
+	var normalized any
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return nil
+	}
+	return normalized
+}
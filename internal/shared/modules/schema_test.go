@@ -0,0 +1,72 @@
+package modules
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func rawDocument(t *testing.T, doc any) bson.Raw {
+	data, err := bson.Marshal(doc)
+	assert.NoError(t, err)
+	return data
+}
+
+func TestSchemaRegistry_DeclareMergesCollections(t *testing.T) {
+	registry := NewSchemaRegistry()
+	registry.Declare(CollectionSchema{Name: "users", Indexes: []IndexSpec{{Name: "email_unique"}}, Validator: bson.M{"bsonType": "object"}})
+	registry.Declare(CollectionSchema{Name: "roles"})
+	registry.Declare(CollectionSchema{Name: "users", Indexes: []IndexSpec{{Name: "roles"}}})
+
+	collections := registry.Collections()
+
+	assert.Len(t, collections, 2)
+	assert.Equal(t, "users", collections[0].Name)
+	assert.Len(t, collections[0].Indexes, 2)
+	assert.Equal(t, bson.M{"bsonType": "object"}, collections[0].Validator)
+}
+
+func TestSameKeys_IgnoresNumericTypes(t *testing.T) {
+	keys := bson.D{{Key: "deleted_at", Value: 1}, {Key: "created_at", Value: -1}}
+
+	assert.True(t, sameKeys(keys, rawDocument(t, bson.D{{Key: "deleted_at", Value: 1.0}, {Key: "created_at", Value: int64(-1)}})))
+	assert.False(t, sameKeys(keys, rawDocument(t, bson.D{{Key: "created_at", Value: -1}, {Key: "deleted_at", Value: 1}})))
+	assert.False(t, sameKeys(keys, rawDocument(t, bson.D{{Key: "deleted_at", Value: 1}})))
+}
+
+func TestSameDocument_IgnoresKeyOrder(t *testing.T) {
+	declared := bson.M{"$jsonSchema": bson.M{"required": bson.A{"email"}, "bsonType": "object"}}
+	stored := rawDocument(t, bson.D{{Key: "$jsonSchema", Value: bson.D{{Key: "bsonType", Value: "object"}, {Key: "required", Value: bson.A{"email"}}}}})
+
+	assert.True(t, sameDocument(declared, stored))
+	assert.False(t, sameDocument(bson.M{"$jsonSchema": bson.M{"bsonType": "object"}}, stored))
+}
+
+func TestIndexDiff(t *testing.T) {
+	yes := true
+	ttl := int32(3600)
+	keys := bson.D{{Key: "email", Value: 1}}
+	current := storedIndex{Name: "email_unique", KeysDocument: rawDocument(t, keys), Unique: &yes}
+
+	assert.Empty(t, indexDiff(IndexSpec{Name: "email_unique", Keys: keys, Unique: true}, current))
+	assert.Equal(t, "unique", indexDiff(IndexSpec{Name: "email_unique", Keys: keys}, current))
+	assert.Equal(t, "keys", indexDiff(IndexSpec{Name: "email_unique", Keys: bson.D{{Key: "name", Value: 1}}, Unique: true}, current))
+
+	current = storedIndex{Name: "finished_at_ttl", KeysDocument: rawDocument(t, bson.D{{Key: "finished_at", Value: 1}}), ExpireAfterSeconds: &ttl}
+	assert.Empty(t, indexDiff(IndexSpec{Keys: bson.D{{Key: "finished_at", Value: 1}}, TTL: time.Hour}, current))
+	assert.Equal(t, "ttl", indexDiff(IndexSpec{Keys: bson.D{{Key: "finished_at", Value: 1}}, TTL: 2 * time.Hour}, current))
+
+	partial := bson.M{"is_system": true}
+	current = storedIndex{Name: "system_name_unique", KeysDocument: rawDocument(t, keys), Unique: &yes, PartialFilterExpression: rawDocument(t, bson.D{{Key: "is_system", Value: true}})}
+	assert.Empty(t, indexDiff(IndexSpec{Name: "system_name_unique", Keys: keys, Unique: true, Partial: partial}, current))
+	assert.Equal(t, "partial", indexDiff(IndexSpec{Name: "system_name_unique", Keys: keys, Unique: true}, current))
+	assert.Equal(t, "partial", indexDiff(IndexSpec{Name: "system_name_unique", Keys: keys, Unique: true, Partial: bson.M{"is_system": false}}, current))
+	current.PartialFilterExpression = nil
+	assert.Equal(t, "partial", indexDiff(IndexSpec{Name: "system_name_unique", Keys: keys, Unique: true, Partial: partial}, current))
+
+	// Text indexes are stored under internal keys
+	current = storedIndex{Name: "search", KeysDocument: rawDocument(t, bson.D{{Key: "_fts", Value: "text"}, {Key: "_ftsx", Value: 1}})}
+	assert.Empty(t, indexDiff(IndexSpec{Name: "search", Keys: bson.D{{Key: "name", Value: "text"}}}, current))
+}
@@ -0,0 +1,49 @@
+package modules
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/rs/zerolog"
+)
+
+// Seed inserts the documents a feature needs to be usable on a fresh
+// deployment. Seeds run on every start, so they must be idempotent.
+type Seed struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// SeedRegistry collects the seeds features declare
+type SeedRegistry struct {
+	lock  sync.RWMutex
+	seeds []Seed
+}
+
+func NewSeedRegistry() *SeedRegistry {
+	return &SeedRegistry{}
+}
+
+// Register declares a seed, seeds run in registration order
+func (r *SeedRegistry) Register(seed Seed) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.seeds = append(r.seeds, seed)
+}
+
+// Run runs every seed, stopping at the first failure since later seeds may
+// depend on the documents of earlier ones
+func (r *SeedRegistry) Run(ctx context.Context, log *zerolog.Logger) error {
+	r.lock.RLock()
+	seeds := append([]Seed{}, r.seeds...)
+	r.lock.RUnlock()
+
+	for _, seed := range seeds {
+		if err := seed.Run(ctx); err != nil {
+			return fmt.Errorf("seed %s: %w", seed.Name, err)
+		}
+		log.Debug().Msgf("Seed %s applied", seed.Name)
+	}
+	return nil
+}
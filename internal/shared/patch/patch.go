@@ -0,0 +1,153 @@
+package patch
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"reflect"
+
+	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
+	"github.com/go-playground/validator/v10"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+const (
+	// MergePatchContentType selects RFC 7396 JSON Merge Patch, also used for plain application/json
+	MergePatchContentType = "application/merge-patch+json"
+	// JSONPatchContentType selects RFC 6902 JSON Patch
+	JSONPatchContentType = "application/json-patch+json"
+)
+
+var validate = validator.New()
+
+// Apply patches the JSON form of current with body according to the content type,
+// decodes the result into target and validates it.
+// Fields unknown to target are rejected.
+func Apply(contentType string, current interface{}, body []byte, target interface{}) error {
+	document, err := json.Marshal(current)
+	if err != nil {
+		return utils.NewInternal("failed to encode document")
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case MergePatchContentType, "application/json", "":
+		document, err = MergePatch(document, body)
+	case JSONPatchContentType:
+		document, err = JSONPatch(document, body)
+	default:
+		return utils.NewBadRequest("unsupported patch content type " + mediaType)
+	}
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(document))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(target); err != nil {
+		return utils.NewBadRequest("patch result is invalid: " + err.Error())
+	}
+
+	if err := validate.Struct(target); err != nil {
+		return utils.NewBadRequest(err.Error())
+	}
+
+	return nil
+}
+
+// MergePatch applies an RFC 7396 merge patch to the document
+func MergePatch(document []byte, patch []byte) ([]byte, error) {
+	var target, changes interface{}
+	if err := json.Unmarshal(document, &target); err != nil {
+		return nil, utils.NewBadRequest("document is not valid JSON")
+	}
+	if err := json.Unmarshal(patch, &changes); err != nil {
+		return nil, utils.NewBadRequest("merge patch is not valid JSON")
+	}
+
+	return json.Marshal(mergeValue(target, changes))
+}
+
+func mergeValue(target interface{}, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = map[string]interface{}{}
+	}
+
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+			continue
+		}
+		targetObject[key] = mergeValue(targetObject[key], value)
+	}
+
+	return targetObject
+}
+
+// Changes is the minimal update turning a stored document into its patched form
+type Changes struct {
+	Set   bson.M
+	Unset bson.M
+}
+
+// Diff compares the BSON form of before and after field by field
+func Diff(before interface{}, after interface{}) (Changes, error) {
+	old, err := toBSON(before)
+	if err != nil {
+		return Changes{}, err
+	}
+	updated, err := toBSON(after)
+	if err != nil {
+		return Changes{}, err
+	}
+
+	changes := Changes{Set: bson.M{}, Unset: bson.M{}}
+	for key, value := range updated {
+		if previous, ok := old[key]; !ok || !reflect.DeepEqual(previous, value) {
+			changes.Set[key] = value
+		}
+	}
+	for key := range old {
+		if _, ok := updated[key]; !ok {
+			changes.Unset[key] = ""
+		}
+	}
+
+	return changes, nil
+}
+
+// Empty reports whether the patch changed nothing
+func (c Changes) Empty() bool {
+	return len(c.Set) == 0 && len(c.Unset) == 0
+}
+
+// Update is the MongoDB update document applying the changes
+func (c Changes) Update() bson.M {
+	update := bson.M{}
+	if len(c.Set) > 0 {
+		update["$set"] = c.Set
+	}
+	if len(c.Unset) > 0 {
+		update["$unset"] = c.Unset
+	}
+	return update
+}
+
+func toBSON(value interface{}) (bson.M, error) {
+	raw, err := bson.Marshal(value)
+	if err != nil {
+		return nil, utils.NewInternal("failed to encode document")
+	}
+
+	var document bson.M
+	if err := bson.Unmarshal(raw, &document); err != nil {
+		return nil, utils.NewInternal("failed to decode document")
+	}
+	return document, nil
+}
@@ -0,0 +1,83 @@
+package patch_test
+
+import (
+	"testing"
+
+	"github.com/HasanNugroho/starter-golang/internal/shared/patch"
+	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type testDocument struct {
+	Name string   `json:"name" bson:"name" validate:"required"`
+	Tags []string `json:"tags,omitempty" bson:"tags,omitempty"`
+}
+
+func TestMergePatch_RFC7396(t *testing.T) {
+	result, err := patch.MergePatch(
+		[]byte(`{"a":"b","c":{"d":"e","f":"g"}}`),
+		[]byte(`{"a":"z","c":{"f":null}}`),
+	)
+
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"a":"z","c":{"d":"e"}}`, string(result))
+}
+
+func TestJSONPatch_Operations(t *testing.T) {
+	result, err := patch.JSONPatch(
+		[]byte(`{"name":"admin","tags":["a","b"]}`),
+		[]byte(`[
+			{"op":"test","path":"/name","value":"admin"},
+			{"op":"replace","path":"/name","value":"editor"},
+			{"op":"add","path":"/tags/1","value":"x"},
+			{"op":"remove","path":"/tags/0"},
+			{"op":"copy","from":"/name","path":"/alias"},
+			{"op":"move","from":"/alias","path":"/label"}
+		]`),
+	)
+
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"name":"editor","label":"editor","tags":["x","b"]}`, string(result))
+}
+
+func TestJSONPatch_FailedTest(t *testing.T) {
+	_, err := patch.JSONPatch([]byte(`{"name":"admin"}`), []byte(`[{"op":"test","path":"/name","value":"root"}]`))
+
+	assert.IsType(t, &utils.BadRequestError{}, err)
+}
+
+func TestApply_ValidatesResult(t *testing.T) {
+	var result testDocument
+	err := patch.Apply(patch.MergePatchContentType, testDocument{Name: "admin"}, []byte(`{"name":null}`), &result)
+
+	assert.IsType(t, &utils.BadRequestError{}, err)
+}
+
+func TestApply_RejectsUnknownFields(t *testing.T) {
+	var result testDocument
+	err := patch.Apply("application/json", testDocument{Name: "admin"}, []byte(`{"role":"x"}`), &result)
+
+	assert.IsType(t, &utils.BadRequestError{}, err)
+}
+
+func TestDiff_SetsAndUnsetsChangedFields(t *testing.T) {
+	before := testDocument{Name: "admin", Tags: []string{"a"}}
+
+	var after testDocument
+	err := patch.Apply(patch.JSONPatchContentType, before, []byte(`[{"op":"remove","path":"/tags"}]`), &after)
+	assert.NoError(t, err)
+
+	changes, err := patch.Diff(before, after)
+
+	assert.NoError(t, err)
+	assert.Empty(t, changes.Set)
+	assert.Equal(t, bson.M{"$unset": bson.M{"tags": ""}}, changes.Update())
+}
+
+func TestDiff_NoChanges(t *testing.T) {
+	changes, err := patch.Diff(testDocument{Name: "admin"}, testDocument{Name: "admin"})
+
+	assert.NoError(t, err)
+	assert.True(t, changes.Empty())
+}
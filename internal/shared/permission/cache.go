@@ -0,0 +1,60 @@
+package permission
+
+import (
+	"sync"
+	"time"
+)
+
+// Cache keeps the compiled matcher of recently seen tokens, so a token is
+// compiled once instead of on every request
+type Cache struct {
+	mu       sync.Mutex
+	size     int
+	matchers map[string]cachedMatcher
+}
+
+type cachedMatcher struct {
+	matcher *Matcher
+	expires time.Time
+}
+
+// NewCache creates a cache holding up to size matchers
+func NewCache(size int) *Cache {
+	return &Cache{size: size, matchers: make(map[string]cachedMatcher, size)}
+}
+
+// Get returns the matcher cached for the key, compiling and storing the granted
+// permissions when there is none. The entry is dropped once expires has passed.
+func (c *Cache) Get(key string, expires time.Time, granted []string) *Matcher {
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cached, ok := c.matchers[key]; ok && now.Before(cached.expires) {
+		return cached.matcher
+	}
+
+	matcher := Compile(granted)
+	if len(c.matchers) >= c.size {
+		c.evict(now)
+	}
+	c.matchers[key] = cachedMatcher{matcher: matcher, expires: expires}
+	return matcher
+}
+
+// evict drops expired matchers, and half of the others when that frees nothing
+func (c *Cache) evict(now time.Time) {
+	for key, cached := range c.matchers {
+		if !now.Before(cached.expires) {
+			delete(c.matchers, key)
+		}
+	}
+
+	for key := range c.matchers {
+		if len(c.matchers) < c.size/2 {
+			return
+		}
+		delete(c.matchers, key)
+	}
+}
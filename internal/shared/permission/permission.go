@@ -0,0 +1,121 @@
+// Package permission matches permission patterns such as users:*, *:read or
+// projects:123:tasks:update against the permissions a route requires.
+//
+// Permissions are made of segments separated by colons. A * segment matches any
+// single segment, and a trailing * also matches every deeper segment, so
+// projects:* covers projects:123:tasks:update while *:read only covers two
+// segment permissions.
+package permission
+
+import "strings"
+
+const (
+	// Separator splits a permission into its segments
+	Separator = ":"
+	// Wildcard matches any segment
+	Wildcard = "*"
+	// System grants every permission
+	System = "manage:system"
+)
+
+// Match reports whether the pattern covers the permission
+func Match(pattern string, permission string) bool {
+	if pattern == permission {
+		return true
+	}
+	if !strings.Contains(pattern, Wildcard) {
+		return false
+	}
+	return matchSegments(strings.Split(pattern, Separator), strings.Split(permission, Separator))
+}
+
+func matchSegments(pattern []string, permission []string) bool {
+	for i, segment := range pattern {
+		if i >= len(permission) {
+			return false
+		}
+		if segment == Wildcard && i == len(pattern)-1 {
+			return true
+		}
+		if segment != Wildcard && segment != permission[i] {
+			return false
+		}
+	}
+	return len(pattern) == len(permission)
+}
+
+// IsPattern reports whether the permission contains a wildcard segment
+func IsPattern(permission string) bool {
+	for _, segment := range strings.Split(permission, Separator) {
+		if segment == Wildcard {
+			return true
+		}
+	}
+	return false
+}
+
+// Registered reports whether the permission, a pattern or not, covers or is
+// covered by one of the registered permissions. Registered permissions may be
+// patterns themselves, e.g. projects:*:tasks:update for nested resources.
+func Registered(permission string, registered []string) bool {
+	for _, known := range registered {
+		if Match(permission, known) || Match(known, permission) {
+			return true
+		}
+	}
+	return false
+}
+
+// Matcher is a set of granted permissions compiled once for fast lookups
+type Matcher struct {
+	all      bool
+	exact    map[string]struct{}
+	patterns [][]string
+}
+
+// Compile prepares the granted permissions of a token for matching
+func Compile(granted []string) *Matcher {
+	m := &Matcher{exact: make(map[string]struct{}, len(granted))}
+	for _, permission := range granted {
+		switch {
+		case permission == System || permission == Wildcard:
+			m.all = true
+		case IsPattern(permission):
+			m.patterns = append(m.patterns, strings.Split(permission, Separator))
+		default:
+			m.exact[permission] = struct{}{}
+		}
+	}
+	return m
+}
+
+// Allows reports whether the granted permissions cover the permission
+func (m *Matcher) Allows(permission string) bool {
+	if m.all {
+		return true
+	}
+	if _, ok := m.exact[permission]; ok {
+		return true
+	}
+	if len(m.patterns) == 0 {
+		return false
+	}
+
+	segments := strings.Split(permission, Separator)
+	for _, pattern := range m.patterns {
+		if matchSegments(pattern, segments) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsAny reports whether the granted permissions cover one of the permissions
+func (m *Matcher) AllowsAny(permissions []string) bool {
+	for _, permission := range permissions {
+		if m.Allows(permission) {
+			return true
+		}
+	}
+	return false
+}
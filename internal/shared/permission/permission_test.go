@@ -0,0 +1,77 @@
+package permission_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/HasanNugroho/starter-golang/internal/shared/permission"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern    string
+		permission string
+		want       bool
+	}{
+		{"users:read", "users:read", true},
+		{"users:read", "users:update", false},
+		{"users:*", "users:read", true},
+		{"users:*", "users:export:csv", true},
+		{"users:*", "users", false},
+		{"users:*", "roles:read", false},
+		{"*:read", "users:read", true},
+		{"*:read", "users:update", false},
+		{"*:read", "projects:123:tasks:read", false},
+		{"projects:*:tasks:update", "projects:123:tasks:update", true},
+		{"projects:*:tasks:update", "projects:123:files:update", false},
+		{"projects:123:*", "projects:123:tasks:update", true},
+		{"projects:123:*", "projects:456:tasks:update", false},
+		{"*", "projects:123:tasks:update", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.permission, func(t *testing.T) {
+			assert.Equal(t, tt.want, permission.Match(tt.pattern, tt.permission))
+			assert.Equal(t, tt.want, permission.Compile([]string{tt.pattern}).Allows(tt.permission))
+		})
+	}
+}
+
+func TestMatcher_System(t *testing.T) {
+	matcher := permission.Compile([]string{"users:read", permission.System})
+
+	assert.True(t, matcher.Allows("roles:delete"))
+	assert.True(t, matcher.AllowsAny([]string{"anything:at:all"}))
+}
+
+func TestMatcher_AllowsAny(t *testing.T) {
+	matcher := permission.Compile([]string{"users:read", "projects:42:*"})
+
+	assert.True(t, matcher.AllowsAny([]string{"roles:read", "users:read"}))
+	assert.True(t, matcher.AllowsAny([]string{"projects:42:tasks:update"}))
+	assert.False(t, matcher.AllowsAny([]string{"projects:7:tasks:update", "users:update"}))
+	assert.False(t, matcher.AllowsAny(nil))
+}
+
+func TestRegistered(t *testing.T) {
+	registered := []string{"users:read", "users:update", "projects:*:tasks:update"}
+
+	assert.True(t, permission.Registered("users:read", registered))
+	assert.True(t, permission.Registered("users:*", registered))
+	assert.True(t, permission.Registered("*:read", registered))
+	assert.True(t, permission.Registered("projects:123:tasks:update", registered))
+	assert.False(t, permission.Registered("roles:*", registered))
+	assert.False(t, permission.Registered("users:delete", registered))
+}
+
+func TestCache_ReusesUntilExpired(t *testing.T) {
+	cache := permission.NewCache(2)
+	later := time.Now().Add(time.Hour)
+
+	first := cache.Get("token", later, []string{"users:read"})
+	assert.Same(t, first, cache.Get("token", later, []string{"roles:read"}))
+
+	expired := cache.Get("old", time.Now().Add(-time.Second), []string{"users:read"})
+	assert.NotSame(t, expired, cache.Get("old", later, []string{"users:read"}))
+}
@@ -0,0 +1,158 @@
+package permission
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Risk tells admins how much a permission exposes when granted
+type Risk string
+
+const (
+	RiskLow      Risk = "low"
+	RiskMedium   Risk = "medium"
+	RiskHigh     Risk = "high"
+	RiskCritical Risk = "critical"
+)
+
+// Definition is a permission a feature declares
+type Definition struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Category    string `json:"category"`
+	Risk        Risk   `json:"risk"`
+	// DependsOn lists permissions the holder also needs for this one to be useful,
+	// e.g. roles:assign needs roles:read to pick the role
+	DependsOn []string `json:"depends_on,omitempty"`
+}
+
+// Category groups the definitions sharing a category
+type Category struct {
+	Name        string       `json:"name"`
+	Permissions []Definition `json:"permissions"`
+}
+
+// Registry collects the permissions features declare, so roles can only grant
+// known permissions and routes can only require them
+type Registry struct {
+	lock        sync.RWMutex
+	definitions []Definition
+	index       map[string]int
+}
+
+func NewRegistry() *Registry {
+	return &Registry{index: map[string]int{}}
+}
+
+// Declare adds definitions, declaring a permission twice is an error
+func (r *Registry) Declare(definitions ...Definition) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for _, definition := range definitions {
+		if err := validDefinition(definition); err != nil {
+			return err
+		}
+		if _, ok := r.index[definition.Name]; ok {
+			return fmt.Errorf("permission %s is declared twice", definition.Name)
+		}
+		r.index[definition.Name] = len(r.definitions)
+		r.definitions = append(r.definitions, definition)
+	}
+	return nil
+}
+
+func validDefinition(definition Definition) error {
+	if definition.Name == "" || strings.Contains(definition.Name, Wildcard) {
+		return fmt.Errorf("permission %q: name must be set and can not be a pattern", definition.Name)
+	}
+	if definition.Description == "" || definition.Category == "" {
+		return fmt.Errorf("permission %s: description and category are required", definition.Name)
+	}
+	switch definition.Risk {
+	case RiskLow, RiskMedium, RiskHigh, RiskCritical:
+	default:
+		return fmt.Errorf("permission %s: unknown risk %q", definition.Name, definition.Risk)
+	}
+	return nil
+}
+
+// Lookup returns the definition of a permission
+func (r *Registry) Lookup(name string) (Definition, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	i, ok := r.index[name]
+	if !ok {
+		return Definition{}, false
+	}
+	return r.definitions[i], true
+}
+
+// Names returns the declared permissions in declaration order
+func (r *Registry) Names() []string {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	names := make([]string, len(r.definitions))
+	for i, definition := range r.definitions {
+		names[i] = definition.Name
+	}
+	return names
+}
+
+// Categories returns the definitions grouped by category, both sorted by name
+func (r *Registry) Categories() []Category {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	var categories []Category
+	positions := map[string]int{}
+	for _, definition := range r.definitions {
+		i, ok := positions[definition.Category]
+		if !ok {
+			i = len(categories)
+			positions[definition.Category] = i
+			categories = append(categories, Category{Name: definition.Category})
+		}
+		categories[i].Permissions = append(categories[i].Permissions, definition)
+	}
+
+	sort.Slice(categories, func(i, j int) bool { return categories[i].Name < categories[j].Name })
+	for _, category := range categories {
+		sort.Slice(category.Permissions, func(i, j int) bool {
+			return category.Permissions[i].Name < category.Permissions[j].Name
+		})
+	}
+	return categories
+}
+
+// Verify checks that dependencies and the permissions routes require are
+// declared. Required permissions are compared as written, so a template such
+// as projects:{id}:tasks:update must be declared the same way.
+func (r *Registry) Verify(required []string) error {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	var problems []string
+	for _, definition := range r.definitions {
+		for _, dependency := range definition.DependsOn {
+			if _, ok := r.index[dependency]; !ok {
+				problems = append(problems, fmt.Sprintf("%s depends on undeclared %s", definition.Name, dependency))
+			}
+		}
+	}
+	for _, name := range required {
+		if _, ok := r.index[name]; !ok {
+			problems = append(problems, fmt.Sprintf("%s is required by a route but not declared", name))
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("invalid permissions: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
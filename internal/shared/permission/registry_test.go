@@ -0,0 +1,53 @@
+package permission_test
+
+import (
+	"testing"
+
+	"github.com/HasanNugroho/starter-golang/internal/shared/permission"
+	"github.com/stretchr/testify/assert"
+)
+
+func newRegistry(t *testing.T) *permission.Registry {
+	registry := permission.NewRegistry()
+	assert.NoError(t, registry.Declare(
+		permission.Definition{Name: "users:read", Description: "List users", Category: "users", Risk: permission.RiskLow},
+		permission.Definition{Name: "roles:assign", Description: "Assign roles", Category: "roles", Risk: permission.RiskHigh, DependsOn: []string{"users:read"}},
+		permission.Definition{Name: "users:delete", Description: "Delete users", Category: "users", Risk: permission.RiskHigh},
+	))
+	return registry
+}
+
+func TestRegistry_Declare(t *testing.T) {
+	registry := newRegistry(t)
+
+	assert.Error(t, registry.Declare(permission.Definition{Name: "users:read", Description: "Again", Category: "users", Risk: permission.RiskLow}))
+	assert.Error(t, registry.Declare(permission.Definition{Name: "users:*", Description: "All", Category: "users", Risk: permission.RiskLow}))
+	assert.Error(t, registry.Declare(permission.Definition{Name: "users:ban", Category: "users", Risk: permission.RiskLow}))
+	assert.Error(t, registry.Declare(permission.Definition{Name: "users:ban", Description: "Ban", Category: "users", Risk: "extreme"}))
+
+	assert.Equal(t, []string{"users:read", "roles:assign", "users:delete"}, registry.Names())
+	definition, ok := registry.Lookup("roles:assign")
+	assert.True(t, ok)
+	assert.Equal(t, permission.RiskHigh, definition.Risk)
+}
+
+func TestRegistry_Categories(t *testing.T) {
+	categories := newRegistry(t).Categories()
+
+	assert.Len(t, categories, 2)
+	assert.Equal(t, "roles", categories[0].Name)
+	assert.Equal(t, "users", categories[1].Name)
+	assert.Equal(t, "users:delete", categories[1].Permissions[0].Name)
+	assert.Equal(t, "users:read", categories[1].Permissions[1].Name)
+}
+
+func TestRegistry_Verify(t *testing.T) {
+	registry := newRegistry(t)
+	assert.NoError(t, registry.Verify([]string{"users:read", "roles:assign"}))
+
+	err := registry.Verify([]string{"users:read", "roles:create"})
+	assert.ErrorContains(t, err, "roles:create is required by a route but not declared")
+
+	assert.NoError(t, registry.Declare(permission.Definition{Name: "roles:unassign", Description: "Unassign roles", Category: "roles", Risk: permission.RiskMedium, DependsOn: []string{"roles:read"}}))
+	assert.ErrorContains(t, registry.Verify(nil), "roles:unassign depends on undeclared roles:read")
+}
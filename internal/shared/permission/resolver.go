@@ -0,0 +1,38 @@
+package permission
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	// ModeToken trusts the permissions written into the token at login
+	ModeToken = "token"
+	// ModeCache resolves the permissions of every request through a Resolver,
+	// tokens only carry the identity of the user
+	ModeCache = "cache"
+)
+
+// ChangedEvent is emitted on app.Bus with a Changed payload when roles, groups
+// or assignments change what users are granted
+const ChangedEvent = "permission.changed"
+
+// Changed names the users whose permissions changed, none means any user may
+// be affected
+type Changed struct {
+	UserIDs []string
+}
+
+// Grants are the effective permissions of a user. Their matcher can be reused
+// until Expires while Generation is unchanged, an empty Generation means they
+// can't be.
+type Grants struct {
+	Permissions []string  `json:"permissions"`
+	Generation  string    `json:"generation"`
+	Expires     time.Time `json:"expires"`
+}
+
+// Resolver returns the effective permissions of a user, inherited ones included
+type Resolver interface {
+	Effective(ctx context.Context, userID string) (Grants, error)
+}
@@ -0,0 +1,287 @@
+package query
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"slices"
+	"strings"
+
+	shared "github.com/HasanNugroho/starter-golang/internal/shared/model"
+	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const (
+	directionNext = "next"
+	directionPrev = "prev"
+)
+
+// Page is a single page of a listing in either page or cursor mode
+type Page[T any] struct {
+	Items      []T
+	Total      int64
+	NextCursor string
+	PrevCursor string
+}
+
+// Response wraps the page into the standard paginated response body
+func (p Page[T]) Response(q *Query) shared.DataWithPagination {
+	paging := utils.BuildPagination(&q.PaginationFilter, p.Total)
+	if q.CursorMode {
+		paging.Page = 0
+	}
+
+	return shared.DataWithPagination{
+		Items:      p.Items,
+		Paging:     paging,
+		NextCursor: p.NextCursor,
+		PrevCursor: p.PrevCursor,
+	}
+}
+
+// cursorPayload is the signed content of an opaque cursor
+type cursorPayload struct {
+	Sort      string `bson:"s"`
+	Direction string `bson:"d"`
+	Values    bson.A `bson:"v"`
+}
+
+// Find runs the query against the collection. Page mode uses skip/limit and
+// always counts; cursor mode uses keyset pagination on the sort key and _id,
+// counting only when include_total is requested.
+func Find[T any](ctx context.Context, collection *mongo.Collection, q *Query, secret []byte) (Page[T], error) {
+	if !q.CursorMode {
+		return findPage[T](ctx, collection, q)
+	}
+	return findCursor[T](ctx, collection, q, secret)
+}
+
+func findPage[T any](ctx context.Context, collection *mongo.Collection, q *Query) (Page[T], error) {
+	opts := options.Find().
+		SetSkip(int64((q.Page - 1) * q.Limit)).
+		SetLimit(int64(q.Limit)).
+		SetSort(q.SortBy)
+
+	cursor, err := collection.Find(ctx, q.Filter, opts)
+	if err != nil {
+		return Page[T]{}, utils.NewInternal("failed to query data")
+	}
+	defer cursor.Close(ctx)
+
+	items := []T{}
+	if err := cursor.All(ctx, &items); err != nil {
+		return Page[T]{}, utils.NewInternal("failed to decode data")
+	}
+
+	total, err := collection.CountDocuments(ctx, q.Filter)
+	if err != nil {
+		return Page[T]{}, utils.NewInternal("failed to count documents")
+	}
+
+	return Page[T]{Items: items, Total: total}, nil
+}
+
+func findCursor[T any](ctx context.Context, collection *mongo.Collection, q *Query, secret []byte) (Page[T], error) {
+	sort := keysetSort(q.SortBy)
+	signature := sortSignature(sort)
+
+	filter := q.Filter
+	direction := directionNext
+	if q.Cursor != "" {
+		payload, err := decodeCursor(q.Cursor, secret)
+		if err != nil {
+			return Page[T]{}, err
+		}
+		if payload.Sort != signature || len(payload.Values) != len(sort) {
+			return Page[T]{}, utils.NewBadRequest("cursor does not match the requested sort")
+		}
+
+		direction = payload.Direction
+		filter = bson.M{"$and": bson.A{q.Filter, keysetFilter(sort, payload.Values, direction)}}
+	}
+
+	// Walking backwards reads the collection in reverse sort order
+	querySort := sort
+	if direction == directionPrev {
+		querySort = invertSort(sort)
+	}
+
+	opts := options.Find().
+		SetLimit(int64(q.Limit + 1)).
+		SetSort(querySort)
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return Page[T]{}, utils.NewInternal("failed to query data")
+	}
+	defer cursor.Close(ctx)
+
+	var raws []bson.Raw
+	if err := cursor.All(ctx, &raws); err != nil {
+		return Page[T]{}, utils.NewInternal("failed to decode data")
+	}
+
+	hasMore := len(raws) > q.Limit
+	if hasMore {
+		raws = raws[:q.Limit]
+	}
+	if direction == directionPrev {
+		slices.Reverse(raws)
+	}
+
+	page := Page[T]{Items: make([]T, 0, len(raws))}
+	for _, raw := range raws {
+		var item T
+		if err := bson.Unmarshal(raw, &item); err != nil {
+			return Page[T]{}, utils.NewInternal("failed to decode data")
+		}
+		page.Items = append(page.Items, item)
+	}
+
+	if len(raws) > 0 {
+		// Forward pages always have something behind them once a cursor was used,
+		// backward pages always have something ahead of them
+		hasNext := (direction == directionNext && hasMore) || direction == directionPrev
+		hasPrev := (direction == directionPrev && hasMore) || (direction == directionNext && q.Cursor != "")
+
+		if hasNext {
+			if page.NextCursor, err = encodeCursor(raws[len(raws)-1], sort, signature, directionNext, secret); err != nil {
+				return Page[T]{}, err
+			}
+		}
+		if hasPrev {
+			if page.PrevCursor, err = encodeCursor(raws[0], sort, signature, directionPrev, secret); err != nil {
+				return Page[T]{}, err
+			}
+		}
+	}
+
+	if q.WithTotal {
+		if page.Total, err = collection.CountDocuments(ctx, q.Filter); err != nil {
+			return Page[T]{}, utils.NewInternal("failed to count documents")
+		}
+	}
+
+	return page, nil
+}
+
+// keysetSort appends _id as a tie-breaker so every position is unique
+func keysetSort(sort bson.D) bson.D {
+	result := slices.Clone(sort)
+	for _, e := range result {
+		if e.Key == "_id" {
+			return result
+		}
+	}
+	return append(result, bson.E{Key: "_id", Value: 1})
+}
+
+func invertSort(sort bson.D) bson.D {
+	result := make(bson.D, 0, len(sort))
+	for _, e := range sort {
+		result = append(result, bson.E{Key: e.Key, Value: -e.Value.(int)})
+	}
+	return result
+}
+
+func sortSignature(sort bson.D) string {
+	parts := make([]string, 0, len(sort))
+	for _, e := range sort {
+		parts = append(parts, fmt.Sprintf("%s:%d", e.Key, e.Value))
+	}
+	return strings.Join(parts, ",")
+}
+
+// keysetFilter selects the documents strictly after (or before) the cursor position:
+// (k1 > v1) OR (k1 = v1 AND k2 > v2) OR ...
+// MongoDB sorts null and missing values first but never matches them with
+// $gt or $lt, so they are compared explicitly: after a null comes every
+// non-null value, before a value come the nulls and nothing comes before a null.
+// _id is never null.
+func keysetFilter(sort bson.D, values bson.A, direction string) bson.M {
+	branches := bson.A{}
+	for i, e := range sort {
+		op := "$gt"
+		if (e.Value.(int) < 0) != (direction == directionPrev) {
+			op = "$lt"
+		}
+		if op == "$lt" && values[i] == nil {
+			continue
+		}
+
+		branch := bson.M{}
+		for j := 0; j < i; j++ {
+			branch[sort[j].Key] = values[j]
+		}
+		switch {
+		case values[i] == nil:
+			branch[e.Key] = bson.M{"$ne": nil}
+		case op == "$lt" && e.Key != "_id":
+			branch["$or"] = bson.A{bson.M{e.Key: bson.M{op: values[i]}}, bson.M{e.Key: nil}}
+		default:
+			branch[e.Key] = bson.M{op: values[i]}
+		}
+		branches = append(branches, branch)
+	}
+	return bson.M{"$or": branches}
+}
+
+func encodeCursor(raw bson.Raw, sort bson.D, signature string, direction string, secret []byte) (string, error) {
+	values := make(bson.A, 0, len(sort))
+	for _, e := range sort {
+		var value interface{}
+		if rv, err := raw.LookupErr(strings.Split(e.Key, ".")...); err == nil {
+			if err := rv.Unmarshal(&value); err != nil {
+				return "", utils.NewInternal("failed to build cursor")
+			}
+		}
+		values = append(values, value)
+	}
+
+	data, err := bson.Marshal(cursorPayload{Sort: signature, Direction: direction, Values: values})
+	if err != nil {
+		return "", utils.NewInternal("failed to build cursor")
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data) + "." + base64.RawURLEncoding.EncodeToString(sign(data, secret)), nil
+}
+
+func decodeCursor(cursor string, secret []byte) (cursorPayload, error) {
+	invalid := utils.NewBadRequest("invalid cursor")
+
+	encoded, mac, ok := strings.Cut(cursor, ".")
+	if !ok {
+		return cursorPayload{}, invalid
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return cursorPayload{}, invalid
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(mac)
+	if err != nil || !hmac.Equal(signature, sign(data, secret)) {
+		return cursorPayload{}, invalid
+	}
+
+	var payload cursorPayload
+	if err := bson.Unmarshal(data, &payload); err != nil {
+		return cursorPayload{}, invalid
+	}
+	if payload.Direction != directionNext && payload.Direction != directionPrev {
+		return cursorPayload{}, invalid
+	}
+
+	return payload, nil
+}
+
+func sign(data []byte, secret []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("cursor:"))
+	mac.Write(data)
+	return mac.Sum(nil)[:16]
+}
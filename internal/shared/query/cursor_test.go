@@ -0,0 +1,80 @@
+package query
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+var testSecret = []byte("secret")
+
+func TestCursor_RoundTrip(t *testing.T) {
+	id := bson.NewObjectID()
+	createdAt := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	raw, err := bson.Marshal(bson.M{"_id": id, "created_at": createdAt, "name": "alice"})
+	assert.NoError(t, err)
+
+	sort := keysetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := encodeCursor(raw, sort, sortSignature(sort), directionNext, testSecret)
+	assert.NoError(t, err)
+
+	payload, err := decodeCursor(cursor, testSecret)
+	assert.NoError(t, err)
+	assert.Equal(t, "created_at:-1,_id:1", payload.Sort)
+	assert.Equal(t, directionNext, payload.Direction)
+	assert.Equal(t, bson.A{bson.NewDateTimeFromTime(createdAt), id}, payload.Values)
+}
+
+func TestCursor_RejectsTampering(t *testing.T) {
+	raw, _ := bson.Marshal(bson.M{"_id": bson.NewObjectID()})
+	sort := keysetSort(bson.D{})
+
+	cursor, err := encodeCursor(raw, sort, sortSignature(sort), directionNext, testSecret)
+	assert.NoError(t, err)
+
+	_, err = decodeCursor(cursor, []byte("other"))
+	assert.Error(t, err)
+
+	_, err = decodeCursor("x"+cursor, testSecret)
+	assert.Error(t, err)
+}
+
+func TestKeysetFilter(t *testing.T) {
+	sort := bson.D{{Key: "name", Value: 1}, {Key: "_id", Value: 1}}
+	values := bson.A{"bob", "id"}
+
+	assert.Equal(t, bson.M{"$or": bson.A{
+		bson.M{"name": bson.M{"$gt": "bob"}},
+		bson.M{"name": "bob", "_id": bson.M{"$gt": "id"}},
+	}}, keysetFilter(sort, values, directionNext))
+
+	assert.Equal(t, bson.M{"$or": bson.A{
+		bson.M{"$or": bson.A{bson.M{"name": bson.M{"$lt": "bob"}}, bson.M{"name": nil}}},
+		bson.M{"name": "bob", "_id": bson.M{"$lt": "id"}},
+	}}, keysetFilter(sort, values, directionPrev))
+}
+
+func TestKeysetFilter_NullValues(t *testing.T) {
+	sort := bson.D{{Key: "name", Value: 1}, {Key: "_id", Value: 1}}
+	values := bson.A{nil, "id"}
+
+	// Nulls sort first: the non-null values and the later nulls follow
+	assert.Equal(t, bson.M{"$or": bson.A{
+		bson.M{"name": bson.M{"$ne": nil}},
+		bson.M{"name": nil, "_id": bson.M{"$gt": "id"}},
+	}}, keysetFilter(sort, values, directionNext))
+
+	// Only the earlier nulls come before a null
+	assert.Equal(t, bson.M{"$or": bson.A{
+		bson.M{"name": nil, "_id": bson.M{"$lt": "id"}},
+	}}, keysetFilter(sort, values, directionPrev))
+
+	// Descending, the nulls come after every value
+	desc := bson.D{{Key: "name", Value: -1}, {Key: "_id", Value: 1}}
+	assert.Equal(t, bson.M{"$or": bson.A{
+		bson.M{"$or": bson.A{bson.M{"name": bson.M{"$lt": "bob"}}, bson.M{"name": nil}}},
+		bson.M{"name": "bob", "_id": bson.M{"$gt": "id"}},
+	}}, keysetFilter(desc, bson.A{"bob", "id"}, directionNext))
+}
@@ -0,0 +1,380 @@
+package query
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	shared "github.com/HasanNugroho/starter-golang/internal/shared/model"
+	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// FieldType is the type a filter value is converted to before querying
+type FieldType int
+
+const (
+	String FieldType = iota
+	Number
+	Bool
+	Time
+	ObjectID
+)
+
+// Operator is a comparison accepted in filter[field][operator]
+type Operator string
+
+const (
+	Eq         Operator = "eq"
+	Ne         Operator = "ne"
+	Contains   Operator = "contains"
+	StartsWith Operator = "startswith"
+	Gt         Operator = "gt"
+	Gte        Operator = "gte"
+	Lt         Operator = "lt"
+	Lte        Operator = "lte"
+	In         Operator = "in"
+	Nin        Operator = "nin"
+	Exists     Operator = "exists"
+)
+
+const (
+	defaultPage  = 1
+	defaultLimit = 10
+	maxLimit     = 100
+)
+
+// defaultOperators lists the operators allowed for a type when a field does not restrict them
+var defaultOperators = map[FieldType][]Operator{
+	String:   {Eq, Ne, Contains, StartsWith, In, Nin, Exists},
+	Number:   {Eq, Ne, Gt, Gte, Lt, Lte, In, Nin, Exists},
+	Bool:     {Eq, Ne, Exists},
+	Time:     {Eq, Ne, Gt, Gte, Lt, Lte, Exists},
+	ObjectID: {Eq, Ne, In, Nin, Exists},
+}
+
+// Field describes a filterable field of a resource
+type Field struct {
+	// Name is the document field, defaults to the API name
+	Name      string
+	Type      FieldType
+	Operators []Operator
+	Sortable  bool
+}
+
+// Alias maps a plain query parameter such as created_after onto a field condition
+type Alias struct {
+	Field    string
+	Operator Operator
+}
+
+// Schema is the per-resource whitelist of filterable and sortable fields
+type Schema struct {
+	Fields      map[string]Field
+	Search      []string
+	DefaultSort string
+	Aliases     map[string]Alias
+}
+
+// Query is a validated listing request ready to run against MongoDB.
+// Sending a cursor parameter (empty for the first page) switches to cursor mode.
+type Query struct {
+	shared.PaginationFilter
+	Filter     bson.M
+	SortBy     bson.D
+	CursorMode bool
+	Cursor     string
+	WithTotal  bool
+}
+
+var filterParam = regexp.MustCompile(`^filter\[([A-Za-z0-9_.]+)\](?:\[([A-Za-z]+)\])?$`)
+
+// Parse validates query string values against the schema and builds a Query
+func Parse(values url.Values, schema Schema) (*Query, error) {
+	q := &Query{
+		PaginationFilter: shared.PaginationFilter{
+			Page:   defaultPage,
+			Limit:  defaultLimit,
+			Sort:   values.Get("sort"),
+			Search: strings.TrimSpace(values.Get("search")),
+		},
+		Filter: bson.M{},
+	}
+
+	if err := parsePaging(values, q); err != nil {
+		return nil, err
+	}
+
+	conditions := make(map[string]bson.M)
+	addCondition := func(name string, op Operator, raw []string) error {
+		field, ok := schema.Fields[name]
+		if !ok {
+			return utils.NewBadRequest("unknown filter field: " + name)
+		}
+
+		if !field.allows(op) {
+			return utils.NewBadRequest(fmt.Sprintf("operator %s is not allowed on field %s", op, name))
+		}
+
+		value, err := field.convert(op, raw)
+		if err != nil {
+			return utils.NewBadRequest(fmt.Sprintf("invalid value for %s: %s", name, err.Error()))
+		}
+
+		column := field.column(name)
+		if conditions[column] == nil {
+			conditions[column] = bson.M{}
+		}
+		for key, v := range operatorExpression(op, value) {
+			conditions[column][key] = v
+		}
+		return nil
+	}
+
+	for key, raw := range values {
+		if alias, ok := schema.Aliases[key]; ok {
+			if err := addCondition(alias.Field, alias.Operator, raw); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if !strings.HasPrefix(key, "filter[") {
+			continue
+		}
+
+		match := filterParam.FindStringSubmatch(key)
+		if match == nil {
+			return nil, utils.NewBadRequest("invalid filter parameter: " + key)
+		}
+
+		op := Eq
+		if match[2] != "" {
+			op = Operator(strings.ToLower(match[2]))
+		}
+
+		if err := addCondition(match[1], op, raw); err != nil {
+			return nil, err
+		}
+	}
+
+	for column, condition := range conditions {
+		q.Filter[column] = condition
+	}
+
+	if search := SearchFilter(q.Search, schema.Search...); search != nil {
+		q.Filter["$or"] = search
+	}
+
+	sort, err := BuildSort(q.Sort, schema.sortFields(), schema.DefaultSort)
+	if err != nil {
+		return nil, err
+	}
+	q.SortBy = sort
+
+	return q, nil
+}
+
+// BuildSort converts a sort expression such as "-created_at,name" into a Mongo sort.
+// Only fields present in the allow-list (API name to document field) are accepted.
+func BuildSort(sort string, allowed map[string]string, fallback string) (bson.D, error) {
+	if strings.TrimSpace(sort) == "" {
+		sort = fallback
+	}
+
+	result := bson.D{}
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(sort, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		direction := 1
+		if strings.HasPrefix(part, "-") {
+			direction = -1
+			part = part[1:]
+		} else {
+			part = strings.TrimPrefix(part, "+")
+		}
+
+		field, ok := allowed[part]
+		if !ok {
+			return nil, utils.NewBadRequest("invalid sort field: " + part)
+		}
+		if seen[field] {
+			continue
+		}
+		seen[field] = true
+
+		result = append(result, bson.E{Key: field, Value: direction})
+	}
+
+	return result, nil
+}
+
+// SearchFilter builds a case-insensitive match of the keyword on any of the given fields
+func SearchFilter(search string, fields ...string) bson.A {
+	search = strings.TrimSpace(search)
+	if search == "" || len(fields) == 0 {
+		return nil
+	}
+
+	pattern := bson.Regex{Pattern: regexp.QuoteMeta(search), Options: "i"}
+	conditions := bson.A{}
+	for _, field := range fields {
+		conditions = append(conditions, bson.M{field: pattern})
+	}
+	return conditions
+}
+
+// ParseDate parses a query date given as RFC 3339 or YYYY-MM-DD
+func ParseDate(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected RFC 3339 or YYYY-MM-DD date")
+	}
+	return t, nil
+}
+
+func parsePaging(values url.Values, q *Query) error {
+	if raw := values.Get("page"); raw != "" {
+		page, err := strconv.Atoi(raw)
+		if err != nil || page < 1 {
+			return utils.NewBadRequest("page must be a positive number")
+		}
+		q.Page = page
+	}
+
+	if raw := values.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 {
+			return utils.NewBadRequest("limit must be a positive number")
+		}
+		q.Limit = min(limit, maxLimit)
+	}
+
+	if values.Has("cursor") {
+		q.CursorMode = true
+		q.Cursor = values.Get("cursor")
+	}
+
+	if raw := values.Get("include_total"); raw != "" {
+		withTotal, err := strconv.ParseBool(raw)
+		if err != nil {
+			return utils.NewBadRequest("include_total must be true or false")
+		}
+		q.WithTotal = withTotal
+	}
+
+	return nil
+}
+
+func (s Schema) sortFields() map[string]string {
+	allowed := make(map[string]string)
+	for name, field := range s.Fields {
+		if field.Sortable {
+			allowed[name] = field.column(name)
+		}
+	}
+	return allowed
+}
+
+func (f Field) column(name string) string {
+	if f.Name != "" {
+		return f.Name
+	}
+	return name
+}
+
+func (f Field) allows(op Operator) bool {
+	operators := f.Operators
+	if operators == nil {
+		operators = defaultOperators[f.Type]
+	}
+	for _, allowed := range operators {
+		if allowed == op {
+			return true
+		}
+	}
+	return false
+}
+
+// convert turns the raw query values into the typed value for the operator
+func (f Field) convert(op Operator, raw []string) (interface{}, error) {
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("value is required")
+	}
+
+	switch op {
+	case Exists:
+		return strconv.ParseBool(raw[0])
+	case In, Nin:
+		var items []string
+		for _, value := range raw {
+			items = append(items, strings.Split(value, ",")...)
+		}
+
+		list := bson.A{}
+		for _, item := range items {
+			value, err := f.scalar(strings.TrimSpace(item))
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		return list, nil
+	case Contains, StartsWith:
+		return raw[0], nil
+	default:
+		return f.scalar(raw[0])
+	}
+}
+
+func (f Field) scalar(raw string) (interface{}, error) {
+	switch f.Type {
+	case Number:
+		if n, err := strconv.ParseInt(raw, 10, 64); err == nil {
+			return n, nil
+		}
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("expected a number")
+		}
+		return n, nil
+	case Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("expected true or false")
+		}
+		return b, nil
+	case Time:
+		return ParseDate(raw)
+	case ObjectID:
+		id, err := bson.ObjectIDFromHex(raw)
+		if err != nil {
+			return nil, fmt.Errorf("expected an object id")
+		}
+		return id, nil
+	default:
+		return raw, nil
+	}
+}
+
+func operatorExpression(op Operator, value interface{}) bson.M {
+	switch op {
+	case Contains:
+		return bson.M{"$regex": bson.Regex{Pattern: regexp.QuoteMeta(value.(string)), Options: "i"}}
+	case StartsWith:
+		return bson.M{"$regex": bson.Regex{Pattern: "^" + regexp.QuoteMeta(value.(string)), Options: "i"}}
+	default:
+		return bson.M{"$" + string(op): value}
+	}
+}
@@ -0,0 +1,114 @@
+package query_test
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/HasanNugroho/starter-golang/internal/shared/query"
+	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+var testSchema = query.Schema{
+	Fields: map[string]query.Field{
+		"email":      {Type: query.String, Sortable: true},
+		"name":       {Type: query.String, Sortable: true},
+		"created_at": {Type: query.Time, Sortable: true},
+		"role_id":    {Name: "roles", Type: query.ObjectID},
+	},
+	Search:      []string{"email", "name"},
+	DefaultSort: "-created_at",
+	Aliases: map[string]query.Alias{
+		"created_after": {Field: "created_at", Operator: query.Gte},
+	},
+}
+
+func parse(t *testing.T, raw string) (*query.Query, error) {
+	values, err := url.ParseQuery(raw)
+	assert.NoError(t, err)
+	return query.Parse(values, testSchema)
+}
+
+func TestParse_Defaults(t *testing.T) {
+	q, err := parse(t, "")
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, q.Page)
+	assert.Equal(t, 10, q.Limit)
+	assert.Empty(t, q.Filter)
+	assert.Equal(t, bson.D{{Key: "created_at", Value: -1}}, q.SortBy)
+}
+
+func TestParse_FilterOperators(t *testing.T) {
+	q, err := parse(t, "filter[email][contains]=a.b&filter[created_at][gte]=2025-01-01&filter[created_at][lt]=2025-02-01")
+
+	assert.NoError(t, err)
+	assert.Equal(t, bson.M{"$regex": bson.Regex{Pattern: `a\.b`, Options: "i"}}, q.Filter["email"])
+	assert.Equal(t, bson.M{
+		"$gte": time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		"$lt":  time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC),
+	}, q.Filter["created_at"])
+}
+
+func TestParse_ImplicitEqAndFieldMapping(t *testing.T) {
+	id := bson.NewObjectID()
+	q, err := parse(t, "filter[role_id]="+id.Hex())
+
+	assert.NoError(t, err)
+	assert.Equal(t, bson.M{"$eq": id}, q.Filter["roles"])
+}
+
+func TestParse_InOperator(t *testing.T) {
+	q, err := parse(t, "filter[name][in]=alice,bob")
+
+	assert.NoError(t, err)
+	assert.Equal(t, bson.M{"$in": bson.A{"alice", "bob"}}, q.Filter["name"])
+}
+
+func TestParse_Alias(t *testing.T) {
+	q, err := parse(t, "created_after=2025-01-01T10:00:00Z")
+
+	assert.NoError(t, err)
+	assert.Equal(t, bson.M{"$gte": time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)}, q.Filter["created_at"])
+}
+
+func TestParse_SearchAndSort(t *testing.T) {
+	q, err := parse(t, "search=jo&sort=name,-email")
+
+	assert.NoError(t, err)
+	assert.Len(t, q.Filter["$or"], 2)
+	assert.Equal(t, bson.D{{Key: "name", Value: 1}, {Key: "email", Value: -1}}, q.SortBy)
+}
+
+func TestParse_Errors(t *testing.T) {
+	cases := []string{
+		"filter[password]=x",
+		"filter[email][gt]=x",
+		"filter[created_at][gte]=yesterday",
+		"filter[role_id]=not-an-id",
+		"filter[email]]=x",
+		"sort=password",
+		"sort=role_id",
+		"page=0",
+		"limit=abc",
+	}
+
+	for _, raw := range cases {
+		_, err := parse(t, raw)
+
+		var badRequest *utils.BadRequestError
+		assert.ErrorAs(t, err, &badRequest, raw)
+	}
+}
+
+func TestParse_CursorMode(t *testing.T) {
+	q, err := parse(t, "cursor=&limit=500&include_total=true")
+
+	assert.NoError(t, err)
+	assert.True(t, q.CursorMode)
+	assert.Empty(t, q.Cursor)
+	assert.True(t, q.WithTotal)
+	assert.Equal(t, 100, q.Limit)
+}
@@ -0,0 +1,64 @@
+package query
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// NotDeleted matches documents that have not been soft deleted
+func NotDeleted() bson.M {
+	return bson.M{"deleted_at": nil}
+}
+
+// OnlyDeleted matches documents in the trash
+func OnlyDeleted() bson.M {
+	return bson.M{"deleted_at": bson.M{"$ne": nil}}
+}
+
+// WithoutDeleted restricts the query to documents that have not been soft deleted
+func (q *Query) WithoutDeleted() *Query {
+	q.Filter = scoped(q.Filter, NotDeleted())
+	return q
+}
+
+// OnlyDeleted restricts the query to soft-deleted documents
+func (q *Query) OnlyDeleted() *Query {
+	q.Filter = scoped(q.Filter, OnlyDeleted())
+	return q
+}
+
+// Where restricts the query to documents matching the filter as well
+func (q *Query) Where(filter bson.M) *Query {
+	q.Filter = scoped(q.Filter, filter)
+	return q
+}
+
+// SoftDelete is the update marking a document as deleted by the given user
+func SoftDelete(deletedBy string) bson.M {
+	set := bson.M{"deleted_at": time.Now()}
+	if id, err := bson.ObjectIDFromHex(deletedBy); err == nil {
+		set["deleted_by"] = id
+	}
+	return bson.M{"$set": set}
+}
+
+// Restore is the update taking a document out of the trash
+func Restore() bson.M {
+	return bson.M{
+		"$unset": bson.M{"deleted_at": "", "deleted_by": ""},
+		"$set":   bson.M{"updated_at": time.Now()},
+	}
+}
+
+// PurgeBefore matches soft-deleted documents older than the cutoff
+func PurgeBefore(cutoff time.Time) bson.M {
+	return bson.M{"deleted_at": bson.M{"$lte": cutoff}}
+}
+
+func scoped(filter bson.M, scope bson.M) bson.M {
+	if len(filter) == 0 {
+		return scope
+	}
+	return bson.M{"$and": bson.A{filter, scope}}
+}
@@ -0,0 +1,91 @@
+package query
+
+import (
+	"context"
+
+	"github.com/HasanNugroho/starter-golang/internal/shared/tenant"
+	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// Collection is a collection whose documents are split between tenants.
+// Repositories reach it through For, which restricts every filter to the
+// active tenant of the request, so no method can forget the scope.
+type Collection struct {
+	collection *mongo.Collection
+	scope      func(ctx echo.Context, filter bson.M) bson.M
+}
+
+// Owned is a collection of documents organizations own, see tenant.Owned
+func Owned(collection *mongo.Collection) Collection {
+	return Collection{collection: collection, scope: tenant.Owned}
+}
+
+// Members is the users collection, restricted to the members of the active
+// tenant, see tenant.Members
+func Members(collection *mongo.Collection) Collection {
+	return Collection{collection: collection, scope: tenant.Members}
+}
+
+// For returns the collection as seen by the request
+func (c Collection) For(ctx echo.Context) Scoped {
+	return Scoped{ctx: ctx, collection: c.collection, scope: c.scope}
+}
+
+// Unscoped returns the whole collection, for the writes keeping references
+// consistent across tenants
+func (c Collection) Unscoped() *mongo.Collection {
+	return c.collection
+}
+
+// Scoped is a collection restricted to the active tenant of a request. Its
+// methods take the context separately so they can run in a transaction.
+type Scoped struct {
+	ctx        echo.Context
+	collection *mongo.Collection
+	scope      func(ctx echo.Context, filter bson.M) bson.M
+}
+
+// Filter restricts the filter to the active tenant, the filter is copied
+func (s Scoped) Filter(filter bson.M) bson.M {
+	return s.scope(s.ctx, filter)
+}
+
+func (s Scoped) FindOne(c context.Context, filter bson.M, opts ...options.Lister[options.FindOneOptions]) *mongo.SingleResult {
+	return s.collection.FindOne(c, s.Filter(filter), opts...)
+}
+
+func (s Scoped) Find(c context.Context, filter bson.M, opts ...options.Lister[options.FindOptions]) (*mongo.Cursor, error) {
+	return s.collection.Find(c, s.Filter(filter), opts...)
+}
+
+func (s Scoped) CountDocuments(c context.Context, filter bson.M) (int64, error) {
+	return s.collection.CountDocuments(c, s.Filter(filter))
+}
+
+// Aggregate runs the pipeline on the documents of the active tenant
+func (s Scoped) Aggregate(c context.Context, pipeline mongo.Pipeline) (*mongo.Cursor, error) {
+	scoped := append(mongo.Pipeline{{{Key: "$match", Value: s.Filter(bson.M{})}}}, pipeline...)
+	return s.collection.Aggregate(c, scoped)
+}
+
+func (s Scoped) UpdateOne(c context.Context, filter bson.M, update interface{}) (*mongo.UpdateResult, error) {
+	return s.collection.UpdateOne(c, s.Filter(filter), update)
+}
+
+func (s Scoped) DeleteOne(c context.Context, filter bson.M) (*mongo.DeleteResult, error) {
+	return s.collection.DeleteOne(c, s.Filter(filter))
+}
+
+// NotMatched explains why a write with the filter matched nothing, see utils.NotMatched
+func (s Scoped) NotMatched(c context.Context, filter bson.M) error {
+	return utils.NotMatched(s.ctx, c, s.collection, s.Filter(filter))
+}
+
+// FindIn is Find on the documents of the active tenant
+func FindIn[T any](c context.Context, collection Scoped, q *Query, secret []byte) (Page[T], error) {
+	return Find[T](c, collection.collection, q.Where(collection.Filter(bson.M{})), secret)
+}
@@ -0,0 +1,24 @@
+package query_test
+
+import (
+	"testing"
+
+	"github.com/HasanNugroho/starter-golang/internal/shared/query"
+	"github.com/HasanNugroho/starter-golang/internal/shared/tenant"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestCollection_Scope(t *testing.T) {
+	ctx := echo.New().NewContext(nil, nil)
+	filter := bson.M{"_id": "id"}
+
+	assert.Equal(t, bson.M{"_id": "id", "organization": nil}, query.Owned(nil).For(ctx).Filter(filter))
+	assert.Equal(t, bson.M{"_id": "id"}, query.Members(nil).For(ctx).Filter(filter))
+
+	id := bson.NewObjectID()
+	tenant.Set(ctx, tenant.Tenant{ID: id, Slug: "acme", Member: true})
+	assert.Equal(t, bson.M{"_id": "id", "organization": id}, query.Owned(nil).For(ctx).Filter(filter))
+	assert.Equal(t, bson.M{"_id": "id", "memberships.organization": id}, query.Members(nil).For(ctx).Filter(filter))
+}
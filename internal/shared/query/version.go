@@ -0,0 +1,9 @@
+package query
+
+import "go.mongodb.org/mongo-driver/v2/bson"
+
+// BumpVersion adds the version increment every write of a versioned document carries
+func BumpVersion(update bson.M) bson.M {
+	update["$inc"] = bson.M{"version": int64(1)}
+	return update
+}
@@ -0,0 +1,197 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var authorizationPattern = regexp.MustCompile(`^AWS4-HMAC-SHA256 Credential=([^/]+)/([^,]+), SignedHeaders=([^,]+), Signature=([0-9a-f]+)$`)
+
+// fakeS3 is an in-process S3 compatible server for path style requests.
+// It checks every signature with the same credentials as the client.
+type fakeS3 struct {
+	mu       sync.Mutex
+	objects  map[string]fakeObject
+	verifier *S3Storage
+}
+
+type fakeObject struct {
+	body        []byte
+	contentType string
+}
+
+func newFakeS3(t *testing.T) (*fakeS3, *S3Storage) {
+	fake := &fakeS3{objects: map[string]fakeObject{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	config := StorageConfig{
+		Driver:      DriverS3,
+		S3Endpoint:  server.URL,
+		S3Region:    "eu-west-1",
+		S3Bucket:    "avatars",
+		S3AccessKey: "access",
+		S3SecretKey: "secret",
+		S3PathStyle: true,
+	}
+
+	client, err := NewS3Storage(config)
+	require.NoError(t, err)
+	fake.verifier, err = NewS3Storage(config)
+	require.NoError(t, err)
+
+	return fake, client
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !f.authorized(r) {
+		w.WriteHeader(http.StatusForbidden)
+		io.WriteString(w, "<Error><Code>SignatureDoesNotMatch</Code></Error>")
+		return
+	}
+
+	key := strings.TrimPrefix(r.URL.Path, "/avatars/")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		f.objects[key] = fakeObject{body: body, contentType: r.Header.Get("Content-Type")}
+	case http.MethodGet:
+		object, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", object.contentType)
+		w.Write(object.body)
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (f *fakeS3) authorized(r *http.Request) bool {
+	headers := http.Header{}
+	headers.Set("Host", r.Host)
+
+	query := r.URL.Query()
+	if signature := query.Get("X-Amz-Signature"); signature != "" {
+		signedAt, err := time.Parse(s3AmzDateFormat, query.Get("X-Amz-Date"))
+		if err != nil {
+			return false
+		}
+		expires, _ := strconv.Atoi(query.Get("X-Amz-Expires"))
+		if time.Now().After(signedAt.Add(time.Duration(expires) * time.Second)) {
+			return false
+		}
+
+		query.Del("X-Amz-Signature")
+		target := *r.URL
+		target.RawQuery = query.Encode()
+		return f.verifier.signature(signedAt, r.Method, &target, headers, s3UnsignedHash) == signature
+	}
+
+	match := authorizationPattern.FindStringSubmatch(r.Header.Get("Authorization"))
+	if match == nil || match[1] != "access" {
+		return false
+	}
+
+	for _, name := range strings.Split(match[3], ";") {
+		if name != "host" {
+			headers.Set(name, r.Header.Get(name))
+		}
+	}
+
+	signedAt, err := time.Parse(s3AmzDateFormat, r.Header.Get("X-Amz-Date"))
+	if err != nil {
+		return false
+	}
+	return f.verifier.signature(signedAt, r.Method, r.URL, headers, r.Header.Get("X-Amz-Content-Sha256")) == match[4]
+}
+
+func TestS3Storage_PutGetDelete(t *testing.T) {
+	fake, client := newFakeS3(t)
+	ctx := context.Background()
+
+	body := "fake image bytes"
+	err := client.Put(ctx, "avatars/user 1/64.png", strings.NewReader(body), int64(len(body)), "image/png")
+	require.NoError(t, err)
+	assert.Contains(t, fake.objects, "avatars/user 1/64.png")
+
+	reader, object, err := client.Get(ctx, "avatars/user 1/64.png")
+	require.NoError(t, err)
+	defer reader.Close()
+
+	data, _ := io.ReadAll(reader)
+	assert.Equal(t, body, string(data))
+	assert.Equal(t, "image/png", object.ContentType)
+	assert.Equal(t, int64(len(body)), object.Size)
+
+	require.NoError(t, client.Delete(ctx, "avatars/user 1/64.png"))
+	_, _, err = client.Get(ctx, "avatars/user 1/64.png")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// Deleting a missing object is not an error
+	assert.NoError(t, client.Delete(ctx, "avatars/user 1/64.png"))
+}
+
+func TestS3Storage_RejectsBadSignature(t *testing.T) {
+	_, client := newFakeS3(t)
+	client.secretKey = "wrong"
+
+	err := client.Put(context.Background(), "a.png", strings.NewReader("x"), 1, "image/png")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "403")
+}
+
+func TestS3Storage_PresignGet(t *testing.T) {
+	_, client := newFakeS3(t)
+	ctx := context.Background()
+	require.NoError(t, client.Put(ctx, "avatars/a.png", strings.NewReader("png"), 3, "image/png"))
+
+	link, err := client.PresignGet("avatars/a.png", time.Minute)
+	require.NoError(t, err)
+
+	parsed, err := url.Parse(link)
+	require.NoError(t, err)
+	assert.Equal(t, "60", parsed.Query().Get("X-Amz-Expires"))
+
+	response, err := http.Get(link)
+	require.NoError(t, err)
+	defer response.Body.Close()
+	data, _ := io.ReadAll(response.Body)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "png", string(data))
+
+	// A tampered key no longer matches the signature
+	response, err = http.Get(strings.Replace(link, "a.png", "b.png", 1))
+	require.NoError(t, err)
+	response.Body.Close()
+	assert.Equal(t, http.StatusForbidden, response.StatusCode)
+}
+
+func TestS3Storage_VirtualHostedURL(t *testing.T) {
+	client, err := NewS3Storage(StorageConfig{S3Endpoint: "https://s3.example.com", S3Bucket: "media"})
+	require.NoError(t, err)
+
+	target, err := client.objectURL("avatars/a b.png")
+	require.NoError(t, err)
+	assert.Equal(t, "https://media.s3.example.com/avatars/a%20b.png", target.String())
+}
@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+const (
+	DriverLocal = "local"
+	DriverS3    = "s3"
+)
+
+// ErrNotFound is returned when no object is stored under the key
+var ErrNotFound = errors.New("object not found")
+
+type StorageConfig struct {
+	Driver        string `mapstructure:"STORAGE_DRIVER"`
+	LocalPath     string `mapstructure:"STORAGE_LOCAL_PATH"`
+	S3Endpoint    string `mapstructure:"S3_ENDPOINT"`
+	S3Region      string `mapstructure:"S3_REGION"`
+	S3Bucket      string `mapstructure:"S3_BUCKET"`
+	S3AccessKey   string `mapstructure:"S3_ACCESS_KEY"`
+	S3SecretKey   string `mapstructure:"S3_SECRET_KEY"`
+	S3PathStyle   bool   `mapstructure:"S3_USE_PATH_STYLE"`
+	URLExpiration int    `mapstructure:"FILE_URL_EXPIRED" envDefault:"60"`
+}
+
+// Object describes a stored blob
+type Object struct {
+	Key         string
+	Size        int64
+	ContentType string
+}
+
+// Storage keeps blobs under slash separated keys
+type Storage interface {
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, Object, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// New returns the storage selected by the config, the local filesystem by default
+func New(config StorageConfig) (Storage, error) {
+	switch config.Driver {
+	case DriverS3:
+		return NewS3Storage(config)
+	case DriverLocal, "":
+		root := config.LocalPath
+		if root == "" {
+			root = "./storage"
+		}
+		return NewLocalStorage(root)
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", config.Driver)
+	}
+}
+
+// CleanKey validates a key so it can not escape its storage root
+func CleanKey(key string) (string, error) {
+	cleaned := path.Clean("/" + key)[1:]
+	if cleaned == "" || cleaned != strings.TrimPrefix(key, "/") {
+		return "", fmt.Errorf("invalid key %q", key)
+	}
+	return cleaned, nil
+}
@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalStorage_PutGetDelete(t *testing.T) {
+	store, err := NewLocalStorage(t.TempDir())
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	require.NoError(t, store.Put(ctx, "avatars/u1/64.png", strings.NewReader("png"), 3, "image/png"))
+
+	reader, object, err := store.Get(ctx, "avatars/u1/64.png")
+	require.NoError(t, err)
+	data, _ := io.ReadAll(reader)
+	reader.Close()
+	assert.Equal(t, "png", string(data))
+	assert.Equal(t, "image/png", object.ContentType)
+	assert.Equal(t, int64(3), object.Size)
+
+	require.NoError(t, store.Delete(ctx, "avatars/u1/64.png"))
+	_, _, err = store.Get(ctx, "avatars/u1/64.png")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.NoError(t, store.Delete(ctx, "avatars/u1/64.png"))
+}
+
+func TestCleanKey(t *testing.T) {
+	for _, key := range []string{"", "../secret", "a/../../b", "a//b", "a/./b"} {
+		_, err := CleanKey(key)
+		assert.Error(t, err, key)
+	}
+
+	key, err := CleanKey("avatars/u1/64.png")
+	require.NoError(t, err)
+	assert.Equal(t, "avatars/u1/64.png", key)
+}
+
+func TestURLSigner(t *testing.T) {
+	store, err := NewLocalStorage(t.TempDir())
+	require.NoError(t, err)
+	signer := NewURLSigner(store, "secret", "/api/v1/files", time.Minute)
+
+	link, err := signer.URL("avatars/u1/64.png")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(link, "/api/v1/files/avatars/u1/64.png?"))
+
+	parsed, err := url.Parse(link)
+	require.NoError(t, err)
+	expires, signature := parsed.Query().Get("expires"), parsed.Query().Get("signature")
+
+	assert.True(t, signer.Verify("avatars/u1/64.png", expires, signature))
+	assert.False(t, signer.Verify("avatars/u2/64.png", expires, signature))
+	assert.False(t, signer.Verify("avatars/u1/64.png", expires+"0", signature))
+
+	signer.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	assert.False(t, signer.Verify("avatars/u1/64.png", expires, signature))
+}
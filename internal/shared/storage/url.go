@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Presigner is implemented by storages that can hand out download URLs themselves
+type Presigner interface {
+	PresignGet(key string, expires time.Duration) (string, error)
+}
+
+// URLSigner builds expiring download URLs. Storages that can presign are asked
+// directly, other keys are served by the API below basePath and verified with Verify.
+type URLSigner struct {
+	storage  Storage
+	secret   []byte
+	basePath string
+	expires  time.Duration
+	now      func() time.Time
+}
+
+func NewURLSigner(storage Storage, secret string, basePath string, expires time.Duration) *URLSigner {
+	return &URLSigner{
+		storage:  storage,
+		secret:   []byte(secret),
+		basePath: basePath,
+		expires:  expires,
+		now:      time.Now,
+	}
+}
+
+// URL returns a download URL for the key that stops working after the signer's expiry
+func (s *URLSigner) URL(key string) (string, error) {
+	if presigner, ok := s.storage.(Presigner); ok {
+		return presigner.PresignGet(key, s.expires)
+	}
+
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return "", err
+	}
+
+	expires := strconv.FormatInt(s.now().Add(s.expires).Unix(), 10)
+	query := url.Values{}
+	query.Set("expires", expires)
+	query.Set("signature", s.sign(cleaned, expires))
+	return s.basePath + "/" + encodePath(cleaned) + "?" + query.Encode(), nil
+}
+
+// Verify reports whether the signature was issued for the key and has not expired
+func (s *URLSigner) Verify(key string, expires string, signature string) bool {
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || s.now().Unix() > expiresAt {
+		return false
+	}
+
+	expected := s.sign(key, expires)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+func (s *URLSigner) sign(key string, expires string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(key + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,90 @@
+package tenant
+
+import (
+	"context"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+const (
+	// Header selects the active tenant of a request, it takes precedence over the token
+	Header = "X-Tenant"
+	// Claim is the token data key carrying the tenant chosen at login
+	Claim = "tenant"
+	// Field is the field of the documents an organization owns
+	Field = "organization"
+	// MemberField is the field of the users listing the organizations they belong to
+	MemberField = "memberships.organization"
+)
+
+// contextKey holds the active tenant of the request
+const contextKey = "tenant"
+
+// Tenant is the organization a request acts in
+type Tenant struct {
+	ID   bson.ObjectID
+	Slug string
+	// Member is false for system admins entering an organization they do not belong to
+	Member bool
+	// Permissions are granted by the roles of the membership, on top of the global ones
+	Permissions []string
+	// Generation is the grants generation Permissions were cached under, empty
+	// when they were not, see utils.GrantsGeneration
+	Generation string
+}
+
+// Resolver finds the organization a user asks for by id or slug, together
+// with what the user's membership grants there
+type Resolver interface {
+	Resolve(ctx context.Context, userID string, ref string) (Tenant, error)
+}
+
+// Set makes the tenant the active one for the rest of the request
+func Set(ctx echo.Context, tenant Tenant) {
+	ctx.Set(contextKey, tenant)
+}
+
+// Current returns the active tenant of the request
+func Current(ctx echo.Context) (Tenant, bool) {
+	tenant, ok := ctx.Get(contextKey).(Tenant)
+	return tenant, ok
+}
+
+// ID returns the id of the active tenant, nil outside of one
+func ID(ctx echo.Context) *bson.ObjectID {
+	if tenant, ok := Current(ctx); ok {
+		return &tenant.ID
+	}
+	return nil
+}
+
+// Owned restricts a filter to the documents the active tenant owns, or to the
+// global ones outside of a tenant. The filter is copied, not modified.
+func Owned(ctx echo.Context, filter bson.M) bson.M {
+	scoped := copyFilter(filter)
+	if id := ID(ctx); id != nil {
+		scoped[Field] = *id
+	} else {
+		scoped[Field] = nil
+	}
+	return scoped
+}
+
+// Members restricts a users filter to the members of the active tenant, users
+// are global so the filter is left as it is outside of a tenant
+func Members(ctx echo.Context, filter bson.M) bson.M {
+	scoped := copyFilter(filter)
+	if id := ID(ctx); id != nil {
+		scoped[MemberField] = *id
+	}
+	return scoped
+}
+
+func copyFilter(filter bson.M) bson.M {
+	scoped := make(bson.M, len(filter)+1)
+	for key, value := range filter {
+		scoped[key] = value
+	}
+	return scoped
+}
@@ -0,0 +1,33 @@
+package tenant_test
+
+import (
+	"testing"
+
+	"github.com/HasanNugroho/starter-golang/internal/shared/tenant"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestScope_OutsideTenant(t *testing.T) {
+	ctx := echo.New().NewContext(nil, nil)
+	filter := bson.M{"deleted_at": nil}
+
+	assert.Nil(t, tenant.ID(ctx))
+	assert.Equal(t, bson.M{"deleted_at": nil, "organization": nil}, tenant.Owned(ctx, filter))
+	assert.Equal(t, bson.M{"deleted_at": nil}, tenant.Members(ctx, filter))
+}
+
+func TestScope_InsideTenant(t *testing.T) {
+	ctx := echo.New().NewContext(nil, nil)
+	id := bson.NewObjectID()
+	tenant.Set(ctx, tenant.Tenant{ID: id, Slug: "acme", Member: true})
+	filter := bson.M{"deleted_at": nil}
+
+	current, ok := tenant.Current(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, "acme", current.Slug)
+	assert.Equal(t, bson.M{"deleted_at": nil, "organization": id}, tenant.Owned(ctx, filter))
+	assert.Equal(t, bson.M{"deleted_at": nil, "memberships.organization": id}, tenant.Members(ctx, filter))
+	assert.Equal(t, bson.M{"deleted_at": nil}, filter, "the filter is copied")
+}
@@ -0,0 +1,129 @@
+package utils
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/HasanNugroho/starter-golang/internal/app"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const (
+	StatusPending     = "pending"
+	StatusActive      = "active"
+	StatusSuspended   = "suspended"
+	StatusLocked      = "locked"
+	StatusDeactivated = "deactivated"
+)
+
+// UserStatusChangedEvent is emitted on app.Bus after every account status transition
+const UserStatusChangedEvent = "user.status_changed"
+
+// userStatusCacheTTL bounds how long a status read from the database is cached,
+// transitions overwrite the cache right away
+const userStatusCacheTTL = 10 * time.Minute
+
+// statusTransitions lists the statuses an account may move to from each status
+var statusTransitions = map[string][]string{
+	StatusPending:     {StatusActive, StatusDeactivated},
+	StatusActive:      {StatusSuspended, StatusLocked, StatusDeactivated},
+	StatusSuspended:   {StatusActive, StatusDeactivated},
+	StatusLocked:      {StatusActive, StatusDeactivated},
+	StatusDeactivated: {StatusActive},
+}
+
+// NormalizeStatus treats accounts created before statuses existed as active
+func NormalizeStatus(status string) string {
+	if status == "" {
+		return StatusActive
+	}
+	return status
+}
+
+// IsValidStatus reports whether the status is a known account status
+func IsValidStatus(status string) bool {
+	_, ok := statusTransitions[status]
+	return ok
+}
+
+// CanTransition reports whether an account may move from one status to another
+func CanTransition(from string, to string) bool {
+	return slices.Contains(statusTransitions[NormalizeStatus(from)], to)
+}
+
+// AccountStatusMessage explains why an account in the status is refused, empty when active
+func AccountStatusMessage(status string) string {
+	switch NormalizeStatus(status) {
+	case StatusActive:
+		return ""
+	case StatusPending:
+		return "account is pending activation"
+	case StatusSuspended:
+		return "account is suspended"
+	case StatusLocked:
+		return "account is locked"
+	case StatusDeactivated:
+		return "account is deactivated"
+	default:
+		return "account is not active"
+	}
+}
+
+// CheckAccountStatus returns the error an account in the status is refused with, nil when active
+func CheckAccountStatus(status string) error {
+	if message := AccountStatusMessage(status); message != "" {
+		return NewForbidden(message)
+	}
+	return nil
+}
+
+// CacheUserStatus records the current status of the user for request time checks
+func CacheUserStatus(app *app.Apps, userID string, status string) {
+	if !app.Config.Redis.Enabled {
+		return
+	}
+	app.Redis.Set(context.Background(), "user_status:"+userID, NormalizeStatus(status), userStatusCacheTTL)
+}
+
+// ForgetUserStatus drops the cached status so the next check reads the database
+func ForgetUserStatus(app *app.Apps, userID string) {
+	if !app.Config.Redis.Enabled {
+		return
+	}
+	app.Redis.Del(context.Background(), "user_status:"+userID)
+}
+
+// CurrentUserStatus returns the status of the user, from the cache when possible
+func CurrentUserStatus(app *app.Apps, userID string) (string, error) {
+	ctx := context.Background()
+	if app.Config.Redis.Enabled {
+		if status, err := app.Redis.Get(ctx, "user_status:"+userID).Result(); err == nil {
+			return status, nil
+		}
+	}
+
+	objectID, err := bson.ObjectIDFromHex(userID)
+	if err != nil {
+		return "", NewUnauthorized("invalid user id")
+	}
+
+	var user struct {
+		Status    string     `bson:"status"`
+		DeletedAt *time.Time `bson:"deleted_at"`
+	}
+	opts := options.FindOne().SetProjection(bson.M{"status": 1, "deleted_at": 1})
+	if err := app.DB.Collection("users").FindOne(ctx, bson.M{"_id": objectID}, opts).Decode(&user); err != nil {
+		return "", NewUnauthorized("user not found")
+	}
+
+	// Soft-deleted accounts can no longer be used, whatever their status
+	status := NormalizeStatus(user.Status)
+	if user.DeletedAt != nil {
+		status = StatusDeactivated
+	}
+
+	CacheUserStatus(app, userID, status)
+	return status, nil
+}
@@ -0,0 +1,30 @@
+package utils_test
+
+import (
+	"testing"
+
+	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanTransition(t *testing.T) {
+	assert.True(t, utils.CanTransition(utils.StatusPending, utils.StatusActive))
+	assert.True(t, utils.CanTransition(utils.StatusActive, utils.StatusSuspended))
+	assert.True(t, utils.CanTransition(utils.StatusLocked, utils.StatusActive))
+	assert.False(t, utils.CanTransition(utils.StatusDeactivated, utils.StatusSuspended))
+	assert.False(t, utils.CanTransition(utils.StatusActive, utils.StatusActive))
+}
+
+func TestCanTransition_LegacyAccountsAreActive(t *testing.T) {
+	assert.True(t, utils.CanTransition("", utils.StatusSuspended))
+	assert.False(t, utils.CanTransition("", utils.StatusActive))
+}
+
+func TestCheckAccountStatus(t *testing.T) {
+	assert.NoError(t, utils.CheckAccountStatus(utils.StatusActive))
+	assert.NoError(t, utils.CheckAccountStatus(""))
+
+	for _, status := range []string{utils.StatusPending, utils.StatusSuspended, utils.StatusLocked, utils.StatusDeactivated} {
+		assert.IsType(t, &utils.ForbiddenError{}, utils.CheckAccountStatus(status), status)
+	}
+}
@@ -0,0 +1,22 @@
+package utils
+
+import (
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// CurrentUserID returns the ID of the authenticated user from the request claims
+func CurrentUserID(ctx echo.Context) string {
+	claims, ok := ctx.Get("claims").(jwt.MapClaims)
+	if !ok {
+		return ""
+	}
+
+	data, ok := claims["data"].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+
+	id, _ := data["id"].(string)
+	return id
+}
@@ -0,0 +1,269 @@
+package utils
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/HasanNugroho/starter-golang/internal/app"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// DPoPHeader is the request header carrying the DPoP proof (RFC 9449)
+	DPoPHeader = "DPoP"
+
+	// DPoPScheme is the authorization scheme used for DPoP-bound access tokens
+	DPoPScheme = "DPoP"
+
+	dpopProofType       = "dpop+jwt"
+	defaultDPoPProofAge = 60
+)
+
+var dpopSigningMethods = []string{"ES256", "ES384", "ES512", "RS256", "RS384", "RS512", "PS256", "PS384", "PS512"}
+
+// DPoPProof holds the verified content of a DPoP proof
+type DPoPProof struct {
+	Thumbprint string
+	JTI        string
+	IssuedAt   time.Time
+}
+
+// ExtractToken splits the Authorization header into token and scheme.
+// Raw tokens without a scheme are still accepted for backward compatibility.
+func ExtractToken(header string) (token string, scheme string) {
+	header = strings.TrimSpace(header)
+	if parts := strings.SplitN(header, " ", 2); len(parts) == 2 {
+		switch {
+		case strings.EqualFold(parts[0], "Bearer"):
+			return strings.TrimSpace(parts[1]), "Bearer"
+		case strings.EqualFold(parts[0], DPoPScheme):
+			return strings.TrimSpace(parts[1]), DPoPScheme
+		}
+	}
+	return header, ""
+}
+
+// DPoPRequestURL returns the htu value expected for the current request
+func DPoPRequestURL(ctx echo.Context) string {
+	req := ctx.Request()
+	return fmt.Sprintf("%s://%s%s", ctx.Scheme(), req.Host, req.URL.Path)
+}
+
+// BoundThumbprint returns the cnf.jkt thumbprint of a DPoP-bound token, if any
+func BoundThumbprint(claims jwt.MapClaims) string {
+	cnf, ok := claims["cnf"].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	jkt, _ := cnf["jkt"].(string)
+	return jkt
+}
+
+// VerifyDPoPProof validates a DPoP proof against the request method and URL.
+// When accessToken is not empty, the proof must also carry its ath hash.
+func VerifyDPoPProof(app *app.Apps, proof string, method string, htu string, accessToken string) (*DPoPProof, error) {
+	if proof == "" {
+		return nil, NewUnauthorized("DPoP proof is required")
+	}
+
+	var thumbprint string
+	token, err := jwt.Parse(proof, func(token *jwt.Token) (interface{}, error) {
+		if typ, _ := token.Header["typ"].(string); !strings.EqualFold(typ, dpopProofType) {
+			return nil, fmt.Errorf("invalid proof type")
+		}
+
+		jwk, ok := token.Header["jwk"].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("missing jwk header")
+		}
+
+		key, jkt, err := parsePublicJWK(jwk)
+		if err != nil {
+			return nil, err
+		}
+		thumbprint = jkt
+		return key, nil
+	}, jwt.WithValidMethods(dpopSigningMethods), jwt.WithoutClaimsValidation())
+	if err != nil || !token.Valid {
+		return nil, NewUnauthorized("invalid DPoP proof")
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, NewUnauthorized("invalid DPoP proof claims")
+	}
+
+	if htm, _ := claims["htm"].(string); htm != method {
+		return nil, NewUnauthorized("DPoP proof method mismatch")
+	}
+
+	if claimed, _ := claims["htu"].(string); normalizeHTU(claimed) == "" || normalizeHTU(claimed) != normalizeHTU(htu) {
+		return nil, NewUnauthorized("DPoP proof URL mismatch")
+	}
+
+	jti, _ := claims["jti"].(string)
+	if jti == "" {
+		return nil, NewUnauthorized("DPoP proof jti is required")
+	}
+
+	iatRaw, ok := claims["iat"].(float64)
+	if !ok {
+		return nil, NewUnauthorized("DPoP proof iat is required")
+	}
+	issuedAt := time.Unix(int64(iatRaw), 0)
+
+	maxAge := time.Duration(app.Config.Security.DPoPProofMaxAge) * time.Second
+	if maxAge <= 0 {
+		maxAge = defaultDPoPProofAge * time.Second
+	}
+	if age := time.Since(issuedAt); age > maxAge || age < -maxAge {
+		return nil, NewUnauthorized("DPoP proof is expired")
+	}
+
+	if accessToken != "" {
+		sum := sha256.Sum256([]byte(accessToken))
+		if ath, _ := claims["ath"].(string); ath != base64.RawURLEncoding.EncodeToString(sum[:]) {
+			return nil, NewUnauthorized("DPoP proof access token hash mismatch")
+		}
+	}
+
+	// Reject replayed proofs
+	if app.Config.Redis.Enabled {
+		key := "dpop_jti:" + thumbprint + ":" + jti
+		stored, err := app.Redis.SetNX(context.Background(), key, "1", 2*maxAge).Result()
+		if err != nil {
+			return nil, NewInternal("failed to check DPoP proof")
+		}
+		if !stored {
+			return nil, NewUnauthorized("DPoP proof has already been used")
+		}
+	}
+
+	return &DPoPProof{
+		Thumbprint: thumbprint,
+		JTI:        jti,
+		IssuedAt:   issuedAt,
+	}, nil
+}
+
+// JWKThumbprint computes the RFC 7638 SHA-256 thumbprint of a public JWK
+func JWKThumbprint(jwk map[string]interface{}) (string, error) {
+	_, jkt, err := parsePublicJWK(jwk)
+	return jkt, err
+}
+
+// parsePublicJWK builds a public key from a JWK and returns it with its thumbprint
+func parsePublicJWK(jwk map[string]interface{}) (interface{}, string, error) {
+	if _, ok := jwk["d"]; ok {
+		return nil, "", fmt.Errorf("jwk must not contain private key material")
+	}
+
+	kty, _ := jwk["kty"].(string)
+	switch kty {
+	case "EC":
+		crv, _ := jwk["crv"].(string)
+		x, _ := jwk["x"].(string)
+		y, _ := jwk["y"].(string)
+
+		key, err := ecPublicKey(crv, x, y)
+		if err != nil {
+			return nil, "", err
+		}
+		canonical := fmt.Sprintf(`{"crv":"%s","kty":"EC","x":"%s","y":"%s"}`, crv, x, y)
+		return key, thumbprint(canonical), nil
+	case "RSA":
+		n, _ := jwk["n"].(string)
+		e, _ := jwk["e"].(string)
+
+		key, err := rsaPublicKey(n, e)
+		if err != nil {
+			return nil, "", err
+		}
+		canonical := fmt.Sprintf(`{"e":"%s","kty":"RSA","n":"%s"}`, e, n)
+		return key, thumbprint(canonical), nil
+	default:
+		return nil, "", fmt.Errorf("unsupported jwk key type")
+	}
+}
+
+func ecPublicKey(crv string, x string, y string) (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	var ecdhCurve ecdh.Curve
+	switch crv {
+	case "P-256":
+		curve, ecdhCurve = elliptic.P256(), ecdh.P256()
+	case "P-384":
+		curve, ecdhCurve = elliptic.P384(), ecdh.P384()
+	case "P-521":
+		curve, ecdhCurve = elliptic.P521(), ecdh.P521()
+	default:
+		return nil, fmt.Errorf("unsupported jwk curve")
+	}
+
+	xb, err := base64.RawURLEncoding.DecodeString(x)
+	if err != nil {
+		return nil, fmt.Errorf("invalid jwk x coordinate")
+	}
+	yb, err := base64.RawURLEncoding.DecodeString(y)
+	if err != nil {
+		return nil, fmt.Errorf("invalid jwk y coordinate")
+	}
+
+	size := (curve.Params().BitSize + 7) / 8
+	if len(xb) != size || len(yb) != size {
+		return nil, fmt.Errorf("invalid jwk coordinate length")
+	}
+
+	// Let crypto/ecdh reject points that are not on the curve
+	point := append([]byte{0x04}, append(xb, yb...)...)
+	if _, err := ecdhCurve.NewPublicKey(point); err != nil {
+		return nil, fmt.Errorf("invalid jwk point")
+	}
+
+	return &ecdsa.PublicKey{
+		Curve: curve,
+		X:     new(big.Int).SetBytes(xb),
+		Y:     new(big.Int).SetBytes(yb),
+	}, nil
+}
+
+func rsaPublicKey(n string, e string) (*rsa.PublicKey, error) {
+	nb, err := base64.RawURLEncoding.DecodeString(n)
+	if err != nil || len(nb) < 256 {
+		return nil, fmt.Errorf("invalid jwk modulus")
+	}
+	eb, err := base64.RawURLEncoding.DecodeString(e)
+	if err != nil || len(eb) == 0 || len(eb) > 4 {
+		return nil, fmt.Errorf("invalid jwk exponent")
+	}
+
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(nb),
+		E: int(new(big.Int).SetBytes(eb).Int64()),
+	}, nil
+}
+
+func thumbprint(canonical string) string {
+	sum := sha256.Sum256([]byte(canonical))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// normalizeHTU drops the query and fragment parts as required by RFC 9449
+func normalizeHTU(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Scheme) + "://" + strings.ToLower(u.Host) + strings.TrimSuffix(u.Path, "/")
+}
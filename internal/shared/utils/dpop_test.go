@@ -0,0 +1,132 @@
+package utils_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/HasanNugroho/starter-golang/config"
+	"github.com/HasanNugroho/starter-golang/internal/app"
+	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+const testHTU = "https://api.example.com/api/v1/users"
+
+func newDPoPTestApp() *app.Apps {
+	return &app.Apps{Config: &config.Config{}}
+}
+
+func newDPoPKey(t *testing.T) (*ecdsa.PrivateKey, map[string]interface{}) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	jwk := map[string]interface{}{
+		"kty": "EC",
+		"crv": "P-256",
+		"x":   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+		"y":   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+	}
+	return key, jwk
+}
+
+func signDPoPProof(t *testing.T, key *ecdsa.PrivateKey, jwk map[string]interface{}, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["typ"] = "dpop+jwt"
+	token.Header["jwk"] = jwk
+
+	proof, err := token.SignedString(key)
+	assert.NoError(t, err)
+	return proof
+}
+
+func proofClaims(method string, htu string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"htm": method,
+		"htu": htu,
+		"iat": time.Now().Unix(),
+		"jti": "proof-id",
+	}
+}
+
+func TestVerifyDPoPProof_Success(t *testing.T) {
+	key, jwk := newDPoPKey(t)
+	claims := proofClaims("GET", testHTU+"?page=1")
+
+	accessToken := "access-token"
+	sum := sha256.Sum256([]byte(accessToken))
+	claims["ath"] = base64.RawURLEncoding.EncodeToString(sum[:])
+
+	proof, err := utils.VerifyDPoPProof(newDPoPTestApp(), signDPoPProof(t, key, jwk, claims), "GET", testHTU, accessToken)
+
+	assert.NoError(t, err)
+	expected, _ := utils.JWKThumbprint(jwk)
+	assert.Equal(t, expected, proof.Thumbprint)
+	assert.Equal(t, "proof-id", proof.JTI)
+}
+
+func TestVerifyDPoPProof_MethodMismatch(t *testing.T) {
+	key, jwk := newDPoPKey(t)
+	proof := signDPoPProof(t, key, jwk, proofClaims("POST", testHTU))
+
+	_, err := utils.VerifyDPoPProof(newDPoPTestApp(), proof, "GET", testHTU, "")
+
+	assert.Error(t, err)
+}
+
+func TestVerifyDPoPProof_URLMismatch(t *testing.T) {
+	key, jwk := newDPoPKey(t)
+	proof := signDPoPProof(t, key, jwk, proofClaims("GET", "https://api.example.com/api/v1/roles"))
+
+	_, err := utils.VerifyDPoPProof(newDPoPTestApp(), proof, "GET", testHTU, "")
+
+	assert.Error(t, err)
+}
+
+func TestVerifyDPoPProof_Expired(t *testing.T) {
+	key, jwk := newDPoPKey(t)
+	claims := proofClaims("GET", testHTU)
+	claims["iat"] = time.Now().Add(-10 * time.Minute).Unix()
+
+	_, err := utils.VerifyDPoPProof(newDPoPTestApp(), signDPoPProof(t, key, jwk, claims), "GET", testHTU, "")
+
+	assert.Error(t, err)
+}
+
+func TestVerifyDPoPProof_AccessTokenHashMismatch(t *testing.T) {
+	key, jwk := newDPoPKey(t)
+	claims := proofClaims("GET", testHTU)
+	claims["ath"] = "not-the-hash"
+
+	_, err := utils.VerifyDPoPProof(newDPoPTestApp(), signDPoPProof(t, key, jwk, claims), "GET", testHTU, "access-token")
+
+	assert.Error(t, err)
+}
+
+func TestVerifyDPoPProof_WrongKey(t *testing.T) {
+	key, _ := newDPoPKey(t)
+	_, otherJWK := newDPoPKey(t)
+
+	_, err := utils.VerifyDPoPProof(newDPoPTestApp(), signDPoPProof(t, key, otherJWK, proofClaims("GET", testHTU)), "GET", testHTU, "")
+
+	assert.Error(t, err)
+}
+
+func TestExtractToken(t *testing.T) {
+	token, scheme := utils.ExtractToken("DPoP abc")
+	assert.Equal(t, "abc", token)
+	assert.Equal(t, utils.DPoPScheme, scheme)
+
+	token, scheme = utils.ExtractToken("Bearer abc")
+	assert.Equal(t, "abc", token)
+	assert.Equal(t, "Bearer", scheme)
+
+	token, scheme = utils.ExtractToken("abc")
+	assert.Equal(t, "abc", token)
+	assert.Empty(t, scheme)
+}
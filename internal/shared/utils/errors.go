@@ -42,6 +42,22 @@ func (e *ConflictError) Error() string {
 	return fmt.Sprintf("conflict: %s", e.Message)
 }
 
+type PreconditionFailedError struct {
+	Message string
+}
+
+func (e *PreconditionFailedError) Error() string {
+	return fmt.Sprintf("precondition failed: %s", e.Message)
+}
+
+type PreconditionRequiredError struct {
+	Message string
+}
+
+func (e *PreconditionRequiredError) Error() string {
+	return fmt.Sprintf("precondition required: %s", e.Message)
+}
+
 type InternalError struct {
 	Message string
 }
@@ -70,6 +86,14 @@ func NewConflict(msg string) error {
 	return &ConflictError{Message: msg}
 }
 
+func NewPreconditionFailed(msg string) error {
+	return &PreconditionFailedError{Message: msg}
+}
+
+func NewPreconditionRequired(msg string) error {
+	return &PreconditionRequiredError{Message: msg}
+}
+
 func NewInternal(msg string) error {
 	return &InternalError{Message: msg}
 }
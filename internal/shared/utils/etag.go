@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// expectedVersionKey holds the version parsed from If-Match on the request context
+const expectedVersionKey = "expected_version"
+
+// SetETag sends the version of the returned document as its entity tag
+func SetETag(ctx echo.Context, version int64) {
+	ctx.Response().Header().Set("ETag", FormatETag(version))
+}
+
+// FormatETag is the strong entity tag of a document version
+func FormatETag(version int64) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// ParseETag returns the document version of an entity tag, weak tags are accepted
+func ParseETag(tag string) (int64, bool) {
+	tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return 0, false
+	}
+
+	version, err := strconv.ParseInt(tag[1:len(tag)-1], 10, 64)
+	if err != nil || version < 0 {
+		return 0, false
+	}
+	return version, true
+}
+
+// SetExpectedVersion records the version the client expects to modify
+func SetExpectedVersion(ctx echo.Context, version int64) {
+	ctx.Set(expectedVersionKey, version)
+}
+
+// ExpectedVersion returns the version from If-Match, false when the request is not conditional
+func ExpectedVersion(ctx echo.Context) (int64, bool) {
+	version, ok := ctx.Get(expectedVersionKey).(int64)
+	return version, ok
+}
+
+// CheckVersion fails early when the client edits a version that is no longer current
+func CheckVersion(ctx echo.Context, current int64) error {
+	if expected, ok := ExpectedVersion(ctx); ok && expected != current {
+		return NewPreconditionFailed("resource was modified, fetch it again and retry")
+	}
+	return nil
+}
+
+// VersionedFilter restricts the update filter to the version from If-Match, if any.
+// Documents written before versioning have no version field and count as version 0.
+func VersionedFilter(ctx echo.Context, filter bson.M) bson.M {
+	expected, ok := ExpectedVersion(ctx)
+	if !ok {
+		return filter
+	}
+
+	scoped := bson.M{}
+	for key, value := range filter {
+		scoped[key] = value
+	}
+
+	if expected == 0 {
+		scoped["version"] = bson.M{"$in": bson.A{int64(0), nil}}
+	} else {
+		scoped["version"] = expected
+	}
+	return scoped
+}
+
+// NotMatched explains why a versioned update matched nothing: the document is
+// gone, or it exists at another version than the client expected
+func NotMatched(ctx echo.Context, c context.Context, collection *mongo.Collection, filter bson.M) error {
+	if _, ok := ExpectedVersion(ctx); ok {
+		if count, err := collection.CountDocuments(c, filter); err == nil && count > 0 {
+			return NewPreconditionFailed("resource was modified, fetch it again and retry")
+		}
+	}
+	return NewNotFound("data not found")
+}
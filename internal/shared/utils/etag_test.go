@@ -0,0 +1,52 @@
+package utils_test
+
+import (
+	"testing"
+
+	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestParseETag(t *testing.T) {
+	version, ok := utils.ParseETag(utils.FormatETag(7))
+	assert.True(t, ok)
+	assert.Equal(t, int64(7), version)
+
+	version, ok = utils.ParseETag(`W/"3"`)
+	assert.True(t, ok)
+	assert.Equal(t, int64(3), version)
+
+	for _, tag := range []string{"", "7", `"abc"`, `"-1"`, `"7`} {
+		_, ok := utils.ParseETag(tag)
+		assert.False(t, ok, tag)
+	}
+}
+
+func TestVersionedFilter(t *testing.T) {
+	ctx := echo.New().NewContext(nil, nil)
+	filter := bson.M{"_id": "x", "deleted_at": nil}
+
+	// Unconditional requests keep the filter as is
+	assert.Equal(t, filter, utils.VersionedFilter(ctx, filter))
+
+	utils.SetExpectedVersion(ctx, 4)
+	assert.Equal(t, bson.M{"_id": "x", "deleted_at": nil, "version": int64(4)}, utils.VersionedFilter(ctx, filter))
+	assert.NotContains(t, filter, "version")
+
+	// Documents written before versioning have no version field
+	utils.SetExpectedVersion(ctx, 0)
+	assert.Equal(t, bson.M{"$in": bson.A{int64(0), nil}}, utils.VersionedFilter(ctx, filter)["version"])
+}
+
+func TestCheckVersion(t *testing.T) {
+	ctx := echo.New().NewContext(nil, nil)
+	assert.NoError(t, utils.CheckVersion(ctx, 5))
+
+	utils.SetExpectedVersion(ctx, 5)
+	assert.NoError(t, utils.CheckVersion(ctx, 5))
+
+	var preconditionErr *utils.PreconditionFailedError
+	assert.ErrorAs(t, utils.CheckVersion(ctx, 6), &preconditionErr)
+}
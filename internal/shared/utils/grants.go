@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/HasanNugroho/starter-golang/internal/app"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// grantsGenerationKey counts the changes that may affect any user
+	grantsGenerationKey = "grants_generation"
+	// grantsGenerationPrefix prefixes the counters of a single user, the user id follows
+	grantsGenerationPrefix = "grants_generation:"
+	// grantsGenerationTTL keeps a user's counter well past the entries cached
+	// under it, so a counter starting over never repeats a generation in use
+	grantsGenerationTTL = 24 * time.Hour
+)
+
+// ErrGrantsChanged is returned by StoreGrants when the grants of the user
+// changed while they were loaded, what was loaded may already be stale
+var ErrGrantsChanged = errors.New("grants changed while they were loaded")
+
+// GrantsGeneration identifies the current version of what the user is granted.
+// Caches read it before loading the grants and store them with StoreGrants.
+func GrantsGeneration(ctx context.Context, app *app.Apps, userID string) (string, error) {
+	values, err := app.Redis.MGet(ctx, grantsGenerationKeys(userID)...).Result()
+	if err != nil {
+		return "", NewInternal("failed to read grants generation")
+	}
+	return grantsGeneration(values), nil
+}
+
+// ChangeGrants moves the users to a new generation, every user when none is
+// given, so fills loaded before the change are not stored
+func ChangeGrants(ctx context.Context, app *app.Apps, userIDs ...string) error {
+	pipe := app.Redis.Pipeline()
+	if len(userIDs) == 0 {
+		pipe.Incr(ctx, grantsGenerationKey)
+	}
+	for _, userID := range userIDs {
+		pipe.Incr(ctx, grantsGenerationPrefix+userID)
+		pipe.Expire(ctx, grantsGenerationPrefix+userID, grantsGenerationTTL)
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return NewInternal("failed to change grants generation")
+	}
+	return nil
+}
+
+// StoreGrants runs the writes of store in a transaction, unless the grants of
+// the user moved past generation since it was read. ErrGrantsChanged is
+// returned then.
+func StoreGrants(ctx context.Context, app *app.Apps, userID string, generation string, store func(pipe redis.Pipeliner)) error {
+	keys := grantsGenerationKeys(userID)
+	err := app.Redis.Watch(ctx, func(tx *redis.Tx) error {
+		values, err := tx.MGet(ctx, keys...).Result()
+		if err != nil {
+			return err
+		}
+		if grantsGeneration(values) != generation {
+			return ErrGrantsChanged
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			store(pipe)
+			return nil
+		})
+		return err
+	}, keys...)
+
+	// A change between the check and the write aborts the transaction
+	if errors.Is(err, redis.TxFailedErr) {
+		return ErrGrantsChanged
+	}
+	return err
+}
+
+func grantsGenerationKeys(userID string) []string {
+	return []string{grantsGenerationKey, grantsGenerationPrefix + userID}
+}
+
+// grantsGeneration joins the counters read by MGet, missing ones count as 0
+func grantsGeneration(values []interface{}) string {
+	parts := make([]string, len(values))
+	for i, value := range values {
+		parts[i] = "0"
+		if counter, ok := value.(string); ok {
+			parts[i] = counter
+		}
+	}
+	return strings.Join(parts, ".")
+}
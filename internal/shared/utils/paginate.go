@@ -8,7 +8,10 @@ import (
 
 func BuildPagination(filter *shared.PaginationFilter, totalItems int64) shared.Pagination {
 	// Hitung total halaman
-	totalPages := int(math.Ceil(float64(totalItems) / float64(filter.Limit)))
+	totalPages := 0
+	if filter.Limit > 0 {
+		totalPages = int(math.Ceil(float64(totalItems) / float64(filter.Limit)))
+	}
 
 	// Buat response dengan pagination
 	return shared.Pagination{
@@ -0,0 +1,26 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// NewSecretToken returns a random URL-safe token for one-time links
+// together with the key it is stored under
+func NewSecretToken() (token string, key string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", NewInternal("failed to generate token")
+	}
+
+	token = base64.RawURLEncoding.EncodeToString(buf)
+	return token, SecretKey(token), nil
+}
+
+// SecretKey hashes a one-time token so only the hash needs to be stored
+func SecretKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -3,22 +3,88 @@ package utils
 import (
 	"context"
 	"encoding/json"
+	"maps"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/HasanNugroho/starter-golang/internal/app"
+	"github.com/HasanNugroho/starter-golang/internal/shared/tenant"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
 )
 
-// createJWT generates a JWT token with a given expiration time
-func createJWT(secretKey string, payload map[string]interface{}, expiration time.Duration) (string, error) {
-	claims := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+const (
+	// TokenExchangeGrantType is the OAuth2 grant type for token exchange (RFC 8693)
+	TokenExchangeGrantType = "urn:ietf:params:oauth:grant-type:token-exchange"
+
+	// AccessTokenType identifies an access token in token exchange requests
+	AccessTokenType = "urn:ietf:params:oauth:token-type:access_token"
+
+	// TokenTypeClaim tells access tokens from refresh tokens, only access
+	// tokens authenticate requests and can be exchanged
+	TokenTypeClaim = "typ"
+	// TokenTypeAccess is the TokenTypeClaim of access tokens, exchanged ones included
+	TokenTypeAccess = "access"
+	// TokenTypeRefresh is the TokenTypeClaim of refresh tokens
+	TokenTypeRefresh = "refresh"
+)
+
+// createJWT generates a JWT token with a given expiration time.
+// Extra claims such as cnf, aud or act are added next to the standard ones.
+func createJWT(secretKey string, payload map[string]interface{}, expiration time.Duration, extra jwt.MapClaims) (string, error) {
+	mapClaims := jwt.MapClaims{
 		"data": payload,
 		"exp":  time.Now().Add(expiration).Unix(),
 		"iat":  time.Now().Unix(),
-	})
+	}
+	for key, value := range extra {
+		mapClaims[key] = value
+	}
+
+	claims := jwt.NewWithClaims(jwt.SigningMethodHS256, mapClaims)
 	return claims.SignedString([]byte(secretKey))
 }
 
+// tokenClaims returns the claims shared by every token issued to a user:
+// the token type, the user's current token version and, when set, the DPoP
+// key binding
+func tokenClaims(app *app.Apps, userID string, jkt string, typ string) (jwt.MapClaims, error) {
+	version, err := CurrentTokenVersion(app, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := jwt.MapClaims{"tv": version, TokenTypeClaim: typ}
+	if jkt != "" {
+		claims["cnf"] = map[string]interface{}{"jkt": jkt}
+	}
+	return claims, nil
+}
+
+// IsTokenType checks the TokenTypeClaim of the token. Tokens issued before the
+// claim existed have none and are not of any type.
+func IsTokenType(claims jwt.MapClaims, typ string) bool {
+	current, _ := claims[TokenTypeClaim].(string)
+	return current == typ
+}
+
+// IdentityClaims drops the permissions and roles from a token payload when
+// app.Grants resolves them on every request, the payload is left untouched
+func IdentityClaims(app *app.Apps, payload map[string]interface{}) map[string]interface{} {
+	if app.Grants == nil {
+		return payload
+	}
+
+	identity := make(map[string]interface{}, len(payload))
+	for key, value := range payload {
+		if key != "permission" && key != "roles" {
+			identity[key] = value
+		}
+	}
+	return identity
+}
+
 // ValidateToken verifies the given JWT token
 func ValidateToken(app *app.Apps, tokenStr string) (*jwt.Token, error) {
 	if app.Config.Redis.Enabled && IsTokenRevoked(app, tokenStr) {
@@ -33,10 +99,21 @@ func ValidateToken(app *app.Apps, tokenStr string) (*jwt.Token, error) {
 		return nil, NewUnauthorized("Token is invalid or has been revoked")
 	}
 
+	// Tokens issued before the user's tokens were revoked are no longer valid
+	if app.Config.Redis.Enabled {
+		claims, ok := token.Claims.(jwt.MapClaims)
+		if !ok || !isTokenVersionCurrent(app, claims) {
+			return nil, NewUnauthorized("Token is invalid or has been revoked")
+		}
+	}
+
 	return token, nil
 }
 
-func GenerateAuthToken(app *app.Apps, payload interface{}) (accessToken string, refreshToken string, err error) {
+// GenerateAuthToken issues an access and refresh token pair.
+// A non-empty jkt binds both tokens to the client's DPoP key, a non-nil
+// notAfter ends the access token when the permissions it carries run out.
+func GenerateAuthToken(app *app.Apps, payload interface{}, jkt string, notAfter *time.Time) (accessToken string, refreshToken string, err error) {
 	payloadBytes, err := json.Marshal(payload)
 	if err != nil {
 		return "", "", NewInternal("failed to generate token")
@@ -47,19 +124,32 @@ func GenerateAuthToken(app *app.Apps, payload interface{}) (accessToken string,
 		return "", "", NewInternal("failed to generate token")
 	}
 
-	accessToken, err = createJWT(app.Config.Security.JWTSecretKey, parsedMap, time.Hour*time.Duration(app.Config.Security.JWTExpired))
+	userID, ok := parsedMap["id"].(string)
+	if !ok || userID == "" {
+		return "", "", NewBadRequest("user ID not found or invalid")
+	}
+
+	claims, err := tokenClaims(app, userID, jkt, TokenTypeAccess)
 	if err != nil {
-		return "", "", NewInternal("failed to generate token")
+		return "", "", err
 	}
 
-	refreshToken, err = createJWT(app.Config.Security.JWTSecretKey, map[string]interface{}{"id": parsedMap["id"]}, time.Hour*time.Duration(app.Config.Security.JWTRefreshTokenExpired))
+	accessToken, err = createJWT(app.Config.Security.JWTSecretKey, parsedMap, accessExpiration(time.Hour*time.Duration(app.Config.Security.JWTExpired), notAfter), claims)
 	if err != nil {
 		return "", "", NewInternal("failed to generate token")
 	}
 
-	userID, ok := parsedMap["id"].(string)
-	if !ok || userID == "" {
-		return "", "", NewBadRequest("user ID not found or invalid")
+	// The refresh token keeps the tenant so renewed access tokens stay in it
+	refreshData := map[string]interface{}{"id": parsedMap["id"]}
+	if current, ok := parsedMap[tenant.Claim].(string); ok {
+		refreshData[tenant.Claim] = current
+	}
+
+	refreshClaims := maps.Clone(claims)
+	refreshClaims[TokenTypeClaim] = TokenTypeRefresh
+	refreshToken, err = createJWT(app.Config.Security.JWTSecretKey, refreshData, time.Hour*time.Duration(app.Config.Security.JWTRefreshTokenExpired), refreshClaims)
+	if err != nil {
+		return "", "", NewInternal("failed to generate token")
 	}
 
 	// Store refresh token
@@ -70,15 +160,19 @@ func GenerateAuthToken(app *app.Apps, payload interface{}) (accessToken string,
 	return accessToken, refreshToken, nil
 }
 
-// RefreshAccessToken validates refresh token and returns a new access token
-func RefreshAccessToken(app *app.Apps, refreshToken string, newPayload map[string]interface{}) (string, error) {
+// RefreshAccessToken validates refresh token and returns a new access token,
+// ending no later than notAfter when set
+func RefreshAccessToken(app *app.Apps, refreshToken string, newPayload map[string]interface{}, jkt string, notAfter *time.Time) (string, error) {
 	ctx := context.Background()
 
 	// Cek apakah token valid
-	_, err := ValidateToken(app, refreshToken)
+	token, err := ValidateToken(app, refreshToken)
 	if err != nil {
 		return "", NewBadRequest("invalid refresh token")
 	}
+	if claims, ok := token.Claims.(jwt.MapClaims); !ok || !IsTokenType(claims, TokenTypeRefresh) {
+		return "", NewBadRequest("invalid refresh token")
+	}
 
 	// Cek apakah refresh token sudah tidak berlaku
 	key := "refresh_token:" + refreshToken
@@ -87,7 +181,13 @@ func RefreshAccessToken(app *app.Apps, refreshToken string, newPayload map[strin
 		return "", NewUnauthorized("refresh token not found or revoked")
 	}
 
-	newAccessToken, err := createJWT(app.Config.Security.JWTSecretKey, newPayload, time.Minute*time.Duration(app.Config.Security.JWTExpired))
+	userID, _ := newPayload["id"].(string)
+	claims, err := tokenClaims(app, userID, jkt, TokenTypeAccess)
+	if err != nil {
+		return "", err
+	}
+
+	newAccessToken, err := createJWT(app.Config.Security.JWTSecretKey, newPayload, accessExpiration(time.Minute*time.Duration(app.Config.Security.JWTExpired), notAfter), claims)
 	if err != nil {
 		return "", NewInternal("failed to generate token")
 	}
@@ -148,3 +248,108 @@ func StoreRefreshToken(app *app.Apps, userID string, refreshToken string, expira
 
 	return app.Redis.Set(context.Background(), key, userID, expiration).Err()
 }
+
+// TokenAudience returns the audience this API accepts for access tokens
+func TokenAudience(app *app.Apps) string {
+	if app.Config.Security.JWTAudience != "" {
+		return app.Config.Security.JWTAudience
+	}
+	return app.Config.AppName
+}
+
+// AcceptsAudience reports whether an audience-restricted token is meant for this API.
+// Tokens without an aud claim are first-party tokens and always accepted.
+func AcceptsAudience(app *app.Apps, claims jwt.MapClaims) bool {
+	audiences, err := claims.GetAudience()
+	if err != nil {
+		return false
+	}
+	if len(audiences) == 0 {
+		return true
+	}
+	return slices.Contains(audiences, TokenAudience(app))
+}
+
+// GenerateExchangedToken issues a short-lived, audience-restricted access token (RFC 8693).
+// The token carries no refresh token and records the delegation chain in act.
+// A non-empty jkt keeps the DPoP binding of the subject token, a non-nil
+// notAfter ends the token when the delegated permissions run out.
+func GenerateExchangedToken(app *app.Apps, payload map[string]interface{}, audience string, scope []string, act map[string]interface{}, jkt string, notAfter *time.Time) (string, time.Duration, error) {
+	expiration := time.Minute * time.Duration(app.Config.Security.TokenExchangeExpired)
+	if expiration <= 0 {
+		expiration = 5 * time.Minute
+	}
+	expiration = accessExpiration(expiration, notAfter)
+
+	userID, _ := payload["id"].(string)
+	claims, err := tokenClaims(app, userID, jkt, TokenTypeAccess)
+	if err != nil {
+		return "", 0, err
+	}
+	claims["aud"] = audience
+	claims["act"] = act
+	claims["scope"] = strings.Join(scope, " ")
+
+	token, err := createJWT(app.Config.Security.JWTSecretKey, payload, expiration, claims)
+	if err != nil {
+		return "", 0, NewInternal("failed to generate token")
+	}
+
+	return token, expiration, nil
+}
+
+// accessExpiration shortens the lifetime of an access token to end at notAfter.
+// Revoking tokens needs Redis, without it this is what takes the permissions
+// of an expired role assignment away.
+func accessExpiration(expiration time.Duration, notAfter *time.Time) time.Duration {
+	if notAfter == nil {
+		return expiration
+	}
+	return max(min(expiration, time.Until(*notAfter)), time.Second)
+}
+
+// RevokeUserTokens invalidates every access and refresh token issued to the user
+// by bumping the user's token version
+func RevokeUserTokens(app *app.Apps, userID string) error {
+	if !app.Config.Redis.Enabled {
+		return nil
+	}
+
+	if err := app.Redis.Incr(context.Background(), "token_version:"+userID).Err(); err != nil {
+		return NewInternal("failed to revoke user tokens")
+	}
+	return nil
+}
+
+// CurrentTokenVersion returns the token version new tokens of the user are issued with.
+// It fails when Redis can't be read, a revoked token must not pass as current.
+func CurrentTokenVersion(app *app.Apps, userID string) (int64, error) {
+	if !app.Config.Redis.Enabled || userID == "" {
+		return 0, nil
+	}
+
+	version, err := app.Redis.Get(context.Background(), "token_version:"+userID).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, NewInternal("failed to read token version")
+	}
+	return version, nil
+}
+
+// isTokenVersionCurrent checks the token was issued after the user's last revocation
+func isTokenVersionCurrent(app *app.Apps, claims jwt.MapClaims) bool {
+	data, ok := claims["data"].(map[string]interface{})
+	if !ok {
+		return true
+	}
+
+	userID, _ := data["id"].(string)
+	version, _ := claims["tv"].(float64)
+	current, err := CurrentTokenVersion(app, userID)
+	if err != nil {
+		return false
+	}
+	return int64(version) >= current
+}
@@ -0,0 +1,54 @@
+package utils_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/HasanNugroho/starter-golang/config"
+	"github.com/HasanNugroho/starter-golang/internal/app"
+	"github.com/HasanNugroho/starter-golang/internal/shared/utils"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCurrentTokenVersion_RedisDisabled(t *testing.T) {
+	version, err := utils.CurrentTokenVersion(&app.Apps{Config: &config.Config{}}, "user")
+	assert.NoError(t, err)
+	assert.Zero(t, version)
+}
+
+func TestTokenVersion_FailsClosed(t *testing.T) {
+	issuer := &app.Apps{Config: &config.Config{}}
+	issuer.Config.Security.JWTSecretKey = "secret"
+	token, _, err := utils.GenerateExchangedToken(issuer, map[string]interface{}{"id": "user"}, "api", nil, nil, "", nil)
+	require.NoError(t, err)
+
+	// Nothing listens there, every Redis call fails
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	t.Cleanup(func() { client.Close() })
+	apps := &app.Apps{Config: &config.Config{}, Redis: client}
+	apps.Config.Security.JWTSecretKey = "secret"
+	apps.Config.Redis.Enabled = true
+
+	_, err = utils.CurrentTokenVersion(apps, "user")
+	assert.Error(t, err)
+
+	_, err = utils.ValidateToken(apps, token)
+	assert.Error(t, err)
+}
+
+func TestGenerateExchangedToken_NotAfter(t *testing.T) {
+	apps := &app.Apps{Config: &config.Config{}}
+	apps.Config.Security.JWTSecretKey = "secret"
+
+	// The token ends with the role assignment granting its permissions
+	notAfter := time.Now().Add(time.Minute)
+	_, expiration, err := utils.GenerateExchangedToken(apps, map[string]interface{}{"id": "user"}, "api", nil, nil, "", &notAfter)
+	require.NoError(t, err)
+	assert.LessOrEqual(t, expiration, time.Minute)
+
+	_, expiration, err = utils.GenerateExchangedToken(apps, map[string]interface{}{"id": "user"}, "api", nil, nil, "", nil)
+	require.NoError(t, err)
+	assert.Equal(t, 5*time.Minute, expiration)
+}